@@ -1,18 +1,35 @@
 package main
 
-// For production, you should add features such as timeouts, circuit breakers (e.g. goresilience), retries, logging, authentication, and metrics.
+// For production, you should add features such as timeouts, logging, authentication, and metrics.
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"os"
 	"time"
 
+	config "github.com/app/shared/go/config"
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
 	middleware "github.com/app/shared/go/middleware"
+	db "github.com/app/shared/go/utils/db"
+	health "github.com/app/shared/go/utils/health"
+	jwt "github.com/app/shared/go/utils/jwt"
 	logger "github.com/app/shared/go/utils/logger"
+	metrics "github.com/app/shared/go/utils/metrics"
+	server "github.com/app/shared/go/utils/server"
+	session "github.com/app/shared/go/utils/session"
+	tracing "github.com/app/shared/go/utils/tracing"
 )
 
 const listenAddr = ":8080"
 
+// configPollInterval is how often config files are checked for changes
+const configPollInterval = 5 * time.Second
+
+// minFreeDiskBytes is the free disk space below which the health report is degraded
+const minFreeDiskBytes = 100 << 20
+
 // ==========================================
 // MAIN
 // ==========================================
@@ -21,57 +38,188 @@ func main() {
 	logger.Init("gateway", os.Getenv("ENVIRONMENT"))
 	logger.Info("Gateway starting")
 
+	// Load and validate configuration
+	routeConfig, err := config.LoadRouteConfig()
+	if err != nil {
+		logger.FatalWithFields("Failed to load route configuration", err, nil)
+	}
+	securityConfig, err := config.LoadSecurityConfig()
+	if err != nil {
+		logger.FatalWithFields("Failed to load security configuration", err, nil)
+	}
+	authConfig, err := config.LoadAuthConfig()
+	if err != nil {
+		logger.FatalWithFields("Failed to load auth configuration", err, nil)
+	}
+
+	// Server with graceful shutdown (SIGTERM/SIGINT)
+	srv := server.New(server.Options{
+		Name:    "gateway",
+		Version: "1.0.0",
+		Addr:    listenAddr,
+	})
+
+	// Tracing (exports to OTEL_EXPORTER_OTLP_ENDPOINT if set), flushed after the server stopped
+	tracer := tracing.InitFromEnv("gateway")
+	srv.OnShutdown("tracing", tracer.Shutdown)
+
+	// Health checks (/livez, /readyz, /startupz, /health)
+	checks := health.NewRegistry("gateway", 0)
+	checks.Register(health.Check{Name: "disk", Check: health.DiskSpaceCheck("/", minFreeDiskBytes)})
+
+	// Background workers (config watcher, health checks) stop on shutdown
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	srv.OnShutdown("background workers", func(context.Context) error {
+		stopWorkers()
+		return nil
+	})
+
+	// Session store of the auth cookie (written by service-a), without DATABASE_URL the cookie is verified as JWT
+	var sessionStore session.Store
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		dbConfig, err := db.PostgresConfigFromURL(databaseURL)
+		if err != nil {
+			logger.FatalWithFields("Invalid DATABASE_URL", err, nil)
+		}
+		pool, err := db.NewPostgresDB(dbConfig)
+		if err != nil {
+			logger.FatalWithFields("Failed to connect to database", err, nil)
+		}
+		srv.CloseOnShutdown("postgres", pool)
+		metrics.RegisterDBStats("postgres", pool)
+		// Not critical: bearer tokens keep working without the database
+		checks.Register(health.Check{Name: "postgres", Check: db.PingCheck(pool)})
+		sessionStore = session.NewPostgresStore(pool)
+	}
+
+	// Build router + complete middleware stack
+	initialHandler, stopHealthChecks, err := buildHandler(ctx, srv, checks, routeConfig, securityConfig, authConfig, sessionStore)
+	if err != nil {
+		logger.FatalWithFields("Failed to build gateway handler", err, nil)
+	}
+	handler := middleware.NewReloadableHandler(initialHandler)
+
+	// Hot reload: rebuild and swap the handler when routeConfig.json / securityConfig.json / authConfig.json / IP lists change (or on SIGHUP)
+	// List files added to securityConfig.json later are watched after a restart (SIGHUP reloads them anyway)
+	watchedFiles := append([]string{config.RouteConfigFile, config.SecurityConfigFile, config.AuthConfigFile}, config.IPListFiles(securityConfig)...)
+	go config.WatchConfigFiles(ctx, configPollInterval, func() error {
+		routeConfig, err := config.ReadRouteConfig()
+		if err != nil {
+			return err
+		}
+		securityConfig, err := config.ReadSecurityConfig()
+		if err != nil {
+			return err
+		}
+		authConfig, err := config.ReadAuthConfig()
+		if err != nil {
+			return err
+		}
+		next, stopNextHealthChecks, err := buildHandler(ctx, srv, checks, routeConfig, securityConfig, authConfig, sessionStore)
+		if err != nil {
+			return err
+		}
+		handler.Swap(next)
+
+		// The old upstream pools are no longer in rotation
+		stopHealthChecks()
+		stopHealthChecks = stopNextHealthChecks
+		return nil
+	}, watchedFiles...)
+
+	// Start server (blocks until shutdown)
+	checks.MarkStarted()
+	logger.Info("Gateway listening on " + listenAddr)
+	if err := srv.Run(context.Background(), handler); err != nil {
+		logger.FatalWithFields(
+			"Gateway stopped with error",
+			err,
+			map[string]interface{}{
+				"address": listenAddr,
+			},
+		)
+	}
+}
+
+// buildHandler creates the router for the given configuration and wraps it with the middleware stack
+// The returned function stops the upstream health checks of the router (they also stop with ctx)
+func buildHandler(ctx context.Context, srv *server.Runner, checks *health.Registry, routeConfig interfaceconfig.IRouteConfig, securityConfig interfaceconfig.ISecurityConfig, authConfig interfaceconfig.IAuthConfig, sessionStore session.Store) (http.Handler, context.CancelFunc, error) {
 	// Create router
 	mux := http.NewServeMux()
 
 	// Root route - Gateway info
-	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+	endpoints := map[string]string{"health": "/api/health", "liveness": health.LivezPath, "readiness": health.ReadyzPath, "metrics": "/metrics", "jwks": jwt.JWKSPath}
+	for _, route := range routeConfig.Backend.Routes {
+		endpoints[route.Name] = route.Prefix + "*"
+	}
+	info, err := json.Marshal(map[string]interface{}{
+		"service":   "gateway",
+		"version":   "1.0.0",
+		"status":    "running",
+		"endpoints": endpoints,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Rate limit buckets of the routes and the gateway's own endpoints
+	store, err := middleware.NewRateLimitStore(securityConfig.RateLimit.Store)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The gateway's own endpoints get the default per-IP limit and timeout of a route
+	protect := middleware.GatewayEndpointMiddleware(store, securityConfig)
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, protect(handler))
+	}
+
+	handle("/api/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/api/" {
 			http.NotFound(w, r)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{
-			"service": "gateway",
-			"version": "1.0.0",
-			"status": "running",
-			"endpoints": {
-				"health": "/health",
-				"service-a": "/service-a/*",
-				"service-b": "/service-b/*"
-			}
-		}`))
+		w.Write(info)
 	})
 
-	// Register service routes
-	mux.Handle("/api/service-a/", middleware.NewProxy("http://service-a:8080", "/api/service-a"))
-	mux.Handle("/api/service-b/", middleware.NewProxy("http://service-b:8080", "/api/service-b"))
+	// Register service routes from routeConfig.json
+	proxies, err := middleware.RegisterRoutes(mux, routeConfig.Backend.Routes, securityConfig, authConfig, store, sessionStore)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Health check endpoint
-	mux.HandleFunc("/api/health", middleware.HealthHandler)
+	// JWKS - public signing keys for token verification by other services
+	handle(jwt.JWKSPath, jwt.JWKSHandler(middleware.JWTPublicKeys(authConfig)))
 
-	// Build complete middleware stack
-	handler := middleware.BuildMiddlewareStack(mux)
-
-	// Configure server with timeouts
-	srv := &http.Server{
-		Addr:         ":8080",
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second, // Time to read request
-		WriteTimeout: 15 * time.Second, // Time to write response
-		IdleTimeout:  60 * time.Second, // Keep-alive timeout
+	// Probe upstream health endpoints
+	healthCtx, stopHealthChecks := context.WithCancel(ctx)
+	for _, proxy := range proxies {
+		proxy.StartHealthChecks(healthCtx)
 	}
 
-	// Start server
-	logger.Info("Gateway listening on :8080")
-	if err := srv.ListenAndServe(); err != nil {
-		logger.FatalWithFields(
-			"Failed to start HTTP server",
-			err,
-			map[string]interface{}{
-				"address": srv.Addr,
-			},
-		)
+	// Health check endpoint (incl. per-upstream status), 503 while shutting down
+	handle("/api/health", srv.ReadinessHandler(middleware.UpstreamHealthHandler(proxies)))
+
+	// Probes - a route without healthy upstreams degrades the gateway, but keeps it ready
+	checks.Register(health.Check{Name: "upstreams", Check: middleware.UpstreamsCheck(proxies)})
+	handle(health.LivezPath, checks.LivezHandler())
+	handle(health.ReadyzPath, srv.ReadinessHandler(checks.ReadyzHandler()))
+	handle(health.StartupzPath, checks.StartupzHandler())
+	handle(health.HealthPath, checks.ReportHandler())
+
+	// Prometheus metrics, protected with a bearer token if METRICS_TOKEN is set
+	// The gateway faces clients, in production /metrics is only served with a token
+	if metricsToken := os.Getenv("METRICS_TOKEN"); metricsToken != "" || os.Getenv("ENVIRONMENT") != "production" {
+		handle("/metrics", metrics.Handler(metricsToken))
+	}
+
+	// Admin: list (GET) and lift (DELETE ?ip=) temporary IP blocks, only with ADMIN_TOKEN set
+	if adminToken := os.Getenv("ADMIN_TOKEN"); adminToken != "" {
+		handle("/admin/ip-blocks", middleware.IPBlockAdminHandler(adminToken))
 	}
+
+	// Build complete middleware stack
+	return middleware.BuildMiddlewareStack(mux, securityConfig), stopHealthChecks, nil
 }
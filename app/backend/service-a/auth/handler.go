@@ -0,0 +1,454 @@
+// app/backend/service-a/auth/handler.go
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	config "github.com/app/shared/go/config"
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	middleware "github.com/app/shared/go/middleware"
+	jwt "github.com/app/shared/go/utils/jwt"
+	logger "github.com/app/shared/go/utils/logger"
+	mail "github.com/app/shared/go/utils/mail"
+	security "github.com/app/shared/go/utils/security"
+	session "github.com/app/shared/go/utils/session"
+)
+
+// ==========================================
+// AUTH ENDPOINTS
+// ==========================================
+// Served behind the gateway (route prefix stripped), which verifies the JWT or the session cookie and forwards
+// the user ID as X-User-ID. Login issues a server-side session (auth cookie) and a JWT bound to it (response body).
+// Login and registration answers do not reveal whether an email address is registered:
+// unknown addresses and wrong passwords get the same 401, duplicate registrations the same 202
+
+// Paths of the endpoints
+const (
+	RegisterPath = "/register"
+	LoginPath    = "/login"
+	LogoutPath   = "/logout"
+	MePath       = "/me"
+	SessionsPath = "/sessions"
+
+	VerifyEmailRequestPath   = "/verify-email/request"
+	VerifyEmailConfirmPath   = "/verify-email/confirm"
+	PasswordResetRequestPath = "/password-reset/request"
+	PasswordResetConfirmPath = "/password-reset/confirm"
+)
+
+// Error codes (JSON envelope of middleware.WriteJSONError)
+const (
+	ErrorCodeValidation         = "VALIDATION_FAILED"
+	ErrorCodeInvalidCredentials = "INVALID_CREDENTIALS"
+	ErrorCodeUsernameTaken      = "USERNAME_TAKEN"
+	ErrorCodeBusy               = "SERVICE_BUSY"
+	ErrorCodeInvalidToken       = "INVALID_TOKEN"
+)
+
+// maxBodyBytes limits the JSON body of the endpoints
+const maxBodyBytes = 16 << 10
+
+// maxPendingMails limits the mails sent in the background at the same time, further mails are dropped
+const maxPendingMails = 64
+
+// registrationMessage is the answer to every accepted registration, new or duplicate email
+const registrationMessage = "Registration received"
+
+// HandlerOptions configures a Handler
+// The config is expected to be validated with config.ValidateAuthConfig and config.Regex to be loaded beforehand
+type HandlerOptions struct {
+	Users    *UserStore
+	Tokens   *TokenStore        // Verification and reset tokens
+	Keys     *jwt.KeyManager    // Signs the issued JWTs
+	Hasher   *security.HashPool // Hashes and verifies the passwords
+	Mailer   mail.Sender        // Sends verification and reset links
+	Sessions *session.Manager   // Auth cookie sessions, revoked on password change
+	Config   interfaceconfig.IAuthConfig
+	BaseURL  string // Frontend URL the links in mails point to, e.g. "https://example.com"
+}
+
+// Handler serves the auth endpoints
+type Handler struct {
+	users     *UserStore
+	tokens    *TokenStore
+	keys      *jwt.KeyManager
+	hasher    *security.HashPool
+	mailer    mail.Sender
+	sessions  *session.Manager
+	cfg       interfaceconfig.IAuthConfig
+	dummyHash string // Verified for unknown emails, so they take as long as wrong passwords
+	baseURL   string
+
+	mailSlots chan struct{}  // Bounds the mails sent in the background
+	mails     sync.WaitGroup // Mails in flight, awaited by Close
+}
+
+// NewHandler creates the auth endpoints
+func NewHandler(opts HandlerOptions) (*Handler, error) {
+	dummyHash, err := opts.Hasher.Hash(context.Background(), security.GenerateID())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Handler{
+		users:     opts.Users,
+		tokens:    opts.Tokens,
+		keys:      opts.Keys,
+		hasher:    opts.Hasher,
+		mailer:    opts.Mailer,
+		sessions:  opts.Sessions,
+		cfg:       opts.Config,
+		dummyHash: dummyHash,
+		baseURL:   strings.TrimRight(opts.BaseURL, "/"),
+		mailSlots: make(chan struct{}, maxPendingMails),
+	}, nil
+}
+
+// Routes registers the endpoints on mux
+func (h *Handler) Routes(mux *http.ServeMux) {
+	mux.HandleFunc("POST "+RegisterPath, h.register)
+	mux.HandleFunc("POST "+LoginPath, h.login)
+	mux.HandleFunc("POST "+LogoutPath, h.logout)
+	mux.HandleFunc("GET "+MePath, h.me)
+	mux.HandleFunc("GET "+SessionsPath, h.listSessions)
+	mux.HandleFunc("DELETE "+SessionsPath, h.revokeAllSessions)
+	mux.HandleFunc("DELETE "+SessionsPath+"/{id}", h.revokeSession)
+	mux.HandleFunc("POST "+VerifyEmailRequestPath, h.requestEmailVerification)
+	mux.HandleFunc("POST "+VerifyEmailConfirmPath, h.confirmEmailVerification)
+	mux.HandleFunc("POST "+PasswordResetRequestPath, h.requestPasswordReset)
+	mux.HandleFunc("POST "+PasswordResetConfirmPath, h.confirmPasswordReset)
+}
+
+// Close waits until the mails sent in the background are done or ctx ends
+func (h *Handler) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.mails.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ==========================================
+// REGISTER
+// ==========================================
+
+type registerRequest struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// register creates an account
+// 202 for new and already registered emails alike, 400 for invalid input, 409 for taken usernames
+func (h *Handler) register(w http.ResponseWriter, r *http.Request) {
+	var req registerRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	email := normalizeEmail(req.Email)
+	username := strings.TrimSpace(req.Username)
+	if err := validateEmail(h.cfg, email); err != nil {
+		middleware.WriteJSONError(w, r, http.StatusBadRequest, ErrorCodeValidation, err.Error(), "")
+		return
+	}
+	if err := validateUsername(username); err != nil {
+		middleware.WriteJSONError(w, r, http.StatusBadRequest, ErrorCodeValidation, err.Error(), "")
+		return
+	}
+	if err := validatePassword(h.cfg, req.Password); err != nil {
+		logger.LogAuthEvent(logger.EventWeakPasswordDetected, "", middleware.GetRequestID(r), middleware.GetClientIPFromContext(r), map[string]interface{}{
+			"path": r.URL.Path,
+		})
+		middleware.WriteJSONError(w, r, http.StatusBadRequest, ErrorCodeValidation, err.Error(), "")
+		return
+	}
+
+	// Hashed before the insert, so duplicate emails take as long as new ones
+	hash, err := h.hasher.Hash(r.Context(), req.Password)
+	if err != nil {
+		h.hashingError(w, r, "Hashing password failed", err)
+		return
+	}
+
+	user, err := h.users.Create(r.Context(), email, username, hash)
+	switch {
+	case errors.Is(err, ErrEmailTaken):
+		logger.WarnWithFields("Registration with already registered email", map[string]interface{}{
+			"request_id": middleware.GetRequestID(r),
+			"ip":         middleware.GetClientIPFromContext(r),
+		})
+		// The owner of the address learns about the attempt, the client does not
+		h.sendInBackground(r, func(ctx context.Context) (mail.Message, bool) {
+			return accountExistsMail(email, h.baseURL), true
+		})
+	case errors.Is(err, ErrUsernameTaken):
+		middleware.WriteJSONError(w, r, http.StatusConflict, ErrorCodeUsernameTaken, "Username is not available", "")
+		return
+	case err != nil:
+		h.internalError(w, r, "Creating user failed", err)
+		return
+	default:
+		logger.InfoWithFields("User registered", map[string]interface{}{
+			"user_id":    user.ID,
+			"request_id": middleware.GetRequestID(r),
+			"ip":         middleware.GetClientIPFromContext(r),
+		})
+		h.sendInBackground(r, func(ctx context.Context) (mail.Message, bool) {
+			return h.verificationMail(ctx, user)
+		})
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"message": registrationMessage})
+}
+
+// ==========================================
+// LOGIN / LOGOUT
+// ==========================================
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"` // Seconds
+	User        User   `json:"user"`
+}
+
+// login verifies the credentials, issues a JWT (response body) and starts a session (auth cookie)
+// A session cookie sent with the request is replaced (session ID rotation)
+// Every failure answers 401 INVALID_CREDENTIALS
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	email := normalizeEmail(req.Email)
+	if validateEmail(h.cfg, email) != nil || !passwordLengthOK(h.cfg, req.Password) {
+		h.loginFailed(w, r, "", "invalid_input")
+		return
+	}
+
+	user, err := h.users.ByEmail(r.Context(), email)
+	if errors.Is(err, ErrUserNotFound) {
+		if _, err := h.hasher.Verify(r.Context(), req.Password, h.dummyHash); err != nil {
+			h.hashingError(w, r, "Verifying password failed", err)
+			return
+		}
+		h.loginFailed(w, r, "", "unknown_email")
+		return
+	}
+	if err != nil {
+		h.internalError(w, r, "Loading user failed", err)
+		return
+	}
+
+	ok, needsRehash, err := h.hasher.VerifyAndUpgrade(r.Context(), req.Password, user.PasswordHash)
+	if err != nil {
+		h.hashingError(w, r, "Verifying password failed", err)
+		return
+	}
+	if !ok {
+		h.loginFailed(w, r, user.ID, "wrong_password")
+		return
+	}
+	if needsRehash {
+		h.upgradePasswordHash(r, user.ID, req.Password)
+	}
+
+	// The token is bound to the session ("sid"), revoking the session revokes the token
+	// Users have no roles yet, session and token carry the same ones
+	s, err := h.sessions.Start(w, r, user.ID, nil)
+	if err != nil {
+		h.internalError(w, r, "Starting session failed", err)
+		return
+	}
+	token, err := h.issueToken(user.ID, s.ID, s.Roles)
+	if err != nil {
+		h.sessions.Revoke(r.Context(), user.ID, s.ID)
+		h.sessions.ClearCookie(w)
+		h.internalError(w, r, "Issuing token failed", err)
+		return
+	}
+
+	logger.LogAuthEvent(logger.EventAuthSuccess, user.ID, middleware.GetRequestID(r), middleware.GetClientIPFromContext(r), map[string]interface{}{
+		"path": r.URL.Path,
+	})
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, loginResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   h.cfg.Cookie.AuthCookieJWTLifetimeSeconds,
+		User:        user,
+	})
+}
+
+// loginFailed logs the reason and answers the uniform 401
+func (h *Handler) loginFailed(w http.ResponseWriter, r *http.Request, userID, reason string) {
+	logger.LogAuthEvent(logger.EventAuthFailed, userID, middleware.GetRequestID(r), middleware.GetClientIPFromContext(r), map[string]interface{}{
+		"path":   r.URL.Path,
+		"reason": reason,
+	})
+	middleware.WriteJSONError(w, r, http.StatusUnauthorized, ErrorCodeInvalidCredentials, "Invalid email or password", "")
+}
+
+// upgradePasswordHash replaces a legacy or weaker hash with one of the current Argon2id parameters
+// Failures are only logged, the login succeeds anyway and the upgrade is retried on the next one
+func (h *Handler) upgradePasswordHash(r *http.Request, userID, password string) {
+	hash, err := h.hasher.Hash(r.Context(), password)
+	if err == nil {
+		err = h.users.UpdatePasswordHash(r.Context(), userID, hash)
+	}
+	if err != nil {
+		logger.ErrorWithFields("Upgrading password hash failed", err, map[string]interface{}{
+			"user_id":    userID,
+			"request_id": middleware.GetRequestID(r),
+		})
+		return
+	}
+	logger.InfoWithFields("Password hash upgraded", map[string]interface{}{
+		"user_id":    userID,
+		"request_id": middleware.GetRequestID(r),
+	})
+}
+
+// logout ends the session of the auth cookie and the session of the bearer token (X-Session-ID from the gateway)
+// and removes the cookie; the gateway rejects JWTs of ended sessions (see the "sid" claim)
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.sessions.End(w, r); err != nil {
+		h.internalError(w, r, "Ending session failed", err)
+		return
+	}
+
+	userID := r.Header.Get(middleware.HeaderUserID)
+	if id := r.Header.Get(middleware.HeaderSessionID); userID != "" && id != "" {
+		// Already ended with the cookie or earlier
+		if err := h.sessions.Revoke(r.Context(), userID, id); err != nil && !errors.Is(err, session.ErrNotFound) {
+			h.internalError(w, r, "Ending session failed", err)
+			return
+		}
+	}
+
+	if userID != "" {
+		logger.InfoWithFields("User logged out", map[string]interface{}{
+			"user_id":    userID,
+			"request_id": middleware.GetRequestID(r),
+		})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// issueToken signs a token for the user, its roles and session with the current signing key
+func (h *Handler) issueToken(userID, sessionID string, roles []string) (string, error) {
+	key, err := h.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return jwt.Sign(jwt.Claims{
+		Subject:   userID,
+		Roles:     roles,
+		Issuer:    h.cfg.JWT.Issuer,
+		Audience:  h.cfg.JWT.Audience,
+		ExpiresAt: now.Add(time.Duration(h.cfg.Cookie.AuthCookieJWTLifetimeSeconds) * time.Second).Unix(),
+		NotBefore: now.Unix(),
+		IssuedAt:  now.Unix(),
+		ID:        security.GenerateID(),
+		SessionID: sessionID,
+	}, key)
+}
+
+// ==========================================
+// ME
+// ==========================================
+
+// me returns the user of the verified token or session (X-User-ID from the gateway)
+func (h *Handler) me(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.ByID(r.Context(), userID)
+	if errors.Is(err, ErrUserNotFound) {
+		// Valid token of a deleted account
+		middleware.WriteJSONError(w, r, http.StatusUnauthorized, middleware.ErrorCodeUnauthorized, "Unauthorized", "")
+		return
+	}
+	if err != nil {
+		h.internalError(w, r, "Loading user failed", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// ==========================================
+// HELPERS
+// ==========================================
+
+// authenticatedUser returns the user ID forwarded by the gateway, a missing or malformed ID is answered with 401
+func authenticatedUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID := r.Header.Get(middleware.HeaderUserID)
+	if userID == "" || (config.Regex.UUID != nil && !config.Regex.UUID.MatchString(userID)) {
+		middleware.WriteJSONError(w, r, http.StatusUnauthorized, middleware.ErrorCodeUnauthorized, "Unauthorized", "")
+		return "", false
+	}
+	return userID, true
+}
+
+// internalError logs err and answers 500 without details
+func (h *Handler) internalError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	logger.ErrorWithFields(msg, err, map[string]interface{}{
+		"request_id": middleware.GetRequestID(r),
+		"path":       r.URL.Path,
+	})
+	middleware.WriteJSONError(w, r, http.StatusInternalServerError, middleware.ErrorCodeInternal, "Internal server error", "")
+}
+
+// hashingError answers 503 if the hashing pool is busy (or the client gave up waiting), 500 otherwise
+func (h *Handler) hashingError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	if errors.Is(err, security.ErrHashPoolBusy) || r.Context().Err() != nil {
+		w.Header().Set("Retry-After", "1")
+		middleware.WriteJSONError(w, r, http.StatusServiceUnavailable, ErrorCodeBusy, "Service busy, please retry", "")
+		return
+	}
+	h.internalError(w, r, msg, err)
+}
+
+// decodeJSON reads the JSON body into v, invalid or oversized bodies are answered with 400
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		middleware.WriteJSONError(w, r, http.StatusBadRequest, middleware.ErrorCodeInvalidBody, "Invalid request body", "")
+		return false
+	}
+	return true
+}
+
+// writeJSON writes v as JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
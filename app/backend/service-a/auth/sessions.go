@@ -0,0 +1,94 @@
+// app/backend/service-a/auth/sessions.go
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	config "github.com/app/shared/go/config"
+	middleware "github.com/app/shared/go/middleware"
+	logger "github.com/app/shared/go/utils/logger"
+	session "github.com/app/shared/go/utils/session"
+)
+
+// ==========================================
+// SESSIONS
+// ==========================================
+// Users list and end their own sessions (devices), the user ID comes from the gateway (X-User-ID)
+
+type sessionResponse struct {
+	session.Session
+	Current bool `json:"current"` // Session of the request's auth cookie
+}
+
+// listSessions returns the active sessions of the user, newest first
+func (h *Handler) listSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessions.List(r.Context(), userID)
+	if err != nil {
+		h.internalError(w, r, "Listing sessions failed", err)
+		return
+	}
+
+	// Bearer requests have no current session
+	current, _ := h.sessions.Load(r)
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		response = append(response, sessionResponse{Session: s, Current: s.ID == current.ID})
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": response})
+}
+
+// revokeSession ends one session of the user
+// 204 on success, 404 if the user has no session with the ID
+func (h *Handler) revokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	if config.Regex.UUID != nil && !config.Regex.UUID.MatchString(id) {
+		middleware.WriteJSONError(w, r, http.StatusNotFound, middleware.ErrorCodeNotFound, "Session not found", "")
+		return
+	}
+
+	err := h.sessions.Revoke(r.Context(), userID, id)
+	if errors.Is(err, session.ErrNotFound) {
+		middleware.WriteJSONError(w, r, http.StatusNotFound, middleware.ErrorCodeNotFound, "Session not found", "")
+		return
+	}
+	if err != nil {
+		h.internalError(w, r, "Revoking session failed", err)
+		return
+	}
+
+	logger.InfoWithFields("Session revoked", map[string]interface{}{
+		"user_id":    userID,
+		"session_id": id,
+		"request_id": middleware.GetRequestID(r),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAllSessions ends every session of the user (log out everywhere) and removes the auth cookie
+func (h *Handler) revokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticatedUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.sessions.RevokeUserSessions(r.Context(), userID); err != nil {
+		h.internalError(w, r, "Revoking sessions failed", err)
+		return
+	}
+	h.sessions.ClearCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
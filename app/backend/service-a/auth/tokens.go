@@ -0,0 +1,133 @@
+// app/backend/service-a/auth/tokens.go
+
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	security "github.com/app/shared/go/utils/security"
+)
+
+// ==========================================
+// TOKEN STORE
+// ==========================================
+// Single-use tokens for email verification and password reset (db/migrations/002_create_auth_tokens_table.up.sql)
+// Only the SHA-256 of a token is stored, a leaked table cannot be used to confirm or reset anything
+
+// Token purposes
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+// tokenBytes is the entropy of a token (256 bit)
+const tokenBytes = 32
+
+// ErrInvalidToken is returned for unknown, expired and already used tokens alike
+var ErrInvalidToken = errors.New("auth: invalid or expired token")
+
+// TokenStore reads and writes the auth_tokens table
+type TokenStore struct {
+	db *sql.DB
+}
+
+// NewTokenStore creates a store on the connection pool
+func NewTokenStore(db *sql.DB) *TokenStore {
+	return &TokenStore{db: db}
+}
+
+// Create issues a token for the user, valid for ttl
+// Earlier tokens of the user with the same purpose are removed, only the newest link works
+func (s *TokenStore) Create(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	token, err := security.GenerateToken(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM auth_tokens WHERE user_id = $1 AND purpose = $2`, userID, purpose); err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO auth_tokens (token_hash, user_id, purpose, expires_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4))`,
+		security.HashSHA256(token), userID, purpose, ttl.Seconds(),
+	); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Check returns ErrInvalidToken if the token is unknown, expired or used, without using it
+// Lets handlers reject bad tokens before expensive work, Consume checks again
+func (s *TokenStore) Check(ctx context.Context, token, purpose string) error {
+	var valid bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM auth_tokens
+			WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		)`,
+		security.HashSHA256(token), purpose,
+	).Scan(&valid)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// Consume marks a valid token as used and runs apply with its user ID in the same transaction
+// Returns ErrInvalidToken if the token is unknown, expired or used; if apply fails the token stays valid
+func (s *TokenStore) Consume(ctx context.Context, token, purpose string, apply func(tx *sql.Tx, userID string) error) (string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE auth_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id`,
+		security.HashSHA256(token), purpose,
+	).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := apply(tx, userID); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// DeleteExpired removes expired and used tokens, returns the number of removed tokens
+func (s *TokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM auth_tokens WHERE expires_at <= CURRENT_TIMESTAMP OR used_at IS NOT NULL`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,143 @@
+// app/backend/service-a/auth/users.go
+
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ==========================================
+// USER STORE
+// ==========================================
+
+// Errors of UserStore
+var (
+	ErrEmailTaken    = errors.New("auth: email already registered")
+	ErrUsernameTaken = errors.New("auth: username already taken")
+	ErrUserNotFound  = errors.New("auth: user not found")
+)
+
+// pgUniqueViolation is the SQLSTATE of unique constraint violations
+const pgUniqueViolation = "23505"
+
+// User is a row of the users table
+type User struct {
+	ID              string     `json:"id"`
+	Email           string     `json:"email"`
+	Username        string     `json:"username"`
+	PasswordHash    string     `json:"-"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"` // nil until the email address is confirmed
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// dbtx is implemented by *sql.DB and *sql.Tx
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// UserStore reads and writes the users table (db/migrations/001_create_users_table.up.sql)
+type UserStore struct {
+	db dbtx
+}
+
+// NewUserStore creates a store on the connection pool
+func NewUserStore(db *sql.DB) *UserStore {
+	return &UserStore{db: db}
+}
+
+// WithTx returns a store running its queries in tx
+func (s *UserStore) WithTx(tx *sql.Tx) *UserStore {
+	return &UserStore{db: tx}
+}
+
+// Create inserts a user, email is expected to be normalized
+// Returns ErrEmailTaken or ErrUsernameTaken if the address or name is already in use
+func (s *UserStore) Create(ctx context.Context, email, username, passwordHash string) (User, error) {
+	user := User{Email: email, Username: username, PasswordHash: passwordHash}
+
+	// Email conflicts insert nothing, username conflicts fail with a unique violation
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO users (email, username, password_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (email) DO NOTHING
+		RETURNING id, created_at`,
+		email, username, passwordHash,
+	).Scan(&user.ID, &user.CreatedAt)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return User{}, ErrEmailTaken
+	case isUniqueViolation(err):
+		return User{}, ErrUsernameTaken
+	case err != nil:
+		return User{}, err
+	}
+	return user, nil
+}
+
+// ByEmail returns the user with the (normalized) email address
+func (s *UserStore) ByEmail(ctx context.Context, email string) (User, error) {
+	return s.queryUser(ctx, `
+		SELECT id, email, username, password_hash, email_verified_at, created_at
+		FROM users WHERE email = $1`, email)
+}
+
+// ByID returns the user with the ID
+func (s *UserStore) ByID(ctx context.Context, id string) (User, error) {
+	return s.queryUser(ctx, `
+		SELECT id, email, username, password_hash, email_verified_at, created_at
+		FROM users WHERE id = $1`, id)
+}
+
+// UpdatePasswordHash replaces the password hash of a user
+func (s *UserStore) UpdatePasswordHash(ctx context.Context, id, passwordHash string) error {
+	return s.exec(ctx, `UPDATE users SET password_hash = $2 WHERE id = $1`, id, passwordHash)
+}
+
+// MarkEmailVerified sets email_verified_at if not set yet
+func (s *UserStore) MarkEmailVerified(ctx context.Context, id string) error {
+	return s.exec(ctx, `
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+		WHERE id = $1`, id)
+}
+
+// exec runs an update of a single user, no affected row becomes ErrUserNotFound
+func (s *UserStore) exec(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// queryUser scans a single user, sql.ErrNoRows becomes ErrUserNotFound
+func (s *UserStore) queryUser(ctx context.Context, query string, arg string) (User, error) {
+	var user User
+	var verifiedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, arg).Scan(
+		&user.ID, &user.Email, &user.Username, &user.PasswordHash, &verifiedAt, &user.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+	return user, nil
+}
+
+// isUniqueViolation reports whether err is a Postgres unique violation (pgconn.PgError)
+func isUniqueViolation(err error) bool {
+	var pgErr interface{ SQLState() string }
+	return errors.As(err, &pgErr) && pgErr.SQLState() == pgUniqueViolation
+}
@@ -0,0 +1,76 @@
+// app/backend/service-a/auth/validation.go
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	config "github.com/app/shared/go/config"
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+)
+
+// ==========================================
+// INPUT VALIDATION
+// ==========================================
+// Limits come from authConfig.json, patterns from regexConfig.json (config.Regex)
+
+// Validation errors (messages are returned to the client)
+var (
+	errInvalidEmail    = errors.New("invalid email address")
+	errInvalidUsername = errors.New("username must be 3-100 characters: letters, digits, '.', '_' or '-'")
+	errInvalidPassword = errors.New("password contains invalid characters")
+)
+
+// maxLengthEmailColumn is the size of users.email
+const maxLengthEmailColumn = 255
+
+// normalizeEmail trims and lowercases an email address, so lookups are case-insensitive
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// validateEmail checks a normalized email address against maxLengthEmailAddress and the Email pattern
+func validateEmail(cfg interfaceconfig.IAuthConfig, email string) error {
+	length := utf8.RuneCountInString(email)
+	if email == "" || length > cfg.MaxLengthEmailAddress || length > maxLengthEmailColumn {
+		return errInvalidEmail
+	}
+	if config.Regex.Email != nil && !config.Regex.Email.MatchString(email) {
+		return errInvalidEmail
+	}
+	return nil
+}
+
+// validateUsername checks a username against the Username pattern
+func validateUsername(username string) error {
+	if config.Regex.Username != nil && !config.Regex.Username.MatchString(username) {
+		return errInvalidUsername
+	}
+	return nil
+}
+
+// passwordLengthOK reports whether a password is within minLengthPassword and maxLengthPassword
+// The maximum also bounds the hashing work per request
+func passwordLengthOK(cfg interfaceconfig.IAuthConfig, password string) bool {
+	length := utf8.RuneCountInString(password)
+	return length >= cfg.MinLengthPassword && length <= cfg.MaxLengthPassword
+}
+
+// validatePassword checks a new password against the length limits and the Password pattern
+func validatePassword(cfg interfaceconfig.IAuthConfig, password string) error {
+	if !passwordLengthOK(cfg, password) {
+		return passwordLengthError(cfg)
+	}
+	if config.Regex.Password != nil && !config.Regex.Password.MatchString(password) {
+		return errInvalidPassword
+	}
+	return nil
+}
+
+// passwordLengthError describes the allowed password length
+func passwordLengthError(cfg interfaceconfig.IAuthConfig) error {
+	return fmt.Errorf("password must be between %d and %d characters", cfg.MinLengthPassword, cfg.MaxLengthPassword)
+}
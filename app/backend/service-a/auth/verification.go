@@ -0,0 +1,346 @@
+// app/backend/service-a/auth/verification.go
+
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	middleware "github.com/app/shared/go/middleware"
+	logger "github.com/app/shared/go/utils/logger"
+	mail "github.com/app/shared/go/utils/mail"
+)
+
+// ==========================================
+// EMAIL VERIFICATION / PASSWORD RESET
+// ==========================================
+// The request endpoints always answer 202: looking up the address, creating the token and sending
+// the mail happen in the background, so neither the answer nor its timing reveals registered addresses.
+// Tokens are valid for timeValidVerifyTokenMinutes and can be used once
+
+// Frontend pages the links in mails point to, the token is passed as query parameter
+const (
+	VerifyEmailPage   = "/verify-email"
+	ResetPasswordPage = "/reset-password"
+)
+
+// mailTimeout bounds sending a mail in the background, including the token creation
+const mailTimeout = time.Minute
+
+// requestMessage is the answer to every verification or reset request
+const requestMessage = "If the address is registered, a mail has been sent"
+
+type emailRequest struct {
+	Email string `json:"email"`
+}
+
+type tokenRequest struct {
+	Token string `json:"token"`
+}
+
+type passwordResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// requestEmailVerification sends a new verification link to an unverified address
+func (h *Handler) requestEmailVerification(w http.ResponseWriter, r *http.Request) {
+	email, ok := h.decodeEmailRequest(w, r)
+	if !ok {
+		return
+	}
+
+	h.sendInBackground(r, func(ctx context.Context) (mail.Message, bool) {
+		user, ok := h.lookupRecipient(ctx, email)
+		if !ok || user.EmailVerifiedAt != nil {
+			return mail.Message{}, false
+		}
+		return h.verificationMail(ctx, user)
+	})
+	writeJSON(w, http.StatusAccepted, map[string]string{"message": requestMessage})
+}
+
+// confirmEmailVerification marks the address of the token's user as verified
+// 204 on success, 400 INVALID_TOKEN for unknown, expired or used tokens
+func (h *Handler) confirmEmailVerification(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, err := h.tokens.Consume(r.Context(), req.Token, PurposeVerifyEmail, func(tx *sql.Tx, userID string) error {
+		return h.users.WithTx(tx).MarkEmailVerified(r.Context(), userID)
+	})
+	if errors.Is(err, ErrInvalidToken) {
+		middleware.WriteJSONError(w, r, http.StatusBadRequest, ErrorCodeInvalidToken, "Invalid or expired token", "")
+		return
+	}
+	if err != nil {
+		h.internalError(w, r, "Verifying email failed", err)
+		return
+	}
+
+	logger.InfoWithFields("Email verified", map[string]interface{}{
+		"user_id":    userID,
+		"request_id": middleware.GetRequestID(r),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestPasswordReset sends a reset link to a registered address
+func (h *Handler) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	email, ok := h.decodeEmailRequest(w, r)
+	if !ok {
+		return
+	}
+
+	requestID := middleware.GetRequestID(r)
+	ip := middleware.GetClientIPFromContext(r)
+	h.sendInBackground(r, func(ctx context.Context) (mail.Message, bool) {
+		user, ok := h.lookupRecipient(ctx, email)
+		if !ok {
+			return mail.Message{}, false
+		}
+		token, err := h.tokens.Create(ctx, user.ID, PurposeResetPassword, h.tokenLifetime())
+		if err != nil {
+			logger.ErrorWithFields("Creating reset token failed", err, map[string]interface{}{
+				"user_id":    user.ID,
+				"request_id": requestID,
+			})
+			return mail.Message{}, false
+		}
+
+		logger.LogAuthEvent(logger.EventPasswordResetRequested, user.ID, requestID, ip, nil)
+		return mail.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Hello %s,\n\nuse this link to choose a new password:\n\n%s\n\n"+
+				"The link is valid for %d minutes and can be used once. "+
+				"If you did not request a reset, you can ignore this mail, your password stays unchanged.\n",
+				user.Username, h.link(ResetPasswordPage, token), h.cfg.TimeValidVerifyTokenMinutes),
+		}, true
+	})
+	writeJSON(w, http.StatusAccepted, map[string]string{"message": requestMessage})
+}
+
+// confirmPasswordReset sets a new password, revokes all sessions of the user (and the JWTs bound to them, see
+// the "sid" claim) and removes the auth cookie
+// 204 on success, 400 VALIDATION_FAILED for weak passwords, 400 INVALID_TOKEN for unknown, expired or used tokens
+func (h *Handler) confirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req passwordResetRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	requestID := middleware.GetRequestID(r)
+	ip := middleware.GetClientIPFromContext(r)
+
+	if err := validatePassword(h.cfg, req.Password); err != nil {
+		logger.LogAuthEvent(logger.EventWeakPasswordDetected, "", requestID, ip, map[string]interface{}{
+			"path": r.URL.Path,
+		})
+		middleware.WriteJSONError(w, r, http.StatusBadRequest, ErrorCodeValidation, err.Error(), "")
+		return
+	}
+
+	invalidToken := func() {
+		logger.LogAuthEvent(logger.EventPasswordResetFailed, "", requestID, ip, map[string]interface{}{
+			"reason": "invalid_token",
+		})
+		middleware.WriteJSONError(w, r, http.StatusBadRequest, ErrorCodeInvalidToken, "Invalid or expired token", "")
+	}
+
+	// Checked before hashing, so guessed tokens cost no hashing memory
+	if err := h.tokens.Check(r.Context(), req.Token, PurposeResetPassword); err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			invalidToken()
+		} else {
+			h.internalError(w, r, "Checking reset token failed", err)
+		}
+		return
+	}
+
+	// Hashed before the transaction, so it does not hold a connection while waiting for the hashing pool
+	hash, err := h.hasher.Hash(r.Context(), req.Password)
+	if err != nil {
+		h.hashingError(w, r, "Hashing password failed", err)
+		return
+	}
+
+	// Consume checks the token again, a concurrent request may have used it meanwhile
+	userID, err := h.tokens.Consume(r.Context(), req.Token, PurposeResetPassword, func(tx *sql.Tx, userID string) error {
+		users := h.users.WithTx(tx)
+		if err := users.UpdatePasswordHash(r.Context(), userID, hash); err != nil {
+			return err
+		}
+		// The reset link proved access to the mailbox
+		return users.MarkEmailVerified(r.Context(), userID)
+	})
+	if errors.Is(err, ErrInvalidToken) {
+		invalidToken()
+		return
+	}
+	if err != nil {
+		logger.LogAuthEvent(logger.EventPasswordResetFailed, "", requestID, ip, map[string]interface{}{
+			"reason": "internal_error",
+		})
+		h.internalError(w, r, "Resetting password failed", err)
+		return
+	}
+
+	// The password is changed already, a failed revocation is logged but does not fail the request
+	if err := h.sessions.RevokeUserSessions(r.Context(), userID); err != nil {
+		logger.ErrorWithFields("Revoking sessions after password reset failed", err, map[string]interface{}{
+			"user_id":    userID,
+			"request_id": requestID,
+		})
+	}
+	h.sessions.ClearCookie(w)
+
+	logger.LogAuthEvent(logger.EventPasswordResetCompleted, userID, requestID, ip, nil)
+	h.sendInBackground(r, func(ctx context.Context) (mail.Message, bool) {
+		user, err := h.users.ByID(ctx, userID)
+		if err != nil {
+			logger.ErrorWithFields("Loading mail recipient failed", err, map[string]interface{}{
+				"user_id": userID,
+			})
+			return mail.Message{}, false
+		}
+		return passwordChangedMail(user), true
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ==========================================
+// MAILS
+// ==========================================
+
+// sendInBackground runs build and sends the built mail after the request, build returns false if there is nothing to send
+// At most maxPendingMails run at the same time, further mails are dropped and logged
+func (h *Handler) sendInBackground(r *http.Request, build func(ctx context.Context) (mail.Message, bool)) {
+	requestID := middleware.GetRequestID(r)
+
+	select {
+	case h.mailSlots <- struct{}{}:
+	default:
+		logger.WarnWithFields("Mail dropped, too many mails pending", map[string]interface{}{
+			"request_id": requestID,
+			"path":       r.URL.Path,
+		})
+		return
+	}
+
+	h.mails.Add(1)
+	go func() {
+		defer func() {
+			<-h.mailSlots
+			h.mails.Done()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+
+		msg, ok := build(ctx)
+		if !ok {
+			return
+		}
+
+		start := time.Now()
+		err := h.mailer.Send(ctx, msg)
+		fields := map[string]interface{}{
+			"request_id": requestID,
+			"subject":    msg.Subject,
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+			logger.LogExternalAPICall(logger.EventExternalAPICallFailed, "mail", "send", time.Since(start), 0, fields)
+			return
+		}
+		logger.LogExternalAPICall(logger.EventExternalAPICallSucceeded, "mail", "send", time.Since(start), 0, fields)
+	}()
+}
+
+// verificationMail creates a verification token for user and renders the mail with the link
+func (h *Handler) verificationMail(ctx context.Context, user User) (mail.Message, bool) {
+	token, err := h.tokens.Create(ctx, user.ID, PurposeVerifyEmail, h.tokenLifetime())
+	if err != nil {
+		logger.ErrorWithFields("Creating verification token failed", err, map[string]interface{}{
+			"user_id": user.ID,
+		})
+		return mail.Message{}, false
+	}
+
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nplease confirm your email address with this link:\n\n%s\n\n"+
+			"The link is valid for %d minutes. If you did not create an account, you can ignore this mail.\n",
+			user.Username, h.link(VerifyEmailPage, token), h.cfg.TimeValidVerifyTokenMinutes),
+	}, true
+}
+
+// accountExistsMail tells the owner of an address about a registration with it
+func accountExistsMail(email, baseURL string) mail.Message {
+	return mail.Message{
+		To:      email,
+		Subject: "Registration attempt",
+		Body: fmt.Sprintf("Hello,\n\nsomeone tried to create an account with this email address, but an account already exists.\n\n"+
+			"If this was you, log in or reset your password at %s%s\n"+
+			"If not, you can ignore this mail.\n", baseURL, ResetPasswordPage),
+	}
+}
+
+// passwordChangedMail notifies the user about a password reset
+func passwordChangedMail(user User) mail.Message {
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: fmt.Sprintf("Hello %s,\n\nthe password of your account was reset and all sessions were logged out.\n\n"+
+			"If you did not do this, reset your password again and contact support.\n", user.Username),
+	}
+}
+
+// ==========================================
+// HELPERS
+// ==========================================
+
+// decodeEmailRequest reads and validates the address of a request endpoint
+func (h *Handler) decodeEmailRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req emailRequest
+	if !decodeJSON(w, r, &req) {
+		return "", false
+	}
+
+	email := normalizeEmail(req.Email)
+	if err := validateEmail(h.cfg, email); err != nil {
+		middleware.WriteJSONError(w, r, http.StatusBadRequest, ErrorCodeValidation, err.Error(), "")
+		return "", false
+	}
+	return email, true
+}
+
+// lookupRecipient loads the user of an address, unknown addresses and errors return false
+func (h *Handler) lookupRecipient(ctx context.Context, email string) (User, bool) {
+	user, err := h.users.ByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, ErrUserNotFound) {
+			logger.ErrorWithFields("Loading mail recipient failed", err, nil)
+		}
+		return User{}, false
+	}
+	return user, true
+}
+
+// tokenLifetime is the validity of verification and reset tokens
+func (h *Handler) tokenLifetime() time.Duration {
+	return time.Duration(h.cfg.TimeValidVerifyTokenMinutes) * time.Minute
+}
+
+// link builds the frontend link for a token
+func (h *Handler) link(page, token string) string {
+	return h.baseURL + page + "?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,3 @@
+-- app/backend/service-a/db/migrations/002_create_auth_tokens_table.down.sql
+DROP TABLE IF EXISTS auth_tokens CASCADE;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- app/backend/service-a/db/migrations/002_create_auth_tokens_table.up.sql
+
+-- Set when the user confirmed the email address
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+-- Single-use tokens for email verification and password reset
+-- Only the SHA-256 of a token is stored, the token itself is only sent by mail
+CREATE TABLE IF NOT EXISTS auth_tokens (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for faster lookups
+CREATE INDEX idx_auth_tokens_user_purpose ON auth_tokens(user_id, purpose);
+CREATE INDEX idx_auth_tokens_expires_at ON auth_tokens(expires_at);
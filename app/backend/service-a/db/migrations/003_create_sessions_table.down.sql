@@ -0,0 +1,2 @@
+-- app/backend/service-a/db/migrations/003_create_sessions_table.down.sql
+DROP TABLE IF EXISTS sessions CASCADE;
\ No newline at end of file
@@ -0,0 +1,21 @@
+-- app/backend/service-a/db/migrations/003_create_sessions_table.up.sql
+
+-- Server-side sessions of the auth cookie (shared/go/utils/session)
+-- Only the SHA-256 of the cookie value is stored, id is the public ID for listing and revocation
+-- Times are written by the services, so they are stored with time zone
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    roles TEXT NOT NULL DEFAULT '', -- Comma separated, forwarded as X-User-Roles
+    created_at TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL,
+    idle_expires_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Index for faster lookups
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
\ No newline at end of file
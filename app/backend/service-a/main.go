@@ -1,31 +1,147 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/app/service-a/auth"
 	shared "github.com/app/shared/go"
 	config "github.com/app/shared/go/config"
+	middleware "github.com/app/shared/go/middleware"
+	db "github.com/app/shared/go/utils/db"
+	health "github.com/app/shared/go/utils/health"
+	jwt "github.com/app/shared/go/utils/jwt"
 	logger "github.com/app/shared/go/utils/logger"
+	mail "github.com/app/shared/go/utils/mail"
+	metrics "github.com/app/shared/go/utils/metrics"
+	security "github.com/app/shared/go/utils/security"
+	server "github.com/app/shared/go/utils/server"
+	session "github.com/app/shared/go/utils/session"
+	tracing "github.com/app/shared/go/utils/tracing"
 )
 
+// minFreeDiskBytes is the free disk space below which the health report is degraded
+const minFreeDiskBytes = 100 << 20
+
+// cleanupInterval is the interval of removing expired tokens and sessions
+const cleanupInterval = time.Hour
+
 // ==========================================
 // SERVICE A
 // ==========================================
 func main() {
 	logger.Init("SERVICE-A", os.Getenv("ENVIRONMENT"))
 
+	// Client IPs from the gateway's forwarding headers
+	middleware.TrustProxiesFromEnv()
+
 	// Load Config
 	routeConfig, err := config.LoadRouteConfig()
 	if err != nil {
 		logger.FatalWithFields("Failed to load route configuration", err, nil)
 	}
 	listenAddress := fmt.Sprintf("%s:%s", routeConfig.Backend.ListenHost, routeConfig.Backend.ListenPort)
+	authConfig, err := config.LoadAuthConfig()
+	if err != nil {
+		logger.FatalWithFields("Failed to load auth configuration", err, nil)
+	}
+	regexConfigPath, err := config.RegexConfigPath()
+	if err != nil {
+		logger.FatalWithFields("Failed to resolve regex configuration", err, nil)
+	}
+	config.LoadRegexConfig(regexConfigPath)
+
+	// Server with graceful shutdown (SIGTERM/SIGINT)
+	srv := server.New(server.Options{
+		Name: "service-a",
+		Addr: listenAddress,
+	})
+
+	// Tracing (exports to OTEL_EXPORTER_OTLP_ENDPOINT if set), flushed on shutdown
+	tracer := tracing.InitFromEnv("service-a")
+	srv.OnShutdown("tracing", tracer.Shutdown)
+
+	// Background workers (key rotation) stop on shutdown
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	srv.OnShutdown("background workers", func(context.Context) error {
+		stopWorkers()
+		return nil
+	})
 
 	// Simple Mux - NO middleware needed!
 	mux := http.NewServeMux()
 
+	// Health checks
+	checks := health.NewRegistry("service-a", 0)
+	checks.Register(health.Check{Name: "disk", Check: health.DiskSpaceCheck("/", minFreeDiskBytes)})
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		dbConfig, err := db.PostgresConfigFromURL(databaseURL)
+		if err != nil {
+			logger.FatalWithFields("Invalid DATABASE_URL", err, nil)
+		}
+		pool, err := db.NewPostgresDB(dbConfig)
+		if err != nil {
+			logger.FatalWithFields("Failed to connect to database", err, nil)
+		}
+		metrics.RegisterDBStats("postgres", pool)
+		checks.Register(health.Check{Name: "postgres", Check: db.PingCheck(pool), Critical: true})
+
+		// Auth endpoints (/register, /login, /logout, /me, /sessions), service-a issues the JWTs and rotates the signing keys
+		signingKeys, err := middleware.NewSigningKeyManager(authConfig)
+		if err != nil {
+			logger.FatalWithFields("Failed to load signing keys", err, nil)
+		}
+		if _, err := signingKeys.EnsureSigningKey(); err != nil {
+			logger.FatalWithFields("Failed to create signing key", err, nil)
+		}
+		go signingKeys.Run(ctx, true)
+
+		// Password hashing bounded by PASSWORD_HASH_MEMORY_MB, optionally peppered with PASSWORD_PEPPER (PASSWORD_PEPPER_PREVIOUS after a rotation)
+		hashOptions, err := security.HashPoolOptionsFromEnv()
+		if err != nil {
+			logger.FatalWithFields("Invalid password hashing settings", err, nil)
+		}
+		hasher := security.NewHashPool(hashOptions)
+
+		// Verification and reset links by mail (MAIL_SENDER: smtp, file or log)
+		mailer, err := mail.NewSenderFromEnv()
+		if err != nil {
+			logger.FatalWithFields("Invalid mail settings", err, nil)
+		}
+		tokens := auth.NewTokenStore(pool)
+
+		// Auth cookie sessions (authCookieName, sliding and absolute expiry from authConfig.json)
+		sessions := session.NewManager(session.OptionsFromConfig(authConfig, session.NewPostgresStore(pool), os.Getenv("ENVIRONMENT") == "production"))
+		go removeExpired(ctx, tokens, sessions)
+
+		authHandler, err := auth.NewHandler(auth.HandlerOptions{
+			Users:    auth.NewUserStore(pool),
+			Tokens:   tokens,
+			Keys:     signingKeys,
+			Hasher:   hasher,
+			Mailer:   mailer,
+			Sessions: sessions,
+			Config:   authConfig,
+			BaseURL:  os.Getenv("APP_BASE_URL"),
+		})
+		if err != nil {
+			logger.FatalWithFields("Failed to create auth handler", err, nil)
+		}
+		// Pending mails still read the database, so the pool closes after them
+		srv.OnShutdown("pending mails", authHandler.Close)
+		srv.CloseOnShutdown("postgres", pool)
+		authHandler.Routes(mux)
+
+		// Public signing keys, the gateway verifies the JWTs against them (jwt.jwksURL)
+		mux.HandleFunc(jwt.JWKSPath, jwt.JWKSHandler(signingKeys))
+	}
+	if rabbitMQAddr := os.Getenv("RABBITMQ_ADDR"); rabbitMQAddr != "" {
+		checks.Register(health.Check{Name: "rabbitmq", Check: health.RabbitMQCheck(rabbitMQAddr)})
+	}
+
 	// Routes
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// Request-ID kommt vom Gateway!
@@ -40,15 +156,49 @@ func main() {
 		fmt.Fprintf(w, "%s\n", msg)
 	})
 
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json") // ← JSON Header
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, `{"status":"OK"}`) // ← JSON Response
-	})
+	// Probes - /readyz returns 503 while shutting down, so the gateway takes the service out of rotation
+	mux.HandleFunc(health.LivezPath, checks.LivezHandler())
+	mux.HandleFunc(health.ReadyzPath, srv.ReadinessHandler(checks.ReadyzHandler()))
+	mux.HandleFunc(health.StartupzPath, checks.StartupzHandler())
+	mux.HandleFunc(health.HealthPath, checks.ReportHandler())
 
-	// Simple Server - NO middleware wrapper!
+	// Prometheus metrics (request count/latency per route, DB pool stats)
+	mux.HandleFunc("/metrics", metrics.Handler(os.Getenv("METRICS_TOKEN")))
+
+	// Simple Server - only request ID, metrics and tracing (continues the gateway's trace via traceparent)
 	logger.Info(fmt.Sprintf("Service A ready on %s", listenAddress))
-	if err := http.ListenAndServe(listenAddress, mux); err != nil {
-		logger.FatalWithFields("Failed to start server", err, nil)
+	checks.MarkStarted()
+	handler := middleware.RequestIDMiddleware(middleware.TracingMiddleware(mux)(middleware.MetricsMiddleware(mux)(mux)))
+	if err := srv.Run(context.Background(), handler); err != nil {
+		logger.FatalWithFields("Service stopped with error", err, nil)
+	}
+}
+
+// removeExpired deletes expired and used tokens and expired sessions every cleanupInterval until ctx ends
+func removeExpired(ctx context.Context, tokens *auth.TokenStore, sessions *session.Manager) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for name, deleteExpired := range map[string]func(context.Context) (int64, error){
+				"tokens":   tokens.DeleteExpired,
+				"sessions": sessions.DeleteExpired,
+			} {
+				removed, err := deleteExpired(ctx)
+				if err != nil {
+					logger.ErrorWithFields("Removing expired "+name+" failed", err, nil)
+					continue
+				}
+				if removed > 0 {
+					logger.InfoWithFields("Expired "+name+" removed", map[string]interface{}{
+						"count": removed,
+					})
+				}
+			}
+		}
 	}
 }
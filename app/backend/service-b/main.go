@@ -1,21 +1,33 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 
 	shared "github.com/app/shared/go"
 	config "github.com/app/shared/go/config"
+	middleware "github.com/app/shared/go/middleware"
+	health "github.com/app/shared/go/utils/health"
 	logger "github.com/app/shared/go/utils/logger"
+	metrics "github.com/app/shared/go/utils/metrics"
+	server "github.com/app/shared/go/utils/server"
+	tracing "github.com/app/shared/go/utils/tracing"
 )
 
+// minFreeDiskBytes is the free disk space below which the health report is degraded
+const minFreeDiskBytes = 100 << 20
+
 // ==========================================
 // SERVICE A
 // ==========================================
 func main() {
 	logger.Init("SERVICE-B", os.Getenv("ENVIRONMENT"))
 
+	// Client IPs from the gateway's forwarding headers
+	middleware.TrustProxiesFromEnv()
+
 	// Load Config
 	routeConfig, err := config.LoadRouteConfig()
 	if err != nil {
@@ -23,6 +35,23 @@ func main() {
 	}
 	listenAddress := fmt.Sprintf("%s:%s", routeConfig.Backend.ListenHost, routeConfig.Backend.ListenPort)
 
+	// Server with graceful shutdown (SIGTERM/SIGINT)
+	srv := server.New(server.Options{
+		Name: "service-b",
+		Addr: listenAddress,
+	})
+
+	// Tracing (exports to OTEL_EXPORTER_OTLP_ENDPOINT if set), flushed on shutdown
+	tracer := tracing.InitFromEnv("service-b")
+	srv.OnShutdown("tracing", tracer.Shutdown)
+
+	// Health checks
+	checks := health.NewRegistry("service-b", 0)
+	checks.Register(health.Check{Name: "disk", Check: health.DiskSpaceCheck("/", minFreeDiskBytes)})
+	if rabbitMQAddr := os.Getenv("RABBITMQ_ADDR"); rabbitMQAddr != "" {
+		checks.Register(health.Check{Name: "rabbitmq", Check: health.RabbitMQCheck(rabbitMQAddr)})
+	}
+
 	// Simple Mux - NO middleware needed!
 	mux := http.NewServeMux()
 
@@ -40,15 +69,19 @@ func main() {
 		fmt.Fprintf(w, "%s\n", msg)
 	})
 
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json") // ← JSON Header
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, `{"status":"OK"}`) // ← JSON Response
-	})
+	// Probes - /readyz returns 503 while shutting down, so the gateway takes the service out of rotation
+	mux.HandleFunc(health.LivezPath, checks.LivezHandler())
+	mux.HandleFunc(health.ReadyzPath, srv.ReadinessHandler(checks.ReadyzHandler()))
+	mux.HandleFunc(health.StartupzPath, checks.StartupzHandler())
+	mux.HandleFunc(health.HealthPath, checks.ReportHandler())
+
+	// Prometheus metrics (request count/latency per route, DB pool stats)
+	mux.HandleFunc("/metrics", metrics.Handler(os.Getenv("METRICS_TOKEN")))
 
-	// Simple Server - NO middleware wrapper!
+	// Simple Server - only metrics and tracing (continues the gateway's trace via traceparent)
 	logger.Info(fmt.Sprintf("Service B ready on %s", listenAddress))
-	if err := http.ListenAndServe(listenAddress, mux); err != nil {
-		logger.FatalWithFields("Failed to start server", err, nil)
+	checks.MarkStarted()
+	if err := srv.Run(context.Background(), middleware.TracingMiddleware(mux)(middleware.MetricsMiddleware(mux)(mux))); err != nil {
+		logger.FatalWithFields("Service stopped with error", err, nil)
 	}
 }
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	ip "github.com/app/shared/go/utils/ip"
+)
+
+// IPListDir is the directory of the IP list files referenced in securityConfig.json (relative to this package)
+const IPListDir = "../../data/config/ipLists"
+
+// IPListFiles returns the IP list files of cfg relative to this package (for WatchConfigFiles)
+func IPListFiles(cfg interfaceconfig.ISecurityConfig) []string {
+	var files []string
+	for _, rule := range cfg.IPFilter.Rules {
+		for _, name := range append(append([]string{}, rule.AllowFiles...), rule.DenyFiles...) {
+			files = append(files, path.Join(IPListDir, name))
+		}
+	}
+	return files
+}
+
+// loadIPLists appends the entries of the allow/deny list files to the inline entries of each rule
+func loadIPLists(cfg *interfaceconfig.ISecurityConfig) error {
+	for i := range cfg.IPFilter.Rules {
+		rule := &cfg.IPFilter.Rules[i]
+
+		allow, err := readIPListFiles(rule.AllowFiles)
+		if err != nil {
+			return err
+		}
+		deny, err := readIPListFiles(rule.DenyFiles)
+		if err != nil {
+			return err
+		}
+		rule.Allow = append(rule.Allow, allow...)
+		rule.Deny = append(rule.Deny, deny...)
+	}
+	return nil
+}
+
+// resolveCloudflareSources turns the list file names in cloudflare.sources into paths (URLs are kept)
+func resolveCloudflareSources(cfg *interfaceconfig.ISecurityConfig) error {
+	for i, source := range cfg.Cloudflare.Sources {
+		if ip.IsURLSource(source) {
+			continue
+		}
+		if source == "" || path.IsAbs(source) || strings.Contains(source, "..") {
+			return fmt.Errorf("cloudflare: invalid list file name %q", source)
+		}
+		file, err := resolveConfigPath(path.Join(IPListDir, source))
+		if err != nil {
+			return err
+		}
+		cfg.Cloudflare.Sources[i] = file
+	}
+	return nil
+}
+
+// readIPListFiles reads list files from IPListDir
+func readIPListFiles(names []string) ([]string, error) {
+	var entries []string
+	for _, name := range names {
+		if name == "" || path.IsAbs(name) || strings.Contains(name, "..") {
+			return nil, fmt.Errorf("ipFilter: invalid list file name %q", name)
+		}
+		file, err := resolveConfigPath(path.Join(IPListDir, name))
+		if err != nil {
+			return nil, err
+		}
+		prefixes, err := ip.ReadPrefixFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("ipFilter: %w", err)
+		}
+		for _, prefix := range prefixes {
+			entries = append(entries, prefix.String())
+		}
+	}
+	return entries, nil
+}
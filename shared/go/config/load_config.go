@@ -40,6 +40,37 @@ func loadRelativeConfig(configPath string, configStruct interface{}) error {
 	return file.LoadJson(absolutePath, configStruct)
 }
 
+// resolveConfigPath returns the absolute path of a config file relative to this package.
+// Used by the config watcher to check the files loaded by loadRelativeConfig.
+func resolveConfigPath(configPath string) (string, error) {
+	callerDir, err := getCallerDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(filepath.Join(callerDir, configPath)), nil
+}
+
+// Relative paths of the config files (relative to this package)
+const (
+	RouteConfigFile    = "../../data/config/routeConfig.json"
+	SecurityConfigFile = "../../data/config/securityConfig.json"
+	AuthConfigFile     = "../../data/config/authConfig.json"
+	RegexConfigFile    = "../../data/config/regexConfig.json"
+)
+
+// RegexConfigPath returns the absolute path of RegexConfigFile (for LoadRegexConfig)
+func RegexConfigPath() (string, error) {
+	return resolveConfigPath(RegexConfigFile)
+}
+
+// JWTKeyDir is the directory of the JWT signing keys (relative to this package, read by the issuer only)
+const JWTKeyDir = "../../data/keys"
+
+// JWTKeyDirPath returns the absolute path of JWTKeyDir
+func JWTKeyDirPath() (string, error) {
+	return resolveConfigPath(JWTKeyDir)
+}
+
 ///////////////////////////////////////////////////////////////////////////////////////////////////////////
 ///////////////////////////////////////////////////////////////////////////////////////////////////////////
 // The following functions are wrapper for loading the routing configuration.
@@ -55,8 +86,73 @@ var (
 
 func LoadRouteConfig() (interfaceconfig.IRouteConfig, error) {
 	routeConfigOnce.Do(func() {
-		const relativePath = "../../data/config/routeConfig.json"
-		routeConfigErr = loadRelativeConfig(relativePath, &RouteConfig)
+		RouteConfig, routeConfigErr = ReadRouteConfig()
 	})
 	return RouteConfig, routeConfigErr
 }
+
+// ReadRouteConfig reads and validates routeConfig.json from disk, bypassing the cache.
+// Used for hot reloading; the cached RouteConfig is left untouched.
+func ReadRouteConfig() (interfaceconfig.IRouteConfig, error) {
+	var cfg interfaceconfig.IRouteConfig
+	if err := loadRelativeConfig(RouteConfigFile, &cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, ValidateRouteConfig(cfg)
+}
+
+// ///////////////////////////
+// Security configurations
+var (
+	securityConfigOnce sync.Once
+	SecurityConfig     interfaceconfig.ISecurityConfig
+	securityConfigErr  error
+)
+
+func LoadSecurityConfig() (interfaceconfig.ISecurityConfig, error) {
+	securityConfigOnce.Do(func() {
+		SecurityConfig, securityConfigErr = ReadSecurityConfig()
+	})
+	return SecurityConfig, securityConfigErr
+}
+
+// ReadSecurityConfig reads and validates securityConfig.json (incl. the IP list files) from disk, bypassing the cache.
+// Used for hot reloading; the cached SecurityConfig is left untouched.
+func ReadSecurityConfig() (interfaceconfig.ISecurityConfig, error) {
+	var cfg interfaceconfig.ISecurityConfig
+	if err := loadRelativeConfig(SecurityConfigFile, &cfg); err != nil {
+		return cfg, err
+	}
+	if err := loadIPLists(&cfg); err != nil {
+		return cfg, err
+	}
+	if err := resolveCloudflareSources(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, ValidateSecurityConfig(cfg)
+}
+
+// ///////////////////////////
+// Auth configurations
+var (
+	authConfigOnce sync.Once
+	AuthConfig     interfaceconfig.IAuthConfig
+	authConfigErr  error
+)
+
+func LoadAuthConfig() (interfaceconfig.IAuthConfig, error) {
+	authConfigOnce.Do(func() {
+		AuthConfig, authConfigErr = ReadAuthConfig()
+	})
+	return AuthConfig, authConfigErr
+}
+
+// ReadAuthConfig reads and validates authConfig.json from disk, bypassing the cache.
+// Used for hot reloading; the cached AuthConfig is left untouched.
+func ReadAuthConfig() (interfaceconfig.IAuthConfig, error) {
+	var cfg interfaceconfig.IAuthConfig
+	if err := loadRelativeConfig(AuthConfigFile, &cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, ValidateAuthConfig(cfg)
+}
@@ -15,6 +15,7 @@ type RegexPatterns struct {
 	URL      *regexp.Regexp
 	Email    *regexp.Regexp
 	Password *regexp.Regexp
+	Username *regexp.Regexp
 }
 
 // Global regex variable
@@ -28,6 +29,7 @@ type rawRegexPatterns struct {
 	URL      string `json:"URL"`
 	Email    string `json:"Email"`
 	Password string `json:"Password"`
+	Username string `json:"Username"`
 }
 
 // LoadRegexConfig loads regex patterns from JSON file
@@ -52,6 +54,7 @@ func LoadRegexConfig(filepath string) {
 	Regex.URL = compileRegex(raw.URL, "URL")
 	Regex.Email = compileRegex(raw.Email, "Email")
 	Regex.Password = compileRegex(raw.Password, "Password")
+	Regex.Username = compileRegex(raw.Username, "Username")
 
 	log.Println("✅ Regex patterns loaded successfully from", filepath)
 }
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/jwt"
+)
+
+// ValidateAuthConfig checks the authentication settings for errors.
+// All problems are collected and returned together so they can be fixed in one go.
+func ValidateAuthConfig(cfg interfaceconfig.IAuthConfig) error {
+	var errs []error
+
+	if cfg.MinLengthPassword <= 0 || cfg.MaxLengthPassword < cfg.MinLengthPassword {
+		errs = append(errs, errors.New("minLengthPassword must be positive and not above maxLengthPassword"))
+	}
+	if cfg.MaxLengthEmailAddress <= 0 {
+		errs = append(errs, errors.New("maxLengthEmailAddress must be positive"))
+	}
+	if cfg.Cookie.AuthCookieName == "" {
+		errs = append(errs, errors.New("cookie.authCookieName must not be empty"))
+	}
+	if cfg.Cookie.AuthCookieLifetimeSeconds <= 0 || cfg.Cookie.AuthCookieJWTLifetimeSeconds <= 0 {
+		errs = append(errs, errors.New("cookie lifetimes must be positive"))
+	}
+	if cfg.Cookie.AuthCookieIdleTimeoutSeconds < 0 || cfg.Cookie.AuthCookieIdleTimeoutSeconds > cfg.Cookie.AuthCookieLifetimeSeconds {
+		errs = append(errs, errors.New("cookie.authCookieIdleTimeoutSeconds must not be negative or above authCookieLifetimeSeconds"))
+	}
+	if cfg.TimeValidVerifyTokenMinutes <= 0 {
+		errs = append(errs, errors.New("timeValidVerifyTokenMinutes must be positive"))
+	}
+
+	if len(cfg.JWT.Algorithms) == 0 {
+		errs = append(errs, errors.New("jwt.algorithms must not be empty"))
+	}
+	for _, alg := range cfg.JWT.Algorithms {
+		switch alg {
+		case jwt.AlgHS256, jwt.AlgRS256, jwt.AlgEdDSA:
+		default:
+			errs = append(errs, fmt.Errorf("jwt.algorithms: %q must be %q, %q or %q", alg, jwt.AlgHS256, jwt.AlgRS256, jwt.AlgEdDSA))
+		}
+	}
+	if cfg.JWT.ClockSkewSeconds < 0 {
+		errs = append(errs, errors.New("jwt.clockSkewSeconds must not be negative"))
+	}
+
+	switch cfg.JWT.Signing.Algorithm {
+	case "", jwt.AlgEdDSA, jwt.AlgRS256:
+	default:
+		errs = append(errs, fmt.Errorf("jwt.signing.algorithm %q must be %q or %q", cfg.JWT.Signing.Algorithm, jwt.AlgEdDSA, jwt.AlgRS256))
+	}
+	for name, value := range map[string]string{
+		"jwt.signing.rotationInterval": cfg.JWT.Signing.RotationInterval,
+		"jwt.jwksCacheTTL":             cfg.JWT.JWKSCacheTTL,
+	} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("%s %q is not a positive duration", name, value))
+		}
+	}
+	// Tokens signed with a replaced key must stay verifiable until they expire
+	lifetime := time.Duration(cfg.Cookie.AuthCookieJWTLifetimeSeconds) * time.Second
+	if overlap, err := time.ParseDuration(cfg.JWT.Signing.Overlap); err != nil || overlap < lifetime {
+		errs = append(errs, fmt.Errorf("jwt.signing.overlap %q must be a duration of at least the JWT lifetime (%s)", cfg.JWT.Signing.Overlap, lifetime))
+	}
+	if cfg.JWT.JWKSURL != "" && !strings.HasPrefix(cfg.JWT.JWKSURL, "https://") && !strings.HasPrefix(cfg.JWT.JWKSURL, "http://") && !filepath.IsAbs(cfg.JWT.JWKSURL) {
+		errs = append(errs, fmt.Errorf("jwt.jwksURL %q must be an http(s) URL or an absolute file path", cfg.JWT.JWKSURL))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid auth configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
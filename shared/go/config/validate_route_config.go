@@ -0,0 +1,230 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/health"
+	"github.com/app/shared/go/utils/jwt"
+	"github.com/app/shared/go/utils/server"
+)
+
+// validHTTPMethods lists the methods accepted in a route's "Methods" field
+var validHTTPMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// maxTargetWeight bounds a target's Weight, the consistent hash ring holds 100 nodes per weight unit
+const maxTargetWeight = 1000
+
+// minCircuitWindow is the shortest CircuitBreaker.Window, the window is split into 10 buckets
+const minCircuitWindow = time.Second
+
+// gatewayPaths are registered by the gateway itself (app/backend/gateway/main.go)
+// A route claiming one of them would make http.ServeMux panic or hide the gateway endpoint
+var gatewayPaths = []string{
+	"/api/",
+	"/api/health",
+	health.LivezPath,
+	health.ReadyzPath,
+	health.StartupzPath,
+	health.HealthPath,
+	jwt.JWKSPath,
+	"/metrics",
+	"/admin/ip-blocks",
+}
+
+// ValidateRouteConfig checks the route configuration for errors.
+// All problems are collected and returned together so they can be fixed in one go.
+func ValidateRouteConfig(cfg interfaceconfig.IRouteConfig) error {
+	var errs []error
+
+	if cfg.Backend.ListenPort == "" {
+		errs = append(errs, errors.New("backend.ListenPort must not be empty"))
+	}
+
+	names := make(map[string]bool)
+	prefixes := make(map[string]bool)
+
+	for i, route := range cfg.Backend.Routes {
+		// Identify the route in error messages by name if possible, otherwise by index
+		ref := fmt.Sprintf("routes[%d]", i)
+		if route.Name != "" {
+			ref = fmt.Sprintf("route %q", route.Name)
+		}
+
+		// Name
+		if route.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: Name must not be empty", ref))
+		} else if names[route.Name] {
+			errs = append(errs, fmt.Errorf("%s: duplicate Name", ref))
+		}
+		names[route.Name] = true
+
+		// Prefix
+		switch {
+		case !strings.HasPrefix(route.Prefix, "/"):
+			errs = append(errs, fmt.Errorf("%s: Prefix %q must start with '/'", ref, route.Prefix))
+		case !strings.HasSuffix(route.Prefix, "/"):
+			errs = append(errs, fmt.Errorf("%s: Prefix %q must end with '/'", ref, route.Prefix))
+		case prefixes[route.Prefix]:
+			errs = append(errs, fmt.Errorf("%s: duplicate Prefix %q", ref, route.Prefix))
+		default:
+			for _, path := range gatewayPaths {
+				if strings.HasPrefix(path, route.Prefix) {
+					errs = append(errs, fmt.Errorf("%s: Prefix %q covers the gateway path %q", ref, route.Prefix, path))
+					break
+				}
+			}
+		}
+		prefixes[route.Prefix] = true
+
+		// Targets
+		if len(route.Targets) == 0 {
+			errs = append(errs, fmt.Errorf("%s: at least one target is required", ref))
+		}
+		for _, target := range route.Targets {
+			if target.Weight < 0 {
+				errs = append(errs, fmt.Errorf("%s: target %q has a negative Weight", ref, target.URL))
+			} else if target.Weight > maxTargetWeight {
+				errs = append(errs, fmt.Errorf("%s: target %q has a Weight above %d", ref, target.URL, maxTargetWeight))
+			}
+			u, err := url.Parse(target.URL)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid target %q: %v", ref, target.URL, err))
+				continue
+			}
+			if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Errorf("%s: target %q must be an absolute http(s) URL", ref, target.URL))
+			}
+		}
+
+		// Load balancing
+		switch route.LoadBalancing.Strategy {
+		case "", interfaceconfig.LoadBalancingRoundRobin, interfaceconfig.LoadBalancingLeastConnections, interfaceconfig.LoadBalancingWeighted:
+			if route.LoadBalancing.HashKey != "" {
+				errs = append(errs, fmt.Errorf("%s: HashKey is only supported with the %q strategy", ref, interfaceconfig.LoadBalancingConsistentHash))
+			}
+		case interfaceconfig.LoadBalancingConsistentHash:
+			hashKey := route.LoadBalancing.HashKey
+			if hashKey != "" && hashKey != "ip" && (!strings.HasPrefix(hashKey, "header:") || strings.TrimPrefix(hashKey, "header:") == "") {
+				errs = append(errs, fmt.Errorf("%s: HashKey %q must be \"ip\" or \"header:<Name>\"", ref, hashKey))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s: unknown load balancing Strategy %q", ref, route.LoadBalancing.Strategy))
+		}
+
+		// Health check
+		for _, field := range []struct{ name, value string }{
+			{"HealthCheck.Interval", route.HealthCheck.Interval},
+			{"HealthCheck.Timeout", route.HealthCheck.Timeout},
+		} {
+			if field.value == "" {
+				continue
+			}
+			if d, err := time.ParseDuration(field.value); err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("%s: %s %q must be a positive duration", ref, field.name, field.value))
+			}
+		}
+		if route.HealthCheck.Path != "" && !strings.HasPrefix(route.HealthCheck.Path, "/") {
+			errs = append(errs, fmt.Errorf("%s: HealthCheck.Path %q must start with '/'", ref, route.HealthCheck.Path))
+		}
+		if route.HealthCheck.UnhealthyThreshold < 0 || route.HealthCheck.HealthyThreshold < 0 {
+			errs = append(errs, fmt.Errorf("%s: HealthCheck thresholds must not be negative", ref))
+		}
+
+		// Circuit breaker
+		for _, field := range []struct{ name, value string }{
+			{"CircuitBreaker.Window", route.CircuitBreaker.Window},
+			{"CircuitBreaker.CoolDown", route.CircuitBreaker.CoolDown},
+		} {
+			if field.value == "" {
+				continue
+			}
+			if d, err := time.ParseDuration(field.value); err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("%s: %s %q must be a positive duration", ref, field.name, field.value))
+			}
+		}
+		if route.CircuitBreaker.Window != "" {
+			if d, err := time.ParseDuration(route.CircuitBreaker.Window); err == nil && d > 0 && d < minCircuitWindow {
+				errs = append(errs, fmt.Errorf("%s: CircuitBreaker.Window %q must be at least %s", ref, route.CircuitBreaker.Window, minCircuitWindow))
+			}
+		}
+		if route.CircuitBreaker.FailureRatio < 0 || route.CircuitBreaker.FailureRatio > 1 {
+			errs = append(errs, fmt.Errorf("%s: CircuitBreaker.FailureRatio must be between 0 and 1", ref))
+		}
+		if route.CircuitBreaker.ConsecutiveFailures < 0 || route.CircuitBreaker.MinRequests < 0 || route.CircuitBreaker.HalfOpenMaxRequests < 0 {
+			errs = append(errs, fmt.Errorf("%s: CircuitBreaker thresholds must not be negative", ref))
+		}
+
+		// Retry
+		for _, field := range []struct{ name, value string }{
+			{"Retry.InitialBackoff", route.Retry.InitialBackoff},
+			{"Retry.MaxBackoff", route.Retry.MaxBackoff},
+		} {
+			if field.value == "" {
+				continue
+			}
+			if d, err := time.ParseDuration(field.value); err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("%s: %s %q must be a positive duration", ref, field.name, field.value))
+			}
+		}
+		if route.Retry.BudgetRatio < 0 {
+			errs = append(errs, fmt.Errorf("%s: Retry.BudgetRatio must not be negative", ref))
+		}
+		if route.Retry.MaxAttempts < 0 || route.Retry.MaxBodyBytes < 0 {
+			errs = append(errs, fmt.Errorf("%s: Retry limits must not be negative", ref))
+		}
+
+		// Methods
+		for _, method := range route.Methods {
+			if !validHTTPMethods[method] {
+				errs = append(errs, fmt.Errorf("%s: unsupported method %q", ref, method))
+			}
+		}
+
+		// Auth
+		switch route.Auth.Mode {
+		case "", interfaceconfig.AuthModePublic, interfaceconfig.AuthModeProtected:
+		default:
+			errs = append(errs, fmt.Errorf("%s: Auth.Mode %q must be %q or %q", ref, route.Auth.Mode, interfaceconfig.AuthModePublic, interfaceconfig.AuthModeProtected))
+		}
+		for _, path := range route.Auth.PublicPaths {
+			if !strings.HasPrefix(path, route.Prefix) {
+				errs = append(errs, fmt.Errorf("%s: Auth.PublicPaths entry %q must start with the Prefix", ref, path))
+			}
+		}
+		if len(route.Auth.Roles) > 0 && route.Auth.Mode != interfaceconfig.AuthModeProtected {
+			errs = append(errs, fmt.Errorf("%s: Auth.Roles requires Auth.Mode %q", ref, interfaceconfig.AuthModeProtected))
+		}
+
+		// Timeout
+		if route.Timeout != "" {
+			timeout, err := time.ParseDuration(route.Timeout)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid Timeout %q: %v", ref, route.Timeout, err))
+			} else if timeout <= 0 {
+				errs = append(errs, fmt.Errorf("%s: Timeout must be positive", ref))
+			} else if timeout >= server.DefaultWriteTimeout {
+				// The server would cut the connection before the 504 is written
+				errs = append(errs, fmt.Errorf("%s: Timeout %s must be below the server write timeout of %s", ref, timeout, server.DefaultWriteTimeout))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid route configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
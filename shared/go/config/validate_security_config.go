@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	ip "github.com/app/shared/go/utils/ip"
+)
+
+// ValidateSecurityConfig checks the gateway middleware settings for errors.
+// All problems are collected and returned together so they can be fixed in one go.
+func ValidateSecurityConfig(cfg interfaceconfig.ISecurityConfig) error {
+	var errs []error
+
+	if cfg.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("maxBodyBytes must be positive"))
+	}
+
+	for env, settings := range map[string]interfaceconfig.IRateLimitSettings{
+		"production":  cfg.RateLimit.Production,
+		"development": cfg.RateLimit.Development,
+	} {
+		if settings.RequestsPerSecond <= 0 {
+			errs = append(errs, fmt.Errorf("rateLimit.%s.requestsPerSecond must be positive", env))
+		}
+		if settings.Burst <= 0 {
+			errs = append(errs, fmt.Errorf("rateLimit.%s.burst must be positive", env))
+		}
+	}
+
+	store := cfg.RateLimit.Store
+	switch store.Type {
+	case "", "memory", "redis":
+	default:
+		errs = append(errs, fmt.Errorf("rateLimit.store.type %q must be \"memory\" or \"redis\"", store.Type))
+	}
+	switch store.FailureMode {
+	case "", "open", "closed":
+	default:
+		errs = append(errs, fmt.Errorf("rateLimit.store.failureMode %q must be \"open\" or \"closed\"", store.FailureMode))
+	}
+	if store.Timeout != "" {
+		if d, err := time.ParseDuration(store.Timeout); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("rateLimit.store.timeout %q is not a positive duration", store.Timeout))
+		}
+	}
+	if store.DB < 0 {
+		errs = append(errs, errors.New("rateLimit.store.db must not be negative"))
+	}
+	if store.MaxKeys < 0 {
+		errs = append(errs, errors.New("rateLimit.store.maxKeys must not be negative"))
+	}
+	if store.IdleTimeout != "" {
+		if d, err := time.ParseDuration(store.IdleTimeout); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("rateLimit.store.idleTimeout %q is not a positive duration", store.IdleTimeout))
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, policy := range cfg.RateLimit.Policies {
+		field := fmt.Sprintf("rateLimit.policies[%d]", i)
+		switch {
+		case policy.Name == "":
+			errs = append(errs, fmt.Errorf("%s.name is required", field))
+		case names[policy.Name]:
+			errs = append(errs, fmt.Errorf("%s.name %q is used more than once", field, policy.Name))
+		}
+		names[policy.Name] = true
+
+		if !strings.HasPrefix(policy.PathPrefix, "/") {
+			errs = append(errs, fmt.Errorf("%s.pathPrefix %q must start with \"/\"", field, policy.PathPrefix))
+		}
+		switch policy.Key {
+		case "ip", "user", "apiKey", "route":
+		case "header":
+			if policy.Header == "" {
+				errs = append(errs, fmt.Errorf("%s.header is required for key \"header\"", field))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s.key %q must be \"ip\", \"user\", \"apiKey\", \"header\" or \"route\"", field, policy.Key))
+		}
+		if policy.Limit <= 0 {
+			errs = append(errs, fmt.Errorf("%s.limit must be positive", field))
+		}
+		if d, err := time.ParseDuration(policy.Window); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("%s.window %q is not a positive duration", field, policy.Window))
+		}
+		if policy.Burst < 0 {
+			errs = append(errs, fmt.Errorf("%s.burst must not be negative", field))
+		}
+	}
+
+	for name, value := range map[string]string{
+		"baseDuration": cfg.IPBlock.BaseDuration,
+		"maxDuration":  cfg.IPBlock.MaxDuration,
+		"forgetAfter":  cfg.IPBlock.ForgetAfter,
+	} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("ipBlock.%s %q is not a positive duration", name, value))
+		}
+	}
+	for name, rule := range map[string]interfaceconfig.IIPBlockRule{
+		"rateLimit":       cfg.IPBlock.Rules.RateLimit,
+		"unauthorized":    cfg.IPBlock.Rules.Unauthorized,
+		"cloudflareSpoof": cfg.IPBlock.Rules.CloudflareSpoof,
+		"oversizedBody":   cfg.IPBlock.Rules.OversizedBody,
+	} {
+		if rule.Threshold < 0 {
+			errs = append(errs, fmt.Errorf("ipBlock.rules.%s.threshold must not be negative", name))
+		}
+		if rule.Threshold == 0 {
+			continue
+		}
+		if d, err := time.ParseDuration(rule.Window); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("ipBlock.rules.%s.window %q is not a positive duration", name, rule.Window))
+		}
+	}
+
+	for i, rule := range cfg.IPFilter.Rules {
+		field := fmt.Sprintf("ipFilter.rules[%d]", i)
+		if !strings.HasPrefix(rule.PathPrefix, "/") {
+			errs = append(errs, fmt.Errorf("%s.pathPrefix %q must start with \"/\"", field, rule.PathPrefix))
+		}
+		for _, entry := range append(append([]string{}, rule.Allow...), rule.Deny...) {
+			if _, err := ip.ParsePrefix(entry); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not an IP or CIDR", field, entry))
+			}
+		}
+	}
+
+	for _, entry := range cfg.TrustedProxies {
+		if _, err := ip.ParsePrefix(entry); err != nil {
+			errs = append(errs, fmt.Errorf("trustedProxies: %q is not an IP or CIDR", entry))
+		}
+	}
+
+	switch cfg.Cloudflare.Mode {
+	case "", "log", "enforce":
+	default:
+		errs = append(errs, fmt.Errorf("cloudflare.mode %q must be \"log\" or \"enforce\"", cfg.Cloudflare.Mode))
+	}
+	for _, source := range cfg.Cloudflare.Sources {
+		if !ip.IsURLSource(source) {
+			continue
+		}
+		if u, err := url.Parse(source); err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("cloudflare.sources: %q is not a valid URL", source))
+		}
+	}
+	if cfg.Cloudflare.RefreshInterval != "" {
+		if d, err := time.ParseDuration(cfg.Cloudflare.RefreshInterval); err != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("cloudflare.refreshInterval %q is not a positive duration", cfg.Cloudflare.RefreshInterval))
+		}
+	}
+
+	for _, origin := range cfg.Cors.AllowOrigins {
+		if origin == "" {
+			errs = append(errs, errors.New("cors.allowOrigins must not contain empty entries"))
+			break
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid security configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+// WatchConfigFiles calls reload whenever one of the config files changes or the process receives SIGHUP.
+// Changes are detected by polling the modification time every interval.
+// configFiles are paths relative to this package (e.g. RouteConfigFile).
+// A failing reload logs EventConfigInvalid and keeps the previous config active,
+// a successful one logs EventConfigReloaded. A panicking reload counts as failed.
+// Blocks until ctx is cancelled.
+func WatchConfigFiles(ctx context.Context, interval time.Duration, reload func() error, configFiles ...string) {
+	// Resolve absolute paths once
+	paths := make([]string, 0, len(configFiles))
+	for _, configFile := range configFiles {
+		path, err := resolveConfigPath(configFile)
+		if err != nil {
+			logger.LogEvent(logger.EventConfigInvalid, map[string]interface{}{
+				"error": err.Error(),
+				"file":  configFile,
+			})
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	modTimes := readModTimes(paths)
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var trigger string
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			trigger = "sighup"
+			modTimes = readModTimes(paths)
+		case <-ticker.C:
+			current := readModTimes(paths)
+			if !modTimesChanged(modTimes, current) {
+				continue
+			}
+			modTimes = current
+			trigger = "file_changed"
+		}
+
+		if err := safeReload(reload); err != nil {
+			logger.LogEvent(logger.EventConfigInvalid, map[string]interface{}{
+				"error":   err.Error(),
+				"trigger": trigger,
+				"files":   paths,
+			})
+			continue
+		}
+
+		logger.LogEvent(logger.EventConfigReloaded, map[string]interface{}{
+			"trigger": trigger,
+			"files":   paths,
+		})
+	}
+}
+
+// safeReload runs reload and returns a panic as error, so a bad config cannot stop the process
+func safeReload(reload func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("reload panicked: %v", r)
+		}
+	}()
+	return reload()
+}
+
+// readModTimes returns the modification time per file (missing files are skipped)
+func readModTimes(paths []string) map[string]time.Time {
+	modTimes := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// modTimesChanged reports whether any file was added, removed or modified
+func modTimesChanged(previous, current map[string]time.Time) bool {
+	if len(previous) != len(current) {
+		return true
+	}
+	for path, modTime := range current {
+		if !previous[path].Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
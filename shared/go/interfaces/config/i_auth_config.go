@@ -0,0 +1,38 @@
+package interfaceconfig
+
+// Auth cookie settings
+type IAuthCookieConfig struct {
+	AuthCookieLifetimeSeconds    int    `json:"authCookieLifetimeSeconds"`    // Absolute lifetime of a session
+	AuthCookieIdleTimeoutSeconds int    `json:"authCookieIdleTimeoutSeconds"` // Sliding expiry of a session, extended on use (0: absolute lifetime only)
+	AuthCookieJWTLifetimeSeconds int    `json:"authCookieJWTLifetimeSeconds"` // Lifetime of the issued JWTs
+	AuthCookieName               string `json:"authCookieName"`               // Cookie holding the session ID (checked if no Authorization header is sent)
+}
+
+// Signing keys in shared/data/keys (private keys encrypted at rest with JWT_KEY_ENCRYPTION_KEY if set)
+type IJWTSigningConfig struct {
+	Algorithm        string `json:"algorithm"`        // Algorithm of new keys: "EdDSA" (default) or "RS256"
+	RotationInterval string `json:"rotationInterval"` // Age after which the issuer rotates the key, e.g. "720h" (empty: never)
+	Overlap          string `json:"overlap"`          // Replaced keys stay valid this long, at least the JWT lifetime
+}
+
+// JWT validation at the gateway
+// Keys: the public signing keys from jwksURL plus JWT_HMAC_SECRET (HS256) and JWT_PUBLIC_KEY_FILE (PEM) if set
+type IJWTConfig struct {
+	Algorithms       []string          `json:"algorithms"`       // Accepted algorithms: "HS256", "RS256", "EdDSA"
+	Issuer           string            `json:"issuer"`           // Required "iss" claim (empty: not checked)
+	Audience         []string          `json:"audience"`         // "aud" must contain one of these (empty: not checked)
+	ClockSkewSeconds int               `json:"clockSkewSeconds"` // Leeway for exp/nbf
+	Signing          IJWTSigningConfig `json:"signing"`
+	JWKSURL          string            `json:"jwksURL"`      // JWKS URL or file of the issuer to verify against (empty: environment keys only)
+	JWKSCacheTTL     string            `json:"jwksCacheTTL"` // Cache duration of the remote JWKS (empty: "10m")
+}
+
+// Main config structure of authConfig.json
+type IAuthConfig struct {
+	MinLengthPassword           int               `json:"minLengthPassword"`
+	MaxLengthPassword           int               `json:"maxLengthPassword"`
+	MaxLengthEmailAddress       int               `json:"maxLengthEmailAddress"`
+	Cookie                      IAuthCookieConfig `json:"cookie"`
+	TimeValidVerifyTokenMinutes int               `json:"timeValidVerifyTokenMinutes"`
+	JWT                         IJWTConfig        `json:"jwt"`
+}
@@ -1,17 +1,96 @@
 package interfaceconfig
 
+// Load balancing strategies
+const (
+	LoadBalancingRoundRobin       = "round-robin"
+	LoadBalancingLeastConnections = "least-connections"
+	LoadBalancingWeighted         = "weighted"
+	LoadBalancingConsistentHash   = "consistent-hash"
+)
+
+// Per-route middleware toggles (nil = enabled)
+type IRouteMiddleware struct {
+	RateLimit   *bool `json:"RateLimit"`
+	Compression *bool `json:"Compression"`
+}
+
+// Single upstream of a route
+type IRouteTarget struct {
+	URL    string `json:"URL"`    // Upstream base URL (e.g. "http://service-a:8080")
+	Weight int    `json:"Weight"` // Relative weight for "weighted" and "consistent-hash" (0 = 1, at most 1000)
+}
+
+// Load balancing over the targets of a route
+type ILoadBalancingConfig struct {
+	Strategy string `json:"Strategy"` // One of the LoadBalancing* constants (empty = round-robin)
+	HashKey  string `json:"HashKey"`  // consistent-hash only: "ip" (default) or "header:<Name>"
+}
+
+// Upstream health checking (active probes + passive proxy errors)
+type IHealthCheckConfig struct {
+	Disabled           bool   `json:"Disabled"`           // Disable active and passive checks
+	Path               string `json:"Path"`               // Probe path on each target (empty = "/readyz")
+	Interval           string `json:"Interval"`           // Probe interval as Go duration (empty = "10s")
+	Timeout            string `json:"Timeout"`            // Probe timeout as Go duration (empty = "2s")
+	UnhealthyThreshold int    `json:"UnhealthyThreshold"` // Consecutive failures before removal (0 = 3)
+	HealthyThreshold   int    `json:"HealthyThreshold"`   // Consecutive successes before re-admission (0 = 2)
+}
+
+// Per-upstream circuit breaker (fails fast while an upstream keeps failing)
+type ICircuitBreakerConfig struct {
+	Disabled            bool    `json:"Disabled"`            // Disable the circuit breaker
+	ConsecutiveFailures int     `json:"ConsecutiveFailures"` // Consecutive failures that open the circuit (0 = 5)
+	FailureRatio        float64 `json:"FailureRatio"`        // Failure ratio within Window that opens the circuit (0 = 0.5)
+	MinRequests         int     `json:"MinRequests"`         // Requests within Window before FailureRatio applies (0 = 10)
+	Window              string  `json:"Window"`              // Rolling window for FailureRatio as Go duration (empty = "60s", at least "1s")
+	CoolDown            string  `json:"CoolDown"`            // Time the circuit stays open before probing as Go duration (empty = "30s")
+	HalfOpenMaxRequests int     `json:"HalfOpenMaxRequests"` // Concurrent probe requests while half-open (0 = 1)
+}
+
+// Retries of idempotent requests (GET/HEAD/OPTIONS/PUT/DELETE or with Idempotency-Key)
+type IRetryConfig struct {
+	Disabled       bool    `json:"Disabled"`       // Disable retries
+	MaxAttempts    int     `json:"MaxAttempts"`    // Attempts incl. the first one (0 = 3)
+	InitialBackoff string  `json:"InitialBackoff"` // Backoff before the first retry as Go duration, doubled per retry (empty = "50ms")
+	MaxBackoff     string  `json:"MaxBackoff"`     // Upper bound of the backoff as Go duration (empty = "1s")
+	BudgetRatio    float64 `json:"BudgetRatio"`    // Retries allowed per request within 10s, e.g. 0.2 = +20% load (0 = 0.2)
+	MaxBodyBytes   int64   `json:"MaxBodyBytes"`   // Larger bodies are not buffered and not retried (0 = 65536)
+}
+
+// Route authentication modes
+const (
+	AuthModePublic    = "public"    // No token required, a valid token is forwarded (default)
+	AuthModeProtected = "protected" // Valid token required (except PublicPaths)
+)
+
+// JWT authentication of a route (see authConfig.json for the token settings)
+type IRouteAuthConfig struct {
+	Mode        string   `json:"Mode"`        // One of the AuthMode* constants (empty = public)
+	PublicPaths []string `json:"PublicPaths"` // Path prefixes (incl. route Prefix) that need no token on protected routes
+	Roles       []string `json:"Roles"`       // Token must have one of these roles (empty = any authenticated user)
+}
+
 // Sub-struct für einzelne Service-Routen
 type IServiceRoute struct {
-	Prefix    string `json:"Prefix"`
-	TargetURL string `json:"TargetURL"`
+	Name           string                `json:"Name"`           // Unique route name, used in logs (e.g. "service-a")
+	Prefix         string                `json:"Prefix"`         // Path prefix incl. trailing slash (e.g. "/api/service-a/")
+	Targets        []IRouteTarget        `json:"Targets"`        // Upstream pool
+	LoadBalancing  ILoadBalancingConfig  `json:"LoadBalancing"`  // How requests are spread over Targets
+	HealthCheck    IHealthCheckConfig    `json:"HealthCheck"`    // When targets are removed from / re-admitted to rotation
+	CircuitBreaker ICircuitBreakerConfig `json:"CircuitBreaker"` // When requests to a failing target fail fast
+	Retry          IRetryConfig          `json:"Retry"`          // Retries of idempotent requests on transient upstream errors
+	StripPrefix    bool                  `json:"StripPrefix"`    // Remove the prefix before forwarding (/api/service-a/foo -> /foo)
+	Methods        []string              `json:"Methods"`        // Allowed HTTP methods (empty = all)
+	Timeout        string                `json:"Timeout"`        // Per-route timeout as Go duration (e.g. "10s", empty = default)
+	Middleware     IRouteMiddleware      `json:"Middleware"`
+	Auth           IRouteAuthConfig      `json:"Auth"` // Public or protected (JWT), identity is forwarded as X-User-ID / X-User-Roles / X-Session-ID
 }
 
 // Struct for the new backend-specific fields
 type IBackendConfig struct {
-	ListenHost string        `json:"ListenHost"`
-	ListenPort string        `json:"ListenPort"`
-	ServiceA   IServiceRoute `json:"ServiceA"`
-	ServiceB   IServiceRoute `json:"ServiceB"`
+	ListenHost string          `json:"ListenHost"`
+	ListenPort string          `json:"ListenPort"`
+	Routes     []IServiceRoute `json:"Routes"`
 }
 
 // Main config structure (must represent the "backend" level)
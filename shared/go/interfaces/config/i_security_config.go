@@ -0,0 +1,101 @@
+package interfaceconfig
+
+// CORS settings for the gateway
+type ICORSConfig struct {
+	AllowOrigins []string `json:"allowOrigins"` // Whitelist, supports "*.example.com" (empty = CORS_ALLOWED_ORIGINS env)
+}
+
+// Per-IP rate limit settings
+type IRateLimitSettings struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	Burst             int     `json:"burst"`
+}
+
+// Limiter store shared by all gateway replicas
+type IRateLimitStoreConfig struct {
+	Type        string `json:"type"`        // "memory" (default, per replica) | "redis" (shared)
+	Address     string `json:"address"`     // host:port of the Redis server (empty = REDIS_ADDR env), password via REDIS_PASSWORD env
+	DB          int    `json:"db"`          // Redis database number
+	KeyPrefix   string `json:"keyPrefix"`   // Prefix of all bucket keys (default "ratelimit:")
+	Timeout     string `json:"timeout"`     // Max time per store call, Go duration (default "50ms")
+	FailureMode string `json:"failureMode"` // "open" (default, allow requests) | "closed" (reject with 503) while the store is unreachable
+	MaxKeys     int    `json:"maxKeys"`     // Memory store: max buckets held, least recently used are dropped (default 100000)
+	IdleTimeout string `json:"idleTimeout"` // Memory store: buckets unused this long are removed, Go duration (default "10m")
+}
+
+// Additional rate limit rule, evaluated for every request matching pathPrefix and methods
+type IRateLimitPolicy struct {
+	Name       string   `json:"name"`       // Unique, part of the bucket keys
+	PathPrefix string   `json:"pathPrefix"` // Matched against the full request path (e.g. "/api/service-a/login")
+	Methods    []string `json:"methods"`    // Empty = all methods
+	Key        string   `json:"key"`        // "ip" | "user" | "apiKey" | "header" | "route"
+	Header     string   `json:"header"`     // Header for key "header" (and "apiKey", default X-API-Key)
+	Limit      int      `json:"limit"`      // Requests per window
+	Window     string   `json:"window"`     // Go duration (e.g. "1m")
+	Burst      int      `json:"burst"`      // Bucket size (default = limit)
+}
+
+// Rate limit settings per environment
+type IRateLimitConfig struct {
+	Production  IRateLimitSettings    `json:"production"`
+	Development IRateLimitSettings    `json:"development"`
+	Store       IRateLimitStoreConfig `json:"store"`
+	Policies    []IRateLimitPolicy    `json:"policies"`
+}
+
+// Escalation of one kind of offense into a temporary IP block
+type IIPBlockRule struct {
+	Threshold int    `json:"threshold"` // Offenses within window that block the IP (0 = never)
+	Window    string `json:"window"`    // Go duration
+}
+
+// Offenses counted towards a block
+type IIPBlockRules struct {
+	RateLimit       IIPBlockRule `json:"rateLimit"`       // Requests rejected by the rate limiter
+	Unauthorized    IIPBlockRule `json:"unauthorized"`    // 401 responses
+	CloudflareSpoof IIPBlockRule `json:"cloudflareSpoof"` // Cloudflare headers from outside Cloudflare
+	OversizedBody   IIPBlockRule `json:"oversizedBody"`   // Request bodies over maxBodyBytes
+}
+
+// Temporary blocking of abusive client IPs
+type IIPBlockConfig struct {
+	Enabled      bool          `json:"enabled"`
+	BaseDuration string        `json:"baseDuration"` // First block, doubled for every further block (default "1m")
+	MaxDuration  string        `json:"maxDuration"`  // Longest block (default "24h")
+	ForgetAfter  string        `json:"forgetAfter"`  // Block level resets after this long without a block (default "24h")
+	Rules        IIPBlockRules `json:"rules"`
+}
+
+// IP allow/deny rule for requests whose path starts with pathPrefix
+// Entries are IPs or CIDRs (IPv4 and IPv6), files are read from shared/data/config/ipLists/
+type IIPFilterRule struct {
+	Name       string   `json:"name"`
+	PathPrefix string   `json:"pathPrefix"` // "/" = all requests, e.g. "/admin/" for admin endpoints
+	Allow      []string `json:"allow"`      // If set (inline or via files), only these IPs pass
+	Deny       []string `json:"deny"`       // Always rejected (checked before allow)
+	AllowFiles []string `json:"allowFiles"` // List files, one IP/CIDR per line, "#" comments
+	DenyFiles  []string `json:"denyFiles"`
+}
+
+// IP allow/deny lists
+type IIPFilterConfig struct {
+	Rules []IIPFilterRule `json:"rules"`
+}
+
+// Cloudflare validation (with USE_CLOUDFLARE=true)
+type ICloudflareConfig struct {
+	Mode            string   `json:"mode"`            // "log" (default) or "enforce" (reject spoofed requests with 403)
+	Sources         []string `json:"sources"`         // URLs or list files in ipLists/ to refresh the ranges from (empty: built-in ranges)
+	RefreshInterval string   `json:"refreshInterval"` // e.g. "24h" (empty: load once on startup)
+}
+
+// Main config structure of securityConfig.json (gateway middleware settings)
+type ISecurityConfig struct {
+	Cors           ICORSConfig       `json:"cors"`
+	MaxBodyBytes   int64             `json:"maxBodyBytes"`
+	RateLimit      IRateLimitConfig  `json:"rateLimit"`
+	IPBlock        IIPBlockConfig    `json:"ipBlock"`
+	IPFilter       IIPFilterConfig   `json:"ipFilter"`
+	TrustedProxies []string          `json:"trustedProxies"`
+	Cloudflare     ICloudflareConfig `json:"cloudflare"` // IPs/CIDRs whose forwarding headers are honoured (empty: loopback + private networks)
+}
@@ -38,39 +38,7 @@ package middleware
 // 	})
 // }
 
-// // 2. IP Whitelist/Blacklist Middleware
-// func IPFilterMiddleware(whitelist []string, blacklist []string) func(http.Handler) http.Handler {
-// 	return func(next http.Handler) http.Handler {
-// 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-// 			ip := getClientIP(r)
-
-// 			// Check blacklist first
-// 			for _, blocked := range blacklist {
-// 				if ip == blocked || strings.HasPrefix(ip, blocked) {
-// 					http.Error(w, "Forbidden", http.StatusForbidden)
-// 					return
-// 				}
-// 			}
-
-// 			// If whitelist exists, check it
-// 			if len(whitelist) > 0 {
-// 				allowed := false
-// 				for _, allowedIP := range whitelist {
-// 					if ip == allowedIP || strings.HasPrefix(ip, allowedIP) {
-// 						allowed = true
-// 						break
-// 					}
-// 				}
-// 				if !allowed {
-// 					http.Error(w, "Forbidden", http.StatusForbidden)
-// 					return
-// 				}
-// 			}
-
-// 			next.ServeHTTP(w, r)
-// 		})
-// 	}
-// }
+// // 2. IP Whitelist/Blacklist -> implemented in ipFilterMiddleware.go (CIDR allow/deny lists per path prefix)
 
 // // 3. Slow Loris Attack Protection
 // func SlowLorisMiddleware(readTimeout time.Duration) func(http.Handler) http.Handler {
@@ -117,7 +85,7 @@ package middleware
 
 // 			if !validKeys[apiKey] {
 // 				logger.WarnWithFields("Invalid API key", map[string]interface{}{
-// 					"ip":   getClientIP(r),
+// 					"ip":   GetClientIPFromContext(r),
 // 					"path": r.URL.Path,
 // 				})
 // 				http.Error(w, "Invalid API key", http.StatusUnauthorized)
@@ -129,52 +97,9 @@ package middleware
 // 	}
 // }
 
-// // 6. Circuit Breaker Middleware (for backend services)
-// // Prevents cascading failures by temporarily blocking requests to failing services
-// func CircuitBreakerMiddleware(threshold int, timeout time.Duration) func(http.Handler) http.Handler {
-// 	// TODO: Implement full circuit breaker pattern
-// 	// Consider using: github.com/sony/gobreaker
-// 	return func(next http.Handler) http.Handler {
-// 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-// 			next.ServeHTTP(w, r)
-// 		})
-// 	}
-// }
-
-// // 7. Metrics/Monitoring Middleware
-// func MetricsMiddleware(next http.Handler) http.Handler {
-// 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-// 		start := time.Now()
-
-// 		// Wrap response writer to capture status code
-// 		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-
-// 		next.ServeHTTP(wrapped, r)
-
-// 		duration := time.Since(start)
+// // 6. Circuit Breaker -> implemented per upstream in circuitBreaker.go (used by LoadBalancedProxy)
 
-// 		// Log metrics
-// 		logger.InfoWithFields("Request metrics", map[string]interface{}{
-// 			"method":      r.Method,
-// 			"path":        r.URL.Path,
-// 			"status":      wrapped.statusCode,
-// 			"duration_ms": duration.Milliseconds(),
-// 			"ip":          getClientIP(r),
-// 		})
-
-// 		// TODO: Send to monitoring system (Prometheus, Datadog, etc.)
-// 	})
-// }
-
-// type responseWriter struct {
-// 	http.ResponseWriter
-// 	statusCode int
-// }
-
-// func (rw *responseWriter) WriteHeader(code int) {
-// 	rw.statusCode = code
-// 	rw.ResponseWriter.WriteHeader(code)
-// }
+// // 7. Metrics/Monitoring Middleware -> implemented in metricsMiddleware.go (Prometheus, /metrics)
 // ```
 
 // ---
@@ -0,0 +1,376 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/app/shared/go/config"
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/jwt"
+	"github.com/app/shared/go/utils/logger"
+	"github.com/app/shared/go/utils/metrics"
+	"github.com/app/shared/go/utils/session"
+)
+
+// Identity headers forwarded to upstreams (client-supplied copies are always removed)
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUserRoles = "X-User-Roles" // Comma separated
+	HeaderSessionID = "X-Session-ID" // Session of the cookie or the token's "sid" claim
+)
+
+var authFailuresTotal = metrics.NewCounterVec(
+	"http_auth_failures_total", "Requests rejected by the JWT authentication.", "route", "reason")
+
+// ==========================================
+// JWT VERIFIER
+// ==========================================
+
+// NewJWTVerifier creates the token verifier from authConfig.json:
+// the signing keys (see JWTPublicKeys) plus JWT_HMAC_SECRET (HS256, at least 32 bytes) and
+// JWT_PUBLIC_KEY_FILE (PEM, RS256 or EdDSA) from the environment if set
+// Without keys every token is rejected (protected routes answer 401)
+// The config is expected to be validated with config.ValidateAuthConfig beforehand
+func NewJWTVerifier(cfg interfaceconfig.IAuthConfig) (*jwt.Verifier, error) {
+	envKeys, err := jwtKeysFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return jwt.NewVerifier(jwt.VerifierOptions{
+		Keys:       jwt.KeySources{envKeys, JWTPublicKeys(cfg)},
+		Algorithms: cfg.JWT.Algorithms,
+		Issuer:     cfg.JWT.Issuer,
+		Audience:   cfg.JWT.Audience,
+		ClockSkew:  time.Duration(cfg.JWT.ClockSkewSeconds) * time.Second,
+	}), nil
+}
+
+// jwtHMACSecretPlaceholder is the start of example values like "change-me-to-a-random-secret",
+// a secret copied from an example would let anyone forge tokens
+const jwtHMACSecretPlaceholder = "change-me"
+
+// jwtKeysFromEnv loads the verification keys from JWT_HMAC_SECRET and JWT_PUBLIC_KEY_FILE
+func jwtKeysFromEnv() (jwt.StaticKeys, error) {
+	var keys jwt.StaticKeys
+
+	if secret := os.Getenv("JWT_HMAC_SECRET"); secret != "" {
+		if strings.HasPrefix(strings.ToLower(secret), jwtHMACSecretPlaceholder) {
+			return nil, errors.New("JWT_HMAC_SECRET is a placeholder, generate a secret (openssl rand -base64 48) or leave it empty")
+		}
+		key, err := jwt.NewHMACKey("", []byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if file := os.Getenv("JWT_PUBLIC_KEY_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		public, err := jwt.ParsePublicKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		key, err := jwt.NewPublicKey("", public)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// ==========================================
+// AUTH MIDDLEWARE
+// ==========================================
+
+// AuthMiddleware validates the JWT of a request (Authorization: Bearer <token>, otherwise the auth cookie)
+// With sessions the auth cookie holds a session ID resolved against the session store, otherwise it holds a JWT
+// Valid tokens and sessions put "user_id" and "auth_claims" into the request context and X-User-ID / X-User-Roles /
+// X-Session-ID into the forwarded request. Protected routes reject missing/invalid tokens with 401 and missing roles with 403,
+// public routes and PublicPaths pass anonymously
+// The route is expected to be validated with config.ValidateRouteConfig beforehand
+func AuthMiddleware(verifier *jwt.Verifier, cookieName string, sessions *session.Manager, route interfaceconfig.IServiceRoute) func(http.Handler) http.Handler {
+	protected := route.Auth.Mode == interfaceconfig.AuthModeProtected
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Upstreams trust these headers, only the gateway sets them
+			r.Header.Del(HeaderUserID)
+			r.Header.Del(HeaderUserRoles)
+			r.Header.Del(HeaderSessionID)
+
+			required := protected && !isPublicPath(route.Auth.PublicPaths, r.URL.Path)
+
+			token, source := tokenFromRequest(r, cookieName)
+			if token == "" {
+				if required {
+					rejectUnauthenticated(w, r, route.Name, "missing_token", source, nil, nil)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var claims *jwt.Claims
+			var err error
+			reason := ""
+			if source == "cookie" && sessions != nil {
+				claims, reason = sessionClaims(sessions, r)
+			} else if claims, err = verifyToken(verifier, token); err != nil {
+				reason = tokenErrorReason(err)
+			} else if claims.SessionID != "" && sessions != nil {
+				reason, err = tokenSessionReason(sessions, r, claims.SessionID)
+			}
+			if reason != "" {
+				if required {
+					rejectUnauthenticated(w, r, route.Name, reason, source, claims, err)
+					return
+				}
+				// Stale cookies on public pages are normal, continue anonymously
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if required && len(route.Auth.Roles) > 0 && !claims.HasRole(route.Auth.Roles...) {
+				authFailuresTotal.WithLabelValues(route.Name, "missing_role").Inc()
+				logger.LogAuthEvent(logger.EventAuthFailed, claims.Subject, GetRequestID(r), GetClientIPFromContext(r), map[string]interface{}{
+					"route":  route.Name,
+					"path":   r.URL.Path,
+					"reason": "missing_role",
+					"roles":  claims.Roles,
+				})
+				WriteJSONError(w, r, http.StatusForbidden, ErrorCodeForbidden, "Forbidden", "")
+				return
+			}
+
+			// Verified identity for logging, rate limiting and the upstream
+			ctx := context.WithValue(r.Context(), "user_id", claims.Subject)
+			ctx = context.WithValue(ctx, "auth_claims", claims)
+			r = r.WithContext(ctx)
+
+			r.Header.Set(HeaderUserID, claims.Subject)
+			if len(claims.Roles) > 0 {
+				r.Header.Set(HeaderUserRoles, strings.Join(claims.Roles, ","))
+			}
+			if claims.SessionID != "" {
+				r.Header.Set(HeaderSessionID, claims.SessionID)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetClaimsFromContext retrieves the verified token claims from request context (nil if anonymous)
+func GetClaimsFromContext(r *http.Request) *jwt.Claims {
+	if claims, ok := r.Context().Value("auth_claims").(*jwt.Claims); ok {
+		return claims
+	}
+	return nil
+}
+
+// tokenFromRequest returns the bearer token and where it came from ("header" or "cookie")
+func tokenFromRequest(r *http.Request, cookieName string) (string, string) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, token, found := strings.Cut(auth, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token), "header"
+		}
+		return "", "header"
+	}
+	if cookieName != "" {
+		if cookie, err := r.Cookie(cookieName); err == nil {
+			return cookie.Value, "cookie"
+		}
+	}
+	return "", ""
+}
+
+// verifyToken verifies token, a nil verifier (no keys) rejects every token
+func verifyToken(verifier *jwt.Verifier, token string) (*jwt.Claims, error) {
+	if verifier == nil {
+		return nil, jwt.ErrTokenUnknownKey
+	}
+	claims, err := verifier.Verify(token)
+	if err == nil && claims.Subject == "" {
+		err = jwt.ErrTokenMalformed
+	}
+	return claims, err
+}
+
+// sessionClaims resolves the session cookie to claims of its user, or returns the reason of the failure
+// Expired and unknown sessions are logged by the session manager
+func sessionClaims(sessions *session.Manager, r *http.Request) (*jwt.Claims, string) {
+	s, err := sessions.Load(r)
+	switch {
+	case err == nil:
+		return &jwt.Claims{Subject: s.UserID, Roles: s.Roles, ID: s.ID, SessionID: s.ID, ExpiresAt: s.ExpiresAt.Unix()}, ""
+	case errors.Is(err, session.ErrExpired):
+		return nil, "session_expired"
+	case errors.Is(err, session.ErrNotFound), errors.Is(err, session.ErrNoSession):
+		return nil, "invalid_session"
+	default:
+		logger.ErrorWithFields("Loading session failed", err, map[string]interface{}{
+			"request_id": GetRequestID(r),
+			"path":       r.URL.Path,
+		})
+		return nil, "session_unavailable"
+	}
+}
+
+// tokenSessionReason checks the session a JWT was issued with ("sid") and extends its idle expiry, so
+// logouts and password resets revoke the token as well, or returns the reason and error of the failure
+func tokenSessionReason(sessions *session.Manager, r *http.Request, id string) (string, error) {
+	_, err := sessions.Active(r.Context(), id)
+	switch {
+	case err == nil:
+		return "", nil
+	case errors.Is(err, session.ErrExpired):
+		return "session_expired", err
+	case errors.Is(err, session.ErrNotFound):
+		return "session_revoked", err
+	default:
+		logger.ErrorWithFields("Loading session of token failed", err, map[string]interface{}{
+			"request_id": GetRequestID(r),
+			"path":       r.URL.Path,
+		})
+		return "session_unavailable", err
+	}
+}
+
+// tokenErrorReason maps verification errors to a short reason for logs and metrics
+func tokenErrorReason(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, jwt.ErrTokenNotYetValid):
+		return "not_yet_valid"
+	case errors.Is(err, jwt.ErrTokenSignature), errors.Is(err, jwt.ErrTokenUnknownKey):
+		return "invalid_signature"
+	case errors.Is(err, jwt.ErrTokenAlgorithm):
+		return "invalid_algorithm"
+	case errors.Is(err, jwt.ErrTokenIssuer), errors.Is(err, jwt.ErrTokenAudience):
+		return "invalid_claims"
+	default:
+		return "malformed"
+	}
+}
+
+// rejectUnauthenticated logs and answers 401 (RFC 6750 WWW-Authenticate)
+// 401 responses also count as offenses for the IP block
+func rejectUnauthenticated(w http.ResponseWriter, r *http.Request, routeName, reason, source string, claims *jwt.Claims, err error) {
+	authFailuresTotal.WithLabelValues(routeName, reason).Inc()
+
+	code := ErrorCodeUnauthorized
+	challenge := `Bearer realm="api"`
+	if err != nil {
+		event := logger.EventTokenInvalid
+		if reason == "expired" {
+			event = logger.EventTokenExpired
+			code = ErrorCodeTokenExpired
+		}
+		userID := ""
+		if claims != nil {
+			userID = claims.Subject // Signature was valid, only the claim checks failed
+		}
+		logger.LogAuthEvent(event, userID, GetRequestID(r), GetClientIPFromContext(r), map[string]interface{}{
+			"route":  routeName,
+			"path":   r.URL.Path,
+			"source": source,
+			"reason": reason,
+		})
+		challenge += `, error="invalid_token"`
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+	WriteJSONError(w, r, http.StatusUnauthorized, code, "Unauthorized", "")
+}
+
+// isPublicPath reports whether path starts with one of the public path prefixes
+func isPublicPath(publicPaths []string, path string) bool {
+	for _, prefix := range publicPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ==========================================
+// SIGNING KEYS
+// ==========================================
+
+// jwtKeySources caches the remote key sets by configuration, so config reloads keep the fetched keys
+var jwtKeySources sync.Map
+
+// JWTKeySource provides verification keys and the keys to publish in the JWKS
+type JWTKeySource interface {
+	jwt.KeySource
+	jwt.PublicKeySource
+}
+
+// JWTPublicKeys returns the signing keys the gateway verifies against and publishes (JWKS):
+// the public key set of the issuing service at jwt.jwksURL (service-a serves it at jwt.JWKSPath)
+// The gateway never reads the private keys; without jwksURL only the keys from the environment are used
+func JWTPublicKeys(cfg interfaceconfig.IAuthConfig) JWTKeySource {
+	if cfg.JWT.JWKSURL == "" {
+		return jwt.StaticKeys(nil)
+	}
+
+	ttl, _ := time.ParseDuration(cfg.JWT.JWKSCacheTTL)
+	cacheKey := cfg.JWT.JWKSURL + "|" + ttl.String()
+	if cached, ok := jwtKeySources.Load(cacheKey); ok {
+		return cached.(JWTKeySource)
+	}
+
+	keySet := jwt.NewRemoteKeySet(jwt.RemoteKeySetOptions{Source: cfg.JWT.JWKSURL, CacheTTL: ttl})
+	keySet.Refresh() // Failures are logged and retried on demand
+	actual, _ := jwtKeySources.LoadOrStore(cacheKey, keySet)
+	return actual.(JWTKeySource)
+}
+
+// NewSigningKeyManager creates the key manager of the issuing service (call Run with rotate = true)
+// The config is expected to be validated with config.ValidateAuthConfig beforehand
+func NewSigningKeyManager(cfg interfaceconfig.IAuthConfig) (*jwt.KeyManager, error) {
+	opts, err := signingKeyOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.NewKeyManager(opts)
+}
+
+// signingKeyOptions converts jwt.signing, the key directory and JWT_KEY_ENCRYPTION_KEY (base64, 16/24/32 bytes)
+func signingKeyOptions(cfg interfaceconfig.IAuthConfig) (jwt.KeyManagerOptions, error) {
+	dir, err := config.JWTKeyDirPath()
+	if err != nil {
+		return jwt.KeyManagerOptions{}, err
+	}
+	rotation, _ := time.ParseDuration(cfg.JWT.Signing.RotationInterval)
+	overlap, _ := time.ParseDuration(cfg.JWT.Signing.Overlap)
+
+	opts := jwt.KeyManagerOptions{
+		Dir:              dir,
+		Algorithm:        cfg.JWT.Signing.Algorithm,
+		RotationInterval: rotation,
+		Overlap:          overlap,
+	}
+	if encoded := os.Getenv("JWT_KEY_ENCRYPTION_KEY"); encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || (len(key) != 16 && len(key) != 24 && len(key) != 32) {
+			return jwt.KeyManagerOptions{}, errors.New("JWT_KEY_ENCRYPTION_KEY must be 16, 24 or 32 bytes, base64 encoded")
+		}
+		opts.EncryptionKey = key
+	}
+	return opts, nil
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/jwt"
+	"github.com/app/shared/go/utils/security"
+	"github.com/app/shared/go/utils/session"
+)
+
+func TestAuthRejectsTokenOfRevokedSession(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwt.NewPrivateKey("test", private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := jwt.NewVerifier(jwt.VerifierOptions{Keys: jwt.StaticKeys{key}})
+
+	sessions := session.NewManager(session.Options{Store: session.NewMemoryStore(), CookieName: "authCookie", Lifetime: time.Hour})
+	s, err := sessions.Start(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", nil), "user-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	route := interfaceconfig.IServiceRoute{Name: "test", Auth: interfaceconfig.IRouteAuthConfig{Mode: interfaceconfig.AuthModeProtected}}
+	var forwardedSession string
+	handler := AuthMiddleware(verifier, "authCookie", sessions, route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedSession = r.Header.Get(HeaderSessionID)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(claims jwt.Claims) int {
+		token, err := jwt.Sign(claims, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/test/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(HeaderSessionID, "spoofed")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	if code := serve(jwt.Claims{Subject: "user-1", ExpiresAt: exp, SessionID: s.ID}); code != http.StatusNoContent {
+		t.Fatalf("status with active session = %d, want %d", code, http.StatusNoContent)
+	}
+	if forwardedSession != s.ID {
+		t.Fatalf("%s = %q, want the sid %q (logout ends it)", HeaderSessionID, forwardedSession, s.ID)
+	}
+
+	// A password reset revokes the sessions of the user, the token goes with them
+	if err := sessions.RevokeUserSessions(context.Background(), "user-1"); err != nil {
+		t.Fatal(err)
+	}
+	if code := serve(jwt.Claims{Subject: "user-1", ExpiresAt: exp, SessionID: s.ID}); code != http.StatusUnauthorized {
+		t.Fatalf("status with revoked session = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	// Tokens without session (other issuers) are not affected
+	if code := serve(jwt.Claims{Subject: "user-1", ExpiresAt: exp}); code != http.StatusNoContent {
+		t.Fatalf("status without sid = %d, want %d", code, http.StatusNoContent)
+	}
+	if forwardedSession != "" {
+		t.Fatalf("%s = %q without sid, want the client's copy removed", HeaderSessionID, forwardedSession)
+	}
+}
+
+func TestAuthTokenSlidesSessionExpiry(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwt.NewPrivateKey("test", private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := jwt.NewVerifier(jwt.VerifierOptions{Keys: jwt.StaticKeys{key}})
+
+	store := session.NewMemoryStore()
+	sessions := session.NewManager(session.Options{Store: store, CookieName: "authCookie", Lifetime: 2 * time.Hour, IdleTimeout: 30 * time.Minute})
+	login := httptest.NewRecorder()
+	s, err := sessions.Start(login, httptest.NewRequest(http.MethodPost, "/login", nil), "user-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenHash := security.HashSHA256(login.Result().Cookies()[0].Value)
+	elapse := func(d time.Duration) {
+		stored, err := store.Get(context.Background(), tokenHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored.LastSeenAt = stored.LastSeenAt.Add(-d)
+		stored.IdleExpiresAt = stored.IdleExpiresAt.Add(-d)
+		stored.ExpiresAt = stored.ExpiresAt.Add(-d)
+		store.Create(context.Background(), tokenHash, stored)
+	}
+
+	route := interfaceconfig.IServiceRoute{Name: "test", Auth: interfaceconfig.IRouteAuthConfig{Mode: interfaceconfig.AuthModeProtected}}
+	handler := AuthMiddleware(verifier, "authCookie", sessions, route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	token, err := jwt.Sign(jwt.Claims{Subject: "user-1", ExpiresAt: time.Now().Add(2 * time.Hour).Unix(), SessionID: s.ID}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/test/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A bearer client in use keeps its session past the idle timeout, like a cookie client
+	for i := 0; i < 3; i++ {
+		elapse(20 * time.Minute)
+		if code := serve(); code != http.StatusNoContent {
+			t.Fatalf("status after %dm of use = %d, want %d", 20*(i+1), code, http.StatusNoContent)
+		}
+	}
+
+	elapse(31 * time.Minute)
+	if code := serve(); code != http.StatusUnauthorized {
+		t.Fatalf("status after 31m idle = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthSessionCookieCarriesRoles(t *testing.T) {
+	sessions := session.NewManager(session.Options{Store: session.NewMemoryStore(), CookieName: "authCookie", Lifetime: time.Hour})
+	route := interfaceconfig.IServiceRoute{Name: "test", Auth: interfaceconfig.IRouteAuthConfig{Mode: interfaceconfig.AuthModeProtected, Roles: []string{"admin"}}}
+	var forwardedRoles string
+	handler := AuthMiddleware(nil, "authCookie", sessions, route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedRoles = r.Header.Get(HeaderUserRoles)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(roles []string) int {
+		login := httptest.NewRecorder()
+		if _, err := sessions.Start(login, httptest.NewRequest(http.MethodPost, "/login", nil), "user-1", roles); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/test/", nil)
+		req.AddCookie(login.Result().Cookies()[0])
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Cookie users are checked against the route's roles like token users
+	if code := serve([]string{"user", "admin"}); code != http.StatusNoContent {
+		t.Fatalf("status with role = %d, want %d", code, http.StatusNoContent)
+	}
+	if forwardedRoles != "user,admin" {
+		t.Fatalf("%s = %q, want %q", HeaderUserRoles, forwardedRoles, "user,admin")
+	}
+	if code := serve(nil); code != http.StatusForbidden {
+		t.Fatalf("status without role = %d, want %d", code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,350 @@
+package middleware
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/logger"
+)
+
+// ==========================================
+// CIRCUIT BREAKER
+// ==========================================
+// Closed: requests pass, failures are counted in a rolling window
+// Open: requests fail fast with 503 until the cool-down has passed
+// Half-open: a limited number of probe requests pass, success closes the circuit, failure reopens it
+//
+// The circuit opens after ConsecutiveFailures failures in a row or when the failure
+// ratio within Window reaches FailureRatio (once MinRequests requests were seen)
+
+// Circuit breaker defaults (used when the route config leaves a field empty)
+const (
+	defaultCircuitConsecutiveFailures = 5
+	defaultCircuitFailureRatio        = 0.5
+	defaultCircuitMinRequests         = 10
+	defaultCircuitWindow              = 60 * time.Second
+	defaultCircuitCoolDown            = 30 * time.Second
+	defaultCircuitHalfOpenMaxRequests = 1
+
+	// circuitWindowBuckets is the resolution of the rolling window
+	circuitWindowBuckets = 10
+)
+
+// ErrCircuitOpen is returned by Allow while the circuit rejects requests
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a circuit breaker
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreakerSettings is the parsed ICircuitBreakerConfig with defaults applied
+type circuitBreakerSettings struct {
+	consecutiveFailures int
+	failureRatio        float64
+	minRequests         int
+	window              time.Duration
+	coolDown            time.Duration
+	halfOpenMaxRequests int
+}
+
+func newCircuitBreakerSettings(cfg interfaceconfig.ICircuitBreakerConfig) circuitBreakerSettings {
+	s := circuitBreakerSettings{
+		consecutiveFailures: cfg.ConsecutiveFailures,
+		failureRatio:        cfg.FailureRatio,
+		minRequests:         cfg.MinRequests,
+		window:              defaultCircuitWindow,
+		coolDown:            defaultCircuitCoolDown,
+		halfOpenMaxRequests: cfg.HalfOpenMaxRequests,
+	}
+	if s.consecutiveFailures <= 0 {
+		s.consecutiveFailures = defaultCircuitConsecutiveFailures
+	}
+	if s.failureRatio <= 0 || s.failureRatio > 1 {
+		s.failureRatio = defaultCircuitFailureRatio
+	}
+	if s.minRequests <= 0 {
+		s.minRequests = defaultCircuitMinRequests
+	}
+	if d, err := time.ParseDuration(cfg.Window); err == nil && d > 0 {
+		s.window = d
+	}
+	if d, err := time.ParseDuration(cfg.CoolDown); err == nil && d > 0 {
+		s.coolDown = d
+	}
+	if s.halfOpenMaxRequests <= 0 {
+		s.halfOpenMaxRequests = defaultCircuitHalfOpenMaxRequests
+	}
+	return s
+}
+
+// circuitBucket counts the requests of one slice of the rolling window
+type circuitBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// CircuitBreaker guards a single upstream
+type CircuitBreaker struct {
+	route    string // Route name, used in logs
+	upstream string // Upstream URL, used in logs
+	settings circuitBreakerSettings
+
+	mu                sync.Mutex
+	state             CircuitState
+	generation        uint64 // Incremented on every state change, results of older generations are dropped
+	openedAt          time.Time
+	consecutive       int // Consecutive failures (closed)
+	buckets           [circuitWindowBuckets]circuitBucket
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+}
+
+// NewCircuitBreaker creates a circuit breaker for an upstream, nil if disabled in the config
+func NewCircuitBreaker(route, upstream string, cfg interfaceconfig.ICircuitBreakerConfig) *CircuitBreaker {
+	if cfg.Disabled {
+		return nil
+	}
+	return &CircuitBreaker{
+		route:    route,
+		upstream: upstream,
+		settings: newCircuitBreakerSettings(cfg),
+	}
+}
+
+// State returns the current state (an open circuit whose cool-down has passed reports half-open)
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	state, transition := cb.currentState(time.Now())
+	cb.mu.Unlock()
+
+	transition.log()
+	return state
+}
+
+// Ready reports whether Allow would currently let a request through
+// Used by the balancers to skip upstreams with an open circuit
+func (cb *CircuitBreaker) Ready() bool {
+	cb.mu.Lock()
+	state, transition := cb.currentState(time.Now())
+	ready := state == CircuitClosed ||
+		(state == CircuitHalfOpen && cb.halfOpenInFlight < cb.settings.halfOpenMaxRequests)
+	cb.mu.Unlock()
+
+	transition.log()
+	return ready
+}
+
+// Allow reserves a request slot
+// Returns the generation that must be passed to Record, or ErrCircuitOpen
+func (cb *CircuitBreaker) Allow() (uint64, error) {
+	cb.mu.Lock()
+	state, transition := cb.currentState(time.Now())
+	generation := cb.generation
+
+	var err error
+	switch state {
+	case CircuitOpen:
+		err = ErrCircuitOpen
+	case CircuitHalfOpen:
+		if cb.halfOpenInFlight >= cb.settings.halfOpenMaxRequests {
+			err = ErrCircuitOpen
+		} else {
+			cb.halfOpenInFlight++
+		}
+	}
+	cb.mu.Unlock()
+
+	transition.log()
+	return generation, err
+}
+
+// RetryAfter returns the remaining cool-down of an open circuit (0 if not open)
+func (cb *CircuitBreaker) RetryAfter() time.Duration {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != CircuitOpen {
+		return 0
+	}
+	if remaining := cb.settings.coolDown - time.Since(cb.openedAt); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Record reports the outcome of a request admitted by Allow
+// ignored: the outcome says nothing about the upstream (e.g. the client went away)
+func (cb *CircuitBreaker) Record(generation uint64, failed, ignored bool) {
+	cb.mu.Lock()
+	now := time.Now()
+	state, transition := cb.currentState(now)
+
+	// The state changed since the request was admitted
+	if generation != cb.generation {
+		cb.mu.Unlock()
+		transition.log()
+		return
+	}
+
+	switch state {
+	case CircuitClosed:
+		if ignored {
+			break
+		}
+		bucket := cb.bucket(now)
+		bucket.requests++
+		if !failed {
+			cb.consecutive = 0
+			break
+		}
+		bucket.failures++
+		cb.consecutive++
+
+		requests, failures := cb.windowCounts(now)
+		switch {
+		case cb.consecutive >= cb.settings.consecutiveFailures:
+			transition = cb.setState(CircuitOpen, now, "consecutive failures", requests, failures)
+		case requests >= cb.settings.minRequests && float64(failures)/float64(requests) >= cb.settings.failureRatio:
+			transition = cb.setState(CircuitOpen, now, "failure ratio", requests, failures)
+		}
+
+	case CircuitHalfOpen:
+		cb.halfOpenInFlight--
+		switch {
+		case ignored:
+		case failed:
+			transition = cb.setState(CircuitOpen, now, "probe failed", 0, 0)
+		default:
+			cb.halfOpenSuccesses++
+			if cb.halfOpenSuccesses >= cb.settings.halfOpenMaxRequests {
+				transition = cb.setState(CircuitClosed, now, "probe succeeded", 0, 0)
+			}
+		}
+	}
+	cb.mu.Unlock()
+
+	transition.log()
+}
+
+// currentState moves an open circuit to half-open once the cool-down has passed
+// Must be called with cb.mu held
+func (cb *CircuitBreaker) currentState(now time.Time) (CircuitState, *circuitTransition) {
+	if cb.state == CircuitOpen && now.Sub(cb.openedAt) >= cb.settings.coolDown {
+		return CircuitHalfOpen, cb.setState(CircuitHalfOpen, now, "cool-down elapsed", 0, 0)
+	}
+	return cb.state, nil
+}
+
+// setState switches the state and resets all counters
+// Must be called with cb.mu held, the returned transition is logged after unlocking
+func (cb *CircuitBreaker) setState(state CircuitState, now time.Time, reason string, requests, failures int) *circuitTransition {
+	transition := &circuitTransition{
+		route:    cb.route,
+		upstream: cb.upstream,
+		from:     cb.state,
+		to:       state,
+		reason:   reason,
+		requests: requests,
+		failures: failures,
+		coolDown: cb.settings.coolDown,
+	}
+
+	cb.state = state
+	cb.generation++
+	cb.consecutive = 0
+	cb.buckets = [circuitWindowBuckets]circuitBucket{}
+	cb.halfOpenInFlight = 0
+	cb.halfOpenSuccesses = 0
+	if state == CircuitOpen {
+		cb.openedAt = now
+	}
+
+	return transition
+}
+
+// bucket returns the window bucket for now, resetting it if it belongs to an older round
+func (cb *CircuitBreaker) bucket(now time.Time) *circuitBucket {
+	width := cb.settings.window / circuitWindowBuckets
+	start := now.Truncate(width)
+	bucket := &cb.buckets[(start.UnixNano()/int64(width))%circuitWindowBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = circuitBucket{start: start}
+	}
+	return bucket
+}
+
+// windowCounts sums the requests and failures within the rolling window
+func (cb *CircuitBreaker) windowCounts(now time.Time) (requests, failures int) {
+	for _, bucket := range cb.buckets {
+		if now.Sub(bucket.start) < cb.settings.window {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return requests, failures
+}
+
+// ==========================================
+// STATE CHANGE LOGGING
+// ==========================================
+
+type circuitTransition struct {
+	route    string
+	upstream string
+	from     CircuitState
+	to       CircuitState
+	reason   string
+	requests int
+	failures int
+	coolDown time.Duration
+}
+
+// log writes the event of a state change (nil = no change)
+func (t *circuitTransition) log() {
+	if t == nil {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"route":    t.route,
+		"upstream": t.upstream,
+		"from":     t.from.String(),
+		"to":       t.to.String(),
+		"reason":   t.reason,
+	}
+
+	switch t.to {
+	case CircuitOpen:
+		fields["cool_down"] = t.coolDown.String()
+		if t.requests > 0 {
+			fields["window_requests"] = t.requests
+			fields["window_failures"] = t.failures
+		}
+		logger.LogEvent(logger.EventCircuitBreakerOpened, fields)
+	case CircuitHalfOpen:
+		logger.LogEvent(logger.EventCircuitBreakerHalfOpen, fields)
+	case CircuitClosed:
+		logger.LogEvent(logger.EventCircuitBreakerClosed, fields)
+	}
+}
@@ -3,10 +3,12 @@
 package middleware
 
 import (
-	"net"
 	"net/http"
+	"time"
 
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
 	ip "github.com/app/shared/go/utils/ip"
+	"github.com/app/shared/go/utils/ipblock"
 	"github.com/app/shared/go/utils/logger"
 )
 
@@ -14,30 +16,20 @@ import (
 // CLOUDFLARE VALIDATION
 // ==========================================
 
-// CloudflareIPRanges contains Cloudflare's IP ranges
-// Update from: https://www.cloudflare.com/ips/
-var CloudflareIPRanges = []string{
-	// IPv4
-	"173.245.48.0/20",
-	"103.21.244.0/22",
-	"103.22.200.0/22",
-	"103.31.4.0/22",
-	"141.101.64.0/18",
-	"108.162.192.0/18",
-	"190.93.240.0/20",
-	"188.114.96.0/20",
-	"197.234.240.0/22",
-	"198.41.128.0/17",
-	"162.158.0.0/15",
-	"104.16.0.0/13",
-	"104.24.0.0/14",
-	"172.64.0.0/13",
-	"131.0.72.0/22",
-	// Add more as needed
+// Cloudflare validation modes (securityConfig.json cloudflare.mode)
+const (
+	CloudflareModeLog     = "log"     // Log and report spoofed requests, let them pass
+	CloudflareModeEnforce = "enforce" // Reject spoofed requests with 403
+)
+
+// cloudflareRefresh converts the refresh settings for ip.Cloudflare.Configure (invalid intervals load once)
+func cloudflareRefresh(cfg interfaceconfig.ICloudflareConfig) ([]string, time.Duration) {
+	interval, _ := time.ParseDuration(cfg.RefreshInterval)
+	return cfg.Sources, interval
 }
 
 // ValidateCloudflareRequest checks if request is genuinely from Cloudflare
-// Validates the connecting IP against Cloudflare's published IP ranges
+// Validates the connecting IP against Cloudflare's IP ranges (ip.Cloudflare, refreshed on a schedule)
 func ValidateCloudflareRequest(r *http.Request) bool {
 	// If no CF headers, not from Cloudflare
 	if !ip.IsCloudflareRequest(r) {
@@ -45,49 +37,45 @@ func ValidateCloudflareRequest(r *http.Request) bool {
 	}
 
 	// Get the actual connecting IP (not the client IP)
-	connectingIP := r.RemoteAddr
-	if host, _, err := net.SplitHostPort(connectingIP); err == nil {
-		connectingIP = host
-	}
-
-	ip := net.ParseIP(connectingIP)
-	if ip == nil {
+	peer, ok := ip.PeerIP(r)
+	if !ok {
 		return false
 	}
 
 	// Check if IP is in Cloudflare's ranges
-	for _, cidr := range CloudflareIPRanges {
-		_, network, err := net.ParseCIDR(cidr)
-		if err != nil {
-			continue
-		}
-		if network.Contains(ip) {
-			return true
-		}
-	}
-
-	return false
+	return ip.Cloudflare.Contains(peer)
 }
 
 // CloudflareValidationMiddleware validates requests claiming to be from Cloudflare
-func CloudflareValidationMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Only validate if request claims to be from Cloudflare
-		if ip.IsCloudflareRequest(r) && !ValidateCloudflareRequest(r) {
-			requestID := GetRequestID(r)
+// The config is expected to be validated with config.ValidateSecurityConfig beforehand
+func CloudflareValidationMiddleware(cfg interfaceconfig.ICloudflareConfig) func(http.Handler) http.Handler {
+	enforce := cfg.Mode == CloudflareModeEnforce
 
-			logger.WarnWithFields("Spoofed Cloudflare headers detected", map[string]interface{}{
-				"request_id":  requestID,
-				"remote_addr": r.RemoteAddr,
-				"cf_ray":      r.Header.Get("CF-Ray"),
-				"path":        r.URL.Path,
-			})
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Only validate if request claims to be from Cloudflare
+			if ip.IsCloudflareRequest(r) && !ValidateCloudflareRequest(r) {
+				logger.LogMiddlewareEventWithIPContext(
+					logger.EventCloudflareSpoof,
+					GetRequestID(r),
+					GetIPContextFromContext(r),
+					r.Method,
+					r.URL.Path,
+					r.UserAgent(),
+					map[string]interface{}{
+						"cf_ray":   r.Header.Get("CF-Ray"),
+						"enforced": enforce,
+					},
+				)
+				ReportOffense(r, ipblock.OffenseCloudflareSpoof)
 
-			// Optional: block the request or just log
-			// http.Error(w, "Forbidden", http.StatusForbidden)
-			// return
-		}
+				if enforce {
+					WriteJSONError(w, r, http.StatusForbidden, ErrorCodeCloudflareSpoof, "Forbidden", "")
+					return
+				}
+			}
 
-		next.ServeHTTP(w, r)
-	})
+			next.ServeHTTP(w, r)
+		})
+	}
 }
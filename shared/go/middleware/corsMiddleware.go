@@ -35,6 +35,7 @@ func CORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
 
 			// Check if origin is allowed
 			if origin != "" {
@@ -43,16 +44,25 @@ func CORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 					w.Header().Set("Access-Control-Allow-Credentials", "true")
 				} else {
 					// Log blocked CORS request
-					requestID := GetRequestID(r)
-					logger.WarnWithFields("CORS request blocked", map[string]interface{}{
-						"request_id":      requestID,
-						"method":          r.Method,
-						"path":            r.URL.Path,
-						"origin":          origin,
-						"ip":              getClientIP(r),
-						"user_agent":      r.UserAgent(),
-						"allowed_origins": allowedOrigins,
-					})
+					logger.LogMiddlewareEvent(
+						logger.EventCORSBlocked,
+						GetRequestID(r),
+						GetClientIPFromContext(r),
+						r.Method,
+						r.URL.Path,
+						r.UserAgent(),
+						map[string]interface{}{
+							"origin":          origin,
+							"allowed_origins": allowedOrigins,
+							"preflight":       preflight,
+						},
+					)
+
+					// Reject preflights, simple requests are blocked by the browser (no Allow-Origin header)
+					if preflight {
+						WriteJSONError(w, r, http.StatusForbidden, ErrorCodeCORSRejected, "Origin not allowed", "")
+						return
+					}
 				}
 			}
 
@@ -65,7 +75,7 @@ func CORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
 
 			// Set exposed headers
 			w.Header().Set("Access-Control-Expose-Headers",
-				"Link, X-Request-ID, X-RateLimit-Limit, X-RateLimit-Remaining")
+				"Link, X-Request-ID, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After")
 
 			// Set max age for preflight cache (24 hours)
 			w.Header().Set("Access-Control-Max-Age", "86400")
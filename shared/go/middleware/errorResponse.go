@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ==========================================
+// ERROR RESPONSES
+// ==========================================
+// Every error generated by the gateway itself uses the same JSON envelope:
+//
+//	{"error": {"code": "UPSTREAM_TIMEOUT", "message": "Gateway Timeout", "request_id": "...", "upstream": "service-a"}}
+//
+// code is stable and meant for clients, message is human-readable,
+// upstream is the route name (only set for proxy errors)
+
+// Error codes
+const (
+	ErrorCodeInternal          = "INTERNAL_ERROR"
+	ErrorCodeGatewayTimeout    = "GATEWAY_TIMEOUT"
+	ErrorCodeRateLimited       = "RATE_LIMITED"
+	ErrorCodeRateLimiterDown   = "RATE_LIMITER_UNAVAILABLE"
+	ErrorCodeRequestTooLarge   = "REQUEST_TOO_LARGE"
+	ErrorCodeInvalidBody       = "INVALID_REQUEST_BODY"
+	ErrorCodeMethodNotAllowed  = "METHOD_NOT_ALLOWED"
+	ErrorCodeCORSRejected      = "CORS_ORIGIN_NOT_ALLOWED"
+	ErrorCodeNoHealthyUpstream = "NO_HEALTHY_UPSTREAM"
+	ErrorCodeCircuitOpen       = "CIRCUIT_OPEN"
+	ErrorCodeIPBlocked         = "IP_BLOCKED"
+	ErrorCodeIPNotAllowed      = "IP_NOT_ALLOWED"
+	ErrorCodeCloudflareSpoof   = "CLOUDFLARE_VALIDATION_FAILED"
+	ErrorCodeUnauthorized      = "UNAUTHORIZED"
+	ErrorCodeTokenExpired      = "TOKEN_EXPIRED"
+	ErrorCodeForbidden         = "FORBIDDEN"
+	ErrorCodeInvalidRequest    = "INVALID_REQUEST"
+	ErrorCodeNotFound          = "NOT_FOUND"
+
+	ErrorCodeUpstreamRefused = "UPSTREAM_CONNECTION_REFUSED"
+	ErrorCodeUpstreamReset   = "UPSTREAM_CONNECTION_RESET"
+	ErrorCodeUpstreamDNS     = "UPSTREAM_DNS_ERROR"
+	ErrorCodeUpstreamTimeout = "UPSTREAM_TIMEOUT"
+	ErrorCodeUpstreamTLS     = "UPSTREAM_TLS_ERROR"
+	ErrorCodeUpstreamError   = "UPSTREAM_ERROR"
+)
+
+// ErrorBody is the content of the error envelope
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Upstream  string `json:"upstream,omitempty"`
+}
+
+// ErrorResponse is the JSON envelope of all gateway error responses
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// WriteJSONError writes an error response in the gateway's JSON envelope
+// upstream is the route name for proxy errors, "" otherwise
+func WriteJSONError(w http.ResponseWriter, r *http.Request, status int, code, message, upstream string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Error: ErrorBody{
+			Code:      code,
+			Message:   message,
+			RequestID: GetRequestID(r),
+			Upstream:  upstream,
+		},
+	})
+}
@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/app/shared/go/utils/logger"
 )
@@ -16,7 +19,7 @@ func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	if id := r.Context().Value("request_id"); id != nil {
 		requestID = id.(string)
 	}
-	ip := getClientIP(r)
+	ip := GetClientIPFromContext(r)
 
 	logger.InfoWithFields("Health Check", map[string]interface{}{
 		"request_id": requestID,
@@ -27,3 +30,92 @@ func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, `{"status":"OK"}`)
 }
+
+// ==========================================
+// UPSTREAM HEALTH HANDLER
+// ==========================================
+
+// Health states reported by UpstreamHealthHandler
+const (
+	healthStatusOK       = "OK"
+	healthStatusDegraded = "DEGRADED"
+	healthStatusDown     = "DOWN"
+)
+
+// routeHealth is the health report of a single route
+type routeHealth struct {
+	Name      string           `json:"name"`
+	Status    string           `json:"status"`
+	Healthy   int              `json:"healthy"`
+	Total     int              `json:"total"`
+	Upstreams []UpstreamStatus `json:"upstreams"`
+}
+
+// UpstreamHealthHandler reports the gateway status including the health of every upstream
+// Responds 503 only if no route has a healthy upstream left
+func UpstreamHealthHandler(proxies []*LoadBalancedProxy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoWithFields("Health Check", map[string]interface{}{
+			"request_id": GetRequestID(r),
+			"ip":         GetClientIPFromContext(r),
+			"path":       r.URL.Path,
+		})
+
+		routes := make([]routeHealth, 0, len(proxies))
+		routesDown := 0
+		overall := healthStatusOK
+
+		for _, proxy := range proxies {
+			report := routeHealth{
+				Name:      proxy.Name,
+				Status:    healthStatusOK,
+				Healthy:   proxy.HealthyUpstreams(),
+				Total:     len(proxy.Upstreams),
+				Upstreams: make([]UpstreamStatus, 0, len(proxy.Upstreams)),
+			}
+			for _, upstream := range proxy.Upstreams {
+				report.Upstreams = append(report.Upstreams, upstream.Status())
+			}
+
+			switch {
+			case report.Healthy == 0:
+				report.Status = healthStatusDown
+				routesDown++
+				overall = healthStatusDegraded
+			case report.Healthy < report.Total:
+				report.Status = healthStatusDegraded
+				overall = healthStatusDegraded
+			}
+			routes = append(routes, report)
+		}
+
+		statusCode := http.StatusOK
+		if len(proxies) > 0 && routesDown == len(proxies) {
+			overall = healthStatusDown
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": overall,
+			"routes": routes,
+		})
+	}
+}
+
+// UpstreamsCheck is a health check (health.CheckFunc) that fails if a route has no healthy upstream
+func UpstreamsCheck(proxies []*LoadBalancedProxy) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var down []string
+		for _, proxy := range proxies {
+			if proxy.HealthyUpstreams() == 0 {
+				down = append(down, proxy.Name)
+			}
+		}
+		if len(down) > 0 {
+			return fmt.Errorf("no healthy upstream for routes: %s", strings.Join(down, ", "))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/ipblock"
+	"github.com/app/shared/go/utils/logger"
+	"github.com/app/shared/go/utils/metrics"
+)
+
+var (
+	ipBlockRejectionsTotal = metrics.NewCounterVec(
+		"http_ip_block_rejections_total", "Requests rejected because the client IP is blocked.")
+	ipBlocksActive = metrics.NewGaugeVec(
+		"ip_blocks_active", "Client IPs currently blocked.")
+)
+
+func init() {
+	metrics.Default.OnScrape(func() {
+		ipBlocksActive.WithLabelValues().Set(float64(len(ipblock.Default.List())))
+	})
+}
+
+// ==========================================
+// IP BLOCK MIDDLEWARE
+// ==========================================
+// Rejects requests from IPs in ipblock.Default with 403 and counts 401 responses as offenses
+// Other middleware reports offenses with ReportOffense (rate limit, Cloudflare spoofing, oversized bodies)
+// Blocks survive config reloads, the settings are applied by BuildMiddlewareStack
+
+// IPBlockMiddleware rejects blocked client IPs
+func IPBlockMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP := GetClientIPFromContext(r)
+
+		if block, blocked := ipblock.Default.Blocked(clientIP); blocked {
+			ipBlockRejectionsTotal.WithLabelValues().Inc()
+			retryAfter := ceilSeconds(time.Until(block.ExpiresAt), 1)
+			w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+			WriteJSONError(w, r, http.StatusForbidden, ErrorCodeIPBlocked, "Forbidden", "")
+			return
+		}
+
+		wrapped := &statusResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(wrapped, r)
+
+		if wrapped.statusCode() == http.StatusUnauthorized {
+			ipblock.Default.Report(clientIP, ipblock.OffenseUnauthorized)
+		}
+	})
+}
+
+// ReportOffense counts an offense of the request's client IP towards a block
+func ReportOffense(r *http.Request, offense ipblock.Offense) {
+	ipblock.Default.Report(GetClientIPFromContext(r), offense)
+}
+
+// ==========================================
+// ADMIN ENDPOINT
+// ==========================================
+
+// IPBlockAdminHandler lists (GET) and lifts (DELETE ?ip=...) blocks
+// Requests must send "Authorization: Bearer <token>", an empty token rejects all requests
+func IPBlockAdminHandler(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		given := []byte(r.Header.Get("Authorization"))
+		if token == "" || subtle.ConstantTimeCompare(given, []byte("Bearer "+token)) != 1 {
+			WriteJSONError(w, r, http.StatusUnauthorized, ErrorCodeUnauthorized, "Unauthorized", "")
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"blocks": ipblock.Default.List(),
+			})
+
+		case http.MethodDelete:
+			clientIP := r.URL.Query().Get("ip")
+			if clientIP == "" {
+				WriteJSONError(w, r, http.StatusBadRequest, ErrorCodeInvalidRequest, "Query parameter ip is required", "")
+				return
+			}
+			if !ipblock.Default.Unblock(clientIP) {
+				WriteJSONError(w, r, http.StatusNotFound, ErrorCodeNotFound, "IP is not blocked", "")
+				return
+			}
+			logger.LogEvent(logger.EventRateLimitIPUnblocked, map[string]interface{}{
+				"client_ip":  clientIP,
+				"request_id": GetRequestID(r),
+				"admin_ip":   GetClientIPFromContext(r),
+			})
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			WriteJSONError(w, r, http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed, "Method Not Allowed", "")
+		}
+	}
+}
+
+// ipBlockOptions converts the ipBlock settings of securityConfig.json
+// The config is expected to be validated with config.ValidateSecurityConfig beforehand
+func ipBlockOptions(cfg interfaceconfig.IIPBlockConfig) ipblock.Options {
+	duration := func(value string) time.Duration {
+		d, _ := time.ParseDuration(value)
+		return d
+	}
+	rule := func(cfg interfaceconfig.IIPBlockRule) ipblock.Rule {
+		return ipblock.Rule{Threshold: cfg.Threshold, Window: duration(cfg.Window)}
+	}
+
+	return ipblock.Options{
+		Enabled:      cfg.Enabled,
+		BaseDuration: duration(cfg.BaseDuration),
+		MaxDuration:  duration(cfg.MaxDuration),
+		ForgetAfter:  duration(cfg.ForgetAfter),
+		Rules: map[ipblock.Offense]ipblock.Rule{
+			ipblock.OffenseRateLimit:       rule(cfg.Rules.RateLimit),
+			ipblock.OffenseUnauthorized:    rule(cfg.Rules.Unauthorized),
+			ipblock.OffenseCloudflareSpoof: rule(cfg.Rules.CloudflareSpoof),
+			ipblock.OffenseOversizedBody:   rule(cfg.Rules.OversizedBody),
+		},
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"strings"
 
 	ip "github.com/app/shared/go/utils/ip"
 	"github.com/app/shared/go/utils/logger"
@@ -15,7 +16,9 @@ import (
 // {
 //     "ip":                 "203.0.113.45",           // The final resolved IP
 //     "remote_addr":        "104.16.0.1:443",         // Connection IP (Cloudflare)
+//     "peer_trusted":       "true",                   // Connection IP is a trusted proxy?
 //     "x_forwarded_for":    "203.0.113.45, 10.0.0.1", // Proxy Chain
+//     "forwarded":          "",                       // RFC 7239 header
 //     "x_real_ip":          "203.0.113.45",           // Nginx header
 //     "cf_connecting_ip":   "203.0.113.45",           // Cloudflare header
 //     "true_client_ip":     "",                       // Enterprise CDN header
@@ -29,8 +32,8 @@ import (
 // IPExtractionMiddleware extracts and caches client IP + context
 func IPExtractionMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract IP
-		clientIP := getClientIP(r)
+		// Extract IP (forwarding headers only count from trusted proxies)
+		clientIP := ip.ClientIP(r)
 
 		// Get detailed context for debugging (cached for reuse)
 		ipContext := ip.GetClientIPWithContext(r)
@@ -57,12 +60,54 @@ func IPExtractionMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// clientIPResolver builds the client IP resolver from securityConfig.json trustedProxies
+// (expected to be validated beforehand, invalid entries are skipped)
+// Without trustedProxies only loopback is trusted
+func clientIPResolver(trustedProxies []string, useCloudflare bool) *ip.Resolver {
+	if len(trustedProxies) == 0 {
+		trustedProxies = ip.DefaultTrustedProxies
+	}
+
+	opts := ip.ResolverOptions{}
+	for _, entry := range trustedProxies {
+		if prefix, err := ip.ParsePrefix(entry); err == nil {
+			opts.TrustedProxies = append(opts.TrustedProxies, prefix)
+		}
+	}
+	if useCloudflare {
+		opts.Cloudflare = ip.Cloudflare
+	}
+
+	return ip.NewResolver(opts)
+}
+
+// TrustProxiesFromEnv sets the trusted proxies of ip.ClientIP from TRUSTED_PROXIES (comma-separated IPs or CIDRs)
+// For services behind the gateway, which have no securityConfig.json; without the variable only loopback is trusted
+func TrustProxiesFromEnv() {
+	var trustedProxies []string
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, err := ip.ParsePrefix(entry); err != nil {
+			logger.WarnWithFields("Ignoring invalid TRUSTED_PROXIES entry", map[string]interface{}{
+				"entry": entry,
+				"error": err.Error(),
+			})
+			continue
+		}
+		trustedProxies = append(trustedProxies, entry)
+	}
+	ip.SetDefaultResolver(clientIPResolver(trustedProxies, false))
+}
+
 // GetClientIPFromContext retrieves client IP from request context
 func GetClientIPFromContext(r *http.Request) string {
 	if ip := r.Context().Value("client_ip"); ip != nil {
 		return ip.(string)
 	}
-	return getClientIP(r) // Fallback
+	return ip.ClientIP(r) // Fallback
 }
 
 // GetIPContextFromContext retrieves detailed IP context from request context
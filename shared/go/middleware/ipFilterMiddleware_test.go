@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/logger"
+)
+
+func TestIPFilterMatchesPathSegments(t *testing.T) {
+	logger.Init("gateway", "test") // Rejections are logged
+	handler := IPFilterMiddleware(interfaceconfig.IIPFilterConfig{Rules: []interfaceconfig.IIPFilterRule{
+		{Name: "admin", PathPrefix: "/admin", Allow: []string{"10.0.0.0/8"}},
+	}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		path       string
+		remoteAddr string
+		status     int
+	}{
+		{"/admin", "192.0.2.1:1234", http.StatusForbidden},
+		{"/admin/ip-blocks", "192.0.2.1:1234", http.StatusForbidden},
+		{"/admin/ip-blocks", "10.1.2.3:1234", http.StatusNoContent},
+		{"/administrator", "192.0.2.1:1234", http.StatusNoContent}, // Another segment
+		{"/api/admin", "192.0.2.1:1234", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.RemoteAddr = tt.remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s from %s: status = %d, want %d", tt.path, tt.remoteAddr, rec.Code, tt.status)
+		}
+	}
+}
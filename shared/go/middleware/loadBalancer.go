@@ -0,0 +1,261 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+)
+
+// ==========================================
+// UPSTREAM
+// ==========================================
+
+// Upstream is a single backend instance of a route
+type Upstream struct {
+	URL    *url.URL
+	Weight int
+
+	active    atomic.Int64 // Requests currently in flight
+	unhealthy atomic.Bool  // Removed from rotation by the health checker
+	health    upstreamHealth
+	breaker   *CircuitBreaker // nil = no circuit breaker
+}
+
+// NewUpstream parses the target URL and creates an upstream (weight <= 0 defaults to 1)
+func NewUpstream(target string, weight int) (*Upstream, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream URL %q: %w", target, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream URL %q: scheme and host are required", target)
+	}
+	if weight <= 0 {
+		weight = 1
+	}
+	return &Upstream{URL: u, Weight: weight}, nil
+}
+
+// ActiveRequests returns the number of requests currently proxied to this upstream
+func (u *Upstream) ActiveRequests() int64 {
+	return u.active.Load()
+}
+
+// Healthy reports whether the upstream is in rotation
+func (u *Upstream) Healthy() bool {
+	return !u.unhealthy.Load()
+}
+
+// SetCircuitBreaker attaches a circuit breaker (nil = none)
+// Must be called before the upstream receives requests
+func (u *Upstream) SetCircuitBreaker(cb *CircuitBreaker) {
+	u.breaker = cb
+}
+
+// CircuitBreaker returns the upstream's circuit breaker, nil if none is attached
+func (u *Upstream) CircuitBreaker() *CircuitBreaker {
+	return u.breaker
+}
+
+// available reports whether the upstream can take a request (healthy and circuit not open)
+func (u *Upstream) available() bool {
+	return u.Healthy() && (u.breaker == nil || u.breaker.Ready())
+}
+
+// acquire/release track in-flight requests (used by least-connections)
+func (u *Upstream) acquire() { u.active.Add(1) }
+func (u *Upstream) release() { u.active.Add(-1) }
+
+// ==========================================
+// BALANCER
+// ==========================================
+
+// Balancer selects an upstream for a request
+// Implementations must be safe for concurrent use
+type Balancer interface {
+	// Next returns an available upstream for the request (healthy, circuit not open), nil if none is left
+	Next(r *http.Request) *Upstream
+}
+
+// NewBalancer creates the balancer for the given strategy (see interfaceconfig.LoadBalancing*)
+// hashKey is only used by consistent-hash: "ip" (default) or "header:<Name>"
+func NewBalancer(strategy, hashKey string, upstreams []*Upstream) (Balancer, error) {
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("load balancer needs at least one upstream")
+	}
+
+	switch strategy {
+	case "", interfaceconfig.LoadBalancingRoundRobin:
+		return &roundRobinBalancer{upstreams: upstreams}, nil
+	case interfaceconfig.LoadBalancingLeastConnections:
+		return &leastConnectionsBalancer{upstreams: upstreams}, nil
+	case interfaceconfig.LoadBalancingWeighted:
+		return newWeightedBalancer(upstreams), nil
+	case interfaceconfig.LoadBalancingConsistentHash:
+		return newConsistentHashBalancer(upstreams, hashKey), nil
+	default:
+		return nil, fmt.Errorf("unknown load balancing strategy %q", strategy)
+	}
+}
+
+// ==========================================
+// ROUND ROBIN
+// ==========================================
+
+type roundRobinBalancer struct {
+	upstreams []*Upstream
+	counter   atomic.Uint64
+}
+
+func (b *roundRobinBalancer) Next(r *http.Request) *Upstream {
+	count := uint64(len(b.upstreams))
+	n := b.counter.Add(1) - 1
+
+	// Skip unavailable upstreams
+	for i := uint64(0); i < count; i++ {
+		if candidate := b.upstreams[(n+i)%count]; candidate.available() {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// ==========================================
+// LEAST CONNECTIONS
+// ==========================================
+
+type leastConnectionsBalancer struct {
+	upstreams []*Upstream
+	counter   atomic.Uint64 // Rotates the start index so ties are spread evenly
+}
+
+func (b *leastConnectionsBalancer) Next(r *http.Request) *Upstream {
+	count := len(b.upstreams)
+	start := int((b.counter.Add(1) - 1) % uint64(count))
+
+	var best *Upstream
+	for i := 0; i < count; i++ {
+		candidate := b.upstreams[(start+i)%count]
+		if !candidate.available() {
+			continue
+		}
+		if best == nil || candidate.ActiveRequests() < best.ActiveRequests() {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// ==========================================
+// WEIGHTED (smooth weighted round robin, as used by nginx)
+// ==========================================
+
+type weightedBalancer struct {
+	upstreams []*Upstream
+	current   []int // Current weight per upstream
+	mu        sync.Mutex
+}
+
+func newWeightedBalancer(upstreams []*Upstream) *weightedBalancer {
+	return &weightedBalancer{
+		upstreams: upstreams,
+		current:   make([]int, len(upstreams)),
+	}
+}
+
+func (b *weightedBalancer) Next(r *http.Request) *Upstream {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Only available upstreams take part, so the total is computed per selection
+	best, total := -1, 0
+	for i, u := range b.upstreams {
+		if !u.available() {
+			continue
+		}
+		b.current[i] += u.Weight
+		total += u.Weight
+		if best == -1 || b.current[i] > b.current[best] {
+			best = i
+		}
+	}
+	if best == -1 {
+		return nil
+	}
+	b.current[best] -= total
+
+	return b.upstreams[best]
+}
+
+// ==========================================
+// CONSISTENT HASH
+// ==========================================
+
+// virtualNodesPerWeight controls how evenly keys are spread over the ring
+const virtualNodesPerWeight = 100
+
+type ringNode struct {
+	hash     uint32
+	upstream *Upstream
+}
+
+type consistentHashBalancer struct {
+	ring       []ringNode // Sorted by hash, immutable after creation
+	headerName string     // Empty = hash on client IP
+}
+
+func newConsistentHashBalancer(upstreams []*Upstream, hashKey string) *consistentHashBalancer {
+	b := &consistentHashBalancer{}
+	if strings.HasPrefix(hashKey, "header:") {
+		b.headerName = strings.TrimPrefix(hashKey, "header:")
+	}
+
+	for _, u := range upstreams {
+		for i := 0; i < u.Weight*virtualNodesPerWeight; i++ {
+			b.ring = append(b.ring, ringNode{
+				hash:     hashString(u.URL.String() + "#" + strconv.Itoa(i)),
+				upstream: u,
+			})
+		}
+	}
+	sort.Slice(b.ring, func(i, j int) bool { return b.ring[i].hash < b.ring[j].hash })
+
+	return b
+}
+
+func (b *consistentHashBalancer) Next(r *http.Request) *Upstream {
+	key := ""
+	if b.headerName != "" {
+		key = r.Header.Get(b.headerName)
+	}
+	if key == "" {
+		key = GetClientIPFromContext(r)
+	}
+
+	hash := hashString(key)
+	start := sort.Search(len(b.ring), func(i int) bool { return b.ring[i].hash >= hash })
+
+	// Walk clockwise (with wrap around) to the first available upstream
+	for i := 0; i < len(b.ring); i++ {
+		if node := b.ring[(start+i)%len(b.ring)]; node.upstream.available() {
+			return node.upstream
+		}
+	}
+	return nil
+}
+
+// hashString hashes a key for the consistent hash ring
+// SHA-256 spreads similar keys ("host#1", "host#2") evenly over the ring
+func hashString(key string) uint32 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
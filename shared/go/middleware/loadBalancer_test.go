@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+)
+
+// testBackend is an upstream that answers with its index, its health endpoint can be failed
+type testBackend struct {
+	*httptest.Server
+	index   int
+	failing atomic.Bool // /readyz answers 503
+	release chan struct{}
+	blocked atomic.Bool // Requests wait for release
+}
+
+// newTestBackends starts n backends, closed when the test ends
+func newTestBackends(t *testing.T, n int) []*testBackend {
+	t.Helper()
+	backends := make([]*testBackend, n)
+	for i := range backends {
+		backend := &testBackend{index: i, release: make(chan struct{})}
+		backend.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == defaultHealthCheckPath {
+				if backend.failing.Load() {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+				return
+			}
+			if backend.blocked.Load() {
+				<-backend.release
+			}
+			w.Write([]byte(strconv.Itoa(backend.index)))
+		}))
+		t.Cleanup(backend.Close)
+		backends[i] = backend
+	}
+	return backends
+}
+
+// newTestRouteProxy creates a proxy over the backends, weighted by their index + 1
+func newTestRouteProxy(t *testing.T, backends []*testBackend, strategy, hashKey string, healthCheck interfaceconfig.IHealthCheckConfig) *LoadBalancedProxy {
+	t.Helper()
+	targets := make([]interfaceconfig.IRouteTarget, len(backends))
+	for i, backend := range backends {
+		targets[i] = interfaceconfig.IRouteTarget{URL: backend.URL, Weight: i + 1}
+	}
+	proxy, err := NewRouteProxy(interfaceconfig.IServiceRoute{
+		Name:           "test",
+		Prefix:         "/api/test/",
+		Targets:        targets,
+		LoadBalancing:  interfaceconfig.ILoadBalancingConfig{Strategy: strategy, HashKey: hashKey},
+		HealthCheck:    healthCheck,
+		CircuitBreaker: interfaceconfig.ICircuitBreakerConfig{Disabled: true},
+		Retry:          interfaceconfig.IRetryConfig{Disabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proxy
+}
+
+// sendRequests proxies n requests and counts the answers per backend index (-1 = not proxied)
+func sendRequests(t *testing.T, proxy http.Handler, n int, header http.Header) map[int]int {
+	t.Helper()
+	counts := make(map[int]int)
+	for i := 0; i < n; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/test/", nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		index, err := strconv.Atoi(rec.Body.String())
+		if rec.Code != http.StatusOK || err != nil {
+			index = -1
+		}
+		counts[index]++
+	}
+	return counts
+}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	backends := newTestBackends(t, 3)
+	proxy := newTestRouteProxy(t, backends, interfaceconfig.LoadBalancingRoundRobin, "", interfaceconfig.IHealthCheckConfig{})
+
+	// Weights are ignored
+	if counts := sendRequests(t, proxy, 30, nil); counts[0] != 10 || counts[1] != 10 || counts[2] != 10 {
+		t.Fatalf("counts = %v, want 10 per backend", counts)
+	}
+
+	proxy.Upstreams[1].unhealthy.Store(true)
+	if counts := sendRequests(t, proxy, 30, nil); counts[1] != 0 || counts[0]+counts[2] != 30 {
+		t.Fatalf("counts = %v, want the unhealthy backend skipped", counts)
+	}
+}
+
+func TestWeightedBalancer(t *testing.T) {
+	backends := newTestBackends(t, 3)
+	proxy := newTestRouteProxy(t, backends, interfaceconfig.LoadBalancingWeighted, "", interfaceconfig.IHealthCheckConfig{})
+
+	// Weights 1:2:3
+	if counts := sendRequests(t, proxy, 60, nil); counts[0] != 10 || counts[1] != 20 || counts[2] != 30 {
+		t.Fatalf("counts = %v, want 10, 20 and 30", counts)
+	}
+
+	// The remaining upstreams keep their ratio
+	proxy.Upstreams[2].unhealthy.Store(true)
+	if counts := sendRequests(t, proxy, 30, nil); counts[0] != 10 || counts[1] != 20 {
+		t.Fatalf("counts = %v, want 10 and 20", counts)
+	}
+}
+
+func TestLeastConnectionsBalancer(t *testing.T) {
+	backends := newTestBackends(t, 3)
+	proxy := newTestRouteProxy(t, backends, interfaceconfig.LoadBalancingLeastConnections, "", interfaceconfig.IHealthCheckConfig{})
+
+	// Ties are spread evenly
+	if counts := sendRequests(t, proxy, 30, nil); counts[0] != 10 || counts[1] != 10 || counts[2] != 10 {
+		t.Fatalf("counts = %v, want 10 per backend", counts)
+	}
+
+	// Hold a request on the first backend
+	backends[0].blocked.Store(true)
+	unblock := sync.OnceFunc(func() { close(backends[0].release) })
+	defer unblock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sendRequests(t, proxy, 1, nil) // The rotation starts at the first upstream again
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for proxy.Upstreams[0].ActiveRequests() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("request to the first backend not in flight")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if counts := sendRequests(t, proxy, 20, nil); counts[0] != 0 || counts[1]+counts[2] != 20 {
+		t.Fatalf("counts = %v, want the busy backend avoided", counts)
+	}
+	unblock()
+	<-done
+	if n := proxy.Upstreams[0].ActiveRequests(); n != 0 {
+		t.Fatalf("ActiveRequests() = %d after the response, want 0", n)
+	}
+}
+
+func TestConsistentHashBalancer(t *testing.T) {
+	backends := newTestBackends(t, 3)
+	proxy := newTestRouteProxy(t, backends, interfaceconfig.LoadBalancingConsistentHash, "header:X-User-ID", interfaceconfig.IHealthCheckConfig{})
+
+	// Every key sticks to one backend, the keys are spread over all of them
+	owners := make(map[string]int)
+	used := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		key := "user-" + strconv.Itoa(i)
+		counts := sendRequests(t, proxy, 3, http.Header{"X-User-Id": {key}})
+		if len(counts) != 1 {
+			t.Fatalf("key %s went to %v, want one backend", key, counts)
+		}
+		for index := range counts {
+			owners[key] = index
+			used[index] = true
+		}
+	}
+	if len(used) != 3 {
+		t.Fatalf("keys went to backends %v, want all 3", used)
+	}
+
+	// Only the keys of an unhealthy backend move
+	proxy.Upstreams[0].unhealthy.Store(true)
+	for key, owner := range owners {
+		counts := sendRequests(t, proxy, 1, http.Header{"X-User-Id": {key}})
+		switch {
+		case owner == 0 && counts[0] != 0:
+			t.Fatalf("key %s stayed on the unhealthy backend", key)
+		case owner != 0 && counts[owner] != 1:
+			t.Fatalf("key %s moved from backend %d to %v", key, owner, counts)
+		}
+	}
+}
+
+func TestBalancersWithoutAvailableUpstream(t *testing.T) {
+	strategies := []string{
+		interfaceconfig.LoadBalancingRoundRobin,
+		interfaceconfig.LoadBalancingLeastConnections,
+		interfaceconfig.LoadBalancingWeighted,
+		interfaceconfig.LoadBalancingConsistentHash,
+	}
+	for _, strategy := range strategies {
+		t.Run(strategy, func(t *testing.T) {
+			backends := newTestBackends(t, 2)
+			proxy := newTestRouteProxy(t, backends, strategy, "", interfaceconfig.IHealthCheckConfig{})
+			for _, upstream := range proxy.Upstreams {
+				upstream.unhealthy.Store(true)
+			}
+
+			if upstream := proxy.balancer.Next(httptest.NewRequest(http.MethodGet, "/api/test/", nil)); upstream != nil {
+				t.Fatalf("Next() = %s, want nil", upstream.URL)
+			}
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/test/", nil))
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Fatalf("status = %d, want 503", rec.Code)
+			}
+		})
+	}
+}
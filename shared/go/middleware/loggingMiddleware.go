@@ -26,7 +26,7 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		reqLogger.InfoWithFields("Incoming request", map[string]interface{}{
 			"method":      r.Method,
 			"path":        r.URL.Path,
-			"ip":          getClientIP(r),
+			"ip":          GetClientIPFromContext(r),
 			"user_agent":  r.UserAgent(),
 			"content_len": r.ContentLength,
 		})
@@ -44,7 +44,7 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 			r.URL.Path,
 			wrapped.statusCode,
 			duration,
-			getClientIP(r),
+			GetClientIPFromContext(r),
 		)
 	})
 }
@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 
+	"github.com/app/shared/go/utils/ipblock"
 	"github.com/app/shared/go/utils/logger"
 )
 
@@ -35,7 +36,8 @@ func MaxBytesMiddleware(maxBytes int64) func(http.Handler) http.Handler {
 					},
 				)
 
-				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				ReportOffense(r, ipblock.OffenseOversizedBody)
+				WriteJSONError(w, r, http.StatusRequestEntityTooLarge, ErrorCodeRequestTooLarge, "Request body too large", "")
 				return
 			}
 
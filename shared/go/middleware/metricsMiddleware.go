@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/app/shared/go/utils/metrics"
+)
+
+// ==========================================
+// METRICS MIDDLEWARE (RED)
+// ==========================================
+// Rate, Errors and Duration per route template, method and status
+// Routes are labelled with the ServeMux pattern (e.g. "/api/service-a/"), never the raw path,
+// so the number of time series stays bounded
+
+// unmatchedRoute labels requests no route matched
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequestsTotal = metrics.NewCounterVec(
+		"http_requests_total", "Total HTTP requests.", "route", "method", "status")
+	httpRequestDuration = metrics.NewHistogramVec(
+		"http_request_duration_seconds", "HTTP request latency in seconds.", nil, "route", "method", "status")
+	httpRequestsInFlight = metrics.NewGaugeVec(
+		"http_requests_in_flight", "HTTP requests currently being served.", "route", "method")
+	rateLimitRejectionsTotal = metrics.NewCounterVec(
+		"http_rate_limit_rejections_total", "Requests rejected by the rate limiter.", "route", "policy")
+	requestTimeoutsTotal = metrics.NewCounterVec(
+		"http_request_timeouts_total", "Requests that exceeded the route timeout.", "route")
+	panicsTotal = metrics.NewCounterVec(
+		"http_panics_total", "Panics recovered by the recovery middleware.")
+	proxyErrorsTotal = metrics.NewCounterVec(
+		"gateway_proxy_errors_total", "Failed upstream requests by error type.", "route", "upstream", "type")
+)
+
+// MetricsMiddleware records request count, latency and in-flight requests
+// mux resolves the route template, with nil all requests are labelled "unmatched"
+func MetricsMiddleware(mux *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := unmatchedRoute
+			if mux != nil {
+				if _, pattern := mux.Handler(r); pattern != "" {
+					route = pattern
+				}
+			}
+			method := methodLabel(r.Method)
+
+			inFlight := httpRequestsInFlight.WithLabelValues(route, method)
+			inFlight.Inc()
+
+			start := time.Now()
+			wrapped := &statusResponseWriter{ResponseWriter: w}
+			completed := false
+
+			// Deferred so requests ending in a panic are counted as 500 before RecoveryMiddleware answers
+			defer func() {
+				inFlight.Dec()
+
+				status := wrapped.statusCode()
+				if !completed {
+					status = http.StatusInternalServerError
+				}
+				statusLabel := strconv.Itoa(status)
+				httpRequestsTotal.WithLabelValues(route, method, statusLabel).Inc()
+				httpRequestDuration.WithLabelValues(route, method, statusLabel).Observe(time.Since(start).Seconds())
+			}()
+
+			next.ServeHTTP(wrapped, r)
+			completed = true
+		})
+	}
+}
+
+// statusResponseWriter captures the status code (shared with TracingMiddleware)
+// The status is atomic because TimeoutMiddleware may answer while the handler is still running
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status atomic.Int32
+}
+
+func (rw *statusResponseWriter) WriteHeader(code int) {
+	rw.status.CompareAndSwap(0, int32(code))
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *statusResponseWriter) Write(b []byte) (int, error) {
+	rw.status.CompareAndSwap(0, http.StatusOK)
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach Flush etc. of the underlying writer (streaming proxies)
+func (rw *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// statusCode returns the written status (200 if nothing was written)
+func (rw *statusResponseWriter) statusCode() int {
+	if status := rw.status.Load(); status != 0 {
+		return int(status)
+	}
+	return http.StatusOK
+}
+
+// routeLabel returns the route template inside a route's middleware stack (set by ServeMux)
+func routeLabel(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	return unmatchedRoute
+}
+
+// methodLabel limits the method label to the standard methods
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
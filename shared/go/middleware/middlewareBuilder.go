@@ -3,25 +3,24 @@ package middleware
 import (
 	"net/http"
 	"os"
-	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/ip"
+	"github.com/app/shared/go/utils/ipblock"
 )
 
 // ==========================================
 // MIDDLEWARE STACK BUILDER
 // ==========================================
 // BuildMiddlewareStack applies all middleware in the correct order
-// Order matters: outermost (first applied) to innermost (last applied)
-func BuildMiddlewareStack(handler http.Handler) http.Handler {
-	// Define CORS whitelist
-	corsWhitelist := []string{
-		"http://localhost:3000",
-		"http://localhost:3001",
-		"http://localhost:8080",
-		"https://app.example.com",
-		"https://*.example.com",
-		"https://www.getpostman.com",
-		"https://web.postman.co",
-	}
+// Every step wraps the handler built so far, so the step applied last runs first
+// Settings come from securityConfig.json, rebuild the stack to apply changed settings
+func BuildMiddlewareStack(handler http.Handler, securityConfig interfaceconfig.ISecurityConfig) http.Handler {
+	// The router resolves route templates for the metrics labels
+	mux, _ := handler.(*http.ServeMux)
+
+	// CORS whitelist
+	corsWhitelist := securityConfig.Cors.AllowOrigins
 
 	if os.Getenv("ENVIRONMENT") == "development" {
 		corsWhitelist = []string{"*"}
@@ -30,47 +29,55 @@ func BuildMiddlewareStack(handler http.Handler) http.Handler {
 	// ==========================================
 	// MIDDLEWARE ORDER (CRITICAL!)
 	// ==========================================
-	// Apply from outermost to innermost
+	// Applied from innermost to outermost, the numbers are the order in which requests pass the steps
 
-	// 1. Recovery - MUST be first to catch ALL panics from any middleware
+	// 12. Recovery - Innermost, catches panics of the router and proxy handlers
 	handler = RecoveryMiddleware(handler)
 
-	// 2. Request ID - MUST be early so all other middlewares can use it for logging
-	handler = RequestIDMiddleware(handler)
+	// 11. Metrics - Request count, latency and in-flight requests per route template (panics count as 500)
+	handler = MetricsMiddleware(mux)(handler)
+
+	// 10. Tracing - Server span per request, continues incoming traceparent
+	handler = TracingMiddleware(mux)(handler)
+
+	// 9. IP Filter - Allow/deny lists per path prefix (needs the client IP)
+	handler = IPFilterMiddleware(securityConfig.IPFilter)(handler)
+
+	// 8. IP Block - Rejects blocked client IPs right after the IP is resolved, counts 401 responses
+	ipblock.Default.Configure(ipBlockOptions(securityConfig.IPBlock))
+	handler = IPBlockMiddleware(handler)
 
-	// 3. IP Extraction (EINMAL früh extrahieren)
+	// 7. IP Extraction (EINMAL extrahieren)
+	//    Forwarding headers only count from trusted proxies (and Cloudflare if enabled)
+	ip.SetDefaultResolver(clientIPResolver(securityConfig.TrustedProxies, os.Getenv("USE_CLOUDFLARE") == "true"))
 	handler = IPExtractionMiddleware(handler)
 
-	// 4. Cloudflare Validation (optional, nur wenn du Cloudflare nutzt)
+	// 6. Cloudflare Validation (optional, nur wenn du Cloudflare nutzt)
+	//    Ranges are refreshed from cloudflare.sources, the last known good ranges stay in use on failures
 	if os.Getenv("USE_CLOUDFLARE") == "true" {
-		handler = CloudflareValidationMiddleware(handler)
+		ip.Cloudflare.Configure(cloudflareRefresh(securityConfig.Cloudflare))
+		handler = CloudflareValidationMiddleware(securityConfig.Cloudflare)(handler)
+	} else {
+		ip.Cloudflare.Stop()
 	}
 
-	// 5. Timeout - Limit total request time (after RequestID so timeouts are logged with ID)
-	handler = TimeoutMiddleware(handler, 10*time.Second)
-
-	// 6. Max Request Size - Reject large requests early
-	handler = MaxBytesMiddleware(10 * 1024 * 1024)(handler) // 10MB
+	// 5. Max Request Size - Limits the body read by everything after it
+	handler = MaxBytesMiddleware(securityConfig.MaxBodyBytes)(handler)
 
-	// 7. CORS - Handle preflight requests early
+	// 4. CORS - Answers preflight requests
 	handler = CORSMiddleware(corsWhitelist)(handler)
 
-	// 8. Security Headers - Always set security headers
+	// 3. Security Headers - Set on every response, including rejections of later steps
 	handler = SecurityHeadersMiddleware(handler)
 
-	// 9. Rate Limiting - Protect against abuse (per IP)
-	if os.Getenv("ENVIRONMENT") == "production" {
-		handler = RateLimitMiddleware(100, 200)(handler) // 100 req/s per IP, burst 200
-	} else {
-		handler = RateLimitMiddleware(1000, 2000)(handler)
-	}
+	// 2. Logging - Captures the final response status/size
+	handler = LoggingMiddleware(handler)
 
-	// 10. Compression - Compress responses
-	handler = CompressionMiddleware(handler)
+	// 1. Request ID - Outermost, so every log line and every rejection (CORS, 413, 403) carries the ID
+	handler = RequestIDMiddleware(handler)
 
-	// 11. Logging - Log requests/responses (uses RequestID from context)
-	//    Should be relatively late so it captures final response status/size
-	handler = LoggingMiddleware(handler)
+	// Timeout, Rate Limiting and Compression are configured per route
+	// (see BuildRouteMiddlewareStack in routeMiddleware.go)
 
 	// Business Logic (Router/Proxy) comes after all middleware
 
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/logger"
+)
+
+func TestEarlyRejectionsCarryRequestID(t *testing.T) {
+	logger.Init("gateway", "test") // LoggingMiddleware needs a request logger
+	handler := BuildMiddlewareStack(http.NewServeMux(), interfaceconfig.ISecurityConfig{MaxBodyBytes: 10})
+
+	// Rejected by MaxBytes before the request reaches the router
+	req := httptest.NewRequest(http.MethodPost, "/api/service-a/", strings.NewReader(strings.Repeat("x", 100)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", rec.Code)
+	}
+	var body struct {
+		Error struct {
+			RequestID string `json:"request_id"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.RequestID == "" || body.Error.RequestID != rec.Header().Get("X-Request-ID") {
+		t.Fatalf("request_id = %q, want the X-Request-ID %q", body.Error.RequestID, rec.Header().Get("X-Request-ID"))
+	}
+}
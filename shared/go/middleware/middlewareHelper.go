@@ -13,29 +13,6 @@ func GetRequestID(r *http.Request) string {
 	return ""
 }
 
-// getClientIP extracts the real client IP from request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (common with proxies/load balancers)
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip != "" {
-		return strings.Split(ip, ",")[0]
-	}
-
-	// Check X-Real-IP header
-	ip = r.Header.Get("X-Real-IP")
-	if ip != "" {
-		return ip
-	}
-
-	// Fallback to RemoteAddr
-	ip = r.RemoteAddr
-	if colon := strings.LastIndex(ip, ":"); colon != -1 {
-		ip = ip[:colon]
-	}
-
-	return ip
-}
-
 // isOriginAllowed checks if the origin is in the whitelist
 func isOriginAllowed(origin string, allowedOrigins []string) bool {
 	if origin == "" {
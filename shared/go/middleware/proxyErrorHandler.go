@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/app/shared/go/utils/ipblock"
+	"github.com/app/shared/go/utils/logger"
+)
+
+// ==========================================
+// PROXY ERROR HANDLER
+// ==========================================
+// Classifies failed upstream requests, logs a matching MW-PRX event
+// and answers with the gateway's JSON error envelope
+
+// proxyError is the classification of a failed upstream request
+type proxyError struct {
+	event   logger.ILogEvent
+	status  int
+	code    string
+	message string
+	errType string // Logged as "error_type"
+}
+
+// classifyProxyError maps a transport error to status, error code and log event
+func classifyProxyError(err error) proxyError {
+	var (
+		dnsErr       *net.DNSError
+		netErr       net.Error
+		maxBytesErr  *http.MaxBytesError
+		certErr      *tls.CertificateVerificationError
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return proxyError{logger.EventProxyClientClosed, 499, ErrorCodeUpstreamError, "Client Closed Request", "client_closed"}
+	case errors.As(err, &maxBytesErr):
+		return proxyError{logger.EventRequestTooLarge, http.StatusRequestEntityTooLarge, ErrorCodeRequestTooLarge, "Request Entity Too Large", "body_too_large"}
+	case errors.As(err, &dnsErr):
+		return proxyError{logger.EventProxyDNSFailed, http.StatusBadGateway, ErrorCodeUpstreamDNS, "Bad Gateway", "dns"}
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return proxyError{logger.EventProxyConnectionFailed, http.StatusBadGateway, ErrorCodeUpstreamRefused, "Bad Gateway", "connection_refused"}
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return proxyError{logger.EventProxyConnectionFailed, http.StatusBadGateway, ErrorCodeUpstreamReset, "Bad Gateway", "connection_reset"}
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &alertErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return proxyError{logger.EventProxyTLSFailed, http.StatusBadGateway, ErrorCodeUpstreamTLS, "Bad Gateway", "tls"}
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return proxyError{logger.EventProxyUpstreamTimeout, http.StatusGatewayTimeout, ErrorCodeUpstreamTimeout, "Gateway Timeout", "timeout"}
+	default:
+		return proxyError{logger.EventProxyRequestFailed, http.StatusBadGateway, ErrorCodeUpstreamError, "Bad Gateway", "other"}
+	}
+}
+
+// errorHandler handles failed upstream requests
+// Counts the failure for passive health checking, logs it and responds with the JSON error envelope
+func (p *LoadBalancedProxy) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	attempt := r.Context().Value("proxy_attempt").(*proxyAttempt)
+	attempt.err = err
+	upstream := attempt.upstream
+
+	// 502/503/504 response discarded by ModifyResponse, the request is retried
+	if errors.Is(err, errRetryableStatus) {
+		attempt.discarded = true
+		logger.WarnWithFields("Retrying proxied request", map[string]interface{}{
+			"request_id":  GetRequestID(r),
+			"route":       p.Name,
+			"upstream":    upstream.URL.String(),
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      attempt.statusCode,
+			"retry_count": attempt.retries,
+		})
+		return
+	}
+
+	classified := classifyProxyError(err)
+	proxyErrorsTotal.WithLabelValues(p.Name, upstream.URL.Host, classified.errType).Inc()
+
+	// Client went away or the route timeout hit - not the upstream's fault
+	ctxErr := r.Context().Err()
+	if ctxErr == nil && classified.errType != "body_too_large" {
+		p.recordHealth(upstream, err, "passive")
+	}
+	if classified.errType == "body_too_large" {
+		ReportOffense(r, ipblock.OffenseOversizedBody)
+	}
+
+	// Retry transport errors unless the request context is done
+	retrying := attempt.retry && ctxErr == nil && classified.errType != "body_too_large"
+
+	logger.LogMiddlewareEventWithIPContext(
+		classified.event,
+		GetRequestID(r),
+		GetIPContextFromContext(r),
+		r.Method,
+		r.URL.Path,
+		r.UserAgent(),
+		map[string]interface{}{
+			"route":       p.Name,
+			"upstream":    upstream.URL.String(),
+			"error":       err.Error(),
+			"error_type":  classified.errType,
+			"status":      classified.status,
+			"retry_count": attempt.retries,
+			"retrying":    retrying,
+		},
+	)
+
+	if retrying {
+		attempt.discarded = true
+		return
+	}
+
+	// Nobody to answer (client gone) or TimeoutMiddleware already sent the 504
+	if ctxErr != nil {
+		return
+	}
+
+	WriteJSONError(w, r, classified.status, classified.code, classified.message, p.Name)
+}
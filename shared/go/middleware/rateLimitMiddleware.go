@@ -3,62 +3,87 @@
 package middleware
 
 import (
-	"fmt"
+	"context"
+	"math"
 	"net/http"
-	"sync"
+	"slices"
+	"strconv"
+	"time"
 
+	"github.com/app/shared/go/utils/ipblock"
 	"github.com/app/shared/go/utils/logger"
-	"golang.org/x/time/rate"
+	"github.com/app/shared/go/utils/ratelimit"
 )
 
 // ==========================================
-// RATE LIMITING MIDDLEWARE (PER IP)
+// RATE LIMITING MIDDLEWARE
 // ==========================================
+// Buckets live in a ratelimit.Store: in memory (per replica) or in Redis (shared by all replicas)
+// See rateLimitStore.go for the store selection and rateLimitPolicy.go for the rules
 
-type ipRateLimiter struct {
-	ips map[string]*rate.Limiter
-	mu  *sync.RWMutex
-	r   rate.Limit
-	b   int
-}
-
-func newIPRateLimiter(r rate.Limit, b int) *ipRateLimiter {
-	return &ipRateLimiter{
-		ips: make(map[string]*rate.Limiter),
-		mu:  &sync.RWMutex{},
-		r:   r,
-		b:   b,
-	}
-}
+// RateLimitMiddleware takes a token from the bucket of every policy matching the request
+// The request is rejected with 429 if any bucket is empty, the tokens taken by the other policies are refunded
+// failOpen allows requests while the store is unreachable
+// Responses carry X-RateLimit-Limit/Remaining/Reset of the most restrictive policy
+// A second RateLimitMiddleware further down the chain (e.g. after Auth) continues with the state of the first,
+// so refunds and the reported policy cover both
+func RateLimitMiddleware(store ratelimit.Store, policies []RateLimitPolicy, failOpen bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var state rateLimitState
+			if earlier, ok := r.Context().Value("rate_limit_state").(*rateLimitState); ok {
+				state = *earlier
+				state.taken = slices.Clone(earlier.taken)
+			}
+			reported, reportedBy, taken := state.reported, state.reportedBy, state.taken
 
-func (i *ipRateLimiter) getLimiter(ip string) *rate.Limiter {
-	i.mu.Lock()
-	defer i.mu.Unlock()
+			for i := range policies {
+				policy := &policies[i]
+				if !policy.Matches(r) {
+					continue
+				}
+				key, ok := policy.BucketKey(r)
+				if !ok {
+					continue
+				}
 
-	limiter, exists := i.ips[ip]
-	if !exists {
-		limiter = rate.NewLimiter(i.r, i.b)
-		i.ips[ip] = limiter
-	}
+				decision, err := store.Allow(r.Context(), key, policy.Limit)
+				if err != nil {
+					// Store outage is logged by the store (MW-RL-004/005)
+					if failOpen {
+						continue
+					}
+					refundTokens(r, store, taken)
+					rateLimitRejectionsTotal.WithLabelValues(routeLabel(r), policy.Name).Inc()
+					w.Header().Set("Retry-After", "1")
+					WriteJSONError(w, r, http.StatusServiceUnavailable, ErrorCodeRateLimiterDown, "Service Unavailable", "")
+					return
+				}
 
-	return limiter
-}
+				if reportedBy == nil || moreRestrictive(decision, reported) {
+					reported, reportedBy = decision, policy
+				}
+				if !decision.Allowed {
+					// Do not spend tokens of the remaining policies on a rejected request,
+					// and give back those the earlier ones took
+					refundTokens(r, store, taken)
+					break
+				}
+				taken = append(taken, takenToken{key: key, limit: policy.Limit})
+			}
 
-// Global rate limiter instance
-var limiter *ipRateLimiter
+			if reportedBy == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			state = rateLimitState{reported: reported, reportedBy: reportedBy, taken: taken}
 
-// RateLimitMiddleware limits requests per IP address
-// requestsPerSecond: maximum requests allowed per second per IP
-// burst: maximum burst size (allows temporary spikes)
-func RateLimitMiddleware(requestsPerSecond float64, burst int) func(http.Handler) http.Handler {
-	limiter = newIPRateLimiter(rate.Limit(requestsPerSecond), burst)
+			setRateLimitHeaders(w, reportedBy, reported)
 
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := GetClientIPFromContext(r)
-			ipLimiter := limiter.getLimiter(ip)
+			if !reported.Allowed {
+				rateLimitRejectionsTotal.WithLabelValues(routeLabel(r), reportedBy.Name).Inc()
+				ReportOffense(r, ipblock.OffenseRateLimit)
 
-			if !ipLimiter.Allow() {
 				// ✅ Mit IP-Context für Security-Monitoring
 				logger.LogMiddlewareEventWithIPContext(
 					logger.EventRateLimitExceeded,
@@ -68,19 +93,64 @@ func RateLimitMiddleware(requestsPerSecond float64, burst int) func(http.Handler
 					r.URL.Path,
 					r.UserAgent(),
 					map[string]interface{}{
-						"rate_limit": requestsPerSecond,
-						"burst":      burst,
+						"policy":      reportedBy.Name,
+						"policy_key":  reportedBy.Key,
+						"rate_limit":  reportedBy.Limit.Rate,
+						"burst":       reportedBy.Limit.Burst,
+						"retry_after": reported.RetryAfter.String(),
 					},
 				)
 
-				w.Header().Set("Retry-After", "1")
-				w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%.0f", requestsPerSecond))
-				w.Header().Set("X-RateLimit-Remaining", "0")
-				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				w.Header().Set("Retry-After", strconv.FormatInt(ceilSeconds(reported.RetryAfter, 1), 10))
+				WriteJSONError(w, r, http.StatusTooManyRequests, ErrorCodeRateLimited, "Too Many Requests", "")
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "rate_limit_state", &state)))
 		})
 	}
 }
+
+// rateLimitState is the outcome of a RateLimitMiddleware, passed on to a later one in the request context
+type rateLimitState struct {
+	reported   ratelimit.Decision
+	reportedBy *RateLimitPolicy
+	taken      []takenToken
+}
+
+// takenToken is a token taken from a bucket for the current request
+type takenToken struct {
+	key   string
+	limit ratelimit.Limit
+}
+
+// refundTokens returns the taken tokens of a rejected request
+// Best effort: a store outage is logged by the store, the tokens then stay spent
+func refundTokens(r *http.Request, store ratelimit.Store, taken []takenToken) {
+	for _, token := range taken {
+		store.Refund(r.Context(), token.key, token.limit)
+	}
+}
+
+// moreRestrictive reports whether a should be reported instead of b:
+// a rejection wins, otherwise the lowest share of remaining tokens
+func moreRestrictive(a, b ratelimit.Decision) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	return float64(a.Remaining)/float64(max(a.Limit, 1)) < float64(b.Remaining)/float64(max(b.Limit, 1))
+}
+
+// setRateLimitHeaders writes the limiter state of a policy's decision
+// X-RateLimit-Limit is the configured quota (not the burst), X-RateLimit-Reset is the number of
+// seconds until the bucket is full again
+func setRateLimitHeaders(w http.ResponseWriter, policy *RateLimitPolicy, decision ratelimit.Decision) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(policy.Quota))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(min(decision.Remaining, policy.Quota)))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(decision.ResetAfter, 0), 10))
+}
+
+// ceilSeconds rounds d up to whole seconds, at least minimum
+func ceilSeconds(d time.Duration, minimum int64) int64 {
+	return max(int64(math.Ceil(d.Seconds())), minimum)
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/jwt"
+	"github.com/app/shared/go/utils/ratelimit"
+	"github.com/app/shared/go/utils/redis"
+)
+
+func TestRateLimitRefundsEarlierPolicies(t *testing.T) {
+	store := ratelimit.NewMemoryStore(ratelimit.MemoryOptions{})
+	defer store.Close()
+	slow := ratelimit.Limit{Rate: 0.001, Burst: 10} // No refill during the test
+	policies := []RateLimitPolicy{
+		{Name: "default", Key: RateLimitKeyRoute, Route: "test", Scope: "route:test", Limit: slow, Quota: 10},
+		{Name: "login", PathPrefix: "/api/login", Key: RateLimitKeyRoute, Route: "test", Scope: "policy:login", Limit: ratelimit.Limit{Rate: 0.001, Burst: 1}, Quota: 1},
+	}
+	handler := RateLimitMiddleware(store, policies, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec
+	}
+
+	if rec := serve("/api/login"); rec.Code != http.StatusNoContent {
+		t.Fatalf("first login status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if rec := serve("/api/login"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second login status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	// The rejected login gave its default token back: 10 - 1 (first login) - 1 (this request)
+	rec := serve("/api/other")
+	if got := rec.Header().Get("X-RateLimit-Remaining"); got != "8" {
+		t.Fatalf("X-RateLimit-Remaining = %s, want 8", got)
+	}
+}
+
+func TestRouteStackRateLimitsBeforeAuth(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwt.NewPrivateKey("test", private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := jwt.NewVerifier(jwt.VerifierOptions{Keys: jwt.StaticKeys{key}})
+	store := ratelimit.NewMemoryStore(ratelimit.MemoryOptions{})
+	defer store.Close()
+
+	// 2 requests per IP, 1 per user, no refill during the test
+	securityConfig := interfaceconfig.ISecurityConfig{RateLimit: interfaceconfig.IRateLimitConfig{
+		Development: interfaceconfig.IRateLimitSettings{RequestsPerSecond: 0.001, Burst: 2},
+		Production:  interfaceconfig.IRateLimitSettings{RequestsPerSecond: 0.001, Burst: 2},
+		Policies:    []interfaceconfig.IRateLimitPolicy{{Name: "per-user", PathPrefix: "/api/test", Key: RateLimitKeyUser, Limit: 1, Window: "1h"}},
+	}}
+	route := interfaceconfig.IServiceRoute{Name: "test", Prefix: "/api/test", Auth: interfaceconfig.IRouteAuthConfig{Mode: interfaceconfig.AuthModeProtected}}
+	handler := BuildRouteMiddlewareStack(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), route, securityConfig, store, verifier, nil, interfaceconfig.IAuthConfig{})
+	serve := func(remoteAddr, userID string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/test/", nil)
+		req.RemoteAddr = remoteAddr
+		if userID != "" {
+			token, err := jwt.Sign(jwt.Claims{Subject: userID, ExpiresAt: time.Now().Add(time.Hour).Unix()}, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Requests without credentials use up the per-IP bucket before Auth rejects them
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if code := serve("192.0.2.1:1234", ""); code != want {
+			t.Fatalf("anonymous request %d status = %d, want %d", i+1, code, want)
+		}
+	}
+
+	// The user policy still applies after Auth
+	if code := serve("192.0.2.2:1234", "user-1"); code != http.StatusNoContent {
+		t.Fatalf("first user-1 request status = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := serve("192.0.2.2:1234", "user-1"); code != http.StatusTooManyRequests {
+		t.Fatalf("second user-1 request status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	// The rejected request gave its per-IP token back, another user of the IP gets through
+	if code := serve("192.0.2.2:1234", "user-2"); code != http.StatusNoContent {
+		t.Fatalf("user-2 request status = %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestRateLimitHeadersReportConfiguredLimit(t *testing.T) {
+	store := ratelimit.NewMemoryStore(ratelimit.MemoryOptions{})
+	defer store.Close()
+	// 5 requests per minute with a burst of 20
+	policies := []RateLimitPolicy{{Name: "api", Key: RateLimitKeyRoute, Route: "test", Scope: "policy:api", Limit: ratelimit.Limit{Rate: 5.0 / 60, Burst: 20}, Quota: 5}}
+	handler := RateLimitMiddleware(store, policies, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/test", nil))
+	if got := rec.Header().Get("X-RateLimit-Limit"); got != "5" {
+		t.Fatalf("X-RateLimit-Limit = %s, want the configured limit 5", got)
+	}
+	if got := rec.Header().Get("X-RateLimit-Remaining"); got != "5" {
+		t.Fatalf("X-RateLimit-Remaining = %s, want at most the limit", got)
+	}
+}
+
+func TestRateLimitStoreUnreachable(t *testing.T) {
+	// A Redis store nothing listens for
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	client := redis.NewClient(redis.Options{Addr: addr, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	store := ratelimit.NewRedisStore(client, DefaultRateLimitKeyPrefix, 100*time.Millisecond)
+
+	policies := []RateLimitPolicy{{Name: "default", Key: RateLimitKeyRoute, Route: "test", Scope: "route:test", Limit: ratelimit.Limit{Rate: 1, Burst: 1}}}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name     string
+		failOpen bool
+		status   int
+	}{
+		{"fail open passes requests", true, http.StatusNoContent},
+		{"fail closed rejects requests", false, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			RateLimitMiddleware(store, policies, tt.failOpen)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/test/", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.failOpen {
+				return
+			}
+			if rec.Header().Get("Retry-After") == "" || !strings.Contains(rec.Body.String(), ErrorCodeRateLimiterDown) {
+				t.Fatalf("response %v %s, want Retry-After and %s", rec.Header(), rec.Body, ErrorCodeRateLimiterDown)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/ratelimit"
+)
+
+// Keys a rate limit policy can count by
+const (
+	RateLimitKeyIP     = "ip"     // Client IP (from IPExtractionMiddleware)
+	RateLimitKeyUser   = "user"   // Authenticated user ID ("user_id" in the request context)
+	RateLimitKeyAPIKey = "apiKey" // API key header (hashed, the key itself is never stored)
+	RateLimitKeyHeader = "header" // Value of any request header
+	RateLimitKeyRoute  = "route"  // One bucket for the route, shared by all clients
+)
+
+// DefaultAPIKeyHeader is read for policies with key "apiKey" and no header set
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// ==========================================
+// RATE LIMIT POLICIES
+// ==========================================
+// A route stack evaluates its default per-IP rule (rateLimit.production/development)
+// plus every policy from securityConfig.json whose pathPrefix and methods match the request.
+// Policies counting by user, API key or header are skipped when the request has no such value.
+// Policies counting by user are evaluated after Auth, all others before it.
+// pathPrefix matches whole path segments: "/api/login" matches "/api/login/otp" but not "/api/loginx"
+
+// RateLimitPolicy is one rate limit rule
+type RateLimitPolicy struct {
+	Name       string
+	PathPrefix string          // Empty = all paths
+	Methods    map[string]bool // Empty = all methods
+	Key        string          // One of the RateLimitKey* constants
+	Header     string          // For RateLimitKeyHeader and RateLimitKeyAPIKey
+	Limit      ratelimit.Limit
+	Quota      int    // Requests per window (per second for the default rule), reported as X-RateLimit-Limit
+	Scope      string // Bucket key prefix
+	Route      string // Route name (value of RateLimitKeyRoute)
+}
+
+// BuildRateLimitPolicies returns the rules for a route: the default per-IP rule first,
+// then the configured policies that can match requests of the route
+// The config is expected to be validated with config.ValidateSecurityConfig beforehand
+func BuildRateLimitPolicies(route interfaceconfig.IServiceRoute, securityConfig interfaceconfig.ISecurityConfig) []RateLimitPolicy {
+	policies := []RateLimitPolicy{DefaultRateLimitPolicy(route.Name, securityConfig)}
+
+	for _, cfg := range securityConfig.RateLimit.Policies {
+		// Skip policies for paths this route never sees
+		if !pathHasPrefix(cfg.PathPrefix, route.Prefix) && !pathHasPrefix(route.Prefix, cfg.PathPrefix) {
+			continue
+		}
+
+		window, _ := time.ParseDuration(cfg.Window)
+		burst := cfg.Burst
+		if burst == 0 {
+			burst = cfg.Limit
+		}
+		header := cfg.Header
+		if cfg.Key == RateLimitKeyAPIKey && header == "" {
+			header = DefaultAPIKeyHeader
+		}
+
+		policy := RateLimitPolicy{
+			Name:       cfg.Name,
+			PathPrefix: cfg.PathPrefix,
+			Key:        cfg.Key,
+			Header:     header,
+			Limit:      ratelimit.Limit{Rate: float64(cfg.Limit) / window.Seconds(), Burst: burst},
+			Quota:      cfg.Limit,
+			Scope:      "policy:" + cfg.Name, // Shared by all routes the policy matches
+			Route:      route.Name,
+		}
+		if len(cfg.Methods) > 0 {
+			policy.Methods = make(map[string]bool, len(cfg.Methods))
+			for _, method := range cfg.Methods {
+				policy.Methods[strings.ToUpper(method)] = true
+			}
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies
+}
+
+// DefaultRateLimitPolicy returns the per-IP rule (rateLimit.production/development) with its own buckets for routeName
+func DefaultRateLimitPolicy(routeName string, securityConfig interfaceconfig.ISecurityConfig) RateLimitPolicy {
+	settings := rateLimitSettings(securityConfig)
+	return RateLimitPolicy{
+		Name:  "default",
+		Key:   RateLimitKeyIP,
+		Limit: ratelimit.Limit{Rate: settings.RequestsPerSecond, Burst: settings.Burst},
+		Quota: int(math.Ceil(settings.RequestsPerSecond)),
+		Scope: "route:" + routeName,
+		Route: routeName,
+	}
+}
+
+// Matches reports whether the policy applies to r
+func (p RateLimitPolicy) Matches(r *http.Request) bool {
+	if len(p.Methods) > 0 && !p.Methods[r.Method] {
+		return false
+	}
+	return pathHasPrefix(r.URL.Path, p.PathPrefix)
+}
+
+// pathHasPrefix reports whether path starts with prefix at a segment boundary
+func pathHasPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// BucketKey returns the store key for r, false if the request has no value for the policy's key
+func (p RateLimitPolicy) BucketKey(r *http.Request) (string, bool) {
+	var value string
+	switch p.Key {
+	case RateLimitKeyIP:
+		value = GetClientIPFromContext(r)
+	case RateLimitKeyUser:
+		value = GetUserIDFromContext(r)
+	case RateLimitKeyAPIKey:
+		if apiKey := r.Header.Get(p.Header); apiKey != "" {
+			sum := sha256.Sum256([]byte(apiKey))
+			value = hex.EncodeToString(sum[:16])
+		}
+	case RateLimitKeyHeader:
+		value = r.Header.Get(p.Header)
+	case RateLimitKeyRoute:
+		value = p.Route
+	}
+
+	if value == "" {
+		return "", false
+	}
+	return p.Scope + ":" + p.Key + ":" + value, true
+}
+
+// GetUserIDFromContext retrieves the authenticated user ID from request context ("" if anonymous)
+func GetUserIDFromContext(r *http.Request) string {
+	if userID, ok := r.Context().Value("user_id").(string); ok {
+		return userID
+	}
+	return ""
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitPolicyMatchesPathSegments(t *testing.T) {
+	policy := RateLimitPolicy{PathPrefix: "/api/login"}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/api/login", true},
+		{"/api/login/", true},
+		{"/api/login/otp", true},
+		{"/api/loginx", false},
+		{"/api/login-history", false},
+		{"/api/log", false},
+	}
+	for _, tt := range tests {
+		if got := policy.Matches(httptest.NewRequest("GET", tt.path, nil)); got != tt.want {
+			t.Errorf("Matches(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// A prefix ending in a slash matches everything below it
+	if !pathHasPrefix("/api/auth/login", "/api/auth/") {
+		t.Error("pathHasPrefix(/api/auth/login, /api/auth/) = false, want true")
+	}
+}
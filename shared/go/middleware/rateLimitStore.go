@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/ratelimit"
+	"github.com/app/shared/go/utils/redis"
+)
+
+// Defaults of the rate limit store
+const (
+	DefaultRateLimitKeyPrefix = "ratelimit:"
+	DefaultRateLimitTimeout   = 50 * time.Millisecond
+)
+
+// ==========================================
+// RATE LIMIT STORE
+// ==========================================
+
+// redisStores caches Redis stores by connection settings, so a config reload
+// keeps the connection pool and the store's outage state
+var redisStores sync.Map
+
+// memoryStore is the current memory store and its settings, so a config reload keeps the buckets
+var memoryStore struct {
+	mu       sync.Mutex
+	settings string
+	store    *ratelimit.MemoryStore
+}
+
+// NewRateLimitStore creates the limiter store configured in securityConfig.json
+// Stores are shared between calls with the same settings, changed settings start with empty memory buckets
+func NewRateLimitStore(cfg interfaceconfig.IRateLimitStoreConfig) (ratelimit.Store, error) {
+	switch cfg.Type {
+	case "", "memory":
+		return memoryRateLimitStore(cfg), nil
+	case "redis":
+		return redisRateLimitStore(cfg)
+	}
+	return nil, fmt.Errorf("unknown rate limit store type %q", cfg.Type)
+}
+
+// memoryRateLimitStore returns the current memory store, a new one if the settings changed
+// A replaced store's janitor stops once the old handlers are gone and the store is garbage collected
+func memoryRateLimitStore(cfg interfaceconfig.IRateLimitStoreConfig) ratelimit.Store {
+	// Config is validated, an unparsable idle timeout cannot occur here
+	idleTimeout, _ := time.ParseDuration(cfg.IdleTimeout)
+	settings := fmt.Sprintf("%d/%s", cfg.MaxKeys, idleTimeout)
+
+	memoryStore.mu.Lock()
+	defer memoryStore.mu.Unlock()
+	if memoryStore.store == nil || memoryStore.settings != settings {
+		memoryStore.store = ratelimit.NewMemoryStore(ratelimit.MemoryOptions{
+			MaxKeys:     cfg.MaxKeys,
+			IdleTimeout: idleTimeout,
+		})
+		memoryStore.settings = settings
+	}
+	return memoryStore.store
+}
+
+// redisRateLimitStore returns the cached Redis store for cfg, creating it on first use
+func redisRateLimitStore(cfg interfaceconfig.IRateLimitStoreConfig) (ratelimit.Store, error) {
+	addr := cfg.Address
+	if addr == "" {
+		addr = os.Getenv("REDIS_ADDR")
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("rate limit store: redis address missing (set rateLimit.store.address or REDIS_ADDR)")
+	}
+
+	prefix := cfg.KeyPrefix
+	if prefix == "" {
+		prefix = DefaultRateLimitKeyPrefix
+	}
+	timeout := DefaultRateLimitTimeout
+	if cfg.Timeout != "" {
+		parsed, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit store: invalid timeout %q: %w", cfg.Timeout, err)
+		}
+		timeout = parsed
+	}
+
+	cacheKey := fmt.Sprintf("%s/%d/%s/%s", addr, cfg.DB, prefix, timeout)
+	if store, ok := redisStores.Load(cacheKey); ok {
+		return store.(ratelimit.Store), nil
+	}
+
+	client := redis.NewClient(redis.Options{
+		Addr:     addr,
+		Password: os.Getenv("REDIS_PASSWORD"),
+		DB:       cfg.DB,
+	})
+	store, loaded := redisStores.LoadOrStore(cacheKey, ratelimit.NewRedisStore(client, prefix, timeout))
+	if loaded {
+		client.Close()
+	}
+	return store.(ratelimit.Store), nil
+}
+
+// rateLimitFailOpen reports whether requests pass while the store is unreachable
+func rateLimitFailOpen(cfg interfaceconfig.IRateLimitStoreConfig) bool {
+	return cfg.FailureMode != "closed"
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/ratelimit"
+)
+
+func TestNewRateLimitStoreKeepsMemoryBucketsOnReload(t *testing.T) {
+	cfg := interfaceconfig.IRateLimitStoreConfig{Type: "memory", MaxKeys: 1000, IdleTimeout: "10m"}
+	limit := ratelimit.Limit{Rate: 0.001, Burst: 1}
+	ctx := context.Background()
+
+	store, err := NewRateLimitStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Allow(ctx, "client", limit)
+
+	// Same settings (e.g. a deny list edit): the bucket is still empty
+	reloaded, _ := NewRateLimitStore(cfg)
+	if decision, _ := reloaded.Allow(ctx, "client", limit); decision.Allowed {
+		t.Fatal("bucket reset by a reload with unchanged store settings")
+	}
+
+	// Changed settings start a new store
+	cfg.MaxKeys = 2000
+	changed, _ := NewRateLimitStore(cfg)
+	if decision, _ := changed.Allow(ctx, "client", limit); !decision.Allowed {
+		t.Fatal("store kept although its settings changed")
+	}
+}
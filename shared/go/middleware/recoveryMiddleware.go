@@ -19,6 +19,7 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 		defer func() {
 			if err := recover(); err != nil {
 				requestID := GetRequestID(r)
+				panicsTotal.WithLabelValues().Inc()
 
 				// Log the panic with comprehensive details
 				logger.ErrorWithFields("Panic recovered",
@@ -27,7 +28,7 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 						"request_id":   requestID,
 						"method":       r.Method,
 						"path":         r.URL.Path,
-						"ip":           getClientIP(r),
+						"ip":           GetClientIPFromContext(r),
 						"user_agent":   r.UserAgent(),
 						"headers":      r.Header,
 						"query_params": r.URL.Query(),
@@ -36,7 +37,7 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 				)
 
 				// Return 500 to client
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				WriteJSONError(w, r, http.StatusInternalServerError, ErrorCodeInternal, "Internal Server Error", "")
 			}
 		}()
 
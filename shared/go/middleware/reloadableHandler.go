@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// ==========================================
+// RELOADABLE HANDLER
+// ==========================================
+
+// ReloadableHandler serves requests with a handler that can be swapped at runtime
+// Used for config hot reloading: each request loads the current handler once,
+// so in-flight requests finish on the handler (and config) they started with
+type ReloadableHandler struct {
+	current atomic.Pointer[http.Handler]
+}
+
+// NewReloadableHandler creates a ReloadableHandler serving the initial handler
+func NewReloadableHandler(initial http.Handler) *ReloadableHandler {
+	h := &ReloadableHandler{}
+	h.Swap(initial)
+	return h
+}
+
+// Swap atomically replaces the handler used for new requests
+func (h *ReloadableHandler) Swap(next http.Handler) {
+	h.current.Store(&next)
+}
+
+func (h *ReloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*h.current.Load()).ServeHTTP(w, r)
+}
@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+)
+
+// ==========================================
+// RETRY POLICY
+// ==========================================
+// Idempotent requests are retried on transport errors (connection refused/reset, ...)
+// and on 502/503/504 responses, preferably on another upstream
+// Backoff: exponential with full jitter (random between 0 and InitialBackoff * 2^retry, capped at MaxBackoff)
+// Budget: retries may add at most BudgetRatio of the route's requests (per 10s window),
+// so a failing upstream does not get hammered with retry storms
+
+// Retry defaults (used when the route config leaves a field empty)
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 50 * time.Millisecond
+	defaultRetryMaxBackoff     = 1 * time.Second
+	defaultRetryBudgetRatio    = 0.2
+	defaultRetryMaxBodyBytes   = 64 << 10
+
+	// retryBudgetWindow is the window in which requests and retries are counted
+	retryBudgetWindow = 10 * time.Second
+	// retryBudgetMinRetries is always allowed per window, so low-traffic routes can still retry
+	retryBudgetMinRetries = 10
+)
+
+// errRetryableStatus makes the proxy discard a 502/503/504 response that will be retried
+var errRetryableStatus = errors.New("upstream returned retryable status")
+
+// retrySettings is the parsed IRetryConfig with defaults applied
+type retrySettings struct {
+	disabled       bool
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxBodyBytes   int64
+}
+
+func newRetrySettings(cfg interfaceconfig.IRetryConfig) retrySettings {
+	s := retrySettings{
+		disabled:       cfg.Disabled,
+		maxAttempts:    cfg.MaxAttempts,
+		initialBackoff: defaultRetryInitialBackoff,
+		maxBackoff:     defaultRetryMaxBackoff,
+		maxBodyBytes:   cfg.MaxBodyBytes,
+	}
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = defaultRetryMaxAttempts
+	}
+	if d, err := time.ParseDuration(cfg.InitialBackoff); err == nil && d > 0 {
+		s.initialBackoff = d
+	}
+	if d, err := time.ParseDuration(cfg.MaxBackoff); err == nil && d > 0 {
+		s.maxBackoff = d
+	}
+	if s.maxBackoff < s.initialBackoff {
+		s.maxBackoff = s.initialBackoff
+	}
+	if s.maxBodyBytes <= 0 {
+		s.maxBodyBytes = defaultRetryMaxBodyBytes
+	}
+	return s
+}
+
+// backoff returns the jittered wait before the given retry (1 = first retry)
+func (s retrySettings) backoff(retry int) time.Duration {
+	ceiling := s.initialBackoff << (retry - 1)
+	if ceiling <= 0 || ceiling > s.maxBackoff {
+		ceiling = s.maxBackoff
+	}
+	return rand.N(ceiling + 1)
+}
+
+// isIdempotentRequest reports whether the request may safely be sent twice
+func isIdempotentRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.Header.Get("Idempotency-Key") != ""
+}
+
+// isRetryableStatus reports whether an upstream response indicates a transient failure
+func isRetryableStatus(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// bufferRequestBody reads the body into memory so it can be replayed
+// Returns false (with the body restored) if it is larger than maxBytes
+func bufferRequestBody(r *http.Request, maxBytes int64) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+	if r.ContentLength > maxBytes {
+		return nil, false, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(body)) > maxBytes {
+		// Too large - forward once, prepending what was already read
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false, nil
+	}
+	r.Body.Close()
+	return body, true, nil
+}
+
+// sleepContext waits for d, returns false if ctx ends first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// ==========================================
+// RETRY BUDGET
+// ==========================================
+
+// retryBudget limits retries to a share of the requests of a route
+type retryBudget struct {
+	ratio float64
+
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	retries     int
+}
+
+func newRetryBudget(ratio float64) *retryBudget {
+	if ratio <= 0 {
+		ratio = defaultRetryBudgetRatio
+	}
+	return &retryBudget{ratio: ratio}
+}
+
+// resetIfExpired starts a new window, must be called with b.mu held
+func (b *retryBudget) resetIfExpired(now time.Time) {
+	if now.Sub(b.windowStart) >= retryBudgetWindow {
+		b.windowStart = now
+		b.requests = 0
+		b.retries = 0
+	}
+}
+
+// recordRequest counts an incoming request
+func (b *retryBudget) recordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.resetIfExpired(time.Now())
+	b.requests++
+}
+
+// canRetry reports whether the budget still allows a retry
+// Concurrent requests may overshoot the budget slightly, which is fine for its purpose
+func (b *retryBudget) canRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.resetIfExpired(time.Now())
+	return b.retries < retryBudgetMinRetries || float64(b.retries) < b.ratio*float64(b.requests)
+}
+
+// recordRetry counts a retry against the budget
+func (b *retryBudget) recordRetry() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.resetIfExpired(time.Now())
+	b.retries++
+}
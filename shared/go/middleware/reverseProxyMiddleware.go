@@ -1,50 +1,362 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/ipblock"
+	"github.com/app/shared/go/utils/logger"
+	"github.com/app/shared/go/utils/tracing"
 )
 
 // ==========================================
 // REVERSE PROXY HANDLER
 // ==========================================
-func NewProxy(target string, prefix string) http.Handler {
-	u, _ := url.Parse(target)
-	proxy := httputil.NewSingleHostReverseProxy(u)
 
-	origDirector := proxy.Director
-	proxy.Director = func(req *http.Request) {
-		origDirector(req)
+// LoadBalancedProxy is a reverse proxy that spreads requests over a pool of upstreams
+type LoadBalancedProxy struct {
+	Name      string      // Route name, used in logs
+	Upstreams []*Upstream // Upstream pool
+
+	balancer    Balancer
+	stripPrefix string
+	healthCheck healthCheckSettings
+	retry       retrySettings
+	retryBudget *retryBudget
+	proxy       *httputil.ReverseProxy
+}
+
+// NewLoadBalancedProxy creates a proxy for the upstream pool
+// stripPrefix is removed from the request path before forwarding ("" = keep path)
+func NewLoadBalancedProxy(name string, upstreams []*Upstream, balancer Balancer, stripPrefix string) *LoadBalancedProxy {
+	p := &LoadBalancedProxy{
+		Name:        name,
+		Upstreams:   upstreams,
+		balancer:    balancer,
+		stripPrefix: stripPrefix,
+		healthCheck: newHealthCheckSettings(interfaceconfig.IHealthCheckConfig{}),
+		retry:       newRetrySettings(interfaceconfig.IRetryConfig{}),
+		retryBudget: newRetryBudget(0),
+	}
+
+	p.proxy = &httputil.ReverseProxy{
+		Director:     p.director,
+		ErrorHandler: p.errorHandler,
+		ModifyResponse: func(resp *http.Response) error {
+			// Remember the status for the circuit breaker
+			if attempt, ok := resp.Request.Context().Value("proxy_attempt").(*proxyAttempt); ok {
+				attempt.statusCode = resp.StatusCode
+
+				// Discard the response if the request will be retried
+				if attempt.retry && isRetryableStatus(resp.StatusCode) {
+					return errRetryableStatus
+				}
+			}
 
-		// Strip the prefix from the path (e.g. /service-a/foo -> /foo)
-		req.URL.Path = strings.TrimPrefix(req.URL.Path, prefix)
-		if req.URL.Path == "" {
-			req.URL.Path = "/"
+			// Remove Content-Length - Go recalculates a new one after compression
+			resp.Header.Del("Content-Length")
+			return nil
+		},
+		// Configure transport with reasonable timeouts
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+
+	return p
+}
+
+// NewRouteProxy creates the load balanced proxy for a configured route
+func NewRouteProxy(route interfaceconfig.IServiceRoute) (*LoadBalancedProxy, error) {
+	upstreams := make([]*Upstream, 0, len(route.Targets))
+	for _, target := range route.Targets {
+		upstream, err := NewUpstream(target.URL, target.Weight)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", route.Name, err)
 		}
+		upstream.SetCircuitBreaker(NewCircuitBreaker(route.Name, upstream.URL.String(), route.CircuitBreaker))
+		upstreams = append(upstreams, upstream)
+	}
+
+	balancer, err := NewBalancer(route.LoadBalancing.Strategy, route.LoadBalancing.HashKey, upstreams)
+	if err != nil {
+		return nil, fmt.Errorf("route %q: %w", route.Name, err)
+	}
+
+	stripPrefix := ""
+	if route.StripPrefix {
+		stripPrefix = strings.TrimSuffix(route.Prefix, "/")
+	}
+
+	proxy := NewLoadBalancedProxy(route.Name, upstreams, balancer, stripPrefix)
+	proxy.healthCheck = newHealthCheckSettings(route.HealthCheck)
+	proxy.retry = newRetrySettings(route.Retry)
+	proxy.retryBudget = newRetryBudget(route.Retry.BudgetRatio)
+
+	return proxy, nil
+}
 
-		// Forward Request-ID header for distributed tracing
-		if requestID := req.Context().Value("request_id"); requestID != nil {
-			req.Header.Set("X-Request-ID", requestID.(string))
+// NewProxy creates a proxy for a single upstream (with the default circuit breaker)
+func NewProxy(target string, prefix string) (http.Handler, error) {
+	upstream, err := NewUpstream(target, 1)
+	if err != nil {
+		return nil, err
+	}
+	upstream.SetCircuitBreaker(NewCircuitBreaker(target, target, interfaceconfig.ICircuitBreakerConfig{}))
+	upstreams := []*Upstream{upstream}
+	return NewLoadBalancedProxy(target, upstreams, &roundRobinBalancer{upstreams: upstreams}, prefix), nil
+}
+
+// proxyAttempt carries the selected upstream through the proxy and collects the outcome
+type proxyAttempt struct {
+	upstream   *Upstream
+	retries    int   // Retries before this attempt (sent as X-Retry-Count)
+	retry      bool  // A failure will be retried, so no error response is written
+	statusCode int   // Upstream response status (set by ModifyResponse)
+	err        error // Transport error (set by errorHandler)
+	discarded  bool  // Failed without writing a response (set by errorHandler)
+	done       bool  // The proxy returned (false while it panics)
+}
+
+func (p *LoadBalancedProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Buffer small bodies of idempotent requests so they can be replayed
+	retryable := !p.retry.disabled && isIdempotentRequest(r)
+	var body []byte
+	if retryable {
+		var err error
+		if body, retryable, err = bufferRequestBody(r, p.retry.maxBodyBytes); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				ReportOffense(r, ipblock.OffenseOversizedBody)
+				WriteJSONError(w, r, http.StatusRequestEntityTooLarge, ErrorCodeRequestTooLarge, "Request Entity Too Large", p.Name)
+				return
+			}
+			WriteJSONError(w, r, http.StatusBadRequest, ErrorCodeInvalidBody, "Bad Request", p.Name)
+			return
+		}
+		if body != nil {
+			r.ContentLength = int64(len(body))
 		}
 	}
+	p.retryBudget.recordRequest()
+
+	var tried []*Upstream
+	for retries := 0; ; retries++ {
+		upstream := p.nextUpstream(r, tried)
+		if upstream == nil {
+			p.rejectUnavailable(w, r)
+			return
+		}
+		if body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		// Decide up front - a discarded response cannot be recovered
+		retry := retryable && retries+1 < p.retry.maxAttempts && p.retryBudget.canRetry()
 
-	proxy.ModifyResponse = func(resp *http.Response) error {
-		// Remove Content-Length - Go recalculates a new one after compression
-		resp.Header.Del("Content-Length")
-		return nil
+		attempt := p.serveAttempt(w, r, upstream, retries, retry)
+		if attempt == nil {
+			return // Rejected by the circuit breaker
+		}
+		if !attempt.discarded {
+			if retries > 0 {
+				logger.InfoWithFields("Proxied request succeeded after retry", map[string]interface{}{
+					"request_id":  GetRequestID(r),
+					"route":       p.Name,
+					"upstream":    upstream.URL.String(),
+					"method":      r.Method,
+					"path":        r.URL.Path,
+					"status":      attempt.statusCode,
+					"retry_count": retries,
+				})
+			}
+			return
+		}
+
+		tried = append(tried, upstream)
+		p.retryBudget.recordRetry()
+		if !sleepContext(r.Context(), p.retry.backoff(retries+1)) {
+			return // Client went away or the route timeout hit (answered by TimeoutMiddleware)
+		}
 	}
+}
 
-	// Configure transport with reasonable timeouts
-	proxy.Transport = &http.Transport{
-		Proxy:                 http.ProxyFromEnvironment,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
+// nextUpstream selects an upstream, preferring one that was not tried yet
+func (p *LoadBalancedProxy) nextUpstream(r *http.Request, tried []*Upstream) *Upstream {
+	var upstream *Upstream
+	for i := 0; i < len(p.Upstreams); i++ {
+		if upstream = p.balancer.Next(r); upstream == nil || !slices.Contains(tried, upstream) {
+			return upstream
+		}
 	}
+	return upstream
+}
 
-	return proxy
+// serveAttempt proxies the request to the upstream once
+// Returns nil if the circuit breaker rejected the attempt (the 503 is already written)
+func (p *LoadBalancedProxy) serveAttempt(w http.ResponseWriter, r *http.Request, upstream *Upstream, retries int, retry bool) *proxyAttempt {
+	attempt := &proxyAttempt{upstream: upstream, retries: retries, retry: retry}
+
+	// Reserve a slot with the circuit breaker (another request may have taken the last half-open slot)
+	// The outcome is recorded deferred: httputil.ReverseProxy panics with http.ErrAbortHandler when the
+	// client goes away mid-response, a panicking attempt is ignored but still releases its half-open slot
+	if upstream.breaker != nil {
+		generation, err := upstream.breaker.Allow()
+		if err != nil {
+			p.rejectOpenCircuit(w, r, upstream)
+			return nil
+		}
+		defer func() {
+			if !attempt.done {
+				upstream.breaker.Record(generation, false, true)
+				return
+			}
+			failed, ignored := attempt.outcome(r)
+			upstream.breaker.Record(generation, failed, ignored)
+		}()
+	}
+
+	// Track in-flight requests for least-connections
+	upstream.acquire()
+	defer upstream.release()
+
+	// Client span per attempt, its context is sent upstream as traceparent (see director)
+	ctx, span := tracing.Start(r.Context(), r.Method, tracing.SpanKindClient)
+	defer span.End()
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("gateway.route", p.Name)
+	span.SetAttribute("server.address", upstream.URL.Host)
+	span.SetAttribute("url.full", upstream.URL.String())
+	if retries > 0 {
+		span.SetAttribute("http.request.resend_count", retries)
+	}
+
+	// Pass the selected upstream to the director
+	ctx = context.WithValue(ctx, "proxy_attempt", attempt)
+	p.proxy.ServeHTTP(w, r.WithContext(ctx))
+	attempt.done = true
+
+	if attempt.err != nil {
+		span.RecordError(attempt.err)
+	}
+	if attempt.statusCode != 0 {
+		span.SetAttribute("http.response.status_code", attempt.statusCode)
+		if attempt.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, http.StatusText(attempt.statusCode))
+		}
+	}
+	return attempt
+}
+
+// outcome classifies the attempt for the circuit breaker
+// Transport errors, upstream timeouts and 5xx responses are failures,
+// requests cancelled by the client and oversized bodies say nothing about the upstream
+func (a *proxyAttempt) outcome(r *http.Request) (failed, ignored bool) {
+	if a.err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.Is(a.err, context.Canceled) || errors.Is(r.Context().Err(), context.Canceled) || errors.As(a.err, &maxBytesErr) {
+			return false, true
+		}
+		return true, false
+	}
+	return a.statusCode >= http.StatusInternalServerError, false
+}
+
+// rejectUnavailable answers when the balancer found no upstream
+func (p *LoadBalancedProxy) rejectUnavailable(w http.ResponseWriter, r *http.Request) {
+	// Healthy upstreams exist, so their circuits are open
+	for _, upstream := range p.Upstreams {
+		if upstream.Healthy() && upstream.breaker != nil {
+			p.rejectOpenCircuit(w, r, upstream)
+			return
+		}
+	}
+
+	WriteJSONError(w, r, http.StatusServiceUnavailable, ErrorCodeNoHealthyUpstream, "Service Unavailable", p.Name)
+}
+
+// rejectOpenCircuit fails fast with 503 while the upstream's circuit is open
+func (p *LoadBalancedProxy) rejectOpenCircuit(w http.ResponseWriter, r *http.Request, upstream *Upstream) {
+	logger.LogEvent(logger.EventCircuitBreakerRejected, map[string]interface{}{
+		"request_id": GetRequestID(r),
+		"route":      p.Name,
+		"upstream":   upstream.URL.String(),
+		"state":      upstream.breaker.State().String(),
+		"method":     r.Method,
+		"path":       r.URL.Path,
+	})
+
+	if retryAfter := upstream.breaker.RetryAfter(); retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	WriteJSONError(w, r, http.StatusServiceUnavailable, ErrorCodeCircuitOpen, "Service temporarily unavailable", p.Name)
+}
+
+// director rewrites the outgoing request to the upstream selected in ServeHTTP
+func (p *LoadBalancedProxy) director(req *http.Request) {
+	attempt := req.Context().Value("proxy_attempt").(*proxyAttempt)
+	upstream := attempt.upstream
+
+	// Strip the prefix from the path (e.g. /service-a/foo -> /foo)
+	req.URL.Path = strings.TrimPrefix(req.URL.Path, p.stripPrefix)
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+	req.URL.RawPath = ""
+
+	// Point the request at the upstream (same as httputil.NewSingleHostReverseProxy)
+	req.URL.Scheme = upstream.URL.Scheme
+	req.URL.Host = upstream.URL.Host
+	req.URL.Path = singleJoiningSlash(upstream.URL.Path, req.URL.Path)
+	if upstream.URL.RawQuery == "" || req.URL.RawQuery == "" {
+		req.URL.RawQuery = upstream.URL.RawQuery + req.URL.RawQuery
+	} else {
+		req.URL.RawQuery = upstream.URL.RawQuery + "&" + req.URL.RawQuery
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		// Explicitly disable User-Agent so it's not set to default value
+		req.Header.Set("User-Agent", "")
+	}
+
+	// Forward Request-ID header for distributed tracing
+	if requestID := req.Context().Value("request_id"); requestID != nil {
+		req.Header.Set("X-Request-ID", requestID.(string))
+	}
+
+	// W3C trace context of the attempt's client span
+	tracing.Inject(req.Context(), req.Header)
+
+	// Let the upstream know this is a retry
+	req.Header.Del("X-Retry-Count")
+	if attempt.retries > 0 {
+		req.Header.Set("X-Retry-Count", strconv.Itoa(attempt.retries))
+	}
+}
+
+// singleJoiningSlash joins two URL paths with exactly one slash
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
 }
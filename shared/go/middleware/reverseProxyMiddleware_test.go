@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+)
+
+// abortingWriter panics like a client that disconnects while the response is copied
+type abortingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w abortingWriter) Write([]byte) (int, error) {
+	panic(http.ErrAbortHandler)
+}
+
+func TestServeAttemptReleasesHalfOpenSlotOnPanic(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	proxy, err := NewRouteProxy(interfaceconfig.IServiceRoute{
+		Name:           "test",
+		Prefix:         "/api/test/",
+		Targets:        []interfaceconfig.IRouteTarget{{URL: backend.URL}},
+		CircuitBreaker: interfaceconfig.ICircuitBreakerConfig{ConsecutiveFailures: 1, CoolDown: "10ms", HalfOpenMaxRequests: 1},
+		Retry:          interfaceconfig.IRetryConfig{Disabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := proxy.Upstreams[0].CircuitBreaker()
+
+	// Open the circuit and wait for the half-open state
+	generation, _ := breaker.Allow()
+	breaker.Record(generation, true, false)
+	time.Sleep(20 * time.Millisecond)
+	if state := breaker.State(); state != CircuitHalfOpen {
+		t.Fatalf("state = %v, want half-open", state)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); !errors.Is(r.(error), http.ErrAbortHandler) {
+				t.Fatalf("recovered %v, want http.ErrAbortHandler", r)
+			}
+		}()
+		proxy.ServeHTTP(abortingWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/api/test/", nil))
+	}()
+
+	if !breaker.Ready() {
+		t.Fatal("half-open slot not released after the panic")
+	}
+
+	// The panic says nothing about the upstream, the next probe closes the circuit
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/test/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if state := breaker.State(); state != CircuitClosed {
+		t.Fatalf("state = %v, want closed", state)
+	}
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/jwt"
+	"github.com/app/shared/go/utils/logger"
+	"github.com/app/shared/go/utils/ratelimit"
+	"github.com/app/shared/go/utils/session"
+)
+
+// DefaultRouteTimeout is used when a route does not configure its own timeout
+const DefaultRouteTimeout = 10 * time.Second
+
+// ==========================================
+// METHOD FILTER MIDDLEWARE
+// ==========================================
+
+// MethodFilterMiddleware rejects requests whose method is not in allowedMethods
+// An empty list allows all methods
+func MethodFilterMiddleware(allowedMethods []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(allowedMethods) == 0 {
+			return next
+		}
+
+		allowed := make(map[string]bool, len(allowedMethods))
+		for _, method := range allowedMethods {
+			allowed[method] = true
+		}
+		allowHeader := strings.Join(allowedMethods, ", ")
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !allowed[r.Method] {
+				w.Header().Set("Allow", allowHeader)
+				WriteJSONError(w, r, http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed, "Method Not Allowed", "")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ==========================================
+// ROUTE MIDDLEWARE STACK
+// ==========================================
+
+// BuildRouteMiddlewareStack applies the per-route middleware configured for a route
+// Execution order: Method Filter → Rate Limiting → Auth → Rate Limiting (user) → Timeout → Compression → handler
+// store holds the rate limit buckets (see NewRateLimitStore), verifier checks the JWTs (see NewJWTVerifier),
+// sessions resolves session cookies (nil: the auth cookie holds a JWT)
+func BuildRouteMiddlewareStack(handler http.Handler, route interfaceconfig.IServiceRoute, securityConfig interfaceconfig.ISecurityConfig, store ratelimit.Store, verifier *jwt.Verifier, sessions *session.Manager, authConfig interfaceconfig.IAuthConfig) http.Handler {
+	// Applied from innermost to outermost
+
+	// Compression - Compress responses
+	if isEnabled(route.Middleware.Compression) {
+		handler = CompressionMiddleware(handler)
+	}
+
+	// Timeout - Limit total request time
+	handler = TimeoutMiddleware(handler, routeTimeout(route))
+
+	// Rate Limiting - Protect against abuse (per IP, separate buckets per route)
+	// Policies keyed by user need the identity and run after Auth, all others before it,
+	// so floods of invalid credentials are limited before any token or session is checked
+	var beforeAuth, afterAuth []RateLimitPolicy
+	if isEnabled(route.Middleware.RateLimit) {
+		for _, policy := range BuildRateLimitPolicies(route, securityConfig) {
+			if policy.Key == RateLimitKeyUser {
+				afterAuth = append(afterAuth, policy)
+			} else {
+				beforeAuth = append(beforeAuth, policy)
+			}
+		}
+	}
+	failOpen := rateLimitFailOpen(securityConfig.RateLimit.Store)
+	if len(afterAuth) > 0 {
+		handler = RateLimitMiddleware(store, afterAuth, failOpen)(handler)
+	}
+
+	// Auth - Verify the JWT or session and forward the identity
+	handler = AuthMiddleware(verifier, authConfig.Cookie.AuthCookieName, sessions, route)(handler)
+
+	if len(beforeAuth) > 0 {
+		handler = RateLimitMiddleware(store, beforeAuth, failOpen)(handler)
+	}
+
+	// Method Filter - Reject unsupported methods before doing any work
+	handler = MethodFilterMiddleware(route.Methods)(handler)
+
+	return handler
+}
+
+// GatewayEndpointMiddleware protects the gateway's own endpoints (probes, health, metrics, admin)
+// with the default per-IP rule and DefaultRouteTimeout, like a route without policies
+func GatewayEndpointMiddleware(store ratelimit.Store, securityConfig interfaceconfig.ISecurityConfig) func(http.Handler) http.Handler {
+	policies := []RateLimitPolicy{DefaultRateLimitPolicy("gateway", securityConfig)}
+	failOpen := rateLimitFailOpen(securityConfig.RateLimit.Store)
+
+	return func(next http.Handler) http.Handler {
+		handler := TimeoutMiddleware(next, DefaultRouteTimeout)
+		return RateLimitMiddleware(store, policies, failOpen)(handler)
+	}
+}
+
+// ==========================================
+// ROUTE TABLE
+// ==========================================
+
+// RegisterRoutes mounts a load balanced reverse proxy with its route middleware for every configured route
+// The routes are expected to be validated with config.ValidateRouteConfig beforehand
+// store holds the rate limit buckets (see NewRateLimitStore),
+// sessionStore holds the sessions of the auth cookie (nil: the auth cookie holds a JWT)
+// Returns the proxies so the caller can start their health checks
+func RegisterRoutes(mux *http.ServeMux, routes []interfaceconfig.IServiceRoute, securityConfig interfaceconfig.ISecurityConfig, authConfig interfaceconfig.IAuthConfig, store ratelimit.Store, sessionStore session.Store) ([]*LoadBalancedProxy, error) {
+	// JWT keys are read from the environment (see NewJWTVerifier)
+	verifier, err := NewJWTVerifier(authConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// The gateway only reads sessions, cookies are issued by service-a
+	var sessions *session.Manager
+	if sessionStore != nil {
+		sessions = session.NewManager(session.OptionsFromConfig(authConfig, sessionStore, false))
+	}
+
+	proxies := make([]*LoadBalancedProxy, 0, len(routes))
+
+	for _, route := range routes {
+		proxy, err := NewRouteProxy(route)
+		if err != nil {
+			return nil, err
+		}
+		mux.Handle(route.Prefix, BuildRouteMiddlewareStack(proxy, route, securityConfig, store, verifier, sessions, authConfig))
+		proxies = append(proxies, proxy)
+
+		logger.InfoWithFields("Route registered", map[string]interface{}{
+			"route":        route.Name,
+			"prefix":       route.Prefix,
+			"targets":      route.Targets,
+			"strategy":     route.LoadBalancing.Strategy,
+			"strip_prefix": route.StripPrefix,
+			"methods":      route.Methods,
+			"timeout":      routeTimeout(route).String(),
+			"auth":         routeAuthMode(route),
+		})
+	}
+
+	return proxies, nil
+}
+
+// routeTimeout returns the configured route timeout or DefaultRouteTimeout
+func routeTimeout(route interfaceconfig.IServiceRoute) time.Duration {
+	if timeout, err := time.ParseDuration(route.Timeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return DefaultRouteTimeout
+}
+
+// routeAuthMode returns the auth mode of a route (empty = public)
+func routeAuthMode(route interfaceconfig.IServiceRoute) string {
+	if route.Auth.Mode == "" {
+		return interfaceconfig.AuthModePublic
+	}
+	return route.Auth.Mode
+}
+
+// rateLimitSettings selects the rate limit settings for the current environment
+func rateLimitSettings(securityConfig interfaceconfig.ISecurityConfig) interfaceconfig.IRateLimitSettings {
+	if os.Getenv("ENVIRONMENT") == "production" {
+		return securityConfig.RateLimit.Production
+	}
+	return securityConfig.RateLimit.Development
+}
+
+// isEnabled treats unset middleware toggles as enabled
+func isEnabled(toggle *bool) bool {
+	return toggle == nil || *toggle
+}
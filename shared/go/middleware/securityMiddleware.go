@@ -65,7 +65,7 @@ func SecurityHeadersMiddleware(next http.Handler) http.Handler {
 					"request_id": requestID,
 					"method":     r.Method,
 					"path":       r.URL.Path,
-					"ip":         getClientIP(r),
+					"ip":         GetClientIPFromContext(r),
 					"user_agent": r.UserAgent(),
 				})
 			}
@@ -44,19 +44,20 @@ func TimeoutMiddleware(next http.Handler, timeout time.Duration) http.Handler {
 			// Timeout occurred
 			requestID := GetRequestID(r)
 			duration := time.Since(start)
+			requestTimeoutsTotal.WithLabelValues(routeLabel(r)).Inc()
 
 			logger.WarnWithFields("Request timeout", map[string]interface{}{
 				"request_id":  requestID,
 				"method":      r.Method,
 				"path":        r.URL.Path,
-				"ip":          getClientIP(r),
+				"ip":          GetClientIPFromContext(r),
 				"user_agent":  r.UserAgent(),
 				"timeout":     timeout.String(),
 				"duration":    duration.String(),
 				"duration_ms": duration.Milliseconds(),
 			})
 
-			http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+			WriteJSONError(w, r, http.StatusGatewayTimeout, ErrorCodeGatewayTimeout, "Gateway Timeout", "")
 		}
 	})
 }
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/app/shared/go/utils/logger"
+	"github.com/app/shared/go/utils/tracing"
+)
+
+// ==========================================
+// TRACING MIDDLEWARE
+// ==========================================
+// Continues the trace of an incoming W3C traceparent header (or starts a new one)
+// with a server span per request. The trace is bound to the request ID, so all
+// log lines of the request carry trace_id and span_id
+// IMPORTANT: Must run after RequestIDMiddleware
+
+// TracingMiddleware creates a server span for every request
+// mux resolves the route template for the span name, nil names spans after the method only
+func TracingMiddleware(mux *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := r.Method
+			route := ""
+			if mux != nil {
+				if _, pattern := mux.Handler(r); pattern != "" {
+					route = pattern
+					name = r.Method + " " + pattern
+				}
+			}
+
+			ctx := tracing.Extract(r.Context(), r.Header)
+			ctx, span := tracing.Start(ctx, name, tracing.SpanKindServer)
+			defer span.End()
+
+			// Services get the request ID from the gateway's header
+			requestID := GetRequestID(r)
+			if requestID == "" {
+				requestID = r.Header.Get("X-Request-ID")
+			}
+			sc := span.SpanContext()
+			unbind := logger.BindTrace(requestID, sc.TraceID.String(), sc.SpanID.String())
+			defer unbind()
+
+			span.SetAttribute("http.request.method", r.Method)
+			span.SetAttribute("url.path", r.URL.Path)
+			span.SetAttribute("user_agent.original", r.UserAgent())
+			span.SetAttribute("request_id", requestID)
+			if route != "" {
+				span.SetAttribute("http.route", route)
+			}
+			if ip := GetClientIPFromContext(r); ip != "" {
+				span.SetAttribute("client.address", ip)
+			}
+
+			wrapped := &statusResponseWriter{ResponseWriter: w}
+			completed := false
+
+			defer func() {
+				status := wrapped.statusCode()
+				if !completed {
+					status = http.StatusInternalServerError
+				}
+				span.SetAttribute("http.response.status_code", status)
+				if status >= http.StatusInternalServerError {
+					span.SetStatus(tracing.StatusError, http.StatusText(status))
+				}
+			}()
+
+			next.ServeHTTP(wrapped, r.WithContext(ctx))
+			completed = true
+		})
+	}
+}
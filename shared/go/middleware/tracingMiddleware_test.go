@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app/shared/go/utils/tracing"
+)
+
+func TestTracingThroughProxy(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+	tracer := tracing.NewTracer("gateway", exporter, tracing.Options{})
+	previous := tracing.Default()
+	tracing.SetDefault(tracer)
+	t.Cleanup(func() {
+		tracing.SetDefault(previous)
+		tracer.Shutdown(context.Background())
+	})
+
+	var upstreamTraceparent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamTraceparent = r.Header.Get(tracing.TraceparentHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	proxy, err := NewProxy(backend.URL, "/api/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/api/test/", proxy)
+	handler := TracingMiddleware(mux)(mux)
+
+	incoming, _ := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req := httptest.NewRequest(http.MethodGet, "/api/test/items", nil)
+	req.Header.Set(tracing.TraceparentHeader, incoming.Traceparent())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	tracer.ForceFlush(context.Background())
+	spans := map[tracing.SpanKind]tracing.SpanData{}
+	for _, span := range exporter.Spans() {
+		spans[span.Kind] = span
+	}
+	server, okServer := spans[tracing.SpanKindServer]
+	client, okClient := spans[tracing.SpanKindClient]
+	if !okServer || !okClient {
+		t.Fatalf("exported %v, want a server and a client span", exporter.Spans())
+	}
+
+	// caller -> gateway server span -> client span -> upstream
+	if server.TraceID != incoming.TraceID || server.ParentSpanID != incoming.SpanID {
+		t.Errorf("server span %s/%s, want child of %s", server.TraceID, server.ParentSpanID, incoming.Traceparent())
+	}
+	if server.Name != "GET /api/test/" {
+		t.Errorf("server span name = %q", server.Name)
+	}
+	if client.TraceID != incoming.TraceID || client.ParentSpanID != server.SpanID {
+		t.Errorf("client span parent = %s, want the server span %s", client.ParentSpanID, server.SpanID)
+	}
+	if client.Attributes["http.response.status_code"] != http.StatusOK {
+		t.Errorf("client span attributes = %v", client.Attributes)
+	}
+
+	upstream, ok := tracing.ParseTraceparent(upstreamTraceparent)
+	if !ok || upstream.TraceID != incoming.TraceID || upstream.SpanID != client.SpanID {
+		t.Errorf("upstream traceparent = %q, want the client span %s", upstreamTraceparent, client.SpanID)
+	}
+}
@@ -0,0 +1,268 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/logger"
+)
+
+// ==========================================
+// UPSTREAM HEALTH CHECKING
+// ==========================================
+// Active: every upstream's health endpoint is probed periodically
+// Passive: proxy errors (connection refused, resets, ...) count as failures
+// After UnhealthyThreshold consecutive failures an upstream is removed from rotation,
+// after HealthyThreshold consecutive successful probes it is re-admitted
+
+// Health check defaults (used when the route config leaves a field empty)
+const (
+	defaultHealthCheckPath     = "/readyz"
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 2 * time.Second
+	defaultUnhealthyThreshold  = 3
+	defaultHealthyThreshold    = 2
+)
+
+// healthCheckSettings is the parsed IHealthCheckConfig with defaults applied
+type healthCheckSettings struct {
+	disabled           bool
+	path               string
+	interval           time.Duration
+	timeout            time.Duration
+	unhealthyThreshold int
+	healthyThreshold   int
+}
+
+func newHealthCheckSettings(cfg interfaceconfig.IHealthCheckConfig) healthCheckSettings {
+	s := healthCheckSettings{
+		disabled:           cfg.Disabled,
+		path:               cfg.Path,
+		interval:           defaultHealthCheckInterval,
+		timeout:            defaultHealthCheckTimeout,
+		unhealthyThreshold: cfg.UnhealthyThreshold,
+		healthyThreshold:   cfg.HealthyThreshold,
+	}
+	if s.path == "" {
+		s.path = defaultHealthCheckPath
+	}
+	if d, err := time.ParseDuration(cfg.Interval); err == nil && d > 0 {
+		s.interval = d
+	}
+	if d, err := time.ParseDuration(cfg.Timeout); err == nil && d > 0 {
+		s.timeout = d
+	}
+	if s.unhealthyThreshold <= 0 {
+		s.unhealthyThreshold = defaultUnhealthyThreshold
+	}
+	if s.healthyThreshold <= 0 {
+		s.healthyThreshold = defaultHealthyThreshold
+	}
+	return s
+}
+
+// upstreamHealth holds the health check counters of an upstream
+type upstreamHealth struct {
+	mu          sync.Mutex
+	failures    int // Consecutive failures (active + passive)
+	successes   int // Consecutive successful probes
+	lastError   string
+	lastChecked time.Time // Last active probe
+}
+
+// UpstreamStatus is the health snapshot of an upstream (reported by /api/health)
+type UpstreamStatus struct {
+	URL                 string     `json:"url"`
+	Healthy             bool       `json:"healthy"`
+	ActiveRequests      int64      `json:"active_requests"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastChecked         *time.Time `json:"last_checked,omitempty"`
+	Circuit             string     `json:"circuit,omitempty"` // Circuit breaker state (empty = no circuit breaker)
+}
+
+// Status returns the current health snapshot of the upstream
+func (u *Upstream) Status() UpstreamStatus {
+	u.health.mu.Lock()
+	defer u.health.mu.Unlock()
+
+	status := UpstreamStatus{
+		URL:                 u.URL.String(),
+		Healthy:             u.Healthy(),
+		ActiveRequests:      u.ActiveRequests(),
+		ConsecutiveFailures: u.health.failures,
+		LastError:           u.health.lastError,
+	}
+	if u.breaker != nil {
+		status.Circuit = u.breaker.State().String()
+	}
+	if !u.health.lastChecked.IsZero() {
+		lastChecked := u.health.lastChecked
+		status.LastChecked = &lastChecked
+	}
+	return status
+}
+
+// recordResult updates the counters with a check result (err == nil = success)
+// probe distinguishes active probes from passive proxy errors
+// Returns true if the upstream changed between healthy and unhealthy
+func (u *Upstream) recordResult(err error, probe bool, settings healthCheckSettings) bool {
+	u.health.mu.Lock()
+	defer u.health.mu.Unlock()
+
+	if probe {
+		u.health.lastChecked = time.Now()
+	}
+
+	if err != nil {
+		u.health.successes = 0
+		u.health.failures++
+		u.health.lastError = err.Error()
+		if u.Healthy() && u.health.failures >= settings.unhealthyThreshold {
+			u.unhealthy.Store(true)
+			return true
+		}
+		return false
+	}
+
+	u.health.failures = 0
+	u.health.successes++
+	u.health.lastError = ""
+	if !u.Healthy() && u.health.successes >= settings.healthyThreshold {
+		u.unhealthy.Store(false)
+		return true
+	}
+	return false
+}
+
+// ==========================================
+// ACTIVE CHECKS
+// ==========================================
+
+// StartHealthChecks probes all upstreams of the proxy until ctx is cancelled
+func (p *LoadBalancedProxy) StartHealthChecks(ctx context.Context) {
+	if p.healthCheck.disabled {
+		return
+	}
+
+	client := &http.Client{Timeout: p.healthCheck.timeout}
+
+	go func() {
+		ticker := time.NewTicker(p.healthCheck.interval)
+		defer ticker.Stop()
+
+		for {
+			p.checkUpstreams(ctx, client)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkUpstreams probes all upstreams concurrently
+func (p *LoadBalancedProxy) checkUpstreams(ctx context.Context, client *http.Client) {
+	var wg sync.WaitGroup
+	for _, upstream := range p.Upstreams {
+		wg.Add(1)
+		go func(upstream *Upstream) {
+			defer wg.Done()
+			err := p.probe(ctx, client, upstream)
+			if ctx.Err() != nil {
+				return // Shutting down, not an upstream failure
+			}
+			p.recordHealth(upstream, err, "active")
+		}(upstream)
+	}
+	wg.Wait()
+}
+
+// probe sends a GET to the upstream's health endpoint, any 2xx counts as healthy
+func (p *LoadBalancedProxy) probe(ctx context.Context, client *http.Client, upstream *Upstream) error {
+	probeURL := *upstream.URL
+	probeURL.Path = singleJoiningSlash(upstream.URL.Path, p.healthCheck.path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("health endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ==========================================
+// STATE CHANGES
+// ==========================================
+
+// recordHealth records a check result and logs upstream and pool state changes
+// source: "active" (probe) or "passive" (proxy error)
+func (p *LoadBalancedProxy) recordHealth(upstream *Upstream, err error, source string) {
+	if p.healthCheck.disabled {
+		return
+	}
+	if !upstream.recordResult(err, source == "active", p.healthCheck) {
+		return
+	}
+
+	if upstream.Healthy() {
+		logger.InfoWithFields("Upstream back in rotation", map[string]interface{}{
+			"route":    p.Name,
+			"upstream": upstream.URL.String(),
+		})
+	} else {
+		logger.LogEvent(logger.EventHealthCheckFailed, map[string]interface{}{
+			"route":     p.Name,
+			"upstream":  upstream.URL.String(),
+			"source":    source,
+			"error":     err.Error(),
+			"threshold": p.healthCheck.unhealthyThreshold,
+		})
+	}
+
+	// Pool state after the change
+	healthy := p.HealthyUpstreams()
+	switch {
+	case healthy == 0:
+		logger.LogEvent(logger.EventHealthCheckFailed, map[string]interface{}{
+			"route":   p.Name,
+			"healthy": healthy,
+			"total":   len(p.Upstreams),
+			"reason":  "no healthy upstreams",
+		})
+	case healthy < len(p.Upstreams):
+		logger.LogEvent(logger.EventHealthCheckDegraded, map[string]interface{}{
+			"route":   p.Name,
+			"healthy": healthy,
+			"total":   len(p.Upstreams),
+		})
+	}
+}
+
+// HealthyUpstreams returns the number of upstreams currently in rotation
+func (p *LoadBalancedProxy) HealthyUpstreams() int {
+	healthy := 0
+	for _, upstream := range p.Upstreams {
+		if upstream.Healthy() {
+			healthy++
+		}
+	}
+	return healthy
+}
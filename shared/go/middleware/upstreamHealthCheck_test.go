@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+)
+
+func TestActiveHealthCheckThresholds(t *testing.T) {
+	backends := newTestBackends(t, 2)
+	proxy := newTestRouteProxy(t, backends, interfaceconfig.LoadBalancingRoundRobin, "", interfaceconfig.IHealthCheckConfig{
+		UnhealthyThreshold: 2,
+		HealthyThreshold:   2,
+	})
+	client := &http.Client{Timeout: time.Second}
+	ctx := context.Background()
+
+	// One failed probe is tolerated
+	backends[1].failing.Store(true)
+	proxy.checkUpstreams(ctx, client)
+	if !proxy.Upstreams[1].Healthy() {
+		t.Fatal("upstream removed after one failure, want the threshold of 2")
+	}
+
+	proxy.checkUpstreams(ctx, client)
+	if proxy.Upstreams[1].Healthy() || proxy.HealthyUpstreams() != 1 {
+		t.Fatalf("HealthyUpstreams() = %d after two failures, want 1", proxy.HealthyUpstreams())
+	}
+	if status := proxy.Upstreams[1].Status(); status.ConsecutiveFailures != 2 || status.LastError == "" || status.LastChecked == nil {
+		t.Fatalf("Status() = %+v, want 2 failures with the last error", status)
+	}
+	if counts := sendRequests(t, proxy, 10, nil); counts[0] != 10 {
+		t.Fatalf("counts = %v, want all requests on the healthy backend", counts)
+	}
+
+	// Re-admitted after two successful probes
+	backends[1].failing.Store(false)
+	proxy.checkUpstreams(ctx, client)
+	if proxy.Upstreams[1].Healthy() {
+		t.Fatal("upstream re-admitted after one success, want the threshold of 2")
+	}
+	proxy.checkUpstreams(ctx, client)
+	if !proxy.Upstreams[1].Healthy() || proxy.HealthyUpstreams() != 2 {
+		t.Fatalf("HealthyUpstreams() = %d after two successes, want 2", proxy.HealthyUpstreams())
+	}
+	if counts := sendRequests(t, proxy, 10, nil); counts[0] != 5 || counts[1] != 5 {
+		t.Fatalf("counts = %v, want 5 per backend", counts)
+	}
+}
+
+func TestStartHealthChecks(t *testing.T) {
+	backends := newTestBackends(t, 2)
+	proxy := newTestRouteProxy(t, backends, interfaceconfig.LoadBalancingRoundRobin, "", interfaceconfig.IHealthCheckConfig{
+		Interval:           "10ms",
+		UnhealthyThreshold: 2,
+		HealthyThreshold:   2,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	proxy.StartHealthChecks(ctx)
+
+	waitForHealthy := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for proxy.HealthyUpstreams() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("HealthyUpstreams() = %d, want %d", proxy.HealthyUpstreams(), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	backends[0].failing.Store(true)
+	waitForHealthy(1)
+	if proxy.Upstreams[0].Healthy() {
+		t.Fatal("failing upstream kept in rotation")
+	}
+
+	backends[0].failing.Store(false)
+	waitForHealthy(2)
+
+	// A backend that is gone fails its probes as well
+	backends[1].Close()
+	waitForHealthy(1)
+	if proxy.Upstreams[1].Healthy() {
+		t.Fatal("closed upstream kept in rotation")
+	}
+}
+
+func TestPassiveHealthCheck(t *testing.T) {
+	backends := newTestBackends(t, 2)
+	proxy := newTestRouteProxy(t, backends, interfaceconfig.LoadBalancingRoundRobin, "", interfaceconfig.IHealthCheckConfig{
+		UnhealthyThreshold: 2,
+	})
+	backends[1].Close()
+
+	// Round robin sends every second request to the closed backend, its errors remove it
+	sendRequests(t, proxy, 4, nil)
+	if proxy.Upstreams[1].Healthy() {
+		t.Fatal("upstream with proxy errors kept in rotation")
+	}
+	if counts := sendRequests(t, proxy, 10, nil); counts[0] != 10 {
+		t.Fatalf("counts = %v, want all requests on the remaining backend", counts)
+	}
+}
+
+func TestHealthChecksDisabled(t *testing.T) {
+	backends := newTestBackends(t, 1)
+	proxy := newTestRouteProxy(t, backends, interfaceconfig.LoadBalancingRoundRobin, "", interfaceconfig.IHealthCheckConfig{
+		Disabled:           true,
+		UnhealthyThreshold: 1,
+	})
+	backends[0].Close()
+
+	sendRequests(t, proxy, 3, nil)
+	if !proxy.Upstreams[0].Healthy() {
+		t.Fatal("upstream removed although health checks are disabled")
+	}
+}
@@ -7,6 +7,10 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	interfaceconfig "github.com/app/shared/go/interfaces/config"
 
@@ -15,12 +19,16 @@ import (
 
 // NewPostgresDB provides connection to PostgreSQL and configures connection pool
 func NewPostgresDB(cfg interfaceconfig.IPostgreConnectionConfig) (*sql.DB, error) {
-	connStr := fmt.Sprintf(
-		"postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DBName, cfg.SSLMode,
-	)
+	// Built with net/url so credentials with special characters are escaped
+	connURL := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Path:     "/" + cfg.DBName,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
 
-	db, err := sql.Open("pgx", connStr)
+	db, err := sql.Open("pgx", connURL.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -51,3 +59,51 @@ func NewPostgresDB(cfg interfaceconfig.IPostgreConnectionConfig) (*sql.DB, error
 	log.Println("✅ Connected to PostgreSQL")
 	return db, nil
 }
+
+// Pool defaults of PostgresConfigFromURL
+const (
+	DefaultConnectTimeout  = 10 * time.Second
+	DefaultMaxOpenConns    = 25
+	DefaultMaxIdleConns    = 5
+	DefaultConnMaxIdleTime = 5 * time.Minute
+	DefaultConnMaxLifetime = 30 * time.Minute
+)
+
+// PostgresConfigFromURL converts a connection URL (e.g. DATABASE_URL) into the config of NewPostgresDB
+// Pool settings use the defaults above, sslmode defaults to "disable"
+func PostgresConfigFromURL(databaseURL string) (interfaceconfig.IPostgreConnectionConfig, error) {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return interfaceconfig.IPostgreConnectionConfig{}, fmt.Errorf("invalid database URL: %w", err)
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return interfaceconfig.IPostgreConnectionConfig{}, fmt.Errorf("invalid database URL scheme %q", u.Scheme)
+	}
+
+	port := 5432
+	if p := u.Port(); p != "" {
+		port, err = strconv.Atoi(p)
+		if err != nil {
+			return interfaceconfig.IPostgreConnectionConfig{}, fmt.Errorf("invalid database port %q", p)
+		}
+	}
+	password, _ := u.User.Password()
+	sslMode := u.Query().Get("sslmode")
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	return interfaceconfig.IPostgreConnectionConfig{
+		Host:            u.Hostname(),
+		Port:            port,
+		User:            u.User.Username(),
+		Password:        password,
+		DBName:          strings.TrimPrefix(u.Path, "/"),
+		SSLMode:         sslMode,
+		Timeout:         DefaultConnectTimeout,
+		MaxOpenConns:    DefaultMaxOpenConns,
+		MaxIdleConns:    DefaultMaxIdleConns,
+		ConnMaxIdleTime: DefaultConnMaxIdleTime,
+		ConnMaxLifetime: DefaultConnMaxLifetime,
+	}, nil
+}
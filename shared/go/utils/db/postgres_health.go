@@ -0,0 +1,31 @@
+// shared\go\utils\db\postgres_health.go
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// OpenPostgresURL opens a connection pool for a connection URL (e.g. DATABASE_URL)
+// Unlike NewPostgresDB it does not ping, so a service can start while the database is
+// still unavailable and report it via its readiness probe
+func OpenPostgresURL(databaseURL string) (*sql.DB, error) {
+	db, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// PingCheck returns a health check that pings the database
+// Usable as health.CheckFunc
+func PingCheck(db *sql.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if err := db.PingContext(ctx); err != nil {
+			return fmt.Errorf("postgres ping failed: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+// shared/go/utils/health/checks.go
+
+package health
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ==========================================
+// COMMON CHECKS
+// ==========================================
+// Postgres: see db.PingCheck (utils/db)
+
+// HTTPCheck requires a 2xx response from url (e.g. an upstream's /readyz)
+func HTTPCheck(url string) CheckFunc {
+	client := &http.Client{}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// TCPCheck requires that a TCP connection to addr ("host:port") can be opened
+func TCPCheck(addr string) CheckFunc {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// amqpProtocolHeader starts an AMQP 0-9-1 connection
+var amqpProtocolHeader = []byte{'A', 'M', 'Q', 'P', 0, 0, 9, 1}
+
+// RabbitMQCheck opens an AMQP connection to addr ("host:port") and waits for the broker's
+// Connection.Start - proves the broker accepts connections, not only that the port is open
+func RabbitMQCheck(addr string) CheckFunc {
+	return func(ctx context.Context) error {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		} else {
+			conn.SetDeadline(time.Now().Add(DefaultCheckTimeout))
+		}
+
+		if _, err := conn.Write(amqpProtocolHeader); err != nil {
+			return fmt.Errorf("amqp handshake: %w", err)
+		}
+
+		// Frame header: type (1 = method), channel (2 bytes), size (4 bytes)
+		frame := make([]byte, 7)
+		if _, err := io.ReadFull(conn, frame); err != nil {
+			return fmt.Errorf("amqp handshake: %w", err)
+		}
+		if bytes.HasPrefix(frame, []byte("AMQP")) {
+			return fmt.Errorf("amqp handshake: broker rejected protocol version")
+		}
+		if frame[0] != 1 {
+			return fmt.Errorf("amqp handshake: unexpected frame type %d", frame[0])
+		}
+		return nil
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+// shared/go/utils/health/disk_other.go
+
+package health
+
+import (
+	"context"
+	"errors"
+)
+
+// DiskSpaceCheck is not supported on this platform and always fails
+func DiskSpaceCheck(path string, minFreeBytes uint64) CheckFunc {
+	return func(ctx context.Context) error {
+		return errors.New("disk space check is not supported on this platform")
+	}
+}
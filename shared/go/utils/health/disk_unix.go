@@ -0,0 +1,27 @@
+//go:build unix
+
+// shared/go/utils/health/disk_unix.go
+
+package health
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+)
+
+// DiskSpaceCheck requires at least minFreeBytes of free space on the file system of path
+func DiskSpaceCheck(path string, minFreeBytes uint64) CheckFunc {
+	return func(ctx context.Context) error {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(path, &stat); err != nil {
+			return fmt.Errorf("statfs %s: %w", path, err)
+		}
+
+		free := stat.Bavail * uint64(stat.Bsize)
+		if free < minFreeBytes {
+			return fmt.Errorf("only %d MB free on %s (minimum %d MB)", free>>20, path, minFreeBytes>>20)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+// shared/go/utils/health/handlers.go
+
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ==========================================
+// PROBE HANDLERS
+// ==========================================
+// /livez    → process is alive (never checks dependencies, a failing DB must not restart the container)
+// /readyz   → startup complete and all critical checks pass, otherwise 503
+// /startupz → startup complete (MarkStarted), otherwise 503
+// /health   → detailed JSON report of all checks, 503 if DOWN
+
+// Paths of the probe endpoints
+const (
+	LivezPath    = "/livez"
+	ReadyzPath   = "/readyz"
+	StartupzPath = "/startupz"
+	HealthPath   = "/health"
+)
+
+// LivezHandler answers the liveness probe
+func (r *Registry) LivezHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status": StatusOK,
+			"uptime": time.Since(r.startTime).Round(time.Second).String(),
+		})
+	}
+}
+
+// ReadyzHandler answers the readiness probe
+func (r *Registry) ReadyzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ready, report := r.Ready(req.Context())
+		if !ready {
+			failed := failedChecks(report, true)
+			sort.Strings(failed)
+			writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+				"status":  StatusDown,
+				"started": report.Started,
+				"failed":  failed,
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status": report.Status,
+		})
+	}
+}
+
+// StartupzHandler answers the startup probe
+func (r *Registry) StartupzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !r.Started() {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+				"status": "STARTING",
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status": StatusOK,
+		})
+	}
+}
+
+// ReportHandler returns the detailed report of all checks
+func (r *Registry) ReportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		report := r.Report(req.Context())
+
+		statusCode := http.StatusOK
+		if report.Status == StatusDown {
+			statusCode = http.StatusServiceUnavailable
+		}
+		writeJSON(w, statusCode, report)
+	}
+}
+
+// writeJSON writes v as JSON response (probes must never be cached)
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,329 @@
+// shared/go/utils/health/health.go
+
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+// ==========================================
+// HEALTH REGISTRY
+// ==========================================
+// Components register named checks (database, message broker, upstreams, disk, ...)
+// Critical checks decide readiness (DOWN), non-critical ones only degrade the report (DEGRADED)
+// Results are cached for the cache TTL, so probes from Docker/Kubernetes/gateways
+// hitting /readyz and /health at the same time do not stampede the dependencies
+
+// Health states
+const (
+	StatusOK       = "OK"
+	StatusDegraded = "DEGRADED"
+	StatusDown     = "DOWN"
+)
+
+// Defaults
+const (
+	DefaultCheckTimeout = 2 * time.Second
+	DefaultCacheTTL     = 5 * time.Second
+)
+
+// CheckFunc returns nil if the dependency is healthy
+// It must respect ctx, which is cancelled after the check's timeout
+type CheckFunc func(ctx context.Context) error
+
+// Check is a named health check
+type Check struct {
+	Name     string
+	Check    CheckFunc
+	Timeout  time.Duration // 0 = DefaultCheckTimeout
+	Critical bool          // A failing critical check makes the service not ready
+}
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Status     string    `json:"status"`
+	Critical   bool      `json:"critical"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// Report is the combined result of all checks
+type Report struct {
+	Status    string                 `json:"status"`
+	Service   string                 `json:"service"`
+	Started   bool                   `json:"started"`
+	Uptime    string                 `json:"uptime"`
+	Checks    map[string]CheckResult `json:"checks"`
+	CheckedAt time.Time              `json:"checked_at"`
+}
+
+// Registry holds the checks of a service
+type Registry struct {
+	service   string
+	cacheTTL  time.Duration
+	startTime time.Time
+
+	mu      sync.Mutex
+	checks  []Check
+	started bool
+	cached  *Report
+	running chan struct{} // Closed when the running evaluation finishes (nil = none running)
+	ready   bool          // Last readiness, used to log changes
+}
+
+// NewRegistry creates an empty registry (cacheTTL 0 = DefaultCacheTTL)
+func NewRegistry(service string, cacheTTL time.Duration) *Registry {
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultCacheTTL
+	}
+	return &Registry{
+		service:   service,
+		cacheTTL:  cacheTTL,
+		startTime: time.Now(),
+		ready:     true,
+	}
+}
+
+// Register adds a check, replacing an existing check with the same name
+func (r *Registry) Register(check Check) {
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultCheckTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.checks {
+		if existing.Name == check.Name {
+			r.checks[i] = check
+			r.cached = nil
+			return
+		}
+	}
+	r.checks = append(r.checks, check)
+	r.cached = nil
+}
+
+// MarkStarted marks the startup as complete (startup probe passes from now on)
+func (r *Registry) MarkStarted() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.started = true
+	r.cached = nil
+}
+
+// Started reports whether MarkStarted was called
+func (r *Registry) Started() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.started
+}
+
+// Report returns the (cached) result of all checks
+// Concurrent callers share a single evaluation
+func (r *Registry) Report(ctx context.Context) Report {
+	r.mu.Lock()
+	for {
+		if r.cached != nil && time.Since(r.cached.CheckedAt) < r.cacheTTL {
+			report := *r.cached
+			r.mu.Unlock()
+			return report
+		}
+		if r.running == nil {
+			break
+		}
+
+		// Another caller is evaluating - wait for its result
+		running := r.running
+		r.mu.Unlock()
+		select {
+		case <-running:
+		case <-ctx.Done():
+			return r.timeoutReport(ctx.Err())
+		}
+		r.mu.Lock()
+	}
+
+	running := make(chan struct{})
+	r.running = running
+	checks := append([]Check(nil), r.checks...)
+	previous := r.cached
+	started := r.started
+	r.mu.Unlock()
+
+	// Run without the caller's context, the shared result must not be cut short by one client
+	report := r.evaluate(checks, started)
+	r.logChanges(previous, &report)
+
+	r.mu.Lock()
+	r.cached = &report
+	r.running = nil
+	r.mu.Unlock()
+	close(running)
+
+	return report
+}
+
+// Ready reports whether all critical checks pass (and startup is complete)
+func (r *Registry) Ready(ctx context.Context) (bool, Report) {
+	report := r.Report(ctx)
+	ready := report.Started && report.Status != StatusDown
+
+	r.mu.Lock()
+	changed := ready != r.ready
+	r.ready = ready
+	r.mu.Unlock()
+
+	if changed && !ready {
+		logger.LogEvent(logger.EventReadinessCheckFailed, map[string]interface{}{
+			"service_name": r.service,
+			"status":       report.Status,
+			"started":      report.Started,
+			"failed":       failedChecks(report, true),
+		})
+	} else if changed {
+		logger.InfoWithFields("Service ready", map[string]interface{}{
+			"service_name": r.service,
+		})
+	}
+
+	return ready, report
+}
+
+// evaluate runs all checks concurrently, each with its own timeout
+func (r *Registry) evaluate(checks []Check, started bool) Report {
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = runCheck(check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:    StatusOK,
+		Service:   r.service,
+		Started:   started,
+		Uptime:    time.Since(r.startTime).Round(time.Second).String(),
+		Checks:    make(map[string]CheckResult, len(checks)),
+		CheckedAt: time.Now(),
+	}
+	for i, check := range checks {
+		result := results[i]
+		report.Checks[check.Name] = result
+		if result.Status == StatusOK {
+			continue
+		}
+		if check.Critical {
+			report.Status = StatusDown
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// runCheck runs a single check with its timeout, recovering from panics
+func runCheck(check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(context.Background(), check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	result := CheckResult{Status: StatusOK, Critical: check.Critical}
+
+	// Run in a goroutine so checks ignoring ctx cannot block the report
+	// The panic is recovered in that goroutine, a recover in runCheck would not see it
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				done <- fmt.Errorf("check panicked: %v", rec)
+			}
+		}()
+		done <- check.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %s", check.Timeout)
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.CheckedAt = time.Now()
+	return result
+}
+
+// logChanges logs checks that started or stopped failing since the previous report
+func (r *Registry) logChanges(previous, current *Report) {
+	for name, result := range current.Checks {
+		wasOK := true
+		if previous != nil {
+			if prev, ok := previous.Checks[name]; ok {
+				wasOK = prev.Status == StatusOK
+			}
+		}
+
+		switch {
+		case wasOK && result.Status != StatusOK:
+			event := logger.EventHealthCheckDegraded
+			if result.Critical {
+				event = logger.EventHealthCheckFailed
+			}
+			logger.LogEvent(event, map[string]interface{}{
+				"service_name": r.service,
+				"check":        name,
+				"critical":     result.Critical,
+				"error":        result.Error,
+			})
+		case !wasOK && result.Status == StatusOK:
+			logger.InfoWithFields("Health check recovered", map[string]interface{}{
+				"service_name": r.service,
+				"check":        name,
+			})
+		}
+	}
+}
+
+// timeoutReport is returned to a caller whose context ended while waiting for the shared evaluation
+func (r *Registry) timeoutReport(err error) Report {
+	if err == nil {
+		err = errors.New("health report unavailable")
+	}
+	return Report{
+		Status:    StatusDown,
+		Service:   r.service,
+		Started:   r.Started(),
+		Uptime:    time.Since(r.startTime).Round(time.Second).String(),
+		Checks:    map[string]CheckResult{"report": {Status: StatusDown, Error: err.Error(), CheckedAt: time.Now()}},
+		CheckedAt: time.Now(),
+	}
+}
+
+// failedChecks lists the names of failing checks (criticalOnly = only critical ones)
+func failedChecks(report Report, criticalOnly bool) []string {
+	var failed []string
+	for name, result := range report.Checks {
+		if result.Status != StatusOK && (result.Critical || !criticalOnly) {
+			failed = append(failed, name)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,223 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+func init() {
+	logger.Init("health-test", "test")
+}
+
+// failing returns a check that fails while fail is set
+func failing(fail *atomic.Bool) CheckFunc {
+	return func(ctx context.Context) error {
+		if fail.Load() {
+			return errors.New("unreachable")
+		}
+		return nil
+	}
+}
+
+func TestReportCriticality(t *testing.T) {
+	var dbDown, cacheDown atomic.Bool
+	registry := NewRegistry("test", time.Nanosecond) // Evaluate on every call
+	registry.Register(Check{Name: "db", Check: failing(&dbDown), Critical: true})
+	registry.Register(Check{Name: "cache", Check: failing(&cacheDown)})
+	registry.MarkStarted()
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		db, cache bool // Failing
+		status    string
+		ready     bool
+	}{
+		{"all pass", false, false, StatusOK, true},
+		{"non-critical fails", false, true, StatusDegraded, true},
+		{"critical fails", true, false, StatusDown, false},
+		{"both fail", true, true, StatusDown, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbDown.Store(tt.db)
+			cacheDown.Store(tt.cache)
+			time.Sleep(time.Millisecond) // Past the cache TTL
+
+			ready, report := registry.Ready(ctx)
+			if report.Status != tt.status || ready != tt.ready {
+				t.Fatalf("status = %s, ready = %v, want %s and %v", report.Status, ready, tt.status, tt.ready)
+			}
+			if got := report.Checks["db"]; got.Critical != true || (got.Status == StatusOK) == tt.db {
+				t.Fatalf("db result = %+v", got)
+			}
+		})
+	}
+}
+
+func TestReadyRequiresStartup(t *testing.T) {
+	registry := NewRegistry("test", 0)
+	if ready, report := registry.Ready(context.Background()); ready || report.Started {
+		t.Fatal("ready before MarkStarted")
+	}
+
+	registry.MarkStarted()
+	if ready, _ := registry.Ready(context.Background()); !ready {
+		t.Fatal("not ready after MarkStarted without failing checks")
+	}
+}
+
+func TestReportIsCached(t *testing.T) {
+	var calls atomic.Int32
+	registry := NewRegistry("test", 50*time.Millisecond)
+	registry.Register(Check{Name: "db", Check: func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}})
+	ctx := context.Background()
+
+	registry.Report(ctx)
+	registry.Report(ctx)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("check ran %d times within the cache TTL, want 1", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	registry.Report(ctx)
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("check ran %d times after the cache TTL, want 2", n)
+	}
+
+	// A new check invalidates the cached report
+	registry.Register(Check{Name: "disk", Check: func(ctx context.Context) error { return nil }})
+	if report := registry.Report(ctx); len(report.Checks) != 2 || calls.Load() != 3 {
+		t.Fatalf("report has %d checks after %d runs, want a fresh report with 2", len(report.Checks), calls.Load())
+	}
+}
+
+func TestConcurrentReportsShareEvaluation(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	registry := NewRegistry("test", time.Minute)
+	registry.Register(Check{Name: "db", Check: func(ctx context.Context) error {
+		calls.Add(1)
+		<-release
+		return nil
+	}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if report := registry.Report(context.Background()); report.Status != StatusOK {
+				t.Errorf("status = %s, want OK", report.Status)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond) // Let all callers queue up
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("check ran %d times for concurrent callers, want 1", n)
+	}
+}
+
+func TestWaitingCallerGivesUpWithItsContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	registry := NewRegistry("test", time.Minute)
+	registry.Register(Check{Name: "db", Timeout: time.Second, Check: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+
+	go registry.Report(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if report := registry.Report(ctx); report.Status != StatusDown || report.Checks["report"].Error == "" {
+		t.Fatalf("report = %+v, want DOWN with the context error", report)
+	}
+}
+
+func TestCheckTimeoutAndPanic(t *testing.T) {
+	registry := NewRegistry("test", 0)
+	registry.Register(Check{Name: "hangs", Timeout: 20 * time.Millisecond, Critical: true, Check: func(ctx context.Context) error {
+		time.Sleep(time.Second) // Ignores ctx
+		return nil
+	}})
+	registry.Register(Check{Name: "panics", Check: func(ctx context.Context) error {
+		panic("boom")
+	}})
+
+	start := time.Now()
+	report := registry.Report(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("report took %v, want the check timeout to cut it short", elapsed)
+	}
+	if got := report.Checks["hangs"]; got.Status != StatusDown || !strings.Contains(got.Error, "timed out") {
+		t.Fatalf("hanging check = %+v, want DOWN with a timeout", got)
+	}
+	if got := report.Checks["panics"]; got.Status != StatusDown || !strings.Contains(got.Error, "panicked") {
+		t.Fatalf("panicking check = %+v, want DOWN with the panic", got)
+	}
+}
+
+func TestProbeHandlers(t *testing.T) {
+	var dbDown atomic.Bool
+	registry := NewRegistry("test", time.Nanosecond)
+	registry.Register(Check{Name: "db", Check: failing(&dbDown), Critical: true})
+
+	serve := func(handler http.HandlerFunc) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Header().Get("Cache-Control") != "no-store" {
+			t.Fatal("probe response cacheable")
+		}
+		var body map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body
+	}
+
+	// Starting: alive, not started, not ready
+	if code, _ := serve(registry.LivezHandler()); code != http.StatusOK {
+		t.Fatalf("livez = %d while starting, want 200", code)
+	}
+	if code, _ := serve(registry.StartupzHandler()); code != http.StatusServiceUnavailable {
+		t.Fatalf("startupz = %d while starting, want 503", code)
+	}
+	if code, body := serve(registry.ReadyzHandler()); code != http.StatusServiceUnavailable || body["started"] != false {
+		t.Fatalf("readyz = %d %v while starting, want 503", code, body)
+	}
+
+	registry.MarkStarted()
+	if code, _ := serve(registry.ReadyzHandler()); code != http.StatusOK {
+		t.Fatalf("readyz = %d after startup, want 200", code)
+	}
+
+	// A failing critical dependency fails readiness, never liveness
+	dbDown.Store(true)
+	time.Sleep(time.Millisecond)
+	if code, body := serve(registry.ReadyzHandler()); code != http.StatusServiceUnavailable || len(body["failed"].([]interface{})) != 1 {
+		t.Fatalf("readyz = %d %v with the database down, want 503 naming db", code, body)
+	}
+	if code, body := serve(registry.ReportHandler()); code != http.StatusServiceUnavailable || body["status"] != StatusDown {
+		t.Fatalf("health = %d %v with the database down, want 503 DOWN", code, body)
+	}
+	if code, _ := serve(registry.LivezHandler()); code != http.StatusOK {
+		t.Fatalf("livez = %d with the database down, want 200", code)
+	}
+}
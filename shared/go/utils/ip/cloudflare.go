@@ -0,0 +1,238 @@
+package ip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+// ==========================================
+// CLOUDFLARE
+// ==========================================
+
+// CloudflareIPRanges contains Cloudflare's IP ranges (built-in fallback until the first successful refresh)
+// Update from: https://www.cloudflare.com/ips/
+var CloudflareIPRanges = []string{
+	// IPv4
+	"173.245.48.0/20",
+	"103.21.244.0/22",
+	"103.22.200.0/22",
+	"103.31.4.0/22",
+	"141.101.64.0/18",
+	"108.162.192.0/18",
+	"190.93.240.0/20",
+	"188.114.96.0/20",
+	"197.234.240.0/22",
+	"198.41.128.0/17",
+	"162.158.0.0/15",
+	"104.16.0.0/13",
+	"104.24.0.0/14",
+	"172.64.0.0/13",
+	"131.0.72.0/22",
+	// IPv6
+	"2400:cb00::/32",
+	"2606:4700::/32",
+	"2803:f800::/32",
+	"2405:b500::/32",
+	"2405:8100::/32",
+	"2a06:98c0::/29",
+	"2c0f:f248::/32",
+}
+
+// CloudflareRefreshTimeout limits one refresh (all sources)
+const CloudflareRefreshTimeout = 30 * time.Second
+
+// maxCloudflareListSize limits the size of a downloaded range list
+const maxCloudflareListSize = 1 << 20
+
+// CloudflareRanges holds Cloudflare's IP ranges, optionally refreshed on a schedule
+// Lookups are lock-free, a refresh swaps the whole set. A failed refresh keeps the last known good set
+type CloudflareRanges struct {
+	set atomic.Pointer[PrefixSet]
+
+	mu       sync.Mutex
+	sources  []string
+	interval time.Duration
+	stop     chan struct{}
+	client   *http.Client
+}
+
+// Cloudflare is the range set used by the gateway (configured in BuildMiddlewareStack)
+var Cloudflare = NewCloudflareRanges()
+
+// NewCloudflareRanges creates a range set with the built-in CloudflareIPRanges
+func NewCloudflareRanges() *CloudflareRanges {
+	c := &CloudflareRanges{client: &http.Client{Timeout: CloudflareRefreshTimeout}}
+	set := NewPrefixSet()
+	for _, cidr := range CloudflareIPRanges {
+		set.Add(netip.MustParsePrefix(cidr))
+	}
+	c.set.Store(set)
+	return c
+}
+
+// Contains reports whether addr belongs to Cloudflare
+func (c *CloudflareRanges) Contains(addr netip.Addr) bool { return c.set.Load().Contains(addr) }
+
+// Len returns the number of ranges
+func (c *CloudflareRanges) Len() int { return c.set.Load().Len() }
+
+// Refresh replaces the ranges with the union of all sources (URLs or local files, one IP/CIDR per line)
+// All sources must load and contain at least one range, otherwise the current ranges are kept
+func (c *CloudflareRanges) Refresh(ctx context.Context, sources []string) error {
+	if len(sources) == 0 {
+		return errors.New("no sources")
+	}
+
+	set := NewPrefixSet()
+	for _, source := range sources {
+		prefixes, err := c.load(ctx, source)
+		if err != nil {
+			return err
+		}
+		if len(prefixes) == 0 {
+			return fmt.Errorf("%s: no ranges", source)
+		}
+		for _, prefix := range prefixes {
+			set.Add(prefix)
+		}
+	}
+
+	c.set.Store(set)
+	return nil
+}
+
+// load reads one source
+func (c *CloudflareRanges) load(ctx context.Context, source string) ([]netip.Prefix, error) {
+	if !IsURLSource(source) {
+		return ReadPrefixFile(source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %d", source, resp.StatusCode)
+	}
+	prefixes, err := ParsePrefixList(io.LimitReader(resp.Body, maxCloudflareListSize))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return prefixes, nil
+}
+
+// Configure sets the refresh sources and interval (no sources: built-in ranges, interval <= 0: load once)
+// The first refresh runs immediately, unchanged settings keep the running schedule (safe to call on every reload)
+func (c *CloudflareRanges) Configure(sources []string, interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stop != nil && slices.Equal(c.sources, sources) && c.interval == interval {
+		return
+	}
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.sources = slices.Clone(sources)
+	c.interval = interval
+
+	if len(sources) == 0 {
+		return
+	}
+	c.stop = make(chan struct{})
+	go c.run(c.sources, interval, c.stop)
+}
+
+// Stop ends the refresh schedule, the current ranges are kept
+func (c *CloudflareRanges) Stop() { c.Configure(nil, 0) }
+
+// run refreshes until stop is closed (once without interval)
+func (c *CloudflareRanges) run(sources []string, interval time.Duration, stop chan struct{}) {
+	if interval <= 0 {
+		c.refreshAndLog(sources, stop)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.refreshAndLog(sources, stop)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshAndLog runs one refresh, cancelled when stop is closed
+func (c *CloudflareRanges) refreshAndLog(sources []string, stop chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), CloudflareRefreshTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := c.Refresh(ctx, sources); err != nil {
+		if ctx.Err() != nil && isClosed(stop) {
+			return
+		}
+		logger.LogEvent(logger.EventCloudflareRefreshFailed, map[string]interface{}{
+			"sources": sources,
+			"ranges":  c.Len(),
+			"error":   err.Error(),
+		})
+		return
+	}
+	logger.LogEvent(logger.EventCloudflareRangesRefreshed, map[string]interface{}{
+		"sources": sources,
+		"ranges":  c.Len(),
+	})
+}
+
+// isClosed reports whether ch is closed
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsURLSource reports whether a range source is a URL (otherwise it is a file path)
+func IsURLSource(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
+}
+
+// IsCloudflareRequest checks if the request claims to come through Cloudflare (headers only, see Resolver for trust)
+func IsCloudflareRequest(r *http.Request) bool {
+	// Cloudflare adds specific headers
+	return r.Header.Get("CF-Ray") != "" ||
+		r.Header.Get("CF-Connecting-IP") != "" ||
+		r.Header.Get("CF-Visitor") != ""
+}
@@ -0,0 +1,168 @@
+package ip
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+func init() {
+	logger.Init("ip-test", "test") // Scheduled refreshes are logged
+}
+
+// rangeSource is an httptest range list whose response can be changed
+type rangeSource struct {
+	mu       sync.Mutex
+	status   int
+	body     string
+	requests atomic.Int32
+	*httptest.Server
+}
+
+// newRangeSource serves body with 200 until set is called
+func newRangeSource(t *testing.T, body string) *rangeSource {
+	s := &rangeSource{status: http.StatusOK, body: body}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.WriteHeader(s.status)
+		fmt.Fprint(w, s.body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *rangeSource) set(status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.body = status, body
+}
+
+func TestCloudflareRefresh(t *testing.T) {
+	ctx := context.Background()
+	source := newRangeSource(t, "192.0.2.0/24\n2001:db8::/32\n")
+	c := NewCloudflareRanges()
+	builtIn := c.Len()
+
+	// Without sources the built-in ranges stay
+	if err := c.Refresh(ctx, nil); err == nil || c.Len() != builtIn {
+		t.Fatalf("Refresh(nil) = %v with %d ranges, want an error and %d", err, c.Len(), builtIn)
+	}
+
+	if err := c.Refresh(ctx, []string{source.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 2 || !c.Contains(netip.MustParseAddr("192.0.2.1")) || c.Contains(netip.MustParseAddr("104.16.0.1")) {
+		t.Fatalf("ranges after refresh: %d, want only the 2 downloaded", c.Len())
+	}
+
+	// Failed refreshes keep the last known good ranges
+	failures := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"non-200 response", http.StatusServiceUnavailable, "198.51.100.0/24\n"},
+		{"empty list", http.StatusOK, ""},
+		{"comments only", http.StatusOK, "# maintenance\n"},
+		{"invalid entry", http.StatusOK, "198.51.100.0/24\nnot-a-range\n"},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			source.set(tt.status, tt.body)
+			if err := c.Refresh(ctx, []string{source.URL}); err == nil {
+				t.Fatal("Refresh() = nil, want an error")
+			}
+			if c.Len() != 2 || !c.Contains(netip.MustParseAddr("192.0.2.1")) || c.Contains(netip.MustParseAddr("198.51.100.1")) {
+				t.Fatalf("ranges changed by a failed refresh: %d", c.Len())
+			}
+		})
+	}
+
+	// All sources must load, a failing one keeps the ranges of the others out
+	source.set(http.StatusOK, "198.51.100.0/24\n")
+	other := newRangeSource(t, "")
+	other.set(http.StatusNotFound, "")
+	if err := c.Refresh(ctx, []string{source.URL, other.URL}); err == nil || c.Contains(netip.MustParseAddr("198.51.100.1")) {
+		t.Fatalf("Refresh() with a failing source = %v, want an error and the old ranges", err)
+	}
+
+	// Sources are merged, files work like URLs
+	file := filepath.Join(t.TempDir(), "cloudflare.txt")
+	if err := os.WriteFile(file, []byte("203.0.113.0/24 # file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Refresh(ctx, []string{source.URL, file}); err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []string{"198.51.100.1", "203.0.113.1"} {
+		if !c.Contains(netip.MustParseAddr(addr)) {
+			t.Fatalf("%s missing after merging sources", addr)
+		}
+	}
+}
+
+func TestCloudflareConfigure(t *testing.T) {
+	source := newRangeSource(t, "192.0.2.0/24\n")
+	c := NewCloudflareRanges()
+	defer c.Stop()
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// The first refresh runs immediately
+	c.Configure([]string{source.URL}, time.Hour)
+	waitFor("the first refresh", func() bool { return c.Contains(netip.MustParseAddr("192.0.2.1")) })
+
+	// Unchanged settings keep the schedule, no extra refresh
+	for range 3 {
+		c.Configure([]string{source.URL}, time.Hour)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := source.requests.Load(); n != 1 {
+		t.Fatalf("%d requests after reconfiguring with the same settings, want 1", n)
+	}
+
+	// New settings restart the schedule with an immediate refresh
+	source.set(http.StatusOK, "198.51.100.0/24\n")
+	c.Configure([]string{source.URL}, 2*time.Hour)
+	waitFor("the refresh after reconfiguring", func() bool { return c.Contains(netip.MustParseAddr("198.51.100.1")) })
+	if n := source.requests.Load(); n != 2 {
+		t.Fatalf("%d requests after changing the interval, want 2", n)
+	}
+
+	// A failing source on reconfigure keeps the last known good ranges
+	failing := newRangeSource(t, "")
+	failing.set(http.StatusInternalServerError, "")
+	c.Configure([]string{failing.URL}, time.Hour)
+	waitFor("the failing refresh", func() bool { return failing.requests.Load() == 1 })
+	time.Sleep(50 * time.Millisecond)
+	if !c.Contains(netip.MustParseAddr("198.51.100.1")) {
+		t.Fatal("ranges lost after a failed refresh")
+	}
+
+	// Stop ends the schedule and keeps the ranges
+	c.Stop()
+	if !c.Contains(netip.MustParseAddr("198.51.100.1")) {
+		t.Fatal("ranges lost after Stop()")
+	}
+}
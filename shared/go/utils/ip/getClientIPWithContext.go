@@ -11,10 +11,15 @@ import (
 // GetClientIPWithContext returns client IP with additional context
 // Useful for detailed logging and debugging
 func GetClientIPWithContext(r *http.Request) map[string]string {
+	resolver := DefaultResolver()
+	peer, _ := PeerIP(r)
+
 	context := map[string]string{
-		"ip":               getClientIP(r),
+		"ip":               resolver.ClientIP(r),
 		"remote_addr":      r.RemoteAddr,
+		"peer_trusted":     conversion.BoolToString(resolver.IsTrusted(peer)),
 		"x_forwarded_for":  r.Header.Get("X-Forwarded-For"),
+		"forwarded":        r.Header.Get("Forwarded"),
 		"x_real_ip":        r.Header.Get("X-Real-IP"),
 		"cf_connecting_ip": r.Header.Get("CF-Connecting-IP"),
 		"true_client_ip":   r.Header.Get("True-Client-IP"),
@@ -3,83 +3,202 @@ package ip
 import (
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
+	"sync/atomic"
 )
 
 // ==========================================
-// CLIENT IP EXTRACTION
+// CLIENT IP RESOLUTION (TRUSTED PROXIES)
 // ==========================================
+// Forwarding headers are only honoured when the immediate peer (RemoteAddr) is a trusted proxy,
+// anyone else could send them to spoof their address:
+// 1. CF-Connecting-IP / True-Client-IP - only if the peer is a trusted Cloudflare edge
+// 2. Forwarded (RFC 7239) - "for=" values, walked right-to-left
+// 3. X-Forwarded-For - walked right-to-left
+// 4. X-Real-IP
+// 5. RemoteAddr
+//
+// Right-to-left: every trusted proxy appends the address it received the request from,
+// so the first untrusted address from the right is the client. Entries left of it are client-supplied
 
-// getClientIP extracts the real client IP from request headers
-// Handles various proxy scenarios including Cloudflare, AWS, GCP, etc.
-// Returns the most trustworthy IP address found
-func getClientIP(r *http.Request) string {
-	// Priority order of IP sources (most trustworthy first):
-	// 1. CF-Connecting-IP (Cloudflare - most reliable)
-	// 2. True-Client-IP (Cloudflare Enterprise & Akamai)
-	// 3. X-Real-IP (Nginx proxy)
-	// 4. X-Forwarded-For (Standard, but can be spoofed)
-	// 5. RemoteAddr (Direct connection fallback)
-
-	// 1. Cloudflare: CF-Connecting-IP
-	// This is the most reliable when using Cloudflare
-	if ip := r.Header.Get("CF-Connecting-IP"); ip != "" {
-		if validIP := validateAndCleanIP(ip); validIP != "" {
-			return validIP
-		}
+// ResolverOptions configures a Resolver
+type ResolverOptions struct {
+	TrustedProxies []netip.Prefix    // Proxies whose forwarding headers are honoured (load balancers, nginx, ...)
+	Cloudflare     *CloudflareRanges // Cloudflare edges, trusted for X-Forwarded-For and CF-Connecting-IP (nil: none)
+}
+
+// Resolver resolves the client IP of requests
+type Resolver struct {
+	trusted    *PrefixSet
+	cloudflare *CloudflareRanges
+}
+
+// DefaultTrustedProxies are trusted until SetDefaultResolver is called: loopback only
+// Deployments behind a reverse proxy list its address (securityConfig.json trustedProxies, TRUSTED_PROXIES),
+// trusting whole private networks would let any host in them spoof client addresses
+var DefaultTrustedProxies = []string{
+	"127.0.0.0/8",
+	"::1/128",
+}
+
+var defaultResolver atomic.Pointer[Resolver]
+
+func init() {
+	prefixes := make([]netip.Prefix, 0, len(DefaultTrustedProxies))
+	for _, cidr := range DefaultTrustedProxies {
+		prefixes = append(prefixes, netip.MustParsePrefix(cidr))
 	}
+	SetDefaultResolver(NewResolver(ResolverOptions{TrustedProxies: prefixes}))
+}
 
-	// 2. Cloudflare Enterprise / Akamai: True-Client-IP
-	if ip := r.Header.Get("True-Client-IP"); ip != "" {
-		if validIP := validateAndCleanIP(ip); validIP != "" {
-			return validIP
-		}
+// NewResolver creates a resolver
+func NewResolver(opts ResolverOptions) *Resolver {
+	return &Resolver{
+		trusted:    NewPrefixSet(opts.TrustedProxies...),
+		cloudflare: opts.Cloudflare,
 	}
+}
 
-	// 3. Nginx / General: X-Real-IP
-	if ip := r.Header.Get("X-Real-IP"); ip != "" {
-		if validIP := validateAndCleanIP(ip); validIP != "" {
-			return validIP
-		}
+// SetDefaultResolver replaces the resolver used by ClientIP (e.g. after a config reload)
+func SetDefaultResolver(r *Resolver) { defaultResolver.Store(r) }
+
+// DefaultResolver returns the resolver used by ClientIP
+func DefaultResolver() *Resolver { return defaultResolver.Load() }
+
+// ClientIP returns the client IP of r using the default resolver ("unknown" if none can be determined)
+func ClientIP(r *http.Request) string { return DefaultResolver().ClientIP(r) }
+
+// ClientIP returns the client IP of r ("unknown" if none can be determined)
+func (res *Resolver) ClientIP(r *http.Request) string {
+	peer, ok := PeerIP(r)
+	if !ok {
+		return "unknown"
+	}
+	if !res.IsTrusted(peer) {
+		return peer.String()
 	}
 
-	// 4. Standard proxy chain: X-Forwarded-For
-	// Format: "client, proxy1, proxy2"
-	// We want the leftmost (original client) IP that is NOT a private/internal IP
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// Split by comma and take the first public IP
-		ips := strings.Split(xff, ",")
-		for _, ip := range ips {
-			ip = strings.TrimSpace(ip)
-			if validIP := validateAndCleanIP(ip); validIP != "" {
-				// Skip private/internal IPs (they are proxies, not the real client)
-				if !isPrivateIP(validIP) {
-					return validIP
-				}
+	// 1. Cloudflare: CF-Connecting-IP, Cloudflare Enterprise: True-Client-IP
+	if res.IsCloudflare(peer) {
+		for _, header := range []string{"CF-Connecting-IP", "True-Client-IP"} {
+			if addr, ok := parseAddr(r.Header.Get(header)); ok {
+				return addr.String()
 			}
 		}
 	}
 
-	// 5. Fallback: Direct connection RemoteAddr
-	if ip := r.RemoteAddr; ip != "" {
-		// Remove port if present (format: "IP:port")
-		if host, _, err := net.SplitHostPort(ip); err == nil {
-			ip = host
+	// 2. Forwarded (RFC 7239)
+	if hops := forwardedFor(r.Header.Values("Forwarded")); len(hops) > 0 {
+		return res.walk(hops, peer).String()
+	}
+
+	// 3. X-Forwarded-For
+	if hops := xForwardedFor(r.Header.Values("X-Forwarded-For")); len(hops) > 0 {
+		return res.walk(hops, peer).String()
+	}
+
+	// 4. X-Real-IP (set by the trusted proxy)
+	if addr, ok := parseAddr(r.Header.Get("X-Real-IP")); ok {
+		return addr.String()
+	}
+
+	// 5. Direct connection
+	return peer.String()
+}
+
+// IsTrusted reports whether addr is a trusted proxy (or a trusted Cloudflare edge)
+func (res *Resolver) IsTrusted(addr netip.Addr) bool {
+	return res.trusted.Contains(addr) || res.IsCloudflare(addr)
+}
+
+// IsCloudflare reports whether addr is a Cloudflare edge trusted by the resolver
+func (res *Resolver) IsCloudflare(addr netip.Addr) bool {
+	return res.cloudflare != nil && res.cloudflare.Contains(addr)
+}
+
+// walk returns the first untrusted hop from the right
+// An unparsable hop ends the walk, the last valid address (a trusted proxy) is returned then;
+// if all hops are trusted the leftmost one is the client
+func (res *Resolver) walk(hops []string, peer netip.Addr) netip.Addr {
+	last := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(hops[i])
+		if !ok {
+			return last
 		}
-		if validIP := validateAndCleanIP(ip); validIP != "" {
-			return validIP
+		if !res.IsTrusted(addr) {
+			return addr
 		}
+		last = addr
+	}
+	return last
+}
+
+// PeerIP returns the address of the immediate peer (RemoteAddr without port)
+func PeerIP(r *http.Request) (netip.Addr, bool) {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
 	}
+	return parseAddr(host)
+}
 
-	// Ultimate fallback
-	return "unknown"
+// xForwardedFor splits X-Forwarded-For headers (multiple header lines are one list)
+func xForwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
 }
 
-// isCloudflareRequest checks if the request is coming through Cloudflare
-// Useful for additional validation or specific handling
-func IsCloudflareRequest(r *http.Request) bool {
-	// Cloudflare adds specific headers
-	return r.Header.Get("CF-Ray") != "" ||
-		r.Header.Get("CF-Connecting-IP") != "" ||
-		r.Header.Get("CF-Visitor") != ""
+// forwardedFor returns the "for" parameters of Forwarded headers in order
+// Example: Forwarded: for=192.0.2.60;proto=https, for="[2001:db8:cafe::17]:4711"
+// Elements without "for" are kept as "" (an unknown hop)
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			if strings.TrimSpace(element) == "" {
+				continue
+			}
+			hop := ""
+			for _, pair := range strings.Split(element, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(strings.TrimSpace(key), "for") {
+					hop = strings.Trim(strings.TrimSpace(val), `"`)
+					break
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// parseAddr parses an IP with optional port or brackets ("1.2.3.4", "1.2.3.4:80", "[::1]:80", "[::1]", "::1")
+// Obfuscated identifiers ("unknown", "_hidden") are not addresses
+func parseAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}, false
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), true
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	} else {
+		s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
 }
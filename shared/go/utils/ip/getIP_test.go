@@ -0,0 +1,101 @@
+package ip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestResolverClientIP(t *testing.T) {
+	withCloudflare := NewResolver(ResolverOptions{
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		Cloudflare:     NewCloudflareRanges(), // Built-in ranges, 104.16.0.0/13 among them
+	})
+	withoutCloudflare := NewResolver(ResolverOptions{
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	})
+
+	tests := []struct {
+		name       string
+		resolver   *Resolver
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		// Untrusted peers
+		{"untrusted peer without headers", withCloudflare, "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"untrusted peer with X-Forwarded-For", withCloudflare, "203.0.113.5:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.5"},
+		{"untrusted peer with Forwarded", withCloudflare, "203.0.113.5:1234", map[string][]string{"Forwarded": {"for=198.51.100.1"}}, "203.0.113.5"},
+		{"untrusted peer with X-Real-IP", withCloudflare, "203.0.113.5:1234", map[string][]string{"X-Real-IP": {"198.51.100.1"}}, "203.0.113.5"},
+		{"untrusted peer with CF-Connecting-IP", withCloudflare, "203.0.113.5:1234", map[string][]string{"CF-Connecting-IP": {"198.51.100.1"}}, "203.0.113.5"},
+		{"invalid RemoteAddr", withCloudflare, "not-an-ip", nil, "unknown"},
+
+		// X-Forwarded-For from a trusted proxy
+		{"single hop", withCloudflare, "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.5"}}, "203.0.113.5"},
+		{"spoofed leftmost entry", withCloudflare, "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1, 203.0.113.5"}}, "203.0.113.5"},
+		{"spoofed trusted entry", withCloudflare, "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"10.9.9.9, 203.0.113.5"}}, "203.0.113.5"},
+		{"chain of trusted proxies", withCloudflare, "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1, 203.0.113.5, 10.0.0.3, 10.0.0.2"}}, "203.0.113.5"},
+		{"all-trusted chain", withCloudflare, "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{"multiple header lines", withCloudflare, "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1", "203.0.113.5"}}, "203.0.113.5"},
+		{"unparsable hop ends the walk", withCloudflare, "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.5, garbage, 10.0.0.2"}}, "10.0.0.2"},
+		{"hop with port", withCloudflare, "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.5:4711"}}, "203.0.113.5"},
+		{"v4-mapped peer", withCloudflare, "[::ffff:10.0.0.1]:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.5"}}, "203.0.113.5"},
+		{"X-Real-IP without X-Forwarded-For", withCloudflare, "10.0.0.1:1234", map[string][]string{"X-Real-IP": {"203.0.113.5"}}, "203.0.113.5"},
+		{"trusted peer without headers", withCloudflare, "10.0.0.1:1234", nil, "10.0.0.1"},
+
+		// Forwarded (RFC 7239) from a trusted proxy, takes precedence over X-Forwarded-For
+		{"Forwarded", withCloudflare, "10.0.0.1:1234", map[string][]string{"Forwarded": {`for=198.51.100.1;proto=https, for="[2001:db8:cafe::17]:4711"`}, "X-Forwarded-For": {"192.0.2.1"}}, "2001:db8:cafe::17"},
+		{"Forwarded case-insensitive parameter", withCloudflare, "10.0.0.1:1234", map[string][]string{"Forwarded": {"proto=https;For=203.0.113.5"}}, "203.0.113.5"},
+		{"Forwarded obfuscated identifier", withCloudflare, "10.0.0.1:1234", map[string][]string{"Forwarded": {"for=198.51.100.1, for=unknown"}}, "10.0.0.1"},
+		{"Forwarded element without for", withCloudflare, "10.0.0.1:1234", map[string][]string{"Forwarded": {"for=198.51.100.1, proto=https"}}, "10.0.0.1"},
+		{"Forwarded without separators", withCloudflare, "10.0.0.1:1234", map[string][]string{"Forwarded": {"for"}}, "10.0.0.1"},
+		{"Forwarded garbage value", withCloudflare, "10.0.0.1:1234", map[string][]string{"Forwarded": {`for="[not-an-ip]:80"`}}, "10.0.0.1"},
+		{"Forwarded empty elements", withCloudflare, "10.0.0.1:1234", map[string][]string{"Forwarded": {" , ,for=203.0.113.5"}}, "203.0.113.5"},
+
+		// Cloudflare
+		{"Cloudflare edge", withCloudflare, "104.16.0.1:443", map[string][]string{"CF-Connecting-IP": {"203.0.113.5"}, "X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.5"},
+		{"Cloudflare True-Client-IP", withCloudflare, "104.16.0.1:443", map[string][]string{"True-Client-IP": {"203.0.113.5"}}, "203.0.113.5"},
+		{"Cloudflare invalid CF-Connecting-IP", withCloudflare, "104.16.0.1:443", map[string][]string{"CF-Connecting-IP": {"garbage"}, "X-Forwarded-For": {"203.0.113.5"}}, "203.0.113.5"},
+		{"Cloudflare edge behind trusted proxy", withCloudflare, "10.0.0.1:1234", map[string][]string{"CF-Connecting-IP": {"198.51.100.1"}, "X-Forwarded-For": {"203.0.113.5, 104.16.0.1"}}, "203.0.113.5"},
+		{"CF-Connecting-IP from non-Cloudflare proxy", withCloudflare, "10.0.0.1:1234", map[string][]string{"CF-Connecting-IP": {"198.51.100.1"}}, "10.0.0.1"},
+		{"Cloudflare not trusted", withoutCloudflare, "104.16.0.1:443", map[string][]string{"CF-Connecting-IP": {"203.0.113.5"}}, "104.16.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, values := range tt.headers {
+				for _, value := range values {
+					r.Header.Add(name, value)
+				}
+			}
+			if got := tt.resolver.ClientIP(r); got != tt.want {
+				t.Fatalf("ClientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultTrustedProxiesAreLoopback(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"127.0.0.1:1234", "203.0.113.5"},
+		{"[::1]:1234", "203.0.113.5"},
+		{"10.0.0.1:1234", "10.0.0.1"},
+		{"172.20.0.10:1234", "172.20.0.10"},
+		{"192.168.1.1:1234", "192.168.1.1"},
+		{"[fd00::1]:1234", "fd00::1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		r.Header.Set("X-Forwarded-For", "203.0.113.5")
+		if got := ClientIP(r); got != tt.want {
+			t.Errorf("ClientIP() from %s = %s, want %s", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
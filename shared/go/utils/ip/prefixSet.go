@@ -0,0 +1,168 @@
+package ip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// ==========================================
+// PREFIX SET (CIDR LOOKUP)
+// ==========================================
+// Binary trie over address bits, one per address family. A lookup walks at most
+// 32 (IPv4) or 128 (IPv6) nodes, independent of the number of prefixes.
+// IPv4-mapped IPv6 addresses (::ffff:1.2.3.4) match IPv4 prefixes
+
+// PrefixSet is a set of CIDR prefixes, safe for concurrent reads after it was filled
+type PrefixSet struct {
+	v4, v6 *prefixNode
+	size   int
+}
+
+// prefixNode is a trie node, terminal = a prefix ends here (everything below matches)
+type prefixNode struct {
+	children [2]*prefixNode
+	terminal bool
+}
+
+// NewPrefixSet creates a set from prefixes
+func NewPrefixSet(prefixes ...netip.Prefix) *PrefixSet {
+	s := &PrefixSet{v4: &prefixNode{}, v6: &prefixNode{}}
+	for _, prefix := range prefixes {
+		s.Add(prefix)
+	}
+	return s
+}
+
+// Add inserts a prefix (host bits are ignored)
+func (s *PrefixSet) Add(prefix netip.Prefix) {
+	prefix = prefix.Masked()
+	addr := prefix.Addr().Unmap()
+	node := s.root(addr)
+	bytes := addr.AsSlice()
+
+	for i := 0; i < prefix.Bits(); i++ {
+		if node.terminal {
+			return // A shorter prefix already covers it
+		}
+		bit := bytes[i/8] >> (7 - i%8) & 1
+		if node.children[bit] == nil {
+			node.children[bit] = &prefixNode{}
+		}
+		node = node.children[bit]
+	}
+	if !node.terminal {
+		s.size -= node.count() // Longer prefixes added before are covered by this one now
+		node.terminal = true
+		node.children = [2]*prefixNode{}
+		s.size++
+	}
+}
+
+// count returns the number of prefixes ending at or below the node
+func (n *prefixNode) count() int {
+	if n == nil {
+		return 0
+	}
+	if n.terminal {
+		return 1
+	}
+	return n.children[0].count() + n.children[1].count()
+}
+
+// Contains reports whether addr is in any prefix of the set
+func (s *PrefixSet) Contains(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	node := s.root(addr)
+	bytes := addr.AsSlice()
+
+	for i := 0; node != nil; i++ {
+		if node.terminal {
+			return true
+		}
+		if i == len(bytes)*8 {
+			return false
+		}
+		node = node.children[bytes[i/8]>>(7-i%8)&1]
+	}
+	return false
+}
+
+// ContainsString parses ip and reports whether it is in the set (false for invalid IPs)
+func (s *PrefixSet) ContainsString(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	return s.Contains(addr)
+}
+
+// Len returns the number of prefixes (prefixes covered by a shorter one are not counted)
+func (s *PrefixSet) Len() int { return s.size }
+
+func (s *PrefixSet) root(addr netip.Addr) *prefixNode {
+	if addr.Is4() {
+		return s.v4
+	}
+	return s.v6
+}
+
+// ParsePrefix parses a CIDR ("10.0.0.0/8", "2001:db8::/32") or a single IP (as /32 or /128)
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), max(prefix.Bits()-96, 0))
+		}
+		return prefix, nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// ParsePrefixList reads one IP or CIDR per line, "#" starts a comment
+func ParsePrefixList(r io.Reader) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, scanner.Err()
+}
+
+// ReadPrefixFile reads a list file (see ParsePrefixList)
+func ReadPrefixFile(path string) ([]netip.Prefix, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prefixes, err := ParsePrefixList(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,139 @@
+package ip
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// mustPrefixes parses entries with ParsePrefix
+func mustPrefixes(t *testing.T, entries ...string) []netip.Prefix {
+	t.Helper()
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		prefix, err := ParsePrefix(entry)
+		if err != nil {
+			t.Fatalf("ParsePrefix(%q): %v", entry, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+func TestPrefixSetContains(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefixes []string
+		in       []string
+		out      []string
+		size     int
+	}{
+		{
+			name:     "single addresses",
+			prefixes: []string{"192.0.2.1", "2001:db8::1"},
+			in:       []string{"192.0.2.1", "2001:db8::1"},
+			out:      []string{"192.0.2.2", "192.0.2.0", "2001:db8::2", "::1"},
+			size:     2,
+		},
+		{
+			name:     "nested prefixes",
+			prefixes: []string{"10.1.2.0/24", "10.0.0.0/8", "10.1.0.0/16"},
+			in:       []string{"10.0.0.1", "10.1.2.3", "10.255.255.255"},
+			out:      []string{"11.0.0.0", "9.255.255.255"},
+			size:     1, // Covered by 10.0.0.0/8
+		},
+		{
+			name:     "covering prefix added last",
+			prefixes: []string{"2001:db8:1::/48", "2001:db8:2::/48", "2001:db8::/32"},
+			in:       []string{"2001:db8:3::1", "2001:db8:ffff::1"},
+			out:      []string{"2001:db9::1"},
+			size:     1,
+		},
+		{
+			name:     "host bits are ignored",
+			prefixes: []string{"192.0.2.77/24"},
+			in:       []string{"192.0.2.0", "192.0.2.255"},
+			out:      []string{"192.0.3.0"},
+			size:     1,
+		},
+		{
+			name:     "v4-mapped addresses match IPv4 prefixes",
+			prefixes: []string{"192.0.2.0/24"},
+			in:       []string{"::ffff:192.0.2.10"},
+			out:      []string{"::ffff:192.0.3.10", "::c000:20a"},
+			size:     1,
+		},
+		{
+			name:     "v4-mapped prefixes are stored as IPv4",
+			prefixes: []string{"::ffff:192.0.2.0/120", "::ffff:198.51.100.7"},
+			in:       []string{"192.0.2.10", "198.51.100.7", "::ffff:198.51.100.7"},
+			out:      []string{"192.0.3.10", "198.51.100.8"},
+			size:     2,
+		},
+		{
+			name:     "IPv4 /0 covers only IPv4",
+			prefixes: []string{"0.0.0.0/0"},
+			in:       []string{"0.0.0.0", "255.255.255.255", "::ffff:10.0.0.1"},
+			out:      []string{"::1", "2001:db8::1"},
+			size:     1,
+		},
+		{
+			name:     "IPv6 /0 covers only IPv6",
+			prefixes: []string{"::/0"},
+			in:       []string{"::", "2001:db8::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+			out:      []string{"10.0.0.1", "::ffff:10.0.0.1"},
+			size:     1,
+		},
+		{
+			name:     "empty set",
+			prefixes: nil,
+			out:      []string{"0.0.0.0", "::"},
+			size:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewPrefixSet(mustPrefixes(t, tt.prefixes...)...)
+			for _, addr := range tt.in {
+				if !s.ContainsString(addr) {
+					t.Errorf("Contains(%s) = false, want true", addr)
+				}
+			}
+			for _, addr := range tt.out {
+				if s.ContainsString(addr) {
+					t.Errorf("Contains(%s) = true, want false", addr)
+				}
+			}
+			if s.Len() != tt.size {
+				t.Errorf("Len() = %d, want %d", s.Len(), tt.size)
+			}
+		})
+	}
+}
+
+func TestPrefixSetInvalidAddresses(t *testing.T) {
+	s := NewPrefixSet(mustPrefixes(t, "0.0.0.0/0", "::/0")...)
+	if s.Contains(netip.Addr{}) {
+		t.Error("Contains(zero Addr) = true")
+	}
+	for _, addr := range []string{"", "unknown", "192.0.2.1:80", "10.0.0.0/8"} {
+		if s.ContainsString(addr) {
+			t.Errorf("ContainsString(%q) = true", addr)
+		}
+	}
+}
+
+func TestParsePrefixList(t *testing.T) {
+	prefixes, err := ParsePrefixList(strings.NewReader("# office\n192.0.2.1\n\n  2001:db8::/32  # vpn\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prefixes) != 2 || prefixes[0].String() != "192.0.2.1/32" || prefixes[1].String() != "2001:db8::/32" {
+		t.Fatalf("prefixes = %v, want [192.0.2.1/32 2001:db8::/32]", prefixes)
+	}
+
+	if _, err := ParsePrefixList(strings.NewReader("192.0.2.1\n192.0.2.300\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("err = %v, want an error for line 2", err)
+	}
+}
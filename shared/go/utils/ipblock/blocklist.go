@@ -0,0 +1,317 @@
+// shared/go/utils/ipblock/blocklist.go
+
+package ipblock
+
+import (
+	"container/list"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+// ==========================================
+// TEMPORARY IP BLOCK LIST
+// ==========================================
+// Middleware reports offenses per client IP. When an IP collects Threshold offenses
+// of one kind within the rule's Window, it is blocked for BaseDuration.
+// Every further block doubles the duration (up to MaxDuration); the level is forgotten
+// after ForgetAfter without a block. State is per process (each gateway replica blocks on its own)
+// IPv4 offenders are tracked per address, IPv6 offenders per /64 (a single host usually owns a whole /64).
+// Above MaxTracked a new offender evicts the least recently reported one without active block
+
+// Offense is a kind of abusive request
+type Offense string
+
+const (
+	OffenseRateLimit       Offense = "rate_limit"       // Request rejected by the rate limiter
+	OffenseUnauthorized    Offense = "unauthorized"     // 401 response (credential guessing)
+	OffenseCloudflareSpoof Offense = "cloudflare_spoof" // Cloudflare headers from outside Cloudflare
+	OffenseOversizedBody   Offense = "oversized_body"   // Request body over the size limit
+)
+
+// Defaults
+const (
+	DefaultBaseDuration = time.Minute
+	DefaultMaxDuration  = 24 * time.Hour
+	DefaultForgetAfter  = 24 * time.Hour
+	DefaultMaxTracked   = 100_000
+)
+
+// Rule escalates Threshold offenses within Window into a block (Threshold 0 = never)
+type Rule struct {
+	Threshold int
+	Window    time.Duration
+}
+
+// Options configures a List (zero durations use the defaults)
+type Options struct {
+	Enabled      bool
+	Rules        map[Offense]Rule
+	BaseDuration time.Duration // First block
+	MaxDuration  time.Duration // Cap of the doubling
+	ForgetAfter  time.Duration // Block level resets after this long without a block
+	MaxTracked   int           // Max IPs with recorded offenses (new offenders evict the least recent above)
+}
+
+// Block is an active block
+type Block struct {
+	IP        string    `json:"ip"` // Address (IPv4) or /64 (IPv6)
+	Reason    Offense   `json:"reason"`
+	Level     int       `json:"level"` // 1 for the first block, duration doubles per level
+	BlockedAt time.Time `json:"blocked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// List holds offense counters and blocks, safe for concurrent use
+type List struct {
+	mu        sync.Mutex
+	opts      Options
+	offenders map[string]*offender
+	recent    *list.List // Offender keys, least recently reported last
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// offender is the history of one IP
+type offender struct {
+	elem      *list.Element           // In List.recent
+	offenses  map[Offense][]time.Time // Recent offenses per kind, oldest first
+	level     int
+	lastBlock time.Time
+	block     *Block // Active or expired block (nil = never blocked or forgotten)
+}
+
+// blocked reports whether the offender has an active block
+func (o *offender) blocked(now time.Time) bool {
+	return o.block != nil && now.Before(o.block.ExpiresAt)
+}
+
+// evictScan is how many of the least recent offenders are searched for one without active block
+const evictScan = 64
+
+// Default is the process-wide block list used by the gateway middleware
+var Default = NewList(Options{})
+
+// NewList creates a block list
+func NewList(opts Options) *List {
+	l := &List{offenders: make(map[string]*offender), recent: list.New(), now: time.Now}
+	l.Configure(opts)
+	return l
+}
+
+// Configure replaces the options, recorded offenses and blocks are kept
+func (l *List) Configure(opts Options) {
+	if opts.BaseDuration <= 0 {
+		opts.BaseDuration = DefaultBaseDuration
+	}
+	if opts.MaxDuration <= 0 {
+		opts.MaxDuration = DefaultMaxDuration
+	}
+	if opts.ForgetAfter <= 0 {
+		opts.ForgetAfter = DefaultForgetAfter
+	}
+	if opts.MaxTracked <= 0 {
+		opts.MaxTracked = DefaultMaxTracked
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.opts = opts
+}
+
+// Blocked returns the active block of ip
+func (l *List) Blocked(ip string) (Block, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.opts.Enabled {
+		return Block{}, false
+	}
+	o, ok := l.offenders[offenderKey(ip)]
+	if !ok || !o.blocked(l.now()) {
+		return Block{}, false
+	}
+	return *o.block, true
+}
+
+// Report records an offense of ip and returns the block if it triggered one
+func (l *List) Report(ip string, offense Offense) (Block, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rule := l.opts.Rules[offense]
+	if !l.opts.Enabled || ip == "" || rule.Threshold <= 0 {
+		return Block{}, false
+	}
+
+	now := l.now()
+	l.pruneLocked(now)
+
+	key := offenderKey(ip)
+	o, ok := l.offenders[key]
+	if ok {
+		l.recent.MoveToFront(o.elem)
+	} else {
+		if len(l.offenders) >= l.opts.MaxTracked {
+			l.evictLocked(now)
+		}
+		o = &offender{offenses: make(map[Offense][]time.Time), elem: l.recent.PushFront(key)}
+		l.offenders[key] = o
+	}
+	if o.blocked(now) {
+		return Block{}, false // Already blocked
+	}
+
+	// Keep the offenses within the window, at most Threshold of them
+	cutoff := now.Add(-rule.Window)
+	times := o.offenses[offense]
+	for len(times) > 0 && (!times[0].After(cutoff) || len(times) >= rule.Threshold) {
+		times = times[1:]
+	}
+	times = append(times, now)
+	o.offenses[offense] = times
+	if len(times) < rule.Threshold {
+		return Block{}, false
+	}
+
+	// Escalate
+	if !o.lastBlock.IsZero() && now.Sub(o.lastBlock) > l.opts.ForgetAfter {
+		o.level = 0
+	}
+	o.level++
+	o.lastBlock = now
+	clear(o.offenses)
+	o.block = &Block{
+		IP:        key,
+		Reason:    offense,
+		Level:     o.level,
+		BlockedAt: now,
+		ExpiresAt: now.Add(l.durationLocked(o.level)),
+	}
+
+	logger.LogEvent(logger.EventRateLimitIPBlocked, map[string]interface{}{
+		"client_ip":   key,
+		"reason":      string(offense),
+		"block_level": o.level,
+		"duration":    o.block.ExpiresAt.Sub(now).String(),
+		"expires_at":  o.block.ExpiresAt,
+		"threshold":   rule.Threshold,
+		"window":      rule.Window.String(),
+	})
+	return *o.block, true
+}
+
+// List returns the active blocks, soonest expiry first
+func (l *List) List() []Block {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	blocks := []Block{}
+	for _, o := range l.offenders {
+		if o.blocked(now) {
+			blocks = append(blocks, *o.block)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].ExpiresAt.Before(blocks[j].ExpiresAt) })
+	return blocks
+}
+
+// Unblock lifts the block of ip (or IPv6 /64) and forgets its history
+// Returns false if ip was not blocked
+func (l *List) Unblock(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := offenderKey(ip)
+	o, ok := l.offenders[key]
+	if !ok {
+		return false
+	}
+	l.removeLocked(key)
+	return o.blocked(l.now())
+}
+
+// offenderKey returns the key ip is tracked under: the address for IPv4, the /64 for IPv6
+// IPv6 prefixes of at least 64 bits (as listed by List) map to their /64, other values are used as they are
+func offenderKey(ip string) string {
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		addr = addr.Unmap().WithZone("")
+		if addr.Is4() {
+			return addr.String()
+		}
+		prefix, _ := addr.Prefix(64)
+		return prefix.String()
+	}
+	if prefix, err := netip.ParsePrefix(ip); err == nil && prefix.Addr().Is6() && prefix.Bits() >= 64 {
+		prefix, _ = prefix.Addr().Prefix(64)
+		return prefix.String()
+	}
+	return ip
+}
+
+// evictLocked removes the least recently reported offender without active block,
+// or the least recent one if the evictScan least recent ones are all blocked
+func (l *List) evictLocked(now time.Time) {
+	victim := l.recent.Back()
+	for e, i := victim, 0; e != nil && i < evictScan; e, i = e.Prev(), i+1 {
+		if !l.offenders[e.Value.(string)].blocked(now) {
+			victim = e
+			break
+		}
+	}
+	if victim != nil {
+		l.removeLocked(victim.Value.(string))
+	}
+}
+
+// removeLocked forgets the offender with key
+func (l *List) removeLocked(key string) {
+	l.recent.Remove(l.offenders[key].elem)
+	delete(l.offenders, key)
+}
+
+// durationLocked returns BaseDuration * 2^(level-1), capped at MaxDuration
+func (l *List) durationLocked(level int) time.Duration {
+	duration := l.opts.BaseDuration
+	for i := 1; i < level && duration < l.opts.MaxDuration; i++ {
+		duration *= 2
+	}
+	return min(duration, l.opts.MaxDuration)
+}
+
+// pruneLocked removes offenders without active block, recent block or recent offense (at most once a minute)
+func (l *List) pruneLocked(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+
+	var longestWindow time.Duration
+	for _, rule := range l.opts.Rules {
+		longestWindow = max(longestWindow, rule.Window)
+	}
+
+	for key, o := range l.offenders {
+		if o.blocked(now) {
+			continue
+		}
+		if !o.lastBlock.IsZero() && now.Sub(o.lastBlock) <= l.opts.ForgetAfter {
+			continue
+		}
+		recent := false
+		for _, times := range o.offenses {
+			if len(times) > 0 && now.Sub(times[len(times)-1]) <= longestWindow {
+				recent = true
+				break
+			}
+		}
+		if !recent {
+			l.removeLocked(key)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package ipblock
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+func init() {
+	logger.Init("ipblock-test", "test") // Blocks are logged
+}
+
+// newTestList returns an enabled list with a manual clock and the returned function to advance it
+func newTestList(opts Options) (*List, func(time.Duration)) {
+	opts.Enabled = true
+	l := NewList(opts)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestReportThreshold(t *testing.T) {
+	l, elapse := newTestList(Options{Rules: map[Offense]Rule{
+		OffenseUnauthorized: {Threshold: 3, Window: time.Minute},
+	}})
+
+	// Offenses older than the window do not count
+	l.Report("192.0.2.1", OffenseUnauthorized)
+	l.Report("192.0.2.1", OffenseUnauthorized)
+	elapse(61 * time.Second)
+	if _, blocked := l.Report("192.0.2.1", OffenseUnauthorized); blocked {
+		t.Fatal("blocked with offenses outside the window")
+	}
+
+	// Other kinds and kinds without rule count separately
+	l.Report("192.0.2.1", OffenseRateLimit)
+	if _, blocked := l.Report("192.0.2.1", OffenseUnauthorized); blocked {
+		t.Fatal("blocked after 2 offenses within the window")
+	}
+	block, blocked := l.Report("192.0.2.1", OffenseUnauthorized)
+	if !blocked {
+		t.Fatal("not blocked after 3 offenses within the window")
+	}
+	if block.IP != "192.0.2.1" || block.Reason != OffenseUnauthorized || block.Level != 1 || block.ExpiresAt.Sub(block.BlockedAt) != DefaultBaseDuration {
+		t.Fatalf("block = %+v, want level 1 for %s", block, DefaultBaseDuration)
+	}
+	if _, blocked := l.Blocked("192.0.2.1"); !blocked {
+		t.Fatal("Blocked() = false after the block")
+	}
+	if _, blocked := l.Blocked("192.0.2.2"); blocked {
+		t.Fatal("Blocked() = true for another IP")
+	}
+
+	elapse(DefaultBaseDuration)
+	if _, blocked := l.Blocked("192.0.2.1"); blocked {
+		t.Fatal("Blocked() = true after the block expired")
+	}
+}
+
+func TestReportEscalation(t *testing.T) {
+	l, elapse := newTestList(Options{
+		Rules:        map[Offense]Rule{OffenseRateLimit: {Threshold: 1, Window: time.Minute}},
+		BaseDuration: time.Minute,
+		MaxDuration:  5 * time.Minute,
+		ForgetAfter:  time.Hour,
+	})
+
+	// Every block doubles the duration up to MaxDuration
+	for level, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		block, blocked := l.Report("192.0.2.1", OffenseRateLimit)
+		if !blocked {
+			t.Fatalf("level %d: not blocked", level+1)
+		}
+		if block.Level != level+1 || block.ExpiresAt.Sub(block.BlockedAt) != want {
+			t.Fatalf("block = level %d for %s, want level %d for %s", block.Level, block.ExpiresAt.Sub(block.BlockedAt), level+1, want)
+		}
+
+		// Offenses while blocked are ignored
+		if _, blocked := l.Report("192.0.2.1", OffenseRateLimit); blocked {
+			t.Fatal("blocked again while blocked")
+		}
+		elapse(want)
+	}
+
+	// The level resets after ForgetAfter without a block
+	elapse(time.Hour + time.Second)
+	if block, _ := l.Report("192.0.2.1", OffenseRateLimit); block.Level != 1 {
+		t.Fatalf("level after ForgetAfter = %d, want 1", block.Level)
+	}
+}
+
+func TestReportForgetsStaleOffenders(t *testing.T) {
+	l, elapse := newTestList(Options{
+		Rules:       map[Offense]Rule{OffenseUnauthorized: {Threshold: 2, Window: time.Minute}},
+		ForgetAfter: time.Hour,
+	})
+
+	l.Report("192.0.2.1", OffenseUnauthorized) // One offense, never blocked
+	l.Report("192.0.2.2", OffenseUnauthorized)
+	l.Report("192.0.2.2", OffenseUnauthorized) // Blocked
+
+	// Offenders without recent offense are pruned, blocked ones are kept until ForgetAfter
+	elapse(2 * time.Minute)
+	l.Report("192.0.2.3", OffenseUnauthorized)
+	if _, ok := l.offenders["192.0.2.1"]; ok {
+		t.Fatal("offender without recent offense kept")
+	}
+	if _, ok := l.offenders["192.0.2.2"]; !ok {
+		t.Fatal("recently blocked offender pruned")
+	}
+
+	elapse(time.Hour)
+	l.Report("192.0.2.3", OffenseUnauthorized)
+	if _, ok := l.offenders["192.0.2.2"]; ok {
+		t.Fatal("blocked offender kept after ForgetAfter")
+	}
+	if len(l.offenders) != l.recent.Len() {
+		t.Fatalf("%d offenders but %d in the recency list", len(l.offenders), l.recent.Len())
+	}
+}
+
+func TestReportMaxTracked(t *testing.T) {
+	l, _ := newTestList(Options{
+		Rules:      map[Offense]Rule{OffenseUnauthorized: {Threshold: 2, Window: time.Hour}},
+		MaxTracked: 3,
+	})
+
+	l.Report("192.0.2.1", OffenseUnauthorized)
+	l.Report("192.0.2.1", OffenseUnauthorized) // Blocked
+	l.Report("192.0.2.2", OffenseUnauthorized)
+	l.Report("192.0.2.3", OffenseUnauthorized)
+
+	// A new offender is tracked and evicts the least recent one without block
+	l.Report("192.0.2.4", OffenseUnauthorized)
+	if _, blocked := l.Report("192.0.2.4", OffenseUnauthorized); !blocked {
+		t.Fatal("new offender not tracked above MaxTracked")
+	}
+	if _, ok := l.offenders["192.0.2.2"]; ok {
+		t.Fatal("least recent offender without block kept")
+	}
+	if _, blocked := l.Blocked("192.0.2.1"); !blocked {
+		t.Fatal("blocked offender evicted")
+	}
+	if len(l.offenders) != 3 || l.recent.Len() != 3 {
+		t.Fatalf("tracking %d offenders (%d in the recency list), want 3", len(l.offenders), l.recent.Len())
+	}
+
+	// With all offenders blocked the least recent block goes
+	l.Report("192.0.2.3", OffenseUnauthorized) // Blocked
+	l.Report("192.0.2.5", OffenseUnauthorized)
+	if _, blocked := l.Blocked("192.0.2.1"); blocked {
+		t.Fatal("least recent block kept")
+	}
+	for _, ip := range []string{"192.0.2.3", "192.0.2.4"} {
+		if _, blocked := l.Blocked(ip); !blocked {
+			t.Fatalf("%s evicted", ip)
+		}
+	}
+}
+
+func TestReportIPv6Prefix(t *testing.T) {
+	l, _ := newTestList(Options{Rules: map[Offense]Rule{OffenseUnauthorized: {Threshold: 3, Window: time.Minute}}})
+
+	// Addresses of one /64 count together
+	for i := 1; i <= 3; i++ {
+		l.Report(fmt.Sprintf("2001:db8:1:2::%d", i), OffenseUnauthorized)
+	}
+	block, blocked := l.Blocked("2001:db8:1:2:ffff::1")
+	if !blocked {
+		t.Fatal("other address of the /64 not blocked")
+	}
+	if block.IP != "2001:db8:1:2::/64" {
+		t.Fatalf("block.IP = %s, want 2001:db8:1:2::/64", block.IP)
+	}
+	if _, blocked := l.Blocked("2001:db8:1:3::1"); blocked {
+		t.Fatal("address of another /64 blocked")
+	}
+
+	// IPv4-mapped addresses are tracked as IPv4
+	for range 3 {
+		l.Report("::ffff:192.0.2.1", OffenseUnauthorized)
+	}
+	if _, blocked := l.Blocked("192.0.2.1"); !blocked {
+		t.Fatal("IPv4-mapped offender not blocked as IPv4")
+	}
+
+	// The admin endpoint lifts blocks by address or by the listed prefix
+	if !l.Unblock(block.IP) {
+		t.Fatal("Unblock(prefix) = false")
+	}
+	if _, blocked := l.Blocked("2001:db8:1:2::1"); blocked {
+		t.Fatal("still blocked after Unblock")
+	}
+	if !l.Unblock("192.0.2.1") || l.Unblock("192.0.2.1") {
+		t.Fatal("Unblock(ip) did not lift the block exactly once")
+	}
+}
@@ -0,0 +1,72 @@
+// shared/go/utils/jwt/claims.go
+
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+// ==========================================
+// CLAIMS
+// ==========================================
+
+// Claims are the registered claims of a token plus the roles used for authorization
+type Claims struct {
+	Subject   string   `json:"sub"`           // User ID
+	Issuer    string   `json:"iss,omitempty"` // Issuing service
+	Audience  Audience `json:"aud,omitempty"` // Intended recipients
+	ExpiresAt int64    `json:"exp"`           // Unix seconds, required
+	NotBefore int64    `json:"nbf,omitempty"` // Unix seconds
+	IssuedAt  int64    `json:"iat,omitempty"` // Unix seconds
+	ID        string   `json:"jti,omitempty"` // Unique token ID
+	SessionID string   `json:"sid,omitempty"` // Server-side session the token was issued with, revoked together
+	Roles     []string `json:"roles,omitempty"`
+}
+
+// HasRole reports whether the claims contain one of roles
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(c.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Audience is the "aud" claim, a single string or an array of strings (RFC 7519 4.1.3)
+type Audience []string
+
+// MarshalJSON writes a single audience as string
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON accepts a string or an array of strings
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = multiple
+	return nil
+}
+
+// Contains reports whether the audience contains one of audiences
+func (a Audience) Contains(audiences ...string) bool {
+	for _, audience := range audiences {
+		if slices.Contains(a, audience) {
+			return true
+		}
+	}
+	return false
+}
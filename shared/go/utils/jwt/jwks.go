@@ -0,0 +1,174 @@
+// shared/go/utils/jwt/jwks.go
+
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+)
+
+// ==========================================
+// JSON WEB KEY SETS (RFC 7517)
+// ==========================================
+
+// JWKSPath is where the key set is served
+const JWKSPath = "/.well-known/jwks.json"
+
+// JWK is a public key in JSON Web Key format (RSA or Ed25519)
+type JWK struct {
+	KeyType   string `json:"kty"`           // "RSA" or "OKP"
+	KeyID     string `json:"kid,omitempty"` // Matches the "kid" header of tokens
+	Use       string `json:"use,omitempty"` // "sig"
+	Algorithm string `json:"alg,omitempty"` // "RS256" or "EdDSA"
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // "Ed25519"
+	X         string `json:"x,omitempty"`   // Ed25519 public key
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS converts the public parts of keys into a key set (HS256 keys are never published)
+func PublicJWKS(keys []Key) JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range keys {
+		if jwk, err := publicJWK(key); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// publicJWK converts the public part of key
+func publicJWK(key Key) (JWK, error) {
+	switch public := key.Public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   "RSA",
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: AlgRS256,
+			N:         encoding.EncodeToString(public.N.Bytes()),
+			E:         encoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType:   "OKP",
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: AlgEdDSA,
+			Curve:     "Ed25519",
+			X:         encoding.EncodeToString(public),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("jwt: key %q has no public key", key.ID)
+	}
+}
+
+// Key converts the JWK into a verification key
+func (j JWK) Key() (Key, error) {
+	if j.Use != "" && j.Use != "sig" {
+		return Key{}, fmt.Errorf("jwt: key %q is not a signing key", j.KeyID)
+	}
+
+	switch j.KeyType {
+	case "RSA":
+		n, errN := encoding.DecodeString(j.N)
+		e, errE := encoding.DecodeString(j.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return Key{}, fmt.Errorf("jwt: invalid RSA key %q", j.KeyID)
+		}
+		public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return NewPublicKey(j.KeyID, public)
+	case "OKP":
+		x, err := encoding.DecodeString(j.X)
+		if err != nil || j.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return Key{}, fmt.Errorf("jwt: invalid Ed25519 key %q", j.KeyID)
+		}
+		return NewPublicKey(j.KeyID, ed25519.PublicKey(x))
+	default:
+		return Key{}, fmt.Errorf("jwt: unsupported key type %q", j.KeyType)
+	}
+}
+
+// ParseJWKS parses a key set, unsupported keys are skipped
+func ParseJWKS(data []byte) ([]Key, error) {
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if key, err := jwk.Key(); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("jwt: key set contains no usable keys")
+	}
+	return keys, nil
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of the public key (used as kid)
+func Thumbprint(key Key) (string, error) {
+	jwk, err := publicJWK(key)
+	if err != nil {
+		return "", err
+	}
+
+	// Required members only, in lexicographic order
+	var members interface{}
+	switch jwk.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return encoding.EncodeToString(sum[:]), nil
+}
+
+// PublicKeySource provides the keys to publish
+type PublicKeySource interface {
+	PublicKeys() []Key
+}
+
+// JWKSHandler serves the public keys of source as key set (GET JWKSPath)
+func JWKSHandler(source PublicKeySource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := json.Marshal(PublicJWKS(source.PublicKeys()))
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Write(data)
+	}
+}
@@ -0,0 +1,145 @@
+package jwt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPublicJWKS(t *testing.T) {
+	keys := newTestKeys(t)
+	set := PublicJWKS([]Key{keys.hmac, keys.rsa, keys.ed})
+
+	// HS256 secrets are never published
+	if len(set.Keys) != 2 {
+		t.Fatalf("PublicJWKS() = %d keys, want 2 (RSA and Ed25519)", len(set.Keys))
+	}
+	rsaJWK, edJWK := set.Keys[0], set.Keys[1]
+	if rsaJWK.KeyType != "RSA" || rsaJWK.Algorithm != AlgRS256 || rsaJWK.KeyID != "rs" || rsaJWK.Use != "sig" || rsaJWK.E != "AQAB" || rsaJWK.N == "" {
+		t.Errorf("RSA JWK = %+v", rsaJWK)
+	}
+	if edJWK.KeyType != "OKP" || edJWK.Algorithm != AlgEdDSA || edJWK.Curve != "Ed25519" || edJWK.KeyID != "ed" || len(edJWK.X) != 43 {
+		t.Errorf("Ed25519 JWK = %+v", edJWK)
+	}
+
+	// Private members are never serialised
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range []string{`"d"`, `"p"`, `"q"`, `"k"`} {
+		if strings.Contains(string(data), member+":") {
+			t.Errorf("key set contains private member %s: %s", member, data)
+		}
+	}
+
+	// Round trip: parsed keys verify tokens of the originals
+	parsed, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewVerifier(VerifierOptions{Keys: StaticKeys(parsed)})
+	for _, key := range []Key{keys.rsa, keys.ed} {
+		if _, err := verifier.Verify(mustSign(t, Claims{Subject: "user-1", ExpiresAt: 1 << 40}, key)); err != nil {
+			t.Errorf("token of %s with the parsed key set: %v", key.ID, err)
+		}
+	}
+
+	if empty, _ := json.Marshal(PublicJWKS(nil)); string(empty) != `{"keys":[]}` {
+		t.Errorf("PublicJWKS(nil) = %s, want an empty key list", empty)
+	}
+}
+
+func TestJWKKey(t *testing.T) {
+	keys := newTestKeys(t)
+	valid := PublicJWKS([]Key{keys.rsa, keys.ed}).Keys
+	rsaJWK, edJWK := valid[0], valid[1]
+	with := func(jwk JWK, change func(*JWK)) JWK {
+		change(&jwk)
+		return jwk
+	}
+
+	invalid := map[string]JWK{
+		"encryption key":        with(edJWK, func(j *JWK) { j.Use = "enc" }),
+		"unsupported type":      with(edJWK, func(j *JWK) { j.KeyType = "EC" }),
+		"other curve":           with(edJWK, func(j *JWK) { j.Curve = "X25519" }),
+		"short x":               with(edJWK, func(j *JWK) { j.X = j.X[:20] }),
+		"x not base64url":       with(edJWK, func(j *JWK) { j.X = "***" }),
+		"RSA without e":         with(rsaJWK, func(j *JWK) { j.E = "" }),
+		"RSA exponent too big":  with(rsaJWK, func(j *JWK) { j.E = "AQABAQAB" }),
+		"RSA modulus too small": with(rsaJWK, func(j *JWK) { j.N = j.N[:170] }),
+	}
+	for name, jwk := range invalid {
+		if _, err := jwk.Key(); err == nil {
+			t.Errorf("%s: Key() = nil error", name)
+		}
+	}
+
+	// Unusable keys are skipped, a set without usable keys is an error
+	data, _ := json.Marshal(JWKS{Keys: []JWK{invalid["encryption key"], edJWK}})
+	if parsed, err := ParseJWKS(data); err != nil || len(parsed) != 1 {
+		t.Errorf("ParseJWKS() = %d keys, %v, want the usable key", len(parsed), err)
+	}
+	data, _ = json.Marshal(JWKS{Keys: []JWK{invalid["encryption key"]}})
+	if _, err := ParseJWKS(data); err == nil {
+		t.Error("ParseJWKS() accepted a set without usable keys")
+	}
+	if _, err := ParseJWKS([]byte("not json")); err == nil {
+		t.Error("ParseJWKS() accepted invalid JSON")
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	// RFC 7638 section 3.1
+	jwk := JWK{
+		KeyType: "RSA",
+		N:       "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:       "AQAB",
+	}
+	key, err := jwk.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbprint, err := Thumbprint(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; thumbprint != want {
+		t.Errorf("Thumbprint() = %s, want %s", thumbprint, want)
+	}
+
+	// Independent of kid and the private key
+	keys := newTestKeys(t)
+	public := Key{ID: "other", Algorithm: AlgEdDSA, Public: keys.ed.Public}
+	a, _ := Thumbprint(keys.ed)
+	b, _ := Thumbprint(public)
+	if a != b {
+		t.Errorf("thumbprints differ: %s, %s", a, b)
+	}
+	if _, err := Thumbprint(keys.hmac); err == nil {
+		t.Error("Thumbprint() of an HS256 key = nil error")
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	keys := newTestKeys(t)
+	handler := JWKSHandler(StaticKeys{keys.hmac, keys.ed})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, JWKSPath, nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("Cache-Control") == "" {
+		t.Fatalf("GET: %d %v", rec.Code, rec.Header())
+	}
+	var set JWKS
+	if err := json.Unmarshal(rec.Body.Bytes(), &set); err != nil || len(set.Keys) != 1 || set.Keys[0].KeyID != "ed" {
+		t.Fatalf("GET body = %s, want the Ed25519 key only", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, JWKSPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD" {
+		t.Fatalf("POST: %d %v, want 405 with Allow", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,245 @@
+// shared/go/utils/jwt/jwt.go
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// JSON WEB TOKENS (RFC 7519, compact JWS)
+// ==========================================
+
+// MaxTokenLength limits the size of accepted tokens
+const MaxTokenLength = 8 << 10
+
+// Verification errors
+// ErrTokenExpired and ErrTokenNotYetValid are returned for tokens with a valid signature only
+var (
+	ErrTokenMalformed   = errors.New("jwt: malformed token")
+	ErrTokenAlgorithm   = errors.New("jwt: algorithm not allowed")
+	ErrTokenUnknownKey  = errors.New("jwt: no key for token")
+	ErrTokenSignature   = errors.New("jwt: invalid signature")
+	ErrTokenExpired     = errors.New("jwt: token expired")
+	ErrTokenNotYetValid = errors.New("jwt: token not valid yet")
+	ErrTokenIssuer      = errors.New("jwt: invalid issuer")
+	ErrTokenAudience    = errors.New("jwt: invalid audience")
+)
+
+// header is the JOSE header
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// ==========================================
+// SIGNING
+// ==========================================
+
+// Sign creates a compact token with the key's algorithm and ID
+func Sign(claims Claims, key Key) (string, error) {
+	hdr, err := json.Marshal(header{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encoding.EncodeToString(hdr) + "." + encoding.EncodeToString(payload)
+	signature, err := sign(key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + encoding.EncodeToString(signature), nil
+}
+
+// sign signs the signing input with key
+func sign(key Key, input []byte) ([]byte, error) {
+	switch key.Algorithm {
+	case AlgHS256:
+		if len(key.Secret) == 0 {
+			return nil, errors.New("jwt: HS256 key without secret")
+		}
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case AlgRS256:
+		private, ok := key.Private.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("jwt: RS256 key without RSA private key")
+		}
+		digest := sha256.Sum256(input)
+		return rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, digest[:])
+	case AlgEdDSA:
+		private, ok := key.Private.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("jwt: EdDSA key without Ed25519 private key")
+		}
+		return ed25519.Sign(private, input), nil
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q", key.Algorithm)
+	}
+}
+
+// ==========================================
+// VERIFICATION
+// ==========================================
+
+// VerifierOptions configures a Verifier
+type VerifierOptions struct {
+	Keys       KeySource        // Verification keys
+	Algorithms []string         // Accepted algorithms (empty: all supported)
+	Issuer     string           // Required "iss" (empty: not checked)
+	Audience   []string         // "aud" must contain one of these (empty: not checked)
+	ClockSkew  time.Duration    // Leeway for exp/nbf
+	Now        func() time.Time // Clock (nil: time.Now)
+}
+
+// Verifier verifies tokens
+type Verifier struct {
+	opts VerifierOptions
+}
+
+// NewVerifier creates a verifier
+func NewVerifier(opts VerifierOptions) *Verifier {
+	if len(opts.Algorithms) == 0 {
+		opts.Algorithms = []string{AlgHS256, AlgRS256, AlgEdDSA}
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Verifier{opts: opts}
+}
+
+// Verify checks signature, algorithm, issuer, audience and exp/nbf and returns the claims
+// The algorithm must be accepted and match the key type, "none" is never accepted
+// Claims are also returned for signed tokens failing the claim checks (e.g. to log the user of an expired token)
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	if len(token) > MaxTokenLength {
+		return nil, ErrTokenMalformed
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	var hdr header
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if !slices.Contains(v.opts.Algorithms, hdr.Algorithm) {
+		return nil, ErrTokenAlgorithm
+	}
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	if err := v.verifySignature(hdr, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err := v.validate(&claims); err != nil {
+		return &claims, err
+	}
+	return &claims, nil
+}
+
+// verifySignature checks the signature against the keys for the token's kid and algorithm
+func (v *Verifier) verifySignature(hdr header, input, signature []byte) error {
+	found := false
+	for _, key := range v.opts.Keys.VerificationKeys(hdr.KeyID) {
+		if key.Algorithm != hdr.Algorithm {
+			continue
+		}
+		found = true
+		if verify(key, input, signature) {
+			return nil
+		}
+	}
+	if !found {
+		return ErrTokenUnknownKey
+	}
+	return ErrTokenSignature
+}
+
+// verify checks one signature
+func verify(key Key, input, signature []byte) bool {
+	switch key.Algorithm {
+	case AlgHS256:
+		if len(key.Secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write(input)
+		return hmac.Equal(signature, mac.Sum(nil))
+	case AlgRS256:
+		public, ok := key.Public.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		digest := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil
+	case AlgEdDSA:
+		public, ok := key.Public.(ed25519.PublicKey)
+		if !ok || len(public) != ed25519.PublicKeySize {
+			return false
+		}
+		return ed25519.Verify(public, input, signature)
+	default:
+		return false
+	}
+}
+
+// validate checks the time based and registered claims
+func (v *Verifier) validate(claims *Claims) error {
+	now := v.opts.Now()
+	skew := v.opts.ClockSkew
+
+	if claims.ExpiresAt == 0 {
+		return ErrTokenMalformed
+	}
+	if !now.Before(time.Unix(claims.ExpiresAt, 0).Add(skew)) {
+		return ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(skew).Before(time.Unix(claims.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+	if v.opts.Issuer != "" && claims.Issuer != v.opts.Issuer {
+		return ErrTokenIssuer
+	}
+	if len(v.opts.Audience) > 0 && !claims.Audience.Contains(v.opts.Audience...) {
+		return ErrTokenAudience
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment
+func decodeSegment(segment string, target interface{}) error {
+	data, err := encoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
@@ -0,0 +1,275 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNow is the clock of the verification tests
+var testNow = time.Unix(1_700_000_000, 0)
+
+// testKeys are one key per algorithm plus an Ed25519 key nobody trusts
+type testKeys struct {
+	hmac, rsa, ed, foreign Key
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	var keys testKeys
+	var err error
+	if keys.hmac, err = NewHMACKey("hs", []byte(strings.Repeat("s", MinHMACSecretLength))); err != nil {
+		t.Fatal(err)
+	}
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, MinRSAKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.rsa, err = NewPrivateKey("rs", rsaPrivate); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []struct {
+		id  string
+		key *Key
+	}{{"ed", &keys.ed}, {"ed", &keys.foreign}} {
+		_, private, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *k.key, err = NewPrivateKey(k.id, private); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keys
+}
+
+// rawToken builds a token with any header, signed as HS256 with secret (nil: no signature)
+func rawToken(t *testing.T, hdr header, claims Claims, secret []byte) string {
+	t.Helper()
+	h, err := json.Marshal(hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := encoding.EncodeToString(h) + "." + encoding.EncodeToString(p)
+	if secret == nil {
+		return input + "."
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return input + "." + encoding.EncodeToString(mac.Sum(nil))
+}
+
+func mustSign(t *testing.T, claims Claims, key Key) string {
+	t.Helper()
+	token, err := Sign(claims, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerify(t *testing.T) {
+	keys := newTestKeys(t)
+	publicKeys := StaticKeys{
+		{ID: keys.rsa.ID, Algorithm: AlgRS256, Public: keys.rsa.Public},
+		{ID: keys.ed.ID, Algorithm: AlgEdDSA, Public: keys.ed.Public},
+	}
+	rsaPEM, err := x509.MarshalPKIXPublicKey(keys.rsa.Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaPEM})
+
+	valid := Claims{Subject: "user-1", Issuer: "service-a", Audience: Audience{"gateway"}, ExpiresAt: testNow.Add(time.Hour).Unix()}
+	with := func(change func(*Claims)) Claims {
+		claims := valid
+		change(&claims)
+		return claims
+	}
+	tampered := func(token string) string {
+		parts := strings.Split(token, ".")
+		parts[1] = encoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`))
+		return strings.Join(parts, ".")
+	}
+
+	tests := []struct {
+		name  string
+		keys  StaticKeys
+		algs  []string
+		token string
+		err   error
+	}{
+		// Signatures
+		{"HS256", StaticKeys{keys.hmac}, nil, mustSign(t, valid, keys.hmac), nil},
+		{"RS256", publicKeys, nil, mustSign(t, valid, keys.rsa), nil},
+		{"EdDSA", publicKeys, nil, mustSign(t, valid, keys.ed), nil},
+		{"HS256 tampered payload", StaticKeys{keys.hmac}, nil, tampered(mustSign(t, valid, keys.hmac)), ErrTokenSignature},
+		{"RS256 tampered payload", publicKeys, nil, tampered(mustSign(t, valid, keys.rsa)), ErrTokenSignature},
+		{"EdDSA tampered payload", publicKeys, nil, tampered(mustSign(t, valid, keys.ed)), ErrTokenSignature},
+		{"other key with the same kid", publicKeys, nil, mustSign(t, valid, keys.foreign), ErrTokenSignature},
+		{"unknown kid", publicKeys, nil, mustSign(t, valid, Key{ID: "other", Algorithm: AlgEdDSA, Private: keys.foreign.Private}), ErrTokenUnknownKey},
+		{"HS256 with a wrong secret", StaticKeys{keys.hmac}, nil, rawToken(t, header{Algorithm: AlgHS256, KeyID: "hs"}, valid, []byte(strings.Repeat("x", 32))), ErrTokenSignature},
+		{"truncated signature", publicKeys, nil, func() string { token := mustSign(t, valid, keys.ed); return token[:len(token)-4] }(), ErrTokenSignature},
+
+		// Algorithm confusion: the public key used as HMAC secret
+		{"HS256 token against an RSA key", publicKeys, nil, rawToken(t, header{Algorithm: AlgHS256, KeyID: "rs"}, valid, rsaPEM), ErrTokenUnknownKey},
+		{"HS256 token against an RSA key without kid", publicKeys, nil, rawToken(t, header{Algorithm: AlgHS256}, valid, rsaPEM), ErrTokenUnknownKey},
+		{"HS256 token against an Ed25519 key", publicKeys, nil, rawToken(t, header{Algorithm: AlgHS256, KeyID: "ed"}, valid, keys.ed.Public.(ed25519.PublicKey)), ErrTokenUnknownKey},
+		{"HS256 not accepted", publicKeys, []string{AlgRS256, AlgEdDSA}, rawToken(t, header{Algorithm: AlgHS256, KeyID: "rs"}, valid, rsaPEM), ErrTokenAlgorithm},
+		{"RS256 header on an Ed25519 token", publicKeys, nil, func() string {
+			parts := strings.Split(mustSign(t, valid, keys.ed), ".")
+			h, _ := json.Marshal(header{Algorithm: AlgRS256, KeyID: "ed"})
+			return encoding.EncodeToString(h) + "." + parts[1] + "." + parts[2]
+		}(), ErrTokenUnknownKey},
+
+		// "none"
+		{"alg none", publicKeys, nil, rawToken(t, header{Algorithm: "none"}, valid, nil), ErrTokenAlgorithm},
+		{"alg None", publicKeys, nil, rawToken(t, header{Algorithm: "None"}, valid, nil), ErrTokenAlgorithm},
+		{"alg none accepted by configuration", publicKeys, []string{"none"}, rawToken(t, header{Algorithm: "none"}, valid, nil), ErrTokenUnknownKey},
+		{"empty alg", publicKeys, nil, rawToken(t, header{}, valid, nil), ErrTokenAlgorithm},
+
+		// Registered claims
+		{"wrong issuer", publicKeys, nil, mustSign(t, with(func(c *Claims) { c.Issuer = "other" }), keys.ed), ErrTokenIssuer},
+		{"missing issuer", publicKeys, nil, mustSign(t, with(func(c *Claims) { c.Issuer = "" }), keys.ed), ErrTokenIssuer},
+		{"wrong audience", publicKeys, nil, mustSign(t, with(func(c *Claims) { c.Audience = Audience{"service-b"} }), keys.ed), ErrTokenAudience},
+		{"missing audience", publicKeys, nil, mustSign(t, with(func(c *Claims) { c.Audience = nil }), keys.ed), ErrTokenAudience},
+		{"audience list", publicKeys, nil, mustSign(t, with(func(c *Claims) { c.Audience = Audience{"service-b", "gateway"} }), keys.ed), nil},
+		{"missing exp", publicKeys, nil, mustSign(t, with(func(c *Claims) { c.ExpiresAt = 0 }), keys.ed), ErrTokenMalformed},
+
+		// Malformed
+		{"two segments", publicKeys, nil, "a.b", ErrTokenMalformed},
+		{"four segments", publicKeys, nil, mustSign(t, valid, keys.ed) + ".x", ErrTokenMalformed},
+		{"header not base64url", publicKeys, nil, "!!!.e30.", ErrTokenMalformed},
+		{"header not JSON", publicKeys, nil, encoding.EncodeToString([]byte("alg")) + ".e30.", ErrTokenMalformed},
+		{"signature not base64url", publicKeys, nil, strings.Join(strings.Split(mustSign(t, valid, keys.ed), ".")[:2], ".") + ".***", ErrTokenMalformed},
+		{"too long", publicKeys, nil, strings.Repeat("a", MaxTokenLength+1), ErrTokenMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewVerifier(VerifierOptions{
+				Keys:       tt.keys,
+				Algorithms: tt.algs,
+				Issuer:     "service-a",
+				Audience:   []string{"gateway"},
+				Now:        func() time.Time { return testNow },
+			})
+			claims, err := verifier.Verify(tt.token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && claims.Subject != "user-1" {
+				t.Fatalf("Verify() subject = %q, want user-1", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestVerifyTimeClaims(t *testing.T) {
+	keys := newTestKeys(t)
+	now := testNow.Unix()
+	const skew = 30 * time.Second
+
+	tests := []struct {
+		name      string
+		skew      time.Duration
+		expiresAt int64
+		notBefore int64
+		err       error
+	}{
+		{"valid", 0, now + 60, now - 60, nil},
+		{"expires now", 0, now, 0, ErrTokenExpired},
+		{"expires in a second", 0, now + 1, 0, nil},
+		{"expired", 0, now - 1, 0, ErrTokenExpired},
+		{"expired within skew", skew, now - 29, 0, nil},
+		{"expired at skew", skew, now - 30, 0, ErrTokenExpired},
+		{"expired beyond skew", skew, now - 31, 0, ErrTokenExpired},
+		{"valid from now", 0, now + 60, now, nil},
+		{"not yet valid", 0, now + 60, now + 1, ErrTokenNotYetValid},
+		{"not yet valid within skew", skew, now + 60, now + 30, nil},
+		{"not yet valid beyond skew", skew, now + 60, now + 31, ErrTokenNotYetValid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewVerifier(VerifierOptions{
+				Keys:      StaticKeys{keys.ed},
+				ClockSkew: tt.skew,
+				Now:       func() time.Time { return testNow },
+			})
+			token := mustSign(t, Claims{Subject: "user-1", ExpiresAt: tt.expiresAt, NotBefore: tt.notBefore}, keys.ed)
+			claims, err := verifier.Verify(token)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.err)
+			}
+			// Signed tokens failing the claim checks still return their claims
+			if claims == nil || claims.Subject != "user-1" {
+				t.Fatalf("Verify() claims = %+v, want the token's claims", claims)
+			}
+		})
+	}
+}
+
+func TestSignRejectsUnusableKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	for name, key := range map[string]Key{
+		"HS256 without secret":        {Algorithm: AlgHS256},
+		"RS256 without private key":   {Algorithm: AlgRS256, Public: keys.rsa.Public},
+		"EdDSA with an RSA key":       {Algorithm: AlgEdDSA, Private: keys.rsa.Private},
+		"unsupported algorithm":       {Algorithm: "ES256", Private: keys.ed.Private},
+		"none":                        {Algorithm: "none"},
+		"RS256 with an Ed25519 key":   {Algorithm: AlgRS256, Private: keys.ed.Private},
+		"EdDSA without a private key": {Algorithm: AlgEdDSA, Public: keys.ed.Public},
+	} {
+		if _, err := Sign(Claims{Subject: "user-1", ExpiresAt: testNow.Unix()}, key); err == nil {
+			t.Errorf("%s: Sign() = nil error", name)
+		}
+	}
+}
+
+func TestAudienceJSON(t *testing.T) {
+	for _, tt := range []struct {
+		audience Audience
+		json     string
+	}{
+		{Audience{"gateway"}, `"gateway"`},
+		{Audience{"gateway", "service-b"}, `["gateway","service-b"]`},
+	} {
+		data, err := json.Marshal(tt.audience)
+		if err != nil || string(data) != tt.json {
+			t.Errorf("Marshal(%v) = %s, %v, want %s", tt.audience, data, err, tt.json)
+		}
+		var decoded Audience
+		if err := json.Unmarshal([]byte(tt.json), &decoded); err != nil || len(decoded) != len(tt.audience) || !decoded.Contains(tt.audience...) {
+			t.Errorf("Unmarshal(%s) = %v, %v, want %v", tt.json, decoded, err, tt.audience)
+		}
+	}
+
+	var claims Claims
+	if err := json.Unmarshal([]byte(`{"aud":42,"exp":1}`), &claims); err == nil {
+		t.Error("Unmarshal() accepted a numeric aud")
+	}
+}
+
+func TestClaimsHasRole(t *testing.T) {
+	claims := Claims{Roles: []string{"user", "admin"}}
+	if !claims.HasRole("admin") || !claims.HasRole("other", "user") {
+		t.Error("HasRole() = false for a contained role")
+	}
+	if claims.HasRole("Admin") || claims.HasRole() || (&Claims{}).HasRole("user") {
+		t.Error("HasRole() = true without a contained role")
+	}
+}
@@ -0,0 +1,141 @@
+// shared/go/utils/jwt/keys.go
+
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ==========================================
+// KEYS
+// ==========================================
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256" // HMAC-SHA256, shared secret
+	AlgRS256 = "RS256" // RSASSA-PKCS1-v1_5 with SHA-256
+	AlgEdDSA = "EdDSA" // Ed25519
+)
+
+// MinHMACSecretLength is the minimum HS256 secret length in bytes (RFC 7518 3.2: at least the hash size)
+const MinHMACSecretLength = 32
+
+// MinRSAKeyBits is the minimum RSA modulus size
+const MinRSAKeyBits = 2048
+
+// Key is a signing or verification key for one algorithm
+// HS256 keys use Secret, RS256/EdDSA keys use Public (verification) and optionally Private (signing)
+type Key struct {
+	ID        string           // "kid" header (optional)
+	Algorithm string           // One of the Alg* constants
+	Secret    []byte           // HS256
+	Public    crypto.PublicKey // RS256: *rsa.PublicKey, EdDSA: ed25519.PublicKey
+	Private   crypto.Signer    // RS256: *rsa.PrivateKey, EdDSA: ed25519.PrivateKey (signing only)
+}
+
+// KeySource returns the candidate verification keys for a token
+// kid is the "kid" header of the token (empty if missing)
+type KeySource interface {
+	VerificationKeys(kid string) []Key
+}
+
+// StaticKeys is a fixed set of keys
+// Tokens with a kid are checked against the key with that ID, tokens without against all keys
+type StaticKeys []Key
+
+// VerificationKeys implements KeySource
+func (k StaticKeys) VerificationKeys(kid string) []Key {
+	if kid == "" {
+		return k
+	}
+	for _, key := range k {
+		if key.ID == kid {
+			return []Key{key}
+		}
+	}
+	return nil
+}
+
+// PublicKeys implements PublicKeySource (HS256 keys are skipped by PublicJWKS)
+func (k StaticKeys) PublicKeys() []Key {
+	return k
+}
+
+// NewHMACKey creates an HS256 key
+func NewHMACKey(id string, secret []byte) (Key, error) {
+	if len(secret) < MinHMACSecretLength {
+		return Key{}, fmt.Errorf("jwt: HS256 secret must be at least %d bytes", MinHMACSecretLength)
+	}
+	return Key{ID: id, Algorithm: AlgHS256, Secret: secret}, nil
+}
+
+// NewPublicKey creates a verification key, the algorithm follows from the key type
+func NewPublicKey(id string, public crypto.PublicKey) (Key, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < MinRSAKeyBits {
+			return Key{}, fmt.Errorf("jwt: RSA keys must have at least %d bits", MinRSAKeyBits)
+		}
+		return Key{ID: id, Algorithm: AlgRS256, Public: pub}, nil
+	case ed25519.PublicKey:
+		return Key{ID: id, Algorithm: AlgEdDSA, Public: pub}, nil
+	default:
+		return Key{}, fmt.Errorf("jwt: unsupported public key type %T", public)
+	}
+}
+
+// NewPrivateKey creates a signing key (incl. its verification key), the algorithm follows from the key type
+func NewPrivateKey(id string, private crypto.Signer) (Key, error) {
+	key, err := NewPublicKey(id, private.Public())
+	if err != nil {
+		return Key{}, err
+	}
+	key.Private = private
+	return key, nil
+}
+
+// ParsePublicKeyPEM parses a PEM encoded RSA or Ed25519 public key (PKIX "PUBLIC KEY" or PKCS#1 "RSA PUBLIC KEY")
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: no PEM block found")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("jwt: unsupported PEM block %q", block.Type)
+	}
+}
+
+// ParsePrivateKeyPEM parses a PEM encoded RSA or Ed25519 private key (PKCS#8 "PRIVATE KEY" or PKCS#1 "RSA PRIVATE KEY")
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: no PEM block found")
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("jwt: unsupported private key type %T", key)
+		}
+		return signer, nil
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("jwt: unsupported PEM block %q", block.Type)
+	}
+}
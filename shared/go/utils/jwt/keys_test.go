@@ -0,0 +1,103 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestNewKeys(t *testing.T) {
+	if _, err := NewHMACKey("hs", make([]byte, MinHMACSecretLength-1)); err == nil {
+		t.Error("NewHMACKey() accepted a short secret")
+	}
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPublicKey("rs", &small.PublicKey); err == nil {
+		t.Error("NewPublicKey() accepted a 1024 bit RSA key")
+	}
+	if _, err := NewPublicKey("x", "not a key"); err == nil {
+		t.Error("NewPublicKey() accepted an unsupported key type")
+	}
+
+	// The algorithm follows from the key type
+	keys := newTestKeys(t)
+	if keys.rsa.Algorithm != AlgRS256 || keys.ed.Algorithm != AlgEdDSA || keys.hmac.Algorithm != AlgHS256 {
+		t.Errorf("algorithms = %s/%s/%s, want RS256/EdDSA/HS256", keys.rsa.Algorithm, keys.ed.Algorithm, keys.hmac.Algorithm)
+	}
+}
+
+func TestStaticKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	set := StaticKeys{keys.hmac, keys.rsa, keys.ed}
+
+	if got := set.VerificationKeys(""); len(got) != 3 {
+		t.Errorf("VerificationKeys(\"\") = %d keys, want all 3", len(got))
+	}
+	if got := set.VerificationKeys("rs"); len(got) != 1 || got[0].Algorithm != AlgRS256 {
+		t.Errorf("VerificationKeys(rs) = %v, want the RSA key", got)
+	}
+	if got := set.VerificationKeys("unknown"); got != nil {
+		t.Errorf("VerificationKeys(unknown) = %v, want none", got)
+	}
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	keys := newTestKeys(t)
+	rsaPrivate := keys.rsa.Private.(*rsa.PrivateKey)
+	edPrivate := keys.ed.Private.(ed25519.PrivateKey)
+
+	pkcs8 := func(key interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+	pkix := func(key interface{}) []byte {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+
+	privates := map[string][]byte{
+		"PKCS#8 RSA":     pkcs8(rsaPrivate),
+		"PKCS#8 Ed25519": pkcs8(edPrivate),
+		"PKCS#1 RSA":     pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPrivate)}),
+	}
+	for name, data := range privates {
+		if _, err := ParsePrivateKeyPEM(data); err != nil {
+			t.Errorf("ParsePrivateKeyPEM(%s): %v", name, err)
+		}
+	}
+	publics := map[string][]byte{
+		"PKIX RSA":     pkix(&rsaPrivate.PublicKey),
+		"PKIX Ed25519": pkix(edPrivate.Public()),
+		"PKCS#1 RSA":   pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaPrivate.PublicKey)}),
+	}
+	for name, data := range publics {
+		if _, err := ParsePublicKeyPEM(data); err != nil {
+			t.Errorf("ParsePublicKeyPEM(%s): %v", name, err)
+		}
+	}
+
+	invalid := map[string][]byte{
+		"no PEM":            []byte("not a key"),
+		"certificate block": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}),
+		"broken DER":        pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1, 2, 3}}),
+	}
+	for name, data := range invalid {
+		if _, err := ParsePrivateKeyPEM(data); err == nil {
+			t.Errorf("ParsePrivateKeyPEM(%s) = nil error", name)
+		}
+	}
+	if _, err := ParsePublicKeyPEM(privates["PKCS#8 RSA"]); err == nil {
+		t.Error("ParsePublicKeyPEM() accepted a private key")
+	}
+}
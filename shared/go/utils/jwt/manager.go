@@ -0,0 +1,369 @@
+// shared/go/utils/jwt/manager.go
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+	"github.com/app/shared/go/utils/security"
+)
+
+// ==========================================
+// SIGNING KEY MANAGER
+// ==========================================
+
+// Defaults of KeyManagerOptions
+const (
+	DefaultKeyReloadInterval = time.Minute
+	DefaultRSAKeyBits        = 3072
+)
+
+// minKeyReloadInterval limits reloads triggered by unknown kids
+const minKeyReloadInterval = 5 * time.Second
+
+// ErrNoSigningKey is returned by SigningKey if no key exists yet
+var ErrNoSigningKey = errors.New("jwt: no signing key")
+
+// KeyManagerOptions configures a KeyManager
+type KeyManagerOptions struct {
+	Dir              string        // Directory of the key files (shared by issuer and gateway)
+	Algorithm        string        // Algorithm of new keys: AlgEdDSA (default) or AlgRS256
+	RotationInterval time.Duration // Age after which Run replaces the signing key (0 = never)
+	Overlap          time.Duration // Replaced keys stay valid for verification this long (at least the token lifetime)
+	EncryptionKey    []byte        // AES key (16, 24 or 32 bytes) for private keys at rest (nil = unencrypted)
+	ReloadInterval   time.Duration // How often Run re-reads Dir for keys rotated by other processes (0 = DefaultKeyReloadInterval)
+}
+
+// managedKey is a key with its creation time
+type managedKey struct {
+	Key
+	createdAt time.Time
+}
+
+// storedKey is the file format of a key (<kid>.json)
+type storedKey struct {
+	ID         string    `json:"kid"`
+	Algorithm  string    `json:"alg"`
+	CreatedAt  time.Time `json:"created_at"`
+	Encrypted  bool      `json:"encrypted"`   // PrivateKey is encrypted with security.EncryptAES
+	PrivateKey string    `json:"private_key"` // PKCS#8 PEM
+}
+
+// KeyManager keeps the signing keys of the token issuer in a directory
+// The newest key signs, replaced keys are kept for verification (and in the JWKS) for Overlap, then removed
+// It is a KeySource and PublicKeySource, so the issuer can verify its tokens and publish the public keys (JWKS)
+type KeyManager struct {
+	opts KeyManagerOptions
+
+	mu         sync.RWMutex
+	keys       []managedKey // Valid keys, newest first
+	expired    []string     // Files of keys past their overlap (removed by Run with rotation)
+	reloadedAt time.Time
+}
+
+// NewKeyManager creates the key directory if needed and loads the keys
+func NewKeyManager(opts KeyManagerOptions) (*KeyManager, error) {
+	if opts.Algorithm == "" {
+		opts.Algorithm = AlgEdDSA
+	}
+	if opts.Algorithm != AlgEdDSA && opts.Algorithm != AlgRS256 {
+		return nil, fmt.Errorf("jwt: unsupported signing algorithm %q", opts.Algorithm)
+	}
+	if opts.ReloadInterval <= 0 {
+		opts.ReloadInterval = DefaultKeyReloadInterval
+	}
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	m := &KeyManager{opts: opts}
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SigningKey returns the newest key
+func (m *KeyManager) SigningKey() (Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.keys) == 0 {
+		return Key{}, ErrNoSigningKey
+	}
+	return m.keys[0].Key, nil
+}
+
+// VerificationKeys implements KeySource, an unknown kid reloads the directory (rotated by another process)
+func (m *KeyManager) VerificationKeys(kid string) []Key {
+	keys := m.find(kid)
+	if len(keys) == 0 && kid != "" {
+		m.mu.RLock()
+		due := time.Since(m.reloadedAt) >= minKeyReloadInterval
+		m.mu.RUnlock()
+		if due && m.Reload() == nil {
+			keys = m.find(kid)
+		}
+	}
+	return keys
+}
+
+// find returns the key with kid (all keys without kid)
+func (m *KeyManager) find(kid string) []Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var keys []Key
+	for _, key := range m.keys {
+		if kid == "" || key.ID == kid {
+			keys = append(keys, key.Key)
+		}
+	}
+	return keys
+}
+
+// PublicKeys implements PublicKeySource (without private keys)
+func (m *KeyManager) PublicKeys() []Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]Key, 0, len(m.keys))
+	for _, key := range m.keys {
+		public := key.Key
+		public.Private = nil
+		keys = append(keys, public)
+	}
+	return keys
+}
+
+// Reload reads the key files, unreadable files are skipped (logged)
+func (m *KeyManager) Reload() error {
+	files, err := filepath.Glob(filepath.Join(m.opts.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	var loaded []managedKey
+	for _, file := range files {
+		key, err := m.readKey(file)
+		if err != nil {
+			logger.WarnWithFields("Skipping unreadable signing key", map[string]interface{}{
+				"file":  file,
+				"error": err.Error(),
+			})
+			continue
+		}
+		loaded = append(loaded, key)
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].createdAt.After(loaded[j].createdAt) })
+
+	// A replaced key stays valid for Overlap after its successor was created
+	now := time.Now()
+	var keys []managedKey
+	var expired []string
+	for i, key := range loaded {
+		if i > 0 && now.Sub(loaded[i-1].createdAt) >= m.opts.Overlap {
+			expired = append(expired, m.keyFile(key.ID))
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.expired = expired
+	m.reloadedAt = now
+	m.mu.Unlock()
+	return nil
+}
+
+// Rotate creates a new signing key, the previous keys stay valid for Overlap
+func (m *KeyManager) Rotate() (Key, error) {
+	private, err := m.generate()
+	if err != nil {
+		return Key{}, err
+	}
+	key, err := NewPrivateKey("", private)
+	if err != nil {
+		return Key{}, err
+	}
+	if key.ID, err = Thumbprint(key); err != nil {
+		return Key{}, err
+	}
+
+	if err := m.writeKey(key, time.Now()); err != nil {
+		return Key{}, err
+	}
+	if err := m.Reload(); err != nil {
+		return Key{}, err
+	}
+
+	logger.LogEvent(logger.EventSigningKeyRotated, map[string]interface{}{
+		"kid":       key.ID,
+		"algorithm": key.Algorithm,
+		"encrypted": m.opts.EncryptionKey != nil,
+	})
+	return key, nil
+}
+
+// Run reloads the keys every ReloadInterval until ctx is done
+// With rotate the manager also creates the first key, rotates after RotationInterval and removes expired keys
+// (rotate in the issuing service only, readers like the gateway just reload)
+func (m *KeyManager) Run(ctx context.Context, rotate bool) {
+	ticker := time.NewTicker(m.opts.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Reload(); err != nil {
+			logger.WarnWithFields("Reloading signing keys failed", map[string]interface{}{
+				"dir":   m.opts.Dir,
+				"error": err.Error(),
+			})
+		}
+		if rotate {
+			m.rotateIfDue()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EnsureSigningKey creates a key if none exists
+func (m *KeyManager) EnsureSigningKey() (Key, error) {
+	if key, err := m.SigningKey(); err == nil {
+		return key, nil
+	}
+	return m.Rotate()
+}
+
+// rotateIfDue rotates a missing or old signing key and removes expired key files
+func (m *KeyManager) rotateIfDue() {
+	m.mu.RLock()
+	due := len(m.keys) == 0 || (m.opts.RotationInterval > 0 && time.Since(m.keys[0].createdAt) >= m.opts.RotationInterval)
+	expired := m.expired
+	m.mu.RUnlock()
+
+	if due {
+		if _, err := m.Rotate(); err != nil {
+			logger.ErrorWithFields("Signing key rotation failed", err, map[string]interface{}{
+				"dir": m.opts.Dir,
+			})
+		}
+	}
+	for _, file := range expired {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.WarnWithFields("Removing expired signing key failed", map[string]interface{}{
+				"file":  file,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// generate creates a private key for the configured algorithm
+func (m *KeyManager) generate() (crypto.Signer, error) {
+	if m.opts.Algorithm == AlgRS256 {
+		return rsa.GenerateKey(rand.Reader, DefaultRSAKeyBits)
+	}
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	return private, err
+}
+
+// keyFile returns the file of a key
+func (m *KeyManager) keyFile(kid string) string {
+	return filepath.Join(m.opts.Dir, kid+".json")
+}
+
+// writeKey stores a key (written to a temp file and renamed, so readers never see partial files)
+func (m *KeyManager) writeKey(key Key, createdAt time.Time) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return err
+	}
+	stored := storedKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		CreatedAt:  createdAt.UTC(),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}
+	if m.opts.EncryptionKey != nil {
+		if stored.PrivateKey, err = security.EncryptAES(m.opts.EncryptionKey, stored.PrivateKey); err != nil {
+			return err
+		}
+		stored.Encrypted = true
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(m.opts.Dir, ".key-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.keyFile(key.ID))
+}
+
+// readKey loads a key file
+func (m *KeyManager) readKey(file string) (managedKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return managedKey{}, err
+	}
+	var stored storedKey
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return managedKey{}, err
+	}
+	if stored.ID == "" || strings.TrimSuffix(filepath.Base(file), ".json") != stored.ID {
+		return managedKey{}, errors.New("kid does not match the file name")
+	}
+
+	privatePEM := stored.PrivateKey
+	if stored.Encrypted {
+		if m.opts.EncryptionKey == nil {
+			return managedKey{}, errors.New("key is encrypted, but no encryption key is configured")
+		}
+		if privatePEM, err = security.DecryptAES(m.opts.EncryptionKey, privatePEM); err != nil {
+			return managedKey{}, err
+		}
+	}
+	private, err := ParsePrivateKeyPEM([]byte(privatePEM))
+	if err != nil {
+		return managedKey{}, err
+	}
+	key, err := NewPrivateKey(stored.ID, private)
+	if err != nil {
+		return managedKey{}, err
+	}
+	if key.Algorithm != stored.Algorithm {
+		return managedKey{}, fmt.Errorf("algorithm %q does not match the key type", stored.Algorithm)
+	}
+	return managedKey{Key: key, createdAt: stored.CreatedAt}, nil
+}
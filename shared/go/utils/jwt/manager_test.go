@@ -0,0 +1,266 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+func init() {
+	logger.Init("jwt-test", "test") // Rotations and refresh failures are logged
+}
+
+// newTestKeyManager creates a manager on a temporary directory
+func newTestKeyManager(t *testing.T, opts KeyManagerOptions) *KeyManager {
+	t.Helper()
+	if opts.Dir == "" {
+		opts.Dir = t.TempDir()
+	}
+	m, err := NewKeyManager(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// writeTestKey stores a new Ed25519 key created at createdAt (Rotate always uses the current time)
+func writeTestKey(t *testing.T, m *KeyManager, createdAt time.Time) Key {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewPrivateKey("", private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ID, err = Thumbprint(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.writeKey(key, createdAt); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeyManagerRotationOverlap(t *testing.T) {
+	m := newTestKeyManager(t, KeyManagerOptions{Overlap: time.Hour})
+	if _, err := m.SigningKey(); !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("SigningKey() without keys = %v, want ErrNoSigningKey", err)
+	}
+
+	now := time.Now()
+	expired := writeTestKey(t, m, now.Add(-3*time.Hour))  // Replaced 2h ago
+	previous := writeTestKey(t, m, now.Add(-2*time.Hour)) // Replaced 30m ago, within the overlap
+	current := writeTestKey(t, m, now.Add(-30*time.Minute))
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if key, err := m.SigningKey(); err != nil || key.ID != current.ID {
+		t.Fatalf("SigningKey() = %s, %v, want the newest key %s", key.ID, err, current.ID)
+	}
+	verifier := NewVerifier(VerifierOptions{Keys: m})
+	claims := Claims{Subject: "user-1", ExpiresAt: now.Add(time.Hour).Unix()}
+	for _, tt := range []struct {
+		name string
+		key  Key
+		err  error
+	}{
+		{"current key", current, nil},
+		{"replaced key within the overlap", previous, nil},
+		{"replaced key past the overlap", expired, ErrTokenUnknownKey},
+	} {
+		if _, err := verifier.Verify(mustSign(t, claims, tt.key)); !errors.Is(err, tt.err) {
+			t.Errorf("%s: Verify() = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	// Published without private keys
+	public := m.PublicKeys()
+	if len(public) != 2 {
+		t.Fatalf("PublicKeys() = %d keys, want 2", len(public))
+	}
+	for _, key := range public {
+		if key.Private != nil {
+			t.Fatalf("PublicKeys() exposes the private key of %s", key.ID)
+		}
+	}
+
+	// Run removes the expired key file and rotates once RotationInterval has passed
+	m.rotateIfDue()
+	if _, err := os.Stat(m.keyFile(expired.ID)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expired key file: %v, want it removed", err)
+	}
+	if key, _ := m.SigningKey(); key.ID != current.ID {
+		t.Fatal("rotated without RotationInterval")
+	}
+	m.opts.RotationInterval = 10 * time.Minute
+	m.rotateIfDue()
+	rotated, _ := m.SigningKey()
+	if rotated.ID == current.ID {
+		t.Fatal("not rotated after RotationInterval")
+	}
+	if _, err := verifier.Verify(mustSign(t, claims, current)); err != nil {
+		t.Fatalf("replaced key rejected right after the rotation: %v", err)
+	}
+}
+
+func TestKeyManagerReloadsOnUnknownKid(t *testing.T) {
+	issuer := newTestKeyManager(t, KeyManagerOptions{Overlap: time.Hour})
+	reader := newTestKeyManager(t, KeyManagerOptions{Dir: issuer.opts.Dir, Overlap: time.Hour})
+
+	// Rotated by the issuer after the reader loaded the directory
+	key, err := issuer.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reloads are limited to one per minKeyReloadInterval
+	if keys := reader.VerificationKeys(key.ID); len(keys) != 0 {
+		t.Fatal("reloaded right after the last reload")
+	}
+	reader.mu.Lock()
+	reader.reloadedAt = time.Now().Add(-minKeyReloadInterval)
+	reader.mu.Unlock()
+	if keys := reader.VerificationKeys(key.ID); len(keys) != 1 || keys[0].ID != key.ID {
+		t.Fatalf("VerificationKeys(new kid) = %v, want the rotated key", keys)
+	}
+
+	second, err := issuer.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := reader.VerificationKeys(second.ID); len(keys) != 0 {
+		t.Fatal("reloaded again within minKeyReloadInterval")
+	}
+}
+
+func TestKeyManagerEncryptedKeys(t *testing.T) {
+	encryptionKey := []byte(strings.Repeat("k", 32))
+	issuer := newTestKeyManager(t, KeyManagerOptions{EncryptionKey: encryptionKey})
+	key, err := issuer.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(issuer.keyFile(key.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "PRIVATE KEY") || !strings.Contains(string(data), `"encrypted": true`) {
+		t.Fatalf("key file stores the private key in plain text: %s", data)
+	}
+
+	tests := []struct {
+		name          string
+		encryptionKey []byte
+		loaded        bool
+	}{
+		{"same encryption key", encryptionKey, true},
+		{"no encryption key", nil, false},
+		{"other encryption key", []byte(strings.Repeat("x", 32)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := newTestKeyManager(t, KeyManagerOptions{Dir: issuer.opts.Dir, EncryptionKey: tt.encryptionKey})
+			loaded, err := reader.SigningKey()
+			if tt.loaded != (err == nil) {
+				t.Fatalf("SigningKey() = %v, want loaded %v", err, tt.loaded)
+			}
+			if tt.loaded && !loaded.Private.(ed25519.PrivateKey).Equal(key.Private) {
+				t.Fatal("decrypted key differs")
+			}
+		})
+	}
+
+	// Unencrypted keys are readable with or without encryption key
+	plain := newTestKeyManager(t, KeyManagerOptions{})
+	key, err = plain.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(plain.keyFile(key.ID)); !strings.Contains(string(data), "PRIVATE KEY") {
+		t.Fatalf("unencrypted key file = %s", data)
+	}
+	if _, err := newTestKeyManager(t, KeyManagerOptions{Dir: plain.opts.Dir, EncryptionKey: encryptionKey}).SigningKey(); err != nil {
+		t.Fatalf("unencrypted key with encryption key configured: %v", err)
+	}
+
+	if _, err := newTestKeyManager(t, KeyManagerOptions{EncryptionKey: []byte("short")}).Rotate(); err == nil {
+		t.Fatal("Rotate() with an invalid AES key = nil error")
+	}
+}
+
+func TestKeyManagerSkipsInvalidFiles(t *testing.T) {
+	m := newTestKeyManager(t, KeyManagerOptions{})
+	valid := writeTestKey(t, m, time.Now())
+	data, err := os.ReadFile(m.keyFile(valid.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := map[string]string{
+		"renamed.json":   string(data), // kid does not match the file name
+		"garbage.json":   "not json",
+		"wrongalg.json":  strings.Replace(strings.Replace(string(data), valid.ID, "wrongalg", 1), `"EdDSA"`, `"RS256"`, 1),
+		"nokey.json":     `{"kid":"nokey","alg":"EdDSA","private_key":""}`,
+		"encrypted.json": `{"kid":"encrypted","alg":"EdDSA","encrypted":true,"private_key":"AAAA"}`,
+	}
+	for name, content := range invalid {
+		if err := os.WriteFile(filepath.Join(m.opts.Dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := m.PublicKeys(); len(keys) != 1 || keys[0].ID != valid.ID {
+		t.Fatalf("PublicKeys() = %v, want only %s", keys, valid.ID)
+	}
+
+	if _, err := NewKeyManager(KeyManagerOptions{Dir: t.TempDir(), Algorithm: AlgHS256}); err == nil {
+		t.Fatal("NewKeyManager() accepted HS256")
+	}
+}
+
+func TestKeyManagerRunCreatesFirstKey(t *testing.T) {
+	m := newTestKeyManager(t, KeyManagerOptions{ReloadInterval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx, true)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := m.SigningKey(); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no signing key created by Run")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context ended")
+	}
+
+	// EnsureSigningKey keeps an existing key
+	key, _ := m.SigningKey()
+	if ensured, err := m.EnsureSigningKey(); err != nil || ensured.ID != key.ID {
+		t.Fatalf("EnsureSigningKey() = %s, %v, want %s", ensured.ID, err, key.ID)
+	}
+}
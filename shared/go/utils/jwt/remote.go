@@ -0,0 +1,164 @@
+// shared/go/utils/jwt/remote.go
+
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+// ==========================================
+// REMOTE KEY SET
+// ==========================================
+
+// Defaults of RemoteKeySetOptions
+const (
+	DefaultJWKSCacheTTL           = 10 * time.Minute
+	DefaultJWKSMinRefreshInterval = 30 * time.Second
+	DefaultJWKSTimeout            = 5 * time.Second
+)
+
+// maxJWKSSize limits the size of a fetched key set
+const maxJWKSSize = 1 << 20
+
+// RemoteKeySetOptions configures a RemoteKeySet
+type RemoteKeySetOptions struct {
+	Source             string        // JWKS URL or local file path
+	CacheTTL           time.Duration // Keys are refetched when older (0 = DefaultJWKSCacheTTL)
+	MinRefreshInterval time.Duration // Minimum time between fetches, also for unknown kids (0 = DefaultJWKSMinRefreshInterval)
+	Timeout            time.Duration // Fetch timeout (0 = DefaultJWKSTimeout)
+}
+
+// RemoteKeySet is a KeySource backed by a JWKS URL or file
+// Keys are cached for CacheTTL and refetched in the background when stale; a token with an unknown kid
+// waits for a refetch (at most one fetch per MinRefreshInterval). Failed fetches keep the last known good keys
+type RemoteKeySet struct {
+	opts   RemoteKeySetOptions
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      []Key
+	fetchedAt time.Time // Last successful fetch
+	triedAt   time.Time // Last fetch attempt
+}
+
+// NewRemoteKeySet creates a key set, keys are fetched on first use
+func NewRemoteKeySet(opts RemoteKeySetOptions) *RemoteKeySet {
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = DefaultJWKSCacheTTL
+	}
+	if opts.MinRefreshInterval <= 0 {
+		opts.MinRefreshInterval = DefaultJWKSMinRefreshInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultJWKSTimeout
+	}
+	return &RemoteKeySet{opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+}
+
+// VerificationKeys implements KeySource
+func (s *RemoteKeySet) VerificationKeys(kid string) []Key {
+	s.mu.Lock()
+	now := time.Now()
+	missing := len(s.keys) == 0 || (kid != "" && StaticKeys(s.keys).VerificationKeys(kid) == nil)
+	stale := now.Sub(s.fetchedAt) >= s.opts.CacheTTL
+	refresh := (missing || stale) && now.Sub(s.triedAt) >= s.opts.MinRefreshInterval
+	if refresh {
+		s.triedAt = now
+	}
+	s.mu.Unlock()
+
+	switch {
+	case refresh && missing:
+		s.refresh()
+	case refresh:
+		go s.refresh()
+	}
+	return StaticKeys(s.PublicKeys()).VerificationKeys(kid)
+}
+
+// PublicKeys returns the cached keys
+func (s *RemoteKeySet) PublicKeys() []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys
+}
+
+// Refresh fetches the keys now
+func (s *RemoteKeySet) Refresh() error {
+	s.mu.Lock()
+	s.triedAt = time.Now()
+	s.mu.Unlock()
+	return s.refresh()
+}
+
+// refresh fetches the keys, on failure the cached keys stay in use
+func (s *RemoteKeySet) refresh() error {
+	keys, err := s.fetch()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		logger.LogEvent(logger.EventJWKSRefreshFailed, map[string]interface{}{
+			"source": s.opts.Source,
+			"keys":   len(s.keys),
+			"error":  err.Error(),
+		})
+		return err
+	}
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+// fetch loads and parses the key set
+func (s *RemoteKeySet) fetch() ([]Key, error) {
+	if !strings.HasPrefix(s.opts.Source, "https://") && !strings.HasPrefix(s.opts.Source, "http://") {
+		data, err := os.ReadFile(s.opts.Source)
+		if err != nil {
+			return nil, err
+		}
+		return ParseJWKS(data)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.opts.Source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %d", s.opts.Source, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// KeySources combines several key sources
+type KeySources []KeySource
+
+// VerificationKeys implements KeySource
+func (k KeySources) VerificationKeys(kid string) []Key {
+	var keys []Key
+	for _, source := range k {
+		keys = append(keys, source.VerificationKeys(kid)...)
+	}
+	return keys
+}
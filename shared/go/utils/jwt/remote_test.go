@@ -0,0 +1,222 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer serves the public keys it is given, or an error status
+type jwksServer struct {
+	mu       sync.Mutex
+	keys     []Key
+	status   int
+	hold     chan struct{} // Responses wait until closed (nil: respond right away)
+	requests atomic.Int32
+	*httptest.Server
+}
+
+func newJWKSServer(t *testing.T, keys ...Key) *jwksServer {
+	s := &jwksServer{keys: keys, status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.mu.Lock()
+		keys, status, hold := s.keys, s.status, s.hold
+		s.mu.Unlock()
+		if hold != nil {
+			<-hold
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		JWKSHandler(StaticKeys(keys)).ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) set(status int, keys ...Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.keys = status, keys
+}
+
+// waitFor polls cond for up to 5s
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// newEdKey creates an Ed25519 key with kid
+func newEdKey(t *testing.T, kid string) Key {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewPrivateKey(kid, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// age moves the last fetch and fetch attempt of s back by d
+func (s *RemoteKeySet) age(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetchedAt = s.fetchedAt.Add(-d)
+	s.triedAt = s.triedAt.Add(-d)
+}
+
+func TestRemoteKeySetRefreshOnUnknownKid(t *testing.T) {
+	first, second := newEdKey(t, "first"), newEdKey(t, "second")
+	server := newJWKSServer(t, first)
+	set := NewRemoteKeySet(RemoteKeySetOptions{Source: server.URL, CacheTTL: time.Hour, MinRefreshInterval: time.Minute})
+
+	// Fetched on first use
+	if keys := set.VerificationKeys("first"); len(keys) != 1 || keys[0].Private != nil {
+		t.Fatalf("VerificationKeys(first) = %v, want the public key", keys)
+	}
+	if n := server.requests.Load(); n != 1 {
+		t.Fatalf("%d requests, want 1", n)
+	}
+
+	// The issuer rotates; an unknown kid refetches, at most once per MinRefreshInterval
+	server.set(http.StatusOK, first, second)
+	if keys := set.VerificationKeys("second"); len(keys) != 0 {
+		t.Fatal("refetched within MinRefreshInterval")
+	}
+	set.age(time.Minute)
+	if keys := set.VerificationKeys("second"); len(keys) != 1 {
+		t.Fatal("unknown kid did not refetch the key set")
+	}
+	if keys := set.VerificationKeys("first"); len(keys) != 1 {
+		t.Fatal("replaced key lost during the overlap")
+	}
+	if n := server.requests.Load(); n != 2 {
+		t.Fatalf("%d requests, want 2", n)
+	}
+
+	// Tokens of both keys verify through the set
+	verifier := NewVerifier(VerifierOptions{Keys: set})
+	for _, key := range []Key{first, second} {
+		if _, err := verifier.Verify(mustSign(t, Claims{Subject: "user-1", ExpiresAt: time.Now().Add(time.Hour).Unix()}, key)); err != nil {
+			t.Fatalf("token of %s: %v", key.ID, err)
+		}
+	}
+}
+
+func TestRemoteKeySetCacheTTL(t *testing.T) {
+	first, second := newEdKey(t, "first"), newEdKey(t, "second")
+	server := newJWKSServer(t, first)
+	set := NewRemoteKeySet(RemoteKeySetOptions{Source: server.URL, CacheTTL: time.Hour, MinRefreshInterval: time.Minute})
+	set.VerificationKeys("first")
+
+	// Cached until the TTL passes
+	server.set(http.StatusOK, second)
+	set.age(59 * time.Minute)
+	if keys := set.VerificationKeys("first"); len(keys) != 1 || server.requests.Load() != 1 {
+		t.Fatalf("keys refetched before the TTL (%d requests)", server.requests.Load())
+	}
+
+	// Stale keys are served while the refetch runs in the background
+	hold := make(chan struct{})
+	release := sync.OnceFunc(func() { close(hold) })
+	defer release()
+	server.mu.Lock()
+	server.hold = hold
+	server.mu.Unlock()
+	set.age(time.Minute)
+	if keys := set.VerificationKeys("first"); len(keys) != 1 {
+		t.Fatal("stale key not served during the background refetch")
+	}
+	release()
+	waitFor(t, "the background refetch", func() bool { return len(StaticKeys(set.PublicKeys()).VerificationKeys("second")) == 1 })
+	if keys := StaticKeys(set.PublicKeys()).VerificationKeys("first"); len(keys) != 0 {
+		t.Fatal("removed key still cached after the refetch")
+	}
+}
+
+func TestRemoteKeySetKeepsLastKnownGood(t *testing.T) {
+	key := newEdKey(t, "key")
+	server := newJWKSServer(t, key)
+	set := NewRemoteKeySet(RemoteKeySetOptions{Source: server.URL})
+	if err := set.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	failures := []struct {
+		name   string
+		status int
+		keys   []Key
+	}{
+		{"server error", http.StatusInternalServerError, nil},
+		{"not found", http.StatusNotFound, nil},
+		{"empty key set", http.StatusOK, nil},
+		{"HS256 keys only", http.StatusOK, []Key{{ID: "hs", Algorithm: AlgHS256, Secret: make([]byte, MinHMACSecretLength)}}},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			server.set(tt.status, tt.keys...)
+			if err := set.Refresh(); err == nil {
+				t.Fatal("Refresh() = nil error")
+			}
+			if keys := StaticKeys(set.PublicKeys()).VerificationKeys("key"); len(keys) != 1 {
+				t.Fatal("last known good key lost")
+			}
+		})
+	}
+
+	unreachable := NewRemoteKeySet(RemoteKeySetOptions{Source: "http://127.0.0.1:1/jwks.json", Timeout: time.Second})
+	if keys := unreachable.VerificationKeys("key"); len(keys) != 0 {
+		t.Fatal("keys from an unreachable source")
+	}
+}
+
+func TestRemoteKeySetFile(t *testing.T) {
+	key := newEdKey(t, "key")
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	data, err := json.Marshal(PublicJWKS([]Key{key}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	set := NewRemoteKeySet(RemoteKeySetOptions{Source: file})
+	if keys := set.VerificationKeys("key"); len(keys) != 1 {
+		t.Fatal("key from file not loaded")
+	}
+	if err := NewRemoteKeySet(RemoteKeySetOptions{Source: file + ".missing"}).Refresh(); err == nil {
+		t.Fatal("Refresh() of a missing file = nil error")
+	}
+
+	// Combined with other sources
+	hmacKey, err := NewHMACKey("hs", make([]byte, MinHMACSecretLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := KeySources{set, StaticKeys{hmacKey}}
+	if keys := sources.VerificationKeys(""); len(keys) != 2 {
+		t.Fatalf("KeySources.VerificationKeys(\"\") = %d keys, want 2", len(keys))
+	}
+	if keys := sources.VerificationKeys("hs"); len(keys) != 1 || keys[0].Algorithm != AlgHS256 {
+		t.Fatalf("KeySources.VerificationKeys(hs) = %v, want the HS256 key", keys)
+	}
+}
@@ -53,6 +53,8 @@ const (
 	ComponentMiddlewareRecovery    = "middleware.recovery"
 	ComponentMiddlewareLogging     = "middleware.logging"
 	ComponentMiddlewareCompression = "middleware.compression"
+	ComponentMiddlewareCircuit     = "middleware.circuitbreaker"
+	ComponentMiddlewareProxy       = "middleware.proxy"
 
 	ComponentAuthJWT      = "auth.jwt"
 	ComponentAuthSession  = "auth.session"
@@ -55,6 +55,22 @@ var (
 		Level:       LevelWarn,
 		Description: "JWT token was revoked",
 	}
+
+	EventSigningKeyRotated = ILogEvent{
+		Code:        "AUTH-JWT-007",
+		Component:   ComponentAuthJWT,
+		Message:     "Signing key rotated",
+		Level:       LevelInfo,
+		Description: "New JWT signing key created, previous keys stay valid for the overlap period",
+	}
+
+	EventJWKSRefreshFailed = ILogEvent{
+		Code:        "AUTH-JWT-008",
+		Component:   ComponentAuthJWT,
+		Message:     "JWKS refresh failed",
+		Level:       LevelWarn,
+		Description: "Key set could not be fetched, the last known good keys stay in use",
+	}
 )
 
 // Session Events (AUTH-SES-xxx)
@@ -21,7 +21,7 @@ var (
 		Component:   ComponentMiddlewareRateLimit,
 		Message:     "IP temporarily blocked",
 		Level:       LevelError,
-		Description: "IP address blocked after multiple rate limit violations",
+		Description: "IP address blocked after repeated abuse (rate limit violations, 401s, spoofed headers, oversized bodies)",
 	}
 
 	EventRateLimitConfigChanged = ILogEvent{
@@ -31,6 +31,30 @@ var (
 		Level:       LevelInfo,
 		Description: "Rate limit settings were updated",
 	}
+
+	EventRateLimitStoreUnavailable = ILogEvent{
+		Code:        "MW-RL-004",
+		Component:   ComponentMiddlewareRateLimit,
+		Message:     "Rate limit store unavailable",
+		Level:       LevelError,
+		Description: "Shared limiter store (Redis) cannot be reached, requests fail open or closed per config",
+	}
+
+	EventRateLimitStoreRecovered = ILogEvent{
+		Code:        "MW-RL-005",
+		Component:   ComponentMiddlewareRateLimit,
+		Message:     "Rate limit store recovered",
+		Level:       LevelInfo,
+		Description: "Shared limiter store (Redis) is reachable again",
+	}
+
+	EventRateLimitIPUnblocked = ILogEvent{
+		Code:        "MW-RL-006",
+		Component:   ComponentMiddlewareRateLimit,
+		Message:     "IP unblocked",
+		Level:       LevelInfo,
+		Description: "Temporary IP block was lifted by an admin",
+	}
 )
 
 // Request Size Events (MW-SZ-xxx)
@@ -112,6 +136,30 @@ var (
 		Level:       LevelError,
 		Description: "Mismatch between connection IP and forwarded headers",
 	}
+
+	EventIPFilterRejected = ILogEvent{
+		Code:        "MW-SEC-006",
+		Component:   ComponentMiddlewareSecurity,
+		Message:     "Request rejected by IP filter",
+		Level:       LevelWarn,
+		Description: "Client IP is on a deny list or not on the allow list of the path",
+	}
+
+	EventCloudflareRangesRefreshed = ILogEvent{
+		Code:        "MW-SEC-007",
+		Component:   ComponentMiddlewareCloudflare,
+		Message:     "Cloudflare IP ranges refreshed",
+		Level:       LevelInfo,
+		Description: "Cloudflare IP ranges reloaded from the configured sources",
+	}
+
+	EventCloudflareRefreshFailed = ILogEvent{
+		Code:        "MW-SEC-008",
+		Component:   ComponentMiddlewareCloudflare,
+		Message:     "Cloudflare IP ranges refresh failed",
+		Level:       LevelWarn,
+		Description: "Cloudflare IP ranges could not be loaded, the last known good ranges stay in use",
+	}
 )
 
 // Recovery Events (MW-REC-xxx)
@@ -144,6 +192,92 @@ var (
 	}
 )
 
+// Circuit Breaker Events (MW-CB-xxx)
+var (
+	EventCircuitBreakerOpened = ILogEvent{
+		Code:        "MW-CB-001",
+		Component:   ComponentMiddlewareCircuit,
+		Message:     "Circuit breaker opened",
+		Level:       LevelError,
+		Description: "Upstream exceeded failure thresholds, requests are rejected until cool-down ends",
+	}
+
+	EventCircuitBreakerHalfOpen = ILogEvent{
+		Code:        "MW-CB-002",
+		Component:   ComponentMiddlewareCircuit,
+		Message:     "Circuit breaker half-open",
+		Level:       LevelInfo,
+		Description: "Cool-down ended, limited probe requests are sent to the upstream",
+	}
+
+	EventCircuitBreakerClosed = ILogEvent{
+		Code:        "MW-CB-003",
+		Component:   ComponentMiddlewareCircuit,
+		Message:     "Circuit breaker closed",
+		Level:       LevelInfo,
+		Description: "Probe requests succeeded, upstream is back in normal operation",
+	}
+
+	EventCircuitBreakerRejected = ILogEvent{
+		Code:        "MW-CB-004",
+		Component:   ComponentMiddlewareCircuit,
+		Message:     "Request rejected by circuit breaker",
+		Level:       LevelWarn,
+		Description: "Request failed fast because the upstream circuit is open",
+	}
+)
+
+// Proxy Events (MW-PRX-xxx)
+var (
+	EventProxyConnectionFailed = ILogEvent{
+		Code:        "MW-PRX-001",
+		Component:   ComponentMiddlewareProxy,
+		Message:     "Upstream connection failed",
+		Level:       LevelError,
+		Description: "Upstream refused or reset the connection",
+	}
+
+	EventProxyDNSFailed = ILogEvent{
+		Code:        "MW-PRX-002",
+		Component:   ComponentMiddlewareProxy,
+		Message:     "Upstream DNS resolution failed",
+		Level:       LevelError,
+		Description: "Upstream host name could not be resolved",
+	}
+
+	EventProxyUpstreamTimeout = ILogEvent{
+		Code:        "MW-PRX-003",
+		Component:   ComponentMiddlewareProxy,
+		Message:     "Upstream timeout",
+		Level:       LevelError,
+		Description: "Upstream did not respond in time",
+	}
+
+	EventProxyTLSFailed = ILogEvent{
+		Code:        "MW-PRX-004",
+		Component:   ComponentMiddlewareProxy,
+		Message:     "Upstream TLS handshake failed",
+		Level:       LevelError,
+		Description: "TLS connection to the upstream failed (certificate, protocol)",
+	}
+
+	EventProxyRequestFailed = ILogEvent{
+		Code:        "MW-PRX-005",
+		Component:   ComponentMiddlewareProxy,
+		Message:     "Upstream request failed",
+		Level:       LevelError,
+		Description: "Proxied request failed for another reason",
+	}
+
+	EventProxyClientClosed = ILogEvent{
+		Code:        "MW-PRX-006",
+		Component:   ComponentMiddlewareProxy,
+		Message:     "Client closed request",
+		Level:       LevelInfo,
+		Description: "Client went away before the upstream responded",
+	}
+)
+
 // Request Logging Events (MW-LOG-xxx)
 var (
 	EventRequestIncoming = ILogEvent{
@@ -61,6 +61,12 @@ func (l *Logger) WithContext(ctx context.Context) *Logger {
 	if userID := ctx.Value("user_id"); userID != nil {
 		newLogger = newLogger.Str("user_id", userID.(string))
 	}
+	if traceID := ctx.Value("trace_id"); traceID != nil {
+		newLogger = newLogger.Str("trace_id", traceID.(string))
+	}
+	if spanID := ctx.Value("span_id"); spanID != nil {
+		newLogger = newLogger.Str("span_id", spanID.(string))
+	}
 
 	return &Logger{zlog: newLogger.Logger()}
 }
@@ -92,6 +98,7 @@ func (l *Logger) DebugWithFields(msg string, fields map[string]interface{}) {
 	for k, v := range fields {
 		event = event.Interface(k, v)
 	}
+	event = withTrace(event, fields)
 	event.Msg(msg)
 }
 
@@ -106,6 +113,7 @@ func (l *Logger) InfoWithFields(msg string, fields map[string]interface{}) {
 	for k, v := range fields {
 		event = event.Interface(k, v)
 	}
+	event = withTrace(event, fields)
 	event.Msg(msg)
 }
 
@@ -120,6 +128,7 @@ func (l *Logger) WarnWithFields(msg string, fields map[string]interface{}) {
 	for k, v := range fields {
 		event = event.Interface(k, v)
 	}
+	event = withTrace(event, fields)
 	event.Msg(msg)
 }
 
@@ -134,6 +143,7 @@ func (l *Logger) ErrorWithFields(msg string, err error, fields map[string]interf
 	for k, v := range fields {
 		event = event.Interface(k, v)
 	}
+	event = withTrace(event, fields)
 	event.Msg(msg)
 }
 
@@ -148,6 +158,7 @@ func (l *Logger) FatalWithFields(msg string, err error, fields map[string]interf
 	for k, v := range fields {
 		event = event.Interface(k, v)
 	}
+	event = withTrace(event, fields)
 	event.Msg(msg)
 }
 
@@ -173,6 +184,7 @@ func (l *Logger) HTTPWithFields(method, path string, statusCode int, duration ti
 	for k, v := range fields {
 		event = event.Interface(k, v)
 	}
+	event = withTrace(event, fields)
 	event.Msg("HTTP Request")
 }
 
@@ -248,10 +260,15 @@ func HTTP(method, path string, statusCode int, duration time.Duration, clientIP
 	}
 }
 
-// WithRequestID creates a logger with request ID
+// WithRequestID creates a logger with request ID (and the trace bound to it, see BindTrace)
 func WithRequestID(requestID string) *Logger {
 	if appLogger != nil {
-		return appLogger.WithField("request_id", requestID)
+		fields := map[string]interface{}{"request_id": requestID}
+		if ids, ok := traceFor(requestID); ok {
+			fields["trace_id"] = ids.traceID
+			fields["span_id"] = ids.spanID
+		}
+		return appLogger.WithFields(fields)
 	}
 	return nil
 }
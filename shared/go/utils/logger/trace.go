@@ -0,0 +1,62 @@
+// shared/go/utils/logger/trace.go
+
+package logger
+
+import (
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// ==========================================
+// TRACE CORRELATION
+// ==========================================
+// The tracing middleware binds the trace of a request to its request ID,
+// every log line with that "request_id" field then also gets "trace_id" and "span_id"
+
+// traceIDs are the trace fields bound to a request ID
+type traceIDs struct {
+	traceID string
+	spanID  string
+}
+
+// traceBindings maps request IDs to *traceIDs
+var traceBindings sync.Map
+
+// BindTrace adds trace_id/span_id to all log lines of requestID until unbind is called
+func BindTrace(requestID, traceID, spanID string) (unbind func()) {
+	if requestID == "" {
+		return func() {}
+	}
+	ids := &traceIDs{traceID: traceID, spanID: spanID}
+	traceBindings.Store(requestID, ids)
+
+	// Only remove our own binding (a client may reuse a request ID for concurrent requests)
+	return func() {
+		traceBindings.CompareAndDelete(requestID, ids)
+	}
+}
+
+// traceFor returns the trace fields bound to requestID
+func traceFor(requestID string) (*traceIDs, bool) {
+	if requestID == "" {
+		return nil, false
+	}
+	ids, ok := traceBindings.Load(requestID)
+	if !ok {
+		return nil, false
+	}
+	return ids.(*traceIDs), true
+}
+
+// withTrace adds the trace fields for the event's request_id field
+func withTrace(event *zerolog.Event, fields map[string]interface{}) *zerolog.Event {
+	if _, ok := fields["trace_id"]; ok {
+		return event
+	}
+	requestID, _ := fields["request_id"].(string)
+	if ids, ok := traceFor(requestID); ok {
+		event = event.Str("trace_id", ids.traceID).Str("span_id", ids.spanID)
+	}
+	return event
+}
@@ -0,0 +1,67 @@
+// shared/go/utils/mail/local.go
+
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+	"github.com/app/shared/go/utils/security"
+)
+
+// ==========================================
+// LOCAL SENDERS
+// ==========================================
+// For development and tests: nothing leaves the machine
+
+// FileSender writes every mail as .eml file into a directory
+type FileSender struct {
+	dir  string
+	from string
+}
+
+// NewFileSender creates the directory if needed
+func NewFileSender(dir, from string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileSender{dir: dir, from: from}, nil
+}
+
+// Send writes msg to <dir>/<time>-<id>.eml
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	data, err := encode(s.from, msg)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000"), security.GenerateID())
+	return os.WriteFile(filepath.Join(s.dir, name), data, 0o600)
+}
+
+// LogSender logs every mail including its body
+type LogSender struct {
+	from string
+}
+
+// NewLogSender creates a log sender
+func NewLogSender(from string) *LogSender {
+	return &LogSender{from: from}
+}
+
+// Send logs msg at info level
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	if _, err := encode(s.from, msg); err != nil {
+		return err
+	}
+	logger.InfoWithFields("Mail (log sender, not delivered)", map[string]interface{}{
+		"from":    s.from,
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"body":    msg.Body,
+	})
+	return nil
+}
@@ -0,0 +1,117 @@
+// shared/go/utils/mail/mail.go
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/app/shared/go/utils/security"
+)
+
+// ==========================================
+// MAIL
+// ==========================================
+// Senders for transactional mails (verification links, password resets):
+//   - SMTPSender delivers via an SMTP server (STARTTLS or implicit TLS)
+//   - FileSender writes .eml files, LogSender logs the mail (local testing only, the body contains the links)
+
+// Sender kinds of MAIL_SENDER
+const (
+	SenderSMTP = "smtp"
+	SenderFile = "file"
+	SenderLog  = "log"
+)
+
+// ErrInvalidHeader is returned for header values containing line breaks (header injection)
+var ErrInvalidHeader = errors.New("mail: invalid header value")
+
+// Message is a plain text mail
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers mails
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewSenderFromEnv creates the sender selected by MAIL_SENDER (default "log"):
+// "smtp" reads SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD and MAIL_FROM,
+// "file" writes to MAIL_DIR (default "tmp/mail")
+func NewSenderFromEnv() (Sender, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch kind := os.Getenv("MAIL_SENDER"); kind {
+	case SenderSMTP:
+		port := 587
+		if value := os.Getenv("SMTP_PORT"); value != "" {
+			var err error
+			if port, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid SMTP_PORT %q", value)
+			}
+		}
+		return NewSMTPSender(SMTPOptions{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		})
+	case SenderFile:
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "tmp/mail"
+		}
+		return NewFileSender(dir, from)
+	case SenderLog, "":
+		return NewLogSender(from), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_SENDER %q (smtp, file or log)", kind)
+	}
+}
+
+// encode renders msg as RFC 5322 message (UTF-8, quoted-printable body)
+func encode(from string, msg Message) ([]byte, error) {
+	for _, value := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+	domain := "localhost"
+	if _, host, found := strings.Cut(from, "@"); found {
+		domain = strings.Trim(host, "> ")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", security.GenerateID(), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	if _, err := body.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,121 @@
+// shared/go/utils/mail/smtp.go
+
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// ==========================================
+// SMTP SENDER
+// ==========================================
+
+// DefaultSMTPTimeout bounds a delivery if the context has no earlier deadline
+const DefaultSMTPTimeout = 30 * time.Second
+
+// SMTPOptions configures an SMTPSender
+type SMTPOptions struct {
+	Host     string
+	Port     int    // 465 = implicit TLS, otherwise STARTTLS if the server offers it
+	Username string // Empty: no authentication
+	Password string
+	From     string
+	Timeout  time.Duration // 0 = DefaultSMTPTimeout
+}
+
+// SMTPSender delivers mails via SMTP
+type SMTPSender struct {
+	opts     SMTPOptions
+	envelope string // Address of From for MAIL FROM
+}
+
+// NewSMTPSender creates an SMTP sender
+func NewSMTPSender(opts SMTPOptions) (*SMTPSender, error) {
+	if opts.Host == "" {
+		return nil, errors.New("mail: SMTP host is required")
+	}
+	from, err := mail.ParseAddress(opts.From)
+	if err != nil {
+		return nil, errors.New("mail: invalid from address")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultSMTPTimeout
+	}
+	return &SMTPSender{opts: opts, envelope: from.Address}, nil
+}
+
+// Send delivers msg, authentication requires TLS (net/smtp refuses plain auth without it, except on localhost)
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return ErrInvalidHeader
+	}
+	data, err := encode(s.opts.From, msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(s.opts.Host, strconv.Itoa(s.opts.Port))
+	tlsConfig := &tls.Config{ServerName: s.opts.Host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	if s.opts.Port == 465 {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	// The whole conversation ends with the context
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.opts.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && s.opts.Port != 465 {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.opts.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.opts.Username, s.opts.Password, s.opts.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.envelope); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
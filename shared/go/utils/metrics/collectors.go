@@ -0,0 +1,86 @@
+// shared/go/utils/metrics/collectors.go
+
+package metrics
+
+import (
+	"database/sql"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// RUNTIME METRICS
+// ==========================================
+// Go runtime and process basics, refreshed on every scrape
+
+var (
+	goGoroutines   = NewGaugeVec("go_goroutines", "Number of goroutines that currently exist.")
+	goHeapAlloc    = NewGaugeVec("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.")
+	goGCCycles     = NewCounterVec("go_gc_cycles_total", "Number of completed GC cycles.")
+	processStarted = NewGaugeVec("process_start_time_seconds", "Start time of the process since unix epoch in seconds.")
+)
+
+func init() {
+	processStarted.WithLabelValues().Set(float64(time.Now().Unix()))
+
+	Default.OnScrape(func() {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		goGoroutines.WithLabelValues().Set(float64(runtime.NumGoroutine()))
+		goHeapAlloc.WithLabelValues().Set(float64(mem.HeapAlloc))
+		goGCCycles.WithLabelValues().set(float64(mem.NumGC))
+	})
+}
+
+// ==========================================
+// DATABASE POOL METRICS
+// ==========================================
+// Mirrors sql.DB.Stats() of registered pools, labelled by pool name
+
+var (
+	dbOpenConnections   = NewGaugeVec("db_pool_open_connections", "Established connections, in use and idle.", "db")
+	dbInUseConnections  = NewGaugeVec("db_pool_in_use_connections", "Connections currently in use.", "db")
+	dbIdleConnections   = NewGaugeVec("db_pool_idle_connections", "Idle connections.", "db")
+	dbMaxOpenConns      = NewGaugeVec("db_pool_max_open_connections", "Maximum number of open connections (0 = unlimited).", "db")
+	dbWaitCount         = NewCounterVec("db_pool_wait_count_total", "Connections waited for because the pool was exhausted.", "db")
+	dbWaitDuration      = NewCounterVec("db_pool_wait_duration_seconds_total", "Total time blocked waiting for a connection.", "db")
+	dbMaxIdleClosed     = NewCounterVec("db_pool_max_idle_closed_total", "Connections closed due to SetMaxIdleConns.", "db")
+	dbMaxIdleTimeClosed = NewCounterVec("db_pool_max_idle_time_closed_total", "Connections closed due to SetConnMaxIdleTime.", "db")
+	dbMaxLifetimeClosed = NewCounterVec("db_pool_max_lifetime_closed_total", "Connections closed due to SetConnMaxLifetime.", "db")
+
+	dbPoolsMu sync.Mutex
+	dbPools   = map[string]*sql.DB{}
+)
+
+func init() {
+	Default.OnScrape(collectDBStats)
+}
+
+// RegisterDBStats exports the pool statistics of db under the given name
+// Registering a name again replaces the pool (e.g. after reconnecting)
+func RegisterDBStats(name string, db *sql.DB) {
+	dbPoolsMu.Lock()
+	defer dbPoolsMu.Unlock()
+
+	dbPools[name] = db
+}
+
+// collectDBStats copies sql.DB.Stats() of all registered pools into the metrics
+func collectDBStats() {
+	dbPoolsMu.Lock()
+	defer dbPoolsMu.Unlock()
+
+	for name, db := range dbPools {
+		stats := db.Stats()
+		dbOpenConnections.WithLabelValues(name).Set(float64(stats.OpenConnections))
+		dbInUseConnections.WithLabelValues(name).Set(float64(stats.InUse))
+		dbIdleConnections.WithLabelValues(name).Set(float64(stats.Idle))
+		dbMaxOpenConns.WithLabelValues(name).Set(float64(stats.MaxOpenConnections))
+		dbWaitCount.WithLabelValues(name).set(float64(stats.WaitCount))
+		dbWaitDuration.WithLabelValues(name).set(stats.WaitDuration.Seconds())
+		dbMaxIdleClosed.WithLabelValues(name).set(float64(stats.MaxIdleClosed))
+		dbMaxIdleTimeClosed.WithLabelValues(name).set(float64(stats.MaxIdleTimeClosed))
+		dbMaxLifetimeClosed.WithLabelValues(name).set(float64(stats.MaxLifetimeClosed))
+	}
+}
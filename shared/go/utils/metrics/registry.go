@@ -0,0 +1,440 @@
+// shared/go/utils/metrics/registry.go
+
+package metrics
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ==========================================
+// METRICS REGISTRY
+// ==========================================
+// Minimal Prometheus-compatible metrics (text exposition format 0.0.4)
+// Counters, gauges and histograms with labels, exposed via Handler() on /metrics
+// Keep label values bounded (route templates, not raw paths) - every combination is a time series
+
+// contentType of the Prometheus text format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are latency buckets in seconds (5ms - 10s)
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default is the registry used by the package-level constructors and Handler()
+var Default = NewRegistry()
+
+// family is a registered metric with all its label combinations
+type family interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families and renders them in the text format
+type Registry struct {
+	mu       sync.RWMutex
+	families []family
+	names    map[string]bool
+	hooks    []func() // Run before every scrape (e.g. copy sql.DB.Stats() into gauges)
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register adds a family, registering the same name twice is a programming error
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[f.name()] {
+		panic(fmt.Sprintf("metrics: %s registered twice", f.name()))
+	}
+	r.names[f.name()] = true
+	r.families = append(r.families, f)
+}
+
+// OnScrape registers fn to run before every scrape
+func (r *Registry) OnScrape(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks = append(r.hooks, fn)
+}
+
+// WriteText writes all metrics in the Prometheus text format
+func (r *Registry) WriteText(w *bufio.Writer) {
+	r.mu.RLock()
+	hooks := append([]func(){}, r.hooks...)
+	families := append([]family{}, r.families...)
+	r.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].name() < families[j].name() })
+	for _, f := range families {
+		f.write(w)
+	}
+}
+
+// Handler serves the registry on /metrics
+// If token is set, requests must send "Authorization: Bearer <token>"
+func (r *Registry) Handler(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		given := []byte(req.Header.Get("Authorization"))
+		if token != "" && subtle.ConstantTimeCompare(given, []byte("Bearer "+token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-store")
+		bw := bufio.NewWriter(w)
+		r.WriteText(bw)
+		bw.Flush()
+	}
+}
+
+// Handler serves the default registry
+func Handler(token string) http.HandlerFunc {
+	return Default.Handler(token)
+}
+
+// ==========================================
+// LABELLED VECTORS
+// ==========================================
+
+// vec holds one child per label value combination
+type vec[T any] struct {
+	metricName string
+	help       string
+	typ        string
+	labels     []string
+	newChild   func() *T
+
+	mu       sync.RWMutex
+	children map[string]*T
+	values   map[string][]string
+}
+
+func newVec[T any](name, help, typ string, labels []string, newChild func() *T) *vec[T] {
+	v := &vec[T]{
+		metricName: name,
+		help:       help,
+		typ:        typ,
+		labels:     labels,
+		newChild:   newChild,
+		children:   make(map[string]*T),
+		values:     make(map[string][]string),
+	}
+
+	// Metrics without labels are exported from the start (e.g. 0 panics)
+	if len(labels) == 0 {
+		v.with(nil)
+	}
+	return v
+}
+
+func (v *vec[T]) name() string { return v.metricName }
+
+// with returns the child for the label values, creating it on first use
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.metricName, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	child, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return child
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if child, ok := v.children[key]; ok {
+		return child
+	}
+	child = v.newChild()
+	v.children[key] = child
+	v.values[key] = append([]string(nil), values...)
+	return child
+}
+
+// each calls fn for every child, sorted by label values
+func (v *vec[T]) each(fn func(labels string, child *T)) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	children := make([]*T, len(keys))
+	labels := make([]string, len(keys))
+	for i, key := range keys {
+		children[i] = v.children[key]
+		labels[i] = formatLabels(v.labels, v.values[key])
+	}
+	v.mu.RUnlock()
+
+	for i := range keys {
+		fn(labels[i], children[i])
+	}
+}
+
+// header writes the HELP and TYPE lines
+func (v *vec[T]) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.metricName, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.metricName, v.typ)
+}
+
+// ==========================================
+// COUNTER
+// ==========================================
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	bits atomic.Uint64
+}
+
+// Inc adds 1
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add adds delta (must not be negative)
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	addFloat(&c.bits, delta)
+}
+
+// set overwrites the value, used to mirror cumulative counters (e.g. sql.DBStats.WaitCount)
+func (c *Counter) set(value float64) { c.bits.Store(math.Float64bits(value)) }
+
+// Value returns the current value
+func (c *Counter) Value() float64 { return math.Float64frombits(c.bits.Load()) }
+
+// CounterVec is a counter with labels
+type CounterVec struct {
+	*vec[Counter]
+}
+
+// NewCounterVec registers a counter on the registry
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{newVec(name, help, "counter", labels, func() *Counter { return &Counter{} })}
+	r.register(v)
+	return v
+}
+
+// NewCounterVec registers a counter on the default registry
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// WithLabelValues returns the counter for the label values (in registration order)
+func (v *CounterVec) WithLabelValues(values ...string) *Counter { return v.with(values) }
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.header(w)
+	v.each(func(labels string, c *Counter) {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, labels, formatFloat(c.Value()))
+	})
+}
+
+// ==========================================
+// GAUGE
+// ==========================================
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+// Set sets the value
+func (g *Gauge) Set(value float64) { g.bits.Store(math.Float64bits(value)) }
+
+// Inc adds 1
+func (g *Gauge) Inc() { addFloat(&g.bits, 1) }
+
+// Dec subtracts 1
+func (g *Gauge) Dec() { addFloat(&g.bits, -1) }
+
+// Add adds delta
+func (g *Gauge) Add(delta float64) { addFloat(&g.bits, delta) }
+
+// Value returns the current value
+func (g *Gauge) Value() float64 { return math.Float64frombits(g.bits.Load()) }
+
+// GaugeVec is a gauge with labels
+type GaugeVec struct {
+	*vec[Gauge]
+}
+
+// NewGaugeVec registers a gauge on the registry
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{newVec(name, help, "gauge", labels, func() *Gauge { return &Gauge{} })}
+	r.register(v)
+	return v
+}
+
+// NewGaugeVec registers a gauge on the default registry
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return Default.NewGaugeVec(name, help, labels...)
+}
+
+// WithLabelValues returns the gauge for the label values (in registration order)
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge { return v.with(values) }
+
+func (v *GaugeVec) write(w *bufio.Writer) {
+	v.header(w)
+	v.each(func(labels string, g *Gauge) {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, labels, formatFloat(g.Value()))
+	})
+}
+
+// ==========================================
+// HISTOGRAM
+// ==========================================
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	upperBounds []float64
+	buckets     []atomic.Uint64 // Non-cumulative, summed up when written
+	count       atomic.Uint64
+	sum         atomic.Uint64 // float64 bits
+}
+
+// Observe records a value (e.g. a duration in seconds)
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.upperBounds, value)
+	if i < len(h.buckets) {
+		h.buckets[i].Add(1)
+	}
+	addFloat(&h.sum, value)
+	h.count.Add(1)
+}
+
+// HistogramVec is a histogram with labels
+type HistogramVec struct {
+	*vec[Histogram]
+	upperBounds []float64
+}
+
+// NewHistogramVec registers a histogram on the registry (nil buckets = DefaultBuckets)
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	upperBounds := append([]float64(nil), buckets...)
+	sort.Float64s(upperBounds)
+
+	v := &HistogramVec{upperBounds: upperBounds}
+	v.vec = newVec(name, help, "histogram", labels, func() *Histogram {
+		return &Histogram{
+			upperBounds: upperBounds,
+			buckets:     make([]atomic.Uint64, len(upperBounds)),
+		}
+	})
+	r.register(v)
+	return v
+}
+
+// NewHistogramVec registers a histogram on the default registry
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// WithLabelValues returns the histogram for the label values (in registration order)
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram { return v.with(values) }
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.header(w)
+	v.each(func(labels string, h *Histogram) {
+		// Read count first: a concurrent Observe may show up in a bucket but not yet in the count
+		count := h.count.Load()
+		var cumulative uint64
+		for i, upperBound := range h.upperBounds {
+			cumulative += h.buckets[i].Load()
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, withLabel(labels, "le", formatFloat(upperBound)), cumulative)
+		}
+		if cumulative > count {
+			count = cumulative
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, withLabel(labels, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.metricName, labels, formatFloat(math.Float64frombits(h.sum.Load())))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.metricName, labels, count)
+	})
+}
+
+// ==========================================
+// FORMATTING HELPERS
+// ==========================================
+
+// addFloat atomically adds delta to a float64 stored as bits
+func addFloat(bits *atomic.Uint64, delta float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+// formatLabels renders {name="value",...} ("" without labels)
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLabel appends a label to rendered labels (used for the histogram "le" label)
+func withLabel(labels, name, value string) string {
+	pair := name + `="` + value + `"`
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(value string) string { return labelValueEscaper.Replace(value) }
+
+func escapeHelp(help string) string { return helpEscaper.Replace(help) }
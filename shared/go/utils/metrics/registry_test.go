@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// render returns the text exposition of the registry
+func render(r *Registry) string {
+	var b strings.Builder
+	w := bufio.NewWriter(&b)
+	r.WriteText(w)
+	w.Flush()
+	return b.String()
+}
+
+func TestWriteTextCountersAndGauges(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("http_requests_total", "Requests by route.\nSecond line \\ backslash", "route", "status")
+	inFlight := r.NewGaugeVec("http_in_flight", "In-flight requests")
+
+	requests.WithLabelValues("/api/b", "200").Add(2)
+	requests.WithLabelValues("/api/a", "500").Inc()
+	requests.WithLabelValues("/api/a", "500").Add(-5) // Ignored, counters only go up
+	inFlight.WithLabelValues().Inc()
+	inFlight.WithLabelValues().Inc()
+	inFlight.WithLabelValues().Dec()
+
+	want := `# HELP http_in_flight In-flight requests
+# TYPE http_in_flight gauge
+http_in_flight 1
+# HELP http_requests_total Requests by route.\nSecond line \\ backslash
+# TYPE http_requests_total counter
+http_requests_total{route="/api/a",status="500"} 1
+http_requests_total{route="/api/b",status="200"} 2
+`
+	if got := render(r); got != want {
+		t.Fatalf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTextEscapesLabelValues(t *testing.T) {
+	r := NewRegistry()
+	errors := r.NewCounterVec("errors_total", "Errors", "message")
+	errors.WithLabelValues("say \"hi\"\nC:\\path").Inc()
+
+	want := `errors_total{message="say \"hi\"\nC:\\path"} 1`
+	if got := render(r); !strings.Contains(got, want+"\n") {
+		t.Fatalf("exposition:\n%s\nwant line %s", got, want)
+	}
+}
+
+func TestWriteTextHistogram(t *testing.T) {
+	r := NewRegistry()
+	latency := r.NewHistogramVec("latency_seconds", "Latency", []float64{1, 0.1, 0.5}, "route") // Sorted on registration
+
+	h := latency.WithLabelValues("/api")
+	for _, value := range []float64{0.05, 0.1, 0.3, 0.7, 2} { // 0.1 is on a bound: le is inclusive
+		h.Observe(value)
+	}
+
+	want := `# HELP latency_seconds Latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/api",le="0.1"} 2
+latency_seconds_bucket{route="/api",le="0.5"} 3
+latency_seconds_bucket{route="/api",le="1"} 4
+latency_seconds_bucket{route="/api",le="+Inf"} 5
+latency_seconds_sum{route="/api"} 3.15
+latency_seconds_count{route="/api"} 5
+`
+	if got := render(r); got != want {
+		t.Fatalf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramWithoutLabels(t *testing.T) {
+	r := NewRegistry()
+	r.NewHistogramVec("size_bytes", "Size", []float64{10}).WithLabelValues().Observe(5)
+
+	for _, line := range []string{`size_bytes_bucket{le="10"} 1`, `size_bytes_bucket{le="+Inf"} 1`, `size_bytes_sum 5`, `size_bytes_count 1`} {
+		if got := render(r); !strings.Contains(got, line+"\n") {
+			t.Fatalf("exposition:\n%s\nwant line %s", got, line)
+		}
+	}
+}
+
+func TestRegistryMisuse(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounterVec("dup_total", "Dup", "a")
+
+	for name, fn := range map[string]func(){
+		"duplicate name":     func() { r.NewCounterVec("dup_total", "Dup") },
+		"wrong label values": func() { counter.WithLabelValues("a", "b") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%s did not panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestOnScrapeRunsBeforeWrite(t *testing.T) {
+	r := NewRegistry()
+	gauge := r.NewGaugeVec("pool_open", "Open connections")
+	open := 0
+	r.OnScrape(func() {
+		open++
+		gauge.WithLabelValues().Set(float64(open))
+	})
+
+	render(r)
+	if got := render(r); !strings.Contains(got, "pool_open 2\n") {
+		t.Fatalf("exposition:\n%s\nwant the value set by the second scrape", got)
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("up_total", "Up").WithLabelValues().Inc()
+
+	tests := []struct {
+		token, authorization string
+		status               int
+	}{
+		{"", "", http.StatusOK},
+		{"secret", "Bearer secret", http.StatusOK},
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret", "secret", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		rec := httptest.NewRecorder()
+		r.Handler(tt.token)(rec, req)
+
+		if rec.Code != tt.status {
+			t.Fatalf("token %q, Authorization %q: status = %d, want %d", tt.token, tt.authorization, rec.Code, tt.status)
+		}
+		if tt.status == http.StatusOK && (rec.Header().Get("Content-Type") != contentType || !strings.Contains(rec.Body.String(), "up_total 1\n")) {
+			t.Fatalf("response %q (%s), want the exposition", rec.Body.String(), rec.Header().Get("Content-Type"))
+		}
+	}
+}
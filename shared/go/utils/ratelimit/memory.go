@@ -0,0 +1,266 @@
+// shared/go/utils/ratelimit/memory.go
+
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"hash/maphash"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/app/shared/go/utils/metrics"
+)
+
+// ==========================================
+// IN-MEMORY STORE
+// ==========================================
+// Token buckets in a process-local map, GCRA like the Redis store (see redis.go)
+// Limits apply per gateway replica
+//
+// The map is split into shards with their own lock, so parallel requests for different keys
+// rarely wait for each other. Every shard keeps its keys in LRU order:
+// - a background janitor removes buckets idle for IdleTimeout (and refilled, so nothing is forgotten)
+// - at MaxKeys the least recently used bucket of the shard is dropped (a scan from many addresses cannot grow memory)
+
+// Defaults
+const (
+	DefaultMemoryShards          = 64
+	DefaultMemoryMaxKeys         = 100_000
+	DefaultMemoryIdleTimeout     = 10 * time.Minute
+	DefaultMemoryCleanupInterval = time.Minute
+)
+
+var (
+	trackedKeys = metrics.NewGaugeVec(
+		"rate_limit_tracked_keys", "Rate limit buckets held in memory.")
+	evictionsTotal = metrics.NewCounterVec(
+		"rate_limit_evictions_total", "In-memory rate limit buckets removed, by reason (idle, capacity).", "reason")
+)
+
+// MemoryOptions configures a MemoryStore (zero values use the defaults)
+type MemoryOptions struct {
+	Shards          int           // Number of independently locked shards
+	MaxKeys         int           // Hard cap on buckets, split evenly across shards
+	IdleTimeout     time.Duration // Buckets unused this long are removed
+	CleanupInterval time.Duration // How often the janitor runs
+}
+
+// MemoryStore keeps token buckets in memory
+// The janitor stops on Close or when the store is garbage collected (e.g. after a config reload)
+type MemoryStore struct {
+	*memoryShards
+}
+
+// memoryShards is the state shared with the janitor goroutine
+// (kept separate so the janitor does not keep the MemoryStore reachable)
+type memoryShards struct {
+	seed        maphash.Seed
+	shards      []memoryShard
+	idleTimeout time.Duration
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// memoryShard is one locked part of the map, list front = most recently used
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List
+	maxKeys int
+}
+
+// memoryEntry is a bucket with its last use
+type memoryEntry struct {
+	key      string
+	tat      time.Time // Theoretical arrival time, the bucket is full from then on
+	lastSeen time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store and starts its janitor
+func NewMemoryStore(opts MemoryOptions) *MemoryStore {
+	if opts.Shards <= 0 {
+		opts.Shards = DefaultMemoryShards
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = DefaultMemoryMaxKeys
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultMemoryIdleTimeout
+	}
+	if opts.CleanupInterval <= 0 {
+		opts.CleanupInterval = DefaultMemoryCleanupInterval
+	}
+
+	shards := &memoryShards{
+		seed:        maphash.MakeSeed(),
+		shards:      make([]memoryShard, opts.Shards),
+		idleTimeout: opts.IdleTimeout,
+		stop:        make(chan struct{}),
+	}
+	perShard := max(opts.MaxKeys/opts.Shards, 1)
+	for i := range shards.shards {
+		shards.shards[i].entries = make(map[string]*list.Element)
+		shards.shards[i].maxKeys = perShard
+	}
+
+	go shards.janitor(opts.CleanupInterval)
+
+	s := &MemoryStore{shards}
+	runtime.AddCleanup(s, func(shards *memoryShards) { shards.close() }, shards)
+	return s
+}
+
+// Allow takes a token from the bucket of key
+func (s *MemoryStore) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	now := time.Now()
+	interval := emissionInterval(limit)
+
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry := shard.entry(key, now)
+	tat := entry.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(interval)
+	allowAt := newTAT.Add(-time.Duration(limit.Burst) * interval)
+	if now.Before(allowAt) {
+		return Decision{
+			Limit:      limit.Burst,
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}, nil
+	}
+
+	entry.tat = newTAT
+	return Decision{
+		Allowed:    true,
+		Limit:      limit.Burst,
+		Remaining:  int(now.Sub(allowAt) / interval),
+		ResetAfter: newTAT.Sub(now),
+	}, nil
+}
+
+// Refund returns a token taken by Allow
+func (s *MemoryStore) Refund(ctx context.Context, key string, limit Limit) error {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	// A tat in the past is a full bucket, Allow starts from now
+	if elem, exists := shard.entries[key]; exists {
+		entry := elem.Value.(*memoryEntry)
+		entry.tat = entry.tat.Add(-emissionInterval(limit))
+	}
+	return nil
+}
+
+// Len returns the number of buckets held
+func (s *MemoryStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		n += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	return n
+}
+
+// Close stops the janitor and drops all buckets
+func (s *MemoryStore) Close() error {
+	s.close()
+	return nil
+}
+
+// shard returns the shard holding key, only this shard is locked for the key
+func (s *MemoryStore) shard(key string) *memoryShard {
+	return &s.shards[maphash.String(s.seed, key)%uint64(len(s.shards))]
+}
+
+// entry returns the bucket of key, creating it on first use, the caller holds the shard lock
+func (shard *memoryShard) entry(key string, now time.Time) *memoryEntry {
+	if elem, exists := shard.entries[key]; exists {
+		entry := elem.Value.(*memoryEntry)
+		entry.lastSeen = now
+		shard.lru.MoveToFront(elem)
+		return entry
+	}
+
+	// Full - drop the least recently used bucket of this shard
+	if len(shard.entries) >= shard.maxKeys {
+		shard.remove(shard.lru.Back())
+		evictionsTotal.WithLabelValues("capacity").Inc()
+	}
+
+	entry := &memoryEntry{key: key, tat: now, lastSeen: now}
+	shard.entries[key] = shard.lru.PushFront(entry)
+	trackedKeys.WithLabelValues().Inc()
+	return entry
+}
+
+// janitor removes idle buckets every interval until the store is closed
+func (s *memoryShards) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.evictIdle(now)
+		}
+	}
+}
+
+// evictIdle removes buckets that were idle for idleTimeout and are full again
+// A bucket that is still refilling is kept, removing it would hand out a fresh burst
+func (s *memoryShards) evictIdle(now time.Time) {
+	cutoff := now.Add(-s.idleTimeout)
+
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		// Walk from the least recently used end, everything after the first recent entry is recent too
+		for elem := shard.lru.Back(); elem != nil; {
+			entry := elem.Value.(*memoryEntry)
+			if entry.lastSeen.After(cutoff) {
+				break
+			}
+			prev := elem.Prev()
+			if !entry.tat.After(now) {
+				shard.remove(elem)
+				evictionsTotal.WithLabelValues("idle").Inc()
+			}
+			elem = prev
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// close stops the janitor and drops all buckets (idempotent)
+func (s *memoryShards) close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		for i := range s.shards {
+			shard := &s.shards[i]
+			shard.mu.Lock()
+			trackedKeys.WithLabelValues().Add(-float64(len(shard.entries)))
+			clear(shard.entries)
+			shard.lru.Init()
+			shard.mu.Unlock()
+		}
+	})
+}
+
+// remove deletes an entry, the caller holds the shard lock
+func (shard *memoryShard) remove(elem *list.Element) {
+	entry := shard.lru.Remove(elem).(*memoryEntry)
+	delete(shard.entries, entry.key)
+	trackedKeys.WithLabelValues().Dec()
+}
@@ -0,0 +1,210 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestMemoryStoreAllow(t *testing.T) {
+	store := NewMemoryStore(MemoryOptions{})
+	defer store.Close()
+	limit := Limit{Rate: 1, Burst: 2}
+	ctx := context.Background()
+
+	for want := 1; want >= 0; want-- {
+		decision, _ := store.Allow(ctx, "client", limit)
+		if !decision.Allowed || decision.Remaining != want || decision.Limit != 2 {
+			t.Fatalf("decision = %+v, want allowed with %d remaining", decision, want)
+		}
+	}
+	decision, _ := store.Allow(ctx, "client", limit)
+	if decision.Allowed || decision.RetryAfter <= 0 || decision.RetryAfter > time.Second {
+		t.Fatalf("decision = %+v, want denied for up to one second", decision)
+	}
+}
+
+func TestMemoryStoreRefund(t *testing.T) {
+	store := NewMemoryStore(MemoryOptions{})
+	defer store.Close()
+	limit := Limit{Rate: 0.001, Burst: 2} // No refill during the test
+	ctx := context.Background()
+
+	store.Allow(ctx, "client", limit)
+	store.Allow(ctx, "client", limit)
+	if decision, _ := store.Allow(ctx, "client", limit); decision.Allowed {
+		t.Fatalf("decision = %+v, want denied", decision)
+	}
+
+	store.Refund(ctx, "client", limit)
+	if decision, _ := store.Allow(ctx, "client", limit); !decision.Allowed || decision.Remaining != 0 {
+		t.Fatalf("decision after refund = %+v, want allowed with 0 remaining", decision)
+	}
+
+	// A refund cannot fill the bucket beyond its burst
+	store.Refund(ctx, "client", limit)
+	store.Refund(ctx, "client", limit)
+	store.Refund(ctx, "client", limit)
+	if decision, _ := store.Allow(ctx, "client", limit); decision.Remaining != 1 {
+		t.Fatalf("decision after refunds = %+v, want 1 remaining", decision)
+	}
+
+	// Unknown keys are ignored
+	if err := store.Refund(ctx, "unknown", limit); err != nil || store.Len() != 1 {
+		t.Fatalf("Refund of an unknown key = %v with %d buckets, want nil with 1", err, store.Len())
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewMemoryStore(MemoryOptions{Shards: 1, MaxKeys: 3})
+	defer store.Close()
+	limit := Limit{Rate: 1, Burst: 1}
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b", "c", "a", "d"} {
+		store.Allow(ctx, key, limit)
+	}
+
+	if n := store.Len(); n != 3 {
+		t.Fatalf("Len() = %d, want the cap of 3", n)
+	}
+	shard := &store.shards[0]
+	if _, ok := shard.entries["b"]; ok {
+		t.Fatal("least recently used key b kept")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := shard.entries[key]; !ok {
+			t.Fatalf("key %s evicted", key)
+		}
+	}
+}
+
+func TestMemoryStoreCapSplitAcrossShards(t *testing.T) {
+	store := NewMemoryStore(MemoryOptions{Shards: 4, MaxKeys: 100})
+	defer store.Close()
+
+	for i := 0; i < 1000; i++ {
+		store.Allow(context.Background(), fmt.Sprintf("10.0.%d.%d", i/256, i%256), Limit{Rate: 1, Burst: 1})
+	}
+	if n := store.Len(); n > 100 {
+		t.Fatalf("Len() = %d, want at most MaxKeys", n)
+	}
+}
+
+func TestMemoryStoreEvictIdle(t *testing.T) {
+	store := NewMemoryStore(MemoryOptions{Shards: 1, IdleTimeout: time.Minute})
+	defer store.Close()
+	ctx := context.Background()
+
+	store.Allow(ctx, "refilled", Limit{Rate: 100, Burst: 1})
+	store.Allow(ctx, "refilling", Limit{Rate: 0.001, Burst: 1}) // Needs 1000s for a token
+
+	// Not idle yet
+	store.evictIdle(time.Now().Add(30 * time.Second))
+	if n := store.Len(); n != 2 {
+		t.Fatalf("Len() = %d after 30s, want 2", n)
+	}
+
+	// Idle, but a bucket that is still refilling is kept
+	store.evictIdle(time.Now().Add(2 * time.Minute))
+	shard := &store.shards[0]
+	if _, ok := shard.entries["refilled"]; ok {
+		t.Fatal("idle refilled bucket kept")
+	}
+	if _, ok := shard.entries["refilling"]; !ok {
+		t.Fatal("refilling bucket evicted, it would hand out a fresh burst")
+	}
+}
+
+func TestMemoryStoreJanitor(t *testing.T) {
+	store := NewMemoryStore(MemoryOptions{IdleTimeout: 20 * time.Millisecond, CleanupInterval: 10 * time.Millisecond})
+	defer store.Close()
+
+	for i := 0; i < 100; i++ {
+		store.Allow(context.Background(), fmt.Sprintf("client-%d", i), Limit{Rate: 1000, Burst: 1})
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for store.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Len() = %d, janitor did not remove idle buckets", store.Len())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// ==========================================
+// BENCHMARKS
+// ==========================================
+// Parallel Allow calls over many keys, compared with a single map behind one mutex
+// Lock contention only shows with several CPUs (-cpu), on one CPU the LRU bookkeeping makes the MemoryStore slower
+// go test -run ^$ -bench Parallel -cpu 1,4,16 ./utils/ratelimit
+
+// benchmarkKeys are client IPs spread over all shards
+var benchmarkKeys = func() []string {
+	keys := make([]string, 4096)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("route:default:ip:10.0.%d.%d", i/256, i%256)
+	}
+	return keys
+}()
+
+// singleMutexStore is the unsharded map the MemoryStore replaced (without idle eviction and cap)
+type singleMutexStore struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func (s *singleMutexStore) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	s.mu.Lock()
+	limiter, ok := s.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+		s.limiters[key] = limiter
+	}
+	s.mu.Unlock()
+
+	now := time.Now()
+	allowed := limiter.AllowN(now, 1)
+	tokens := limiter.TokensAt(now)
+	return Decision{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  max(int(tokens), 0),
+		ResetAfter: durationFromSeconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}, nil
+}
+
+func BenchmarkMemoryStoreParallel(b *testing.B) {
+	store := NewMemoryStore(MemoryOptions{})
+	defer store.Close()
+	benchmarkParallel(b, store)
+}
+
+func BenchmarkSingleMutexStoreParallel(b *testing.B) {
+	benchmarkParallel(b, &singleMutexStore{limiters: make(map[string]*rate.Limiter)})
+}
+
+func benchmarkParallel(b *testing.B, store interface {
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+}) {
+	limit := Limit{Rate: 1e6, Burst: 1e6}
+	ctx := context.Background()
+	var worker atomic.Int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// Every goroutine walks the keys from its own offset
+		i := int(worker.Add(1)) * 997
+		for pb.Next() {
+			store.Allow(ctx, benchmarkKeys[i%len(benchmarkKeys)], limit)
+			i++
+		}
+	})
+}
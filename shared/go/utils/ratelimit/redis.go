@@ -0,0 +1,198 @@
+// shared/go/utils/ratelimit/redis.go
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+	"github.com/app/shared/go/utils/redis"
+)
+
+// ==========================================
+// REDIS STORE
+// ==========================================
+// Token buckets shared by all replicas, implemented as GCRA (generic cell rate algorithm):
+// a bucket is a single key holding the "theoretical arrival time" (TAT) in microseconds.
+// The Lua script reads and updates it atomically using the Redis clock, so replica clocks do not matter
+
+// gcraScript - KEYS[1] bucket, ARGV[1] emission interval (µs per token), ARGV[2] burst
+// Returns {allowed, remaining, retry_after_us, reset_after_us}
+const gcraScript = `
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local tat = tonumber(redis.call('GET', KEYS[1])) or now
+if tat < now then
+  tat = now
+end
+local new_tat = tat + interval
+local allow_at = new_tat - burst * interval
+if now < allow_at then
+  return {0, 0, allow_at - now, tat - now}
+end
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - allow_at) / interval), 0, new_tat - now}
+`
+
+var gcra = redis.NewScript(gcraScript)
+
+// refundScript - KEYS[1] bucket, ARGV[1] emission interval (µs per token)
+// Moves the TAT back by one token, a TAT that is no longer ahead of now is a full bucket and removed
+const refundScript = `
+local interval = tonumber(ARGV[1])
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if not tat then
+  return 0
+end
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local new_tat = tat - interval
+if new_tat <= now then
+  redis.call('DEL', KEYS[1])
+  return 1
+end
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+return 1
+`
+
+var refund = redis.NewScript(refundScript)
+
+// unavailableBackoff is how long the store is skipped after a failed call,
+// so an outage does not add the Redis timeout to every request
+const unavailableBackoff = time.Second
+
+// RedisStore keeps token buckets in Redis
+type RedisStore struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+
+	mu               sync.Mutex
+	unavailableUntil time.Time
+	unavailable      bool
+}
+
+// NewRedisStore creates a store using client, keys are prefixed with prefix
+// timeout limits every call (0 = client's IO timeout)
+func NewRedisStore(client *redis.Client, prefix string, timeout time.Duration) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix, timeout: timeout}
+}
+
+// Allow takes a token from the bucket of key
+// Returns ErrStoreUnavailable (wrapped) if Redis cannot be reached, ctx.Err() if ctx ended
+func (s *RedisStore) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	if s.skipping() {
+		return Decision{}, ErrStoreUnavailable
+	}
+
+	caller := ctx
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	reply, err := gcra.Run(ctx, s.client, []string{s.prefix + key}, intervalMicros(limit), limit.Burst)
+	if err != nil {
+		if caller.Err() != nil {
+			// The client went away (e.g. while waiting for a connection), not a Redis failure
+			return Decision{}, caller.Err()
+		}
+		s.failed(err)
+		return Decision{}, fmt.Errorf("%w: %v", ErrStoreUnavailable, err)
+	}
+	s.recovered()
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 4 {
+		return Decision{}, fmt.Errorf("rate limit script returned unexpected reply %v", reply)
+	}
+	var n [4]int64
+	for i, value := range values {
+		if n[i], ok = value.(int64); !ok {
+			return Decision{}, fmt.Errorf("rate limit script returned unexpected reply %v", reply)
+		}
+	}
+
+	return Decision{
+		Allowed:    n[0] == 1,
+		Limit:      limit.Burst,
+		Remaining:  int(n[1]),
+		RetryAfter: time.Duration(n[2]) * time.Microsecond,
+		ResetAfter: time.Duration(n[3]) * time.Microsecond,
+	}, nil
+}
+
+// Refund returns a token taken by Allow
+// Returns ErrStoreUnavailable (wrapped) if Redis cannot be reached, ctx.Err() if ctx ended
+func (s *RedisStore) Refund(ctx context.Context, key string, limit Limit) error {
+	if s.skipping() {
+		return ErrStoreUnavailable
+	}
+
+	caller := ctx
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	if _, err := refund.Run(ctx, s.client, []string{s.prefix + key}, intervalMicros(limit)); err != nil {
+		if caller.Err() != nil {
+			return caller.Err()
+		}
+		s.failed(err)
+		return fmt.Errorf("%w: %v", ErrStoreUnavailable, err)
+	}
+	s.recovered()
+	return nil
+}
+
+// intervalMicros is the emission interval passed to the scripts
+func intervalMicros(limit Limit) int64 {
+	return int64(math.Max(1, math.Round(1e6/limit.Rate)))
+}
+
+// skipping reports whether the store is in its backoff after a failure
+func (s *RedisStore) skipping() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.unavailable && time.Now().Before(s.unavailableUntil)
+}
+
+// failed starts the backoff, logging the outage once
+func (s *RedisStore) failed(err error) {
+	s.mu.Lock()
+	wasUnavailable := s.unavailable
+	s.unavailable = true
+	s.unavailableUntil = time.Now().Add(unavailableBackoff)
+	s.mu.Unlock()
+
+	if !wasUnavailable {
+		logger.LogEvent(logger.EventRateLimitStoreUnavailable, map[string]interface{}{
+			"store": "redis",
+			"error": err.Error(),
+		})
+	}
+}
+
+// recovered ends an outage
+func (s *RedisStore) recovered() {
+	s.mu.Lock()
+	wasUnavailable := s.unavailable
+	s.unavailable = false
+	s.mu.Unlock()
+
+	if wasUnavailable {
+		logger.LogEvent(logger.EventRateLimitStoreRecovered, map[string]interface{}{
+			"store": "redis",
+		})
+	}
+}
@@ -0,0 +1,422 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/app/shared/go/utils/redis"
+)
+
+// ==========================================
+// STORE TESTS
+// ==========================================
+// The tests run against redisStandIn, an in-process server with Go ports of the scripts and a
+// settable clock. REDIS_TEST_ADDR (host:port) runs them against a real server and its Lua instead
+
+func TestRedisStoreAllowDeny(t *testing.T) {
+	client, _ := newTestRedis(t)
+	store := NewRedisStore(client, testKeyPrefix(), time.Second)
+	limit := Limit{Rate: 1, Burst: 3}
+	ctx := context.Background()
+
+	for want := 2; want >= 0; want-- {
+		decision, err := store.Allow(ctx, "client", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !decision.Allowed || decision.Remaining != want || decision.Limit != 3 {
+			t.Fatalf("decision = %+v, want allowed with %d remaining", decision, want)
+		}
+	}
+
+	decision, err := store.Allow(ctx, "client", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Allowed || decision.Remaining != 0 {
+		t.Fatalf("decision = %+v, want denied", decision)
+	}
+	if decision.RetryAfter <= 0 || decision.RetryAfter > time.Second {
+		t.Fatalf("RetryAfter = %v, want up to one emission interval", decision.RetryAfter)
+	}
+	if decision.ResetAfter <= 2*time.Second || decision.ResetAfter > 3*time.Second {
+		t.Fatalf("ResetAfter = %v, want the time to refill 3 tokens", decision.ResetAfter)
+	}
+
+	// Buckets are per key
+	if decision, _ := store.Allow(ctx, "other", limit); !decision.Allowed {
+		t.Fatalf("decision of another key = %+v, want allowed", decision)
+	}
+}
+
+func TestRedisStoreRefund(t *testing.T) {
+	client, _ := newTestRedis(t)
+	store := NewRedisStore(client, testKeyPrefix(), time.Second)
+	limit := Limit{Rate: 0.001, Burst: 2} // No refill during the test
+	ctx := context.Background()
+
+	store.Allow(ctx, "client", limit)
+	store.Allow(ctx, "client", limit)
+	if decision, _ := store.Allow(ctx, "client", limit); decision.Allowed {
+		t.Fatalf("decision = %+v, want denied", decision)
+	}
+
+	if err := store.Refund(ctx, "client", limit); err != nil {
+		t.Fatal(err)
+	}
+	if decision, _ := store.Allow(ctx, "client", limit); !decision.Allowed || decision.Remaining != 0 {
+		t.Fatalf("decision after refund = %+v, want allowed with 0 remaining", decision)
+	}
+
+	// A refund cannot fill the bucket beyond its burst
+	for i := 0; i < 3; i++ {
+		if err := store.Refund(ctx, "client", limit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if decision, _ := store.Allow(ctx, "client", limit); decision.Remaining != 1 {
+		t.Fatalf("decision after refunds = %+v, want 1 remaining", decision)
+	}
+
+	// Unknown keys are ignored
+	if err := store.Refund(ctx, "unknown", limit); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedisStoreUsesServerClock(t *testing.T) {
+	client, standIn := newTestRedis(t)
+	if standIn == nil {
+		t.Skip("needs the stand-in's clock")
+	}
+	store := NewRedisStore(client, testKeyPrefix(), time.Second)
+	limit := Limit{Rate: 20, Burst: 1} // One token every 50ms
+	ctx := context.Background()
+
+	// The server clock is far off the local one, only the server clock counts
+	standIn.setClock(time.Now().Add(-time.Hour))
+	if decision, _ := store.Allow(ctx, "client", limit); !decision.Allowed {
+		t.Fatalf("first request denied: %+v", decision)
+	}
+
+	// Local time passing does not refill the bucket
+	time.Sleep(100 * time.Millisecond)
+	if decision, _ := store.Allow(ctx, "client", limit); decision.Allowed {
+		t.Fatalf("request allowed although the server clock did not move: %+v", decision)
+	}
+
+	// Server time passing does
+	standIn.advance(50 * time.Millisecond)
+	if decision, _ := store.Allow(ctx, "client", limit); !decision.Allowed {
+		t.Fatalf("request denied after one emission interval: %+v", decision)
+	}
+}
+
+func TestRedisStoreUnavailable(t *testing.T) {
+	client := redis.NewClient(redis.Options{Addr: unusedAddr(t), DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	store := NewRedisStore(client, testKeyPrefix(), 100*time.Millisecond)
+
+	_, err := store.Allow(context.Background(), "client", Limit{Rate: 1, Burst: 1})
+	if !errors.Is(err, ErrStoreUnavailable) {
+		t.Fatalf("err = %v, want ErrStoreUnavailable", err)
+	}
+
+	// Within the backoff the store is skipped without a connection attempt
+	if !store.skipping() {
+		t.Fatal("store not skipped after a failure")
+	}
+	if _, err := store.Allow(context.Background(), "client", Limit{Rate: 1, Burst: 1}); !errors.Is(err, ErrStoreUnavailable) {
+		t.Fatalf("err = %v, want ErrStoreUnavailable", err)
+	}
+}
+
+func TestRedisStoreIgnoresCancelledCallers(t *testing.T) {
+	client, _ := newTestRedis(t)
+	store := NewRedisStore(client, testKeyPrefix(), time.Second)
+	limit := Limit{Rate: 1, Burst: 1}
+
+	// A client that disconnected does not put the store into its backoff for everyone else
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.Allow(ctx, "client", limit); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Allow() = %v, want context.Canceled", err)
+	}
+	if err := store.Refund(ctx, "client", limit); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Refund() = %v, want context.Canceled", err)
+	}
+	if store.skipping() {
+		t.Fatal("store skipped after a cancelled call")
+	}
+	if decision, err := store.Allow(context.Background(), "client", limit); err != nil || !decision.Allowed {
+		t.Fatalf("Allow() = %+v, %v after a cancelled call, want allowed", decision, err)
+	}
+}
+
+// newTestRedis returns a client for REDIS_TEST_ADDR, or for a new stand-in (returned as well)
+func newTestRedis(t *testing.T) (*redis.Client, *redisStandIn) {
+	t.Helper()
+	if addr := os.Getenv("REDIS_TEST_ADDR"); addr != "" {
+		client := redis.NewClient(redis.Options{Addr: addr})
+		t.Cleanup(func() { client.Close() })
+		return client, nil
+	}
+
+	standIn, err := newRedisStandIn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := redis.NewClient(redis.Options{Addr: standIn.addr()})
+	t.Cleanup(func() {
+		client.Close()
+		standIn.close()
+	})
+	return client, standIn
+}
+
+// testKeyPrefix keeps runs against a shared server apart
+func testKeyPrefix() string {
+	return fmt.Sprintf("ratelimit-test:%d:", time.Now().UnixNano())
+}
+
+// unusedAddr returns an address nothing listens on
+func unusedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// ==========================================
+// STAND-IN SERVER
+// ==========================================
+
+// redisStandIn speaks enough of the Redis protocol for RedisStore: TIME, GET, EVAL and EVALSHA of its scripts
+type redisStandIn struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu     sync.Mutex
+	now    time.Time
+	values map[string]string
+	loaded map[string]bool // SHAs of scripts sent with EVAL, EVALSHA works from then on
+	conns  map[net.Conn]bool
+}
+
+func newRedisStandIn() (*redisStandIn, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &redisStandIn{ln: ln, now: time.Now(), values: make(map[string]string), loaded: make(map[string]bool), conns: make(map[net.Conn]bool)}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *redisStandIn) addr() string { return s.ln.Addr().String() }
+
+func (s *redisStandIn) setClock(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+func (s *redisStandIn) advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = s.now.Add(d)
+}
+
+func (s *redisStandIn) close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for cn := range s.conns {
+		cn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *redisStandIn) serve() {
+	defer s.wg.Done()
+	for {
+		cn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[cn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(cn)
+	}
+}
+
+func (s *redisStandIn) handle(cn net.Conn) {
+	defer s.wg.Done()
+	defer cn.Close()
+
+	r := bufio.NewReader(cn)
+	w := bufio.NewWriter(cn)
+	for {
+		args, err := readStandInCommand(r)
+		if err != nil {
+			return
+		}
+		writeStandInReply(w, s.exec(args))
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// exec returns the reply: string, int64, []interface{}, nil or redis.Error
+func (s *redisStandIn) exec(args []string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch cmd := strings.ToUpper(args[0]); {
+	case cmd == "TIME":
+		return []interface{}{strconv.FormatInt(s.now.Unix(), 10), strconv.Itoa(s.now.Nanosecond() / 1000)}
+	case cmd == "GET" && len(args) == 2:
+		if value, ok := s.values[args[1]]; ok {
+			return value
+		}
+		return nil
+	case cmd == "EVALSHA" && len(args) >= 4:
+		if !s.loaded[args[1]] {
+			return redis.Error("NOSCRIPT No matching script. Please use EVAL.")
+		}
+		return s.eval(args[1], args[3:])
+	case cmd == "EVAL" && len(args) >= 4:
+		sha := redis.NewScript(args[1]).SHA()
+		s.loaded[sha] = true
+		return s.eval(sha, args[3:])
+	}
+	return redis.Error("ERR unsupported command " + args[0])
+}
+
+// eval runs the Go port of a script by SHA, args are the key followed by ARGV
+func (s *redisStandIn) eval(sha string, args []string) interface{} {
+	switch {
+	case sha == gcra.SHA() && len(args) == 3:
+		return s.gcra(args[0], args[1], args[2])
+	case sha == refund.SHA() && len(args) == 2:
+		return s.refund(args[0], args[1])
+	}
+	return redis.Error("ERR unknown script")
+}
+
+// gcra is the Go port of gcraScript (KEYS[1], ARGV[1] interval, ARGV[2] burst)
+func (s *redisStandIn) gcra(key, intervalArg, burstArg string) interface{} {
+	interval, err1 := strconv.ParseInt(intervalArg, 10, 64)
+	burst, err2 := strconv.ParseInt(burstArg, 10, 64)
+	if err1 != nil || err2 != nil {
+		return redis.Error("ERR invalid script arguments")
+	}
+
+	now := s.now.UnixMicro()
+	tat, err := strconv.ParseInt(s.values[key], 10, 64)
+	if err != nil || tat < now {
+		tat = now
+	}
+	newTAT := tat + interval
+	allowAt := newTAT - burst*interval
+	if now < allowAt {
+		return []interface{}{int64(0), int64(0), allowAt - now, tat - now}
+	}
+	s.values[key] = strconv.FormatInt(newTAT, 10)
+	return []interface{}{int64(1), (now - allowAt) / interval, int64(0), newTAT - now}
+}
+
+// refund is the Go port of refundScript (KEYS[1], ARGV[1] interval)
+func (s *redisStandIn) refund(key, intervalArg string) interface{} {
+	interval, err := strconv.ParseInt(intervalArg, 10, 64)
+	if err != nil {
+		return redis.Error("ERR invalid script arguments")
+	}
+
+	tat, err := strconv.ParseInt(s.values[key], 10, 64)
+	if err != nil {
+		return int64(0)
+	}
+	newTAT := tat - interval
+	if newTAT <= s.now.UnixMicro() {
+		delete(s.values, key)
+		return int64(1)
+	}
+	s.values[key] = strconv.FormatInt(newTAT, 10)
+	return int64(1)
+}
+
+// readStandInCommand reads a command sent as RESP array of bulk strings
+func readStandInCommand(r *bufio.Reader) ([]string, error) {
+	n, err := readStandInLength(r, '*')
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		size, err := readStandInLength(r, '$')
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return args, nil
+}
+
+// readStandInLength reads a "<prefix><n>\r\n" line
+func readStandInLength(r *bufio.Reader, prefix byte) (int, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) < 2 || line[0] != prefix {
+		return 0, fmt.Errorf("unexpected line %q", line)
+	}
+	return strconv.Atoi(line[1:])
+}
+
+// writeStandInReply encodes a reply of exec
+func writeStandInReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case redis.Error:
+		w.WriteString("-" + string(v) + "\r\n")
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeStandInReply(w, item)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// shared/go/utils/ratelimit/store.go
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ==========================================
+// LIMITER STORE
+// ==========================================
+// A Store keeps token buckets by key. The in-memory store limits per process,
+// the Redis store shares the buckets between all gateway replicas
+
+// ErrStoreUnavailable is returned while a remote store cannot be reached
+var ErrStoreUnavailable = errors.New("rate limit store unavailable")
+
+// Limit is a token bucket: Rate tokens per second refill up to Burst
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Decision is the outcome of a single Allow call
+type Decision struct {
+	Allowed    bool
+	Limit      int           // Bucket size (burst)
+	Remaining  int           // Tokens left after this request
+	RetryAfter time.Duration // Time until the next request is allowed (0 if allowed)
+	ResetAfter time.Duration // Time until the bucket is full again
+}
+
+// Store takes one token for key from a bucket with the given limit
+// Buckets are created on first use, a key should always be used with the same limit
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+	// Refund returns the token of an allowed Allow, e.g. when another policy rejected the request
+	Refund(ctx context.Context, key string, limit Limit) error
+}
+
+// emissionInterval is the time one token takes to refill
+func emissionInterval(limit Limit) time.Duration {
+	return max(time.Duration(float64(time.Second)/limit.Rate), 1)
+}
+
+// durationFromSeconds converts fractional seconds to a duration
+func durationFromSeconds(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
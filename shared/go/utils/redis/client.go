@@ -0,0 +1,333 @@
+// shared/go/utils/redis/client.go
+
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// REDIS CLIENT (RESP2)
+// ==========================================
+// Minimal client for the Redis protocol with a connection pool
+// Works with Redis, Valkey, KeyDB and Dragonfly
+// Only what the gateway needs: commands as argument lists and Lua scripts (EVALSHA with EVAL fallback)
+
+// Defaults
+const (
+	DefaultDialTimeout = 1 * time.Second
+	DefaultIOTimeout   = 500 * time.Millisecond
+	DefaultPoolSize    = 16
+)
+
+// ErrNil is returned for nil replies (e.g. GET of a missing key)
+var ErrNil = errors.New("redis: nil reply")
+
+// ErrClosed is returned after Close
+var ErrClosed = errors.New("redis: client closed")
+
+// Error is an error reply of the server (the connection stays usable)
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Options configures a Client (zero values use the defaults)
+type Options struct {
+	Addr        string // host:port
+	Password    string // AUTH if set
+	DB          int    // SELECT if not 0
+	DialTimeout time.Duration
+	IOTimeout   time.Duration // Read/write deadline if ctx has none
+	PoolSize    int           // Maximum number of connections
+}
+
+// Client is a pooled Redis client, safe for concurrent use
+type Client struct {
+	opts Options
+	sem  chan struct{} // Limits open connections to PoolSize
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// conn is a single connection with buffered reader/writer
+type conn struct {
+	netConn net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer
+}
+
+// NewClient creates a client, connections are opened lazily
+func NewClient(opts Options) *Client {
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DefaultDialTimeout
+	}
+	if opts.IOTimeout <= 0 {
+		opts.IOTimeout = DefaultIOTimeout
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = DefaultPoolSize
+	}
+	return &Client{
+		opts: opts,
+		sem:  make(chan struct{}, opts.PoolSize),
+	}
+}
+
+// Do sends a command and returns the reply as string (simple/bulk string), int64 or []interface{}
+// Nil replies return ErrNil, error replies an Error
+func (c *Client) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	// Wait for a free slot in the pool
+	select {
+	case c.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-c.sem }()
+
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(ctx, c.opts.IOTimeout, args)
+	var serverErr Error
+	if err != nil && !errors.As(err, &serverErr) && !errors.Is(err, ErrNil) {
+		// Network or protocol error - the connection state is unknown
+		cn.netConn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// Ping checks the connection
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Close closes all idle connections, connections in use are closed when returned
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for _, cn := range c.idle {
+		cn.netConn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+// get returns an idle connection or dials a new one
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+
+	dialer := net.Dialer{Timeout: c.opts.DialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: dial %s: %w", c.opts.Addr, err)
+	}
+	cn := &conn{netConn: netConn, r: bufio.NewReader(netConn), w: bufio.NewWriter(netConn)}
+
+	if c.opts.Password != "" {
+		if _, err := cn.do(ctx, c.opts.IOTimeout, []interface{}{"AUTH", c.opts.Password}); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("redis: auth: %w", err)
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := cn.do(ctx, c.opts.IOTimeout, []interface{}{"SELECT", c.opts.DB}); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("redis: select %d: %w", c.opts.DB, err)
+		}
+	}
+	return cn, nil
+}
+
+// put returns a connection to the pool
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || len(c.idle) >= c.opts.PoolSize {
+		cn.netConn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+// do writes one command and reads its reply
+func (cn *conn) do(ctx context.Context, timeout time.Duration, args []interface{}) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(timeout)
+	}
+	cn.netConn.SetDeadline(deadline)
+
+	if err := writeCommand(cn.w, args); err != nil {
+		return nil, err
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(cn.r)
+}
+
+// ==========================================
+// SCRIPTS
+// ==========================================
+
+// Script is a Lua script run with EVALSHA, falling back to EVAL if the server does not know it yet
+type Script struct {
+	src string
+	sha string
+}
+
+// NewScript creates a script
+func NewScript(src string) *Script {
+	sum := sha1.Sum([]byte(src))
+	return &Script{src: src, sha: hex.EncodeToString(sum[:])}
+}
+
+// Source returns the Lua source
+func (s *Script) Source() string { return s.src }
+
+// SHA returns the SHA1 the server uses to identify the script
+func (s *Script) SHA() string { return s.sha }
+
+// Run executes the script with keys and args
+func (s *Script) Run(ctx context.Context, c *Client, keys []string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Do(ctx, s.evalArgs("EVALSHA", s.sha, keys, args)...)
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		return c.Do(ctx, s.evalArgs("EVAL", s.src, keys, args)...)
+	}
+	return reply, err
+}
+
+func (s *Script) evalArgs(cmd, script string, keys []string, args []interface{}) []interface{} {
+	all := make([]interface{}, 0, 3+len(keys)+len(args))
+	all = append(all, cmd, script, len(keys))
+	for _, key := range keys {
+		all = append(all, key)
+	}
+	return append(all, args...)
+}
+
+// ==========================================
+// RESP ENCODING
+// ==========================================
+
+// writeCommand encodes args as RESP array of bulk strings
+func writeCommand(w *bufio.Writer, args []interface{}) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var s string
+		switch v := arg.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		case int:
+			s = strconv.Itoa(v)
+		case int64:
+			s = strconv.FormatInt(v, 10)
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			s = fmt.Sprint(v)
+		}
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+	}
+	return nil
+}
+
+// readReply decodes one RESP reply
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, ErrNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line)
+		}
+		if n < 0 {
+			return nil, ErrNil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := readReply(r)
+			var serverErr Error
+			switch {
+			case errors.As(err, &serverErr):
+				item = serverErr // Keep reading, the remaining items belong to this reply
+			case errors.Is(err, ErrNil):
+				item = nil
+			case err != nil:
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+// readLine reads a line without the trailing CRLF
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
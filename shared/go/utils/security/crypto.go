@@ -9,6 +9,17 @@ import (
 	"io"
 )
 
+// GenerateToken creates a cryptographically secure random token of n bytes.
+// The token is Base64 URL encoded without padding, so it can be used in links.
+func GenerateToken(n int) (string, error) {
+	// Fill the token with cryptographically secure random bytes.
+	token := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
 // EncryptAES encrypts plaintext using AES-GCM (Galois/Counter Mode).
 // It generates a random Nonce and prepends it to the ciphertext before Base64 encoding.
 func EncryptAES(key []byte, plaintext string) (string, error) {
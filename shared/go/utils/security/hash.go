@@ -11,6 +11,8 @@ import (
 	"strings"
 
 	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
 )
 
 // Argon2Params defines the parameters for Argon2id hashing
@@ -61,6 +63,12 @@ func LowResourceArgon2Params() *Argon2Params {
 // HashPassword hashes a password using Argon2id with the given parameters
 // Returns the hash in PHC string format: $argon2id$v=19$m=65536,t=3,p=4$salt$hash
 func HashPassword(password string, params *Argon2Params) (string, error) {
+	return hashArgon2id([]byte(password), params, "")
+}
+
+// hashArgon2id hashes input (the password, or its HMAC with a pepper) into a PHC string
+// keyID is the ID of the pepper, written as keyid parameter ("" = no pepper)
+func hashArgon2id(input []byte, params *Argon2Params, keyID string) (string, error) {
 	// Generate random salt
 	salt := make([]byte, params.SaltLength)
 	if _, err := rand.Read(salt); err != nil {
@@ -69,7 +77,7 @@ func HashPassword(password string, params *Argon2Params) (string, error) {
 
 	// Generate hash
 	hash := argon2.IDKey(
-		[]byte(password),
+		input,
 		salt,
 		params.Iterations,
 		params.Memory,
@@ -81,12 +89,15 @@ func HashPassword(password string, params *Argon2Params) (string, error) {
 	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
 	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
 
+	paramString := fmt.Sprintf("m=%d,t=%d,p=%d", params.Memory, params.Iterations, params.Parallelism)
+	if keyID != "" {
+		paramString += ",keyid=" + keyID
+	}
+
 	encodedHash := fmt.Sprintf(
-		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		"$argon2id$v=%d$%s$%s$%s",
 		argon2.Version,
-		params.Memory,
-		params.Iterations,
-		params.Parallelism,
+		paramString,
 		b64Salt,
 		b64Hash,
 	)
@@ -100,8 +111,55 @@ func HashPasswordDefault(password string) (string, error) {
 }
 
 // VerifyPassword verifies a password against an Argon2id hash
+// Legacy bcrypt ($2a$, $2b$, $2y$) and scrypt PHC ($scrypt$ln=..,r=..,p=..$salt$hash) hashes are verified as well
 // Returns true if the password matches, false otherwise
 func VerifyPassword(password, encodedHash string) (bool, error) {
+	switch {
+	case isBcryptHash(encodedHash):
+		return verifyBcrypt(password, encodedHash)
+	case strings.HasPrefix(encodedHash, "$scrypt$"):
+		return verifyScrypt(password, encodedHash)
+	default:
+		return verifyArgon2id(password, encodedHash)
+	}
+}
+
+// VerifyAndUpgrade verifies a password like VerifyPassword and reports whether the hash should be replaced
+// with HashPassword(password, params): legacy formats always, Argon2id hashes if memory, iterations,
+// parallelism, salt or key length are below params
+// needsRehash is only true if the password matched
+func VerifyAndUpgrade(password, encodedHash string, params *Argon2Params) (match bool, needsRehash bool, err error) {
+	match, err = VerifyPassword(password, encodedHash)
+	if err != nil || !match {
+		return false, false, err
+	}
+	return true, NeedsRehash(encodedHash, params), nil
+}
+
+// NeedsRehash reports whether encodedHash is not Argon2id or was created with weaker parameters than params
+func NeedsRehash(encodedHash string, params *Argon2Params) bool {
+	stored, _, _, err := decodeHash(encodedHash)
+	if err != nil {
+		return true
+	}
+	return stored.Memory < params.Memory ||
+		stored.Iterations < params.Iterations ||
+		stored.Parallelism < params.Parallelism ||
+		stored.SaltLength < params.SaltLength ||
+		stored.KeyLength < params.KeyLength
+}
+
+// verifyArgon2id verifies a password against an Argon2id PHC string
+// Hashes created with a pepper (keyid parameter) can only be verified by a HashPool with that pepper
+func verifyArgon2id(password, encodedHash string) (bool, error) {
+	if hashKeyID(encodedHash) != "" {
+		return false, ErrPepperRequired
+	}
+	return compareArgon2id([]byte(password), encodedHash)
+}
+
+// compareArgon2id hashes input with the parameters and salt of encodedHash and compares the result
+func compareArgon2id(input []byte, encodedHash string) (bool, error) {
 	// Parse the encoded hash
 	params, salt, hash, err := decodeHash(encodedHash)
 	if err != nil {
@@ -110,7 +168,7 @@ func VerifyPassword(password, encodedHash string) (bool, error) {
 
 	// Generate hash from provided password
 	testHash := argon2.IDKey(
-		[]byte(password),
+		input,
 		salt,
 		params.Iterations,
 		params.Memory,
@@ -170,6 +228,89 @@ func decodeHash(encodedHash string) (*Argon2Params, []byte, []byte, error) {
 	return params, salt, hash, nil
 }
 
+// hashKeyID returns the keyid parameter of an Argon2id PHC string ("" if none)
+func hashKeyID(encodedHash string) string {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 {
+		return ""
+	}
+	for _, param := range strings.Split(parts[3], ",") {
+		if value, found := strings.CutPrefix(param, "keyid="); found {
+			return value
+		}
+	}
+	return ""
+}
+
+// ==========================================
+// LEGACY FORMATS
+// ==========================================
+// Only verified, so imported users can log in once and get an Argon2id hash (see VerifyAndUpgrade)
+
+// Bounds of the scrypt cost of stored hashes (memory is 128 * r * 2^ln bytes)
+const (
+	maxScryptLogN        = 20
+	maxScryptParallelism = 16
+	maxScryptMemory      = 1 << 30
+)
+
+// isBcryptHash reports whether encodedHash is a bcrypt hash
+func isBcryptHash(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$2a$") ||
+		strings.HasPrefix(encodedHash, "$2b$") ||
+		strings.HasPrefix(encodedHash, "$2y$")
+}
+
+// verifyBcrypt verifies a password against a bcrypt hash
+func verifyBcrypt(password, encodedHash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("invalid bcrypt hash: %w", err)
+	}
+	return true, nil
+}
+
+// verifyScrypt verifies a password against a scrypt PHC string: $scrypt$ln=15,r=8,p=1$salt$hash
+// Salt and hash are base64 without padding ('.' instead of '+' as written by passlib is accepted)
+func verifyScrypt(password, encodedHash string) (bool, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 5 {
+		return false, errors.New("invalid hash format")
+	}
+
+	var logN, r, p int
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &logN, &r, &p); err != nil {
+		return false, fmt.Errorf("invalid parameters: %w", err)
+	}
+	if logN < 1 || logN > maxScryptLogN || r < 1 || p < 1 || p > maxScryptParallelism ||
+		r > maxScryptMemory>>(7+logN) {
+		return false, errors.New("unsupported scrypt parameters")
+	}
+
+	salt, err := decodeLegacyBase64(parts[3])
+	if err != nil {
+		return false, fmt.Errorf("invalid salt: %w", err)
+	}
+	hash, err := decodeLegacyBase64(parts[4])
+	if err != nil || len(hash) == 0 {
+		return false, errors.New("invalid hash")
+	}
+
+	testHash, err := scrypt.Key([]byte(password), salt, 1<<logN, r, p, len(hash))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(hash, testHash) == 1, nil
+}
+
+// decodeLegacyBase64 decodes unpadded standard base64, also in passlib's variant with '.' for '+'
+func decodeLegacyBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(strings.TrimRight(s, "="), ".", "+"))
+}
+
 // HashSHA256 creates a SHA-256 hash of input
 // WARNING: DO NOT USE FOR PASSWORDS! Only for data integrity checks
 func HashSHA256(input string) string {
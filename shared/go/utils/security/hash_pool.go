@@ -0,0 +1,361 @@
+package security
+
+import (
+	"container/list"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/app/shared/go/utils/metrics"
+)
+
+// ==========================================
+// PASSWORD HASHING POOL
+// ==========================================
+// Argon2id allocates its full memory cost per hash (64 MB with DefaultArgon2Params), so a burst of
+// logins can exhaust the memory of a container. HashPool runs hashes only while their memory fits
+// into a budget, further requests wait in FIFO order until a slot is free, the context ends or MaxWait passes
+
+// Defaults of HashPoolOptions
+const (
+	DefaultHashMemoryBudget = 256 << 20 // 4 concurrent hashes with DefaultArgon2Params
+	DefaultHashMaxWait      = 5 * time.Second
+)
+
+// MinPepperLength is the minimum length of a pepper secret in bytes
+const MinPepperLength = 32
+
+// Errors of HashPool
+var (
+	ErrHashPoolBusy   = errors.New("security: password hashing pool busy")
+	ErrPepperRequired = errors.New("security: hash was created with a pepper")
+	ErrUnknownPepper  = errors.New("security: hash was created with a different pepper")
+)
+
+var (
+	hashQueueDepth = metrics.NewGaugeVec(
+		"password_hash_queue_depth", "Password hashes waiting for memory of the hashing pool.")
+	hashInFlight = metrics.NewGaugeVec(
+		"password_hash_in_flight", "Password hashes currently running.")
+	hashWaitSeconds = metrics.NewHistogramVec(
+		"password_hash_wait_seconds", "Time password hashes waited for the hashing pool.", nil)
+	hashRejectedTotal = metrics.NewCounterVec(
+		"password_hash_rejected_total", "Password hashes that gave up waiting for the hashing pool.", "reason")
+)
+
+// HashPoolOptions configures a HashPool
+type HashPoolOptions struct {
+	Params       *Argon2Params // Parameters of new hashes (nil = DefaultArgon2Params)
+	MemoryBudget uint64        // Bytes all running hashes may use together (0 = DefaultHashMemoryBudget), one hash always fits
+	MaxWait      time.Duration // Longest wait for memory (0 = DefaultHashMaxWait), an earlier context deadline wins
+	Pepper       *Pepper       // Secret mixed into new hashes (nil = none)
+	Previous     []*Pepper     // Replaced peppers, their hashes are still verified and rehashed with Pepper on login
+}
+
+// HashPool hashes and verifies passwords within a memory budget
+type HashPool struct {
+	params  *Argon2Params
+	maxWait time.Duration
+	pepper  *Pepper
+	peppers map[string]*Pepper // Current and previous peppers by ID
+	sem     *weightedSemaphore // Weights in KiB
+}
+
+// NewHashPool creates a hashing pool
+func NewHashPool(opts HashPoolOptions) *HashPool {
+	if opts.Params == nil {
+		opts.Params = DefaultArgon2Params()
+	}
+	if opts.MemoryBudget == 0 {
+		opts.MemoryBudget = DefaultHashMemoryBudget
+	}
+	if opts.MaxWait <= 0 {
+		opts.MaxWait = DefaultHashMaxWait
+	}
+
+	peppers := make(map[string]*Pepper, len(opts.Previous)+1)
+	for _, pepper := range append(opts.Previous, opts.Pepper) {
+		if pepper != nil {
+			peppers[pepper.id] = pepper
+		}
+	}
+
+	return &HashPool{
+		params:  opts.Params,
+		maxWait: opts.MaxWait,
+		pepper:  opts.Pepper,
+		peppers: peppers,
+		sem:     newWeightedSemaphore(int64(opts.MemoryBudget >> 10)),
+	}
+}
+
+// HashPoolOptionsFromEnv reads PASSWORD_HASH_MEMORY_MB (memory budget), PASSWORD_PEPPER (at least 32 bytes)
+// and PASSWORD_PEPPER_PREVIOUS (comma separated replaced peppers, kept until all their hashes were rehashed)
+// Unset variables keep the defaults
+func HashPoolOptionsFromEnv() (HashPoolOptions, error) {
+	var opts HashPoolOptions
+
+	if value := os.Getenv("PASSWORD_HASH_MEMORY_MB"); value != "" {
+		mb, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || mb == 0 {
+			return HashPoolOptions{}, fmt.Errorf("PASSWORD_HASH_MEMORY_MB must be a positive number, got %q", value)
+		}
+		opts.MemoryBudget = mb << 20
+	}
+
+	if secret := os.Getenv("PASSWORD_PEPPER"); secret != "" {
+		pepper, err := NewPepper([]byte(secret))
+		if err != nil {
+			return HashPoolOptions{}, fmt.Errorf("PASSWORD_PEPPER: %w", err)
+		}
+		opts.Pepper = pepper
+	}
+
+	if secrets := os.Getenv("PASSWORD_PEPPER_PREVIOUS"); secrets != "" {
+		for _, secret := range strings.Split(secrets, ",") {
+			pepper, err := NewPepper([]byte(strings.TrimSpace(secret)))
+			if err != nil {
+				return HashPoolOptions{}, fmt.Errorf("PASSWORD_PEPPER_PREVIOUS: %w", err)
+			}
+			opts.Previous = append(opts.Previous, pepper)
+		}
+	}
+
+	return opts, nil
+}
+
+// Params returns the parameters of new hashes
+func (p *HashPool) Params() *Argon2Params {
+	return p.params
+}
+
+// Hash hashes a password with the pool's parameters and pepper
+// Returns ErrHashPoolBusy if no memory was free within MaxWait, or the context error
+func (p *HashPool) Hash(ctx context.Context, password string) (string, error) {
+	release, err := p.acquire(ctx, p.params.Memory)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	if p.pepper != nil {
+		return hashArgon2id(p.pepper.apply(password), p.params, p.pepper.id)
+	}
+	return hashArgon2id([]byte(password), p.params, "")
+}
+
+// Verify verifies a password like VerifyPassword, hashes with a pepper (keyid) need the current or a previous pepper
+func (p *HashPool) Verify(ctx context.Context, password, encodedHash string) (bool, error) {
+	release, err := p.acquire(ctx, hashMemory(encodedHash))
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	keyID := hashKeyID(encodedHash)
+	if keyID == "" {
+		return VerifyPassword(password, encodedHash)
+	}
+	pepper, ok := p.peppers[keyID]
+	switch {
+	case ok:
+		return compareArgon2id(pepper.apply(password), encodedHash)
+	case len(p.peppers) == 0:
+		return false, ErrPepperRequired
+	default:
+		return false, ErrUnknownPepper
+	}
+}
+
+// VerifyAndUpgrade verifies a password like Verify and reports whether the hash should be replaced with Hash:
+// see VerifyAndUpgrade, additionally hashes without the pool's current pepper (unpeppered or previous pepper)
+func (p *HashPool) VerifyAndUpgrade(ctx context.Context, password, encodedHash string) (match bool, needsRehash bool, err error) {
+	match, err = p.Verify(ctx, password, encodedHash)
+	if err != nil || !match {
+		return false, false, err
+	}
+	needsRehash = NeedsRehash(encodedHash, p.params) || hashKeyID(encodedHash) != p.pepperID()
+	return true, needsRehash, nil
+}
+
+// pepperID returns the ID of the current pepper ("" without pepper)
+func (p *HashPool) pepperID() string {
+	if p.pepper == nil {
+		return ""
+	}
+	return p.pepper.id
+}
+
+// acquire waits until memoryKiB fits into the budget, the returned function frees it again
+func (p *HashPool) acquire(ctx context.Context, memoryKiB uint32) (func(), error) {
+	weight := min(max(int64(memoryKiB), 1), p.sem.size)
+
+	waitCtx, cancel := context.WithTimeout(ctx, p.maxWait)
+	defer cancel()
+
+	start := time.Now()
+	if err := p.sem.acquire(waitCtx, weight); err != nil {
+		if ctx.Err() != nil {
+			hashRejectedTotal.WithLabelValues("canceled").Inc()
+			return nil, ctx.Err()
+		}
+		hashRejectedTotal.WithLabelValues("timeout").Inc()
+		return nil, ErrHashPoolBusy
+	}
+	hashWaitSeconds.WithLabelValues().Observe(time.Since(start).Seconds())
+	hashInFlight.WithLabelValues().Inc()
+
+	return func() {
+		hashInFlight.WithLabelValues().Dec()
+		p.sem.release(weight)
+	}, nil
+}
+
+// hashMemory estimates the memory in KiB needed to verify encodedHash
+func hashMemory(encodedHash string) uint32 {
+	switch {
+	case isBcryptHash(encodedHash):
+		return 4
+	case strings.HasPrefix(encodedHash, "$scrypt$"):
+		var logN, r, p int
+		parts := strings.Split(encodedHash, "$")
+		if len(parts) == 5 {
+			fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &logN, &r, &p)
+		}
+		if logN < 1 || logN > maxScryptLogN || r < 1 || r > maxScryptMemory>>(7+logN) {
+			return 1 // Rejected by verifyScrypt
+		}
+		return uint32(128 * r << logN >> 10)
+	default:
+		params, _, _, err := decodeHash(encodedHash)
+		if err != nil {
+			return 1
+		}
+		return params.Memory
+	}
+}
+
+// ==========================================
+// PEPPER
+// ==========================================
+
+// Pepper is a secret mixed into passwords before hashing (HMAC-SHA256), kept outside the database
+// Hashes record the pepper ID (keyid parameter), so hashes created before a pepper was set or with a
+// replaced pepper (HashPoolOptions.Previous) are still verified
+type Pepper struct {
+	secret []byte
+	id     string
+}
+
+// NewPepper creates a pepper from a secret of at least MinPepperLength bytes
+func NewPepper(secret []byte) (*Pepper, error) {
+	if len(secret) < MinPepperLength {
+		return nil, fmt.Errorf("pepper must be at least %d bytes", MinPepperLength)
+	}
+	sum := sha256.Sum256(secret)
+	return &Pepper{
+		secret: secret,
+		id:     base64.RawStdEncoding.EncodeToString(sum[:6]),
+	}, nil
+}
+
+// ID returns the pepper ID written into hashes (derived from the secret)
+func (p *Pepper) ID() string {
+	return p.id
+}
+
+// apply returns the HMAC of the password, the input of Argon2id
+func (p *Pepper) apply(password string) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// ==========================================
+// WEIGHTED SEMAPHORE
+// ==========================================
+
+// weightedSemaphore hands out weights up to size in FIFO order
+type weightedSemaphore struct {
+	mu      sync.Mutex
+	size    int64
+	used    int64
+	waiters list.List // *semaphoreWaiter
+}
+
+type semaphoreWaiter struct {
+	weight int64
+	ready  chan struct{} // Closed when the weight was acquired
+}
+
+func newWeightedSemaphore(size int64) *weightedSemaphore {
+	return &weightedSemaphore{size: max(size, 1)}
+}
+
+// acquire waits for weight (at most size) until ctx ends
+func (s *weightedSemaphore) acquire(ctx context.Context, weight int64) error {
+	s.mu.Lock()
+	if s.used+weight <= s.size && s.waiters.Len() == 0 {
+		s.used += weight
+		s.mu.Unlock()
+		return nil
+	}
+
+	waiter := &semaphoreWaiter{weight: weight, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(waiter)
+	hashQueueDepth.WithLabelValues().Inc()
+	s.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-waiter.ready:
+			// Acquired while giving up, hand it back
+			s.used -= weight
+		default:
+			s.waiters.Remove(elem)
+			hashQueueDepth.WithLabelValues().Dec()
+		}
+		// A removed head may have blocked smaller weights behind it
+		s.notifyWaiters()
+		return ctx.Err()
+	}
+}
+
+// release frees weight and wakes the waiters that fit now
+func (s *weightedSemaphore) release(weight int64) {
+	s.mu.Lock()
+	s.used -= weight
+	s.notifyWaiters()
+	s.mu.Unlock()
+}
+
+// notifyWaiters hands out weight to the waiters in order while it fits (s.mu held)
+func (s *weightedSemaphore) notifyWaiters() {
+	for {
+		front := s.waiters.Front()
+		if front == nil {
+			return
+		}
+		waiter := front.Value.(*semaphoreWaiter)
+		if s.used+waiter.weight > s.size {
+			return
+		}
+		s.used += waiter.weight
+		s.waiters.Remove(front)
+		hashQueueDepth.WithLabelValues().Dec()
+		close(waiter.ready)
+	}
+}
@@ -0,0 +1,260 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPepper(t *testing.T, secret string) *Pepper {
+	t.Helper()
+	pepper, err := NewPepper([]byte(strings.Repeat(secret, MinPepperLength)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pepper
+}
+
+func TestHashPoolPepperRotation(t *testing.T) {
+	ctx := context.Background()
+	oldPepper, newPepper := testPepper(t, "a"), testPepper(t, "b")
+
+	oldHash, err := NewHashPool(HashPoolOptions{Params: testParams, Pepper: oldPepper}).Hash(ctx, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the old pepper the hash cannot be verified
+	rotatedWithoutPrevious := NewHashPool(HashPoolOptions{Params: testParams, Pepper: newPepper})
+	if _, _, err := rotatedWithoutPrevious.VerifyAndUpgrade(ctx, "correct horse", oldHash); !errors.Is(err, ErrUnknownPepper) {
+		t.Fatalf("err = %v, want ErrUnknownPepper", err)
+	}
+
+	// With it the hash is verified and flagged for a rehash with the new pepper
+	rotated := NewHashPool(HashPoolOptions{Params: testParams, Pepper: newPepper, Previous: []*Pepper{oldPepper}})
+	match, needsRehash, err := rotated.VerifyAndUpgrade(ctx, "correct horse", oldHash)
+	if err != nil || !match || !needsRehash {
+		t.Fatalf("VerifyAndUpgrade = %v, %v, %v, want a match that needs a rehash", match, needsRehash, err)
+	}
+	if match, _, _ := rotated.VerifyAndUpgrade(ctx, "wrong", oldHash); match {
+		t.Fatal("wrong password matched")
+	}
+
+	newHash, err := rotated.Hash(ctx, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashKeyID(newHash) != newPepper.ID() {
+		t.Fatalf("keyid of the new hash = %q, want %q", hashKeyID(newHash), newPepper.ID())
+	}
+	match, needsRehash, err = rotated.VerifyAndUpgrade(ctx, "correct horse", newHash)
+	if err != nil || !match || needsRehash {
+		t.Fatalf("VerifyAndUpgrade = %v, %v, %v, want a match without rehash", match, needsRehash, err)
+	}
+}
+
+func TestHashPoolPepperAdded(t *testing.T) {
+	ctx := context.Background()
+	plainHash, err := NewHashPool(HashPoolOptions{Params: testParams}).Hash(ctx, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peppered := NewHashPool(HashPoolOptions{Params: testParams, Pepper: testPepper(t, "a")})
+	match, needsRehash, err := peppered.VerifyAndUpgrade(ctx, "correct horse", plainHash)
+	if err != nil || !match || !needsRehash {
+		t.Fatalf("VerifyAndUpgrade = %v, %v, %v, want a match that needs a rehash", match, needsRehash, err)
+	}
+
+	pepperedHash, _ := peppered.Hash(ctx, "correct horse")
+	if _, _, err := NewHashPool(HashPoolOptions{Params: testParams}).VerifyAndUpgrade(ctx, "correct horse", pepperedHash); !errors.Is(err, ErrPepperRequired) {
+		t.Fatalf("err = %v, want ErrPepperRequired", err)
+	}
+}
+
+func TestHashPoolOptionsFromEnvPreviousPeppers(t *testing.T) {
+	t.Setenv("PASSWORD_PEPPER", strings.Repeat("c", MinPepperLength))
+	t.Setenv("PASSWORD_PEPPER_PREVIOUS", strings.Repeat("a", MinPepperLength)+", "+strings.Repeat("b", MinPepperLength))
+
+	opts, err := HashPoolOptionsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts.Previous) != 2 || opts.Previous[0].ID() != testPepper(t, "a").ID() || opts.Previous[1].ID() != testPepper(t, "b").ID() {
+		t.Fatalf("Previous = %v, want the peppers a and b", opts.Previous)
+	}
+
+	t.Setenv("PASSWORD_PEPPER_PREVIOUS", "too-short")
+	if _, err := HashPoolOptionsFromEnv(); err == nil {
+		t.Fatal("short previous pepper accepted")
+	}
+}
+
+func TestHashPoolBusy(t *testing.T) {
+	pool := NewHashPool(HashPoolOptions{Params: testParams, MemoryBudget: 64 << 10, MaxWait: 20 * time.Millisecond})
+	release, err := pool.acquire(context.Background(), testParams.Memory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pool.Hash(context.Background(), "correct horse"); !errors.Is(err, ErrHashPoolBusy) {
+		t.Fatalf("err = %v, want ErrHashPoolBusy after MaxWait", err)
+	}
+
+	// The caller's context ending is reported as such, not as a busy pool
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := pool.Hash(ctx, "correct horse"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := NewHashPool(HashPoolOptions{Params: testParams, MemoryBudget: 64 << 10}).Hash(ctx, "correct horse"); err != nil {
+		t.Fatal(err) // Free memory is acquired without waiting
+	}
+
+	if waiting := waiters(pool.sem); waiting != 0 {
+		t.Fatalf("%d waiters left after giving up, want 0", waiting)
+	}
+	release()
+	if _, err := pool.Hash(context.Background(), "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWeightedSemaphoreFIFO(t *testing.T) {
+	sem := newWeightedSemaphore(10)
+	if err := sem.acquire(context.Background(), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan int, 4)
+	for i, weight := range []int64{6, 2, 6, 2} {
+		go func() {
+			if err := sem.acquire(context.Background(), weight); err == nil {
+				acquired <- i
+			}
+		}()
+		waitForWaiters(t, sem, i+1) // Queue in order
+	}
+
+	// 6 and 2 fit, the second 6 does not and the 2 behind it waits although it would fit
+	sem.release(10)
+	expectAcquired(t, acquired, 0, 1)
+	if used, waiting := usage(sem); used != 8 || waiting != 2 {
+		t.Fatalf("used = %d with %d waiters, want 8 with 2", used, waiting)
+	}
+
+	// New requests queue behind the waiters instead of taking the free weight
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sem.acquire(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want the request to queue and give up", err)
+	}
+
+	sem.release(6)
+	expectAcquired(t, acquired, 2, 3)
+
+	sem.release(2)
+	sem.release(6)
+	sem.release(2)
+	if used, waiting := usage(sem); used != 0 || waiting != 0 {
+		t.Fatalf("used = %d with %d waiters, want 0 with 0", used, waiting)
+	}
+}
+
+func TestWeightedSemaphoreGiveUpHandsBack(t *testing.T) {
+	var gaveUp int
+	for range 20 {
+		sem := newWeightedSemaphore(10)
+		if err := sem.acquire(context.Background(), 10); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		first, second := make(chan error, 1), make(chan error, 1)
+		go func() { first <- sem.acquire(ctx, 10) }()
+		waitForWaiters(t, sem, 1)
+		go func() { second <- sem.acquire(context.Background(), 10) }()
+		waitForWaiters(t, sem, 2)
+
+		// The first waiter is handed the weight while its context ends
+		sem.mu.Lock()
+		cancel()
+		sem.used -= 10
+		sem.notifyWaiters()
+		sem.mu.Unlock()
+
+		// Either it keeps the weight or gives it back, the second waiter gets it in both cases
+		if err := <-first; err == nil {
+			sem.release(10)
+		} else if errors.Is(err, context.Canceled) {
+			gaveUp++
+		} else {
+			t.Fatalf("err = %v, want nil or context.Canceled", err)
+		}
+		select {
+		case err := <-second:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("second waiter did not get the weight")
+		}
+
+		sem.release(10)
+		if used, waiting := usage(sem); used != 0 || waiting != 0 {
+			t.Fatalf("used = %d with %d waiters, want 0 with 0", used, waiting)
+		}
+	}
+	if gaveUp == 0 {
+		t.Fatal("first waiter never gave up, the hand back was not exercised")
+	}
+}
+
+func usage(sem *weightedSemaphore) (used int64, waiting int) {
+	sem.mu.Lock()
+	defer sem.mu.Unlock()
+	return sem.used, sem.waiters.Len()
+}
+
+func waiters(sem *weightedSemaphore) int {
+	_, waiting := usage(sem)
+	return waiting
+}
+
+func waitForWaiters(t *testing.T, sem *weightedSemaphore, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for waiters(sem) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d waiters, want %d", waiters(sem), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func expectAcquired(t *testing.T, acquired <-chan int, want ...int) {
+	t.Helper()
+	got := make(map[int]bool)
+	for range want {
+		select {
+		case i := <-acquired:
+			got[i] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("acquired %v, want %v", got, want)
+		}
+	}
+	for _, i := range want {
+		if !got[i] {
+			t.Fatalf("acquired %v, want %v", got, want)
+		}
+	}
+	select {
+	case i := <-acquired:
+		t.Fatalf("waiter %d acquired out of order", i)
+	default:
+	}
+}
@@ -0,0 +1,179 @@
+package security
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// testParams keeps the tests fast, NeedsRehash compares against them
+var testParams = &Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+// rfc7914Vector is scrypt("password", "NaCl", N=1024, r=8, p=16) from RFC 7914 section 12, as PHC string
+func rfc7914Vector(t *testing.T) string {
+	t.Helper()
+	hash, err := hex.DecodeString("fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "$scrypt$ln=10,r=8,p=16$" + base64.RawStdEncoding.EncodeToString([]byte("NaCl")) + "$" + base64.RawStdEncoding.EncodeToString(hash)
+}
+
+func TestVerifyPasswordLegacyVectors(t *testing.T) {
+	scryptVector := rfc7914Vector(t)
+	long := "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789chars after 72 are ignored"
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		match    bool
+	}{
+		// crypt_blowfish / OpenBSD test vectors
+		{"bcrypt $2a$", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U", true},
+		{"bcrypt $2a$ wrong password", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U*", false},
+		{"bcrypt $2a$ second vector", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.VGOzA784oUp/Z0DY336zx7pLYAy0lwK", "U*U*", true},
+		{"bcrypt $2a$ over 72 bytes", "$2a$05$abcdefghijklmnopqrstuu5s2v8.iXieOjg/.AySBTTZIIVFJeBui", long, true},
+		{"bcrypt $2b$", "$2b$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U", true},
+		{"bcrypt $2b$ wrong password", "$2b$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U ", false},
+		{"bcrypt $2y$", "$2y$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U", true},
+		{"bcrypt $2y$ wrong password", "$2y$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "u*u", false},
+
+		// RFC 7914, in standard and passlib ('.' for '+') base64
+		{"scrypt", scryptVector, "password", true},
+		{"scrypt wrong password", scryptVector, "Password", false},
+		{"scrypt passlib alphabet", strings.ReplaceAll(scryptVector, "+", "."), "password", true},
+		{"scrypt padded base64", scryptVector + "=", "password", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := VerifyPassword(tt.password, tt.hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != tt.match {
+				t.Fatalf("VerifyPassword() = %v, want %v", match, tt.match)
+			}
+		})
+	}
+	if !strings.Contains(scryptVector, "+") {
+		t.Fatal("the scrypt vector does not exercise the passlib alphabet")
+	}
+}
+
+func TestVerifyPasswordInvalidHashes(t *testing.T) {
+	for name, hash := range map[string]string{
+		"empty":                  "",
+		"unknown format":         "$1$salt$hash",
+		"bcrypt truncated":       "$2a$05$CCCCCCCCCCCCCCCCCCCCC",
+		"bcrypt invalid cost":    "$2a$99$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW",
+		"scrypt missing part":    "$scrypt$ln=10,r=8,p=1$c2FsdA",
+		"scrypt bad parameters":  "$scrypt$n=10$c2FsdA$aGFzaA",
+		"scrypt ln too high":     "$scrypt$ln=21,r=8,p=1$c2FsdA$aGFzaA",
+		"scrypt memory too high": "$scrypt$ln=20,r=9,p=1$c2FsdA$aGFzaA",
+		"scrypt p too high":      "$scrypt$ln=10,r=8,p=17$c2FsdA$aGFzaA",
+		"scrypt empty hash":      "$scrypt$ln=10,r=8,p=1$c2FsdA$",
+		"argon2 wrong version":   "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHRzYWx0$aGFzaA",
+		"argon2i":                "$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0$aGFzaA",
+		"argon2 bad salt":        "$argon2id$v=19$m=64,t=1,p=1$!!!$aGFzaA",
+	} {
+		if match, err := VerifyPassword("password", hash); err == nil || match {
+			t.Errorf("%s: VerifyPassword() = %v, %v, want an error", name, match, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	target := &Argon2Params{Memory: 64, Iterations: 2, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+	with := func(change func(*Argon2Params)) *Argon2Params {
+		params := *target
+		change(&params)
+		return &params
+	}
+	hash := func(params *Argon2Params) string {
+		encoded, err := HashPassword("correct horse", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+
+	tests := []struct {
+		name   string
+		hash   string
+		rehash bool
+	}{
+		{"equal parameters", hash(target), false},
+		{"more memory", hash(with(func(p *Argon2Params) { p.Memory *= 2 })), false},
+		{"more iterations", hash(with(func(p *Argon2Params) { p.Iterations++ })), false},
+		{"longer salt and key", hash(with(func(p *Argon2Params) { p.SaltLength, p.KeyLength = 32, 64 })), false},
+		{"less memory", hash(with(func(p *Argon2Params) { p.Memory /= 2 })), true},
+		{"fewer iterations", hash(with(func(p *Argon2Params) { p.Iterations = 1 })), true},
+		{"less parallelism", hash(with(func(p *Argon2Params) { p.Parallelism = 1 })), true},
+		{"shorter salt", hash(with(func(p *Argon2Params) { p.SaltLength = 8 })), true},
+		{"shorter key", hash(with(func(p *Argon2Params) { p.KeyLength = 16 })), true},
+		{"bcrypt", "$2b$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", true},
+		{"scrypt", rfc7914Vector(t), true},
+		{"invalid", "not a hash", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hash, target); got != tt.rehash {
+				t.Fatalf("NeedsRehash() = %v, want %v", got, tt.rehash)
+			}
+		})
+	}
+}
+
+func TestVerifyAndUpgrade(t *testing.T) {
+	weaker := &Argon2Params{Memory: testParams.Memory / 2, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	stronger := &Argon2Params{Memory: testParams.Memory * 2, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	hash := func(params *Argon2Params) string {
+		encoded, err := HashPassword("U*U", params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		match    bool
+		rehash   bool
+	}{
+		{"weaker Argon2id", hash(weaker), "U*U", true, true},
+		{"equal Argon2id", hash(testParams), "U*U", true, false},
+		{"stronger Argon2id", hash(stronger), "U*U", true, false},
+		{"bcrypt", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U", true, true},
+		{"scrypt", rfc7914Vector(t), "password", true, true},
+		{"wrong password on a weaker hash", hash(weaker), "U*U*", false, false},
+		{"wrong password on bcrypt", "$2y$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", "U*U*", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := VerifyAndUpgrade(tt.password, tt.hash, testParams)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match != tt.match || rehash != tt.rehash {
+				t.Fatalf("VerifyAndUpgrade() = %v, %v, want %v, %v", match, rehash, tt.match, tt.rehash)
+			}
+		})
+	}
+
+	// The upgraded hash verifies and needs no further rehash
+	upgraded := hash(testParams)
+	if match, rehash, err := VerifyAndUpgrade("U*U", upgraded, testParams); !match || rehash || err != nil {
+		t.Fatalf("upgraded hash: VerifyAndUpgrade() = %v, %v, %v", match, rehash, err)
+	}
+
+	if _, _, err := VerifyAndUpgrade("U*U", "$argon2id$broken", testParams); err == nil {
+		t.Fatal("VerifyAndUpgrade() with an invalid hash = nil error")
+	}
+}
@@ -0,0 +1,231 @@
+// shared/go/utils/server/runner.go
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+// ==========================================
+// SERVER RUNNER
+// ==========================================
+// Runs an HTTP server until SIGTERM/SIGINT and shuts it down gracefully:
+//  1. Readiness flips to failing (ReadinessHandler returns 503), keep-alives are disabled
+//  2. Drain period: load balancers notice and stop sending new requests
+//  3. http.Server.Shutdown waits for in-flight requests (up to the shutdown timeout)
+//  4. Shutdown hooks run (health checkers, DB pools, ...)
+// A second signal during shutdown terminates the process immediately
+
+// Defaults (overridable via Options or SHUTDOWN_DRAIN_PERIOD / SHUTDOWN_TIMEOUT as Go durations)
+const (
+	DefaultReadTimeout     = 15 * time.Second
+	DefaultWriteTimeout    = 15 * time.Second
+	DefaultIdleTimeout     = 60 * time.Second
+	DefaultDrainPeriod     = 5 * time.Second // 0 in development for fast restarts
+	DefaultShutdownTimeout = 20 * time.Second
+)
+
+// Options configures a Runner (zero values use the defaults)
+type Options struct {
+	Name    string // Service name, used in lifecycle events
+	Version string
+	Addr    string // Listen address (e.g. ":8080")
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	DrainPeriod     time.Duration
+	ShutdownTimeout time.Duration
+}
+
+// shutdownHook is a cleanup function run after the server stopped
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Runner runs an HTTP server with graceful shutdown
+type Runner struct {
+	opts     Options
+	draining atomic.Bool
+	hooks    []shutdownHook
+}
+
+// New creates a runner, defaults are applied to all empty options
+func New(opts Options) *Runner {
+	if opts.ReadTimeout <= 0 {
+		opts.ReadTimeout = DefaultReadTimeout
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = DefaultWriteTimeout
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	if opts.DrainPeriod <= 0 {
+		opts.DrainPeriod = durationFromEnv("SHUTDOWN_DRAIN_PERIOD", DefaultDrainPeriod)
+		if os.Getenv("ENVIRONMENT") == "development" && os.Getenv("SHUTDOWN_DRAIN_PERIOD") == "" {
+			opts.DrainPeriod = 0
+		}
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = durationFromEnv("SHUTDOWN_TIMEOUT", DefaultShutdownTimeout)
+	}
+	return &Runner{opts: opts}
+}
+
+// OnShutdown registers a hook that runs after the server stopped accepting requests
+// Hooks run in registration order and share the remaining shutdown deadline
+func (s *Runner) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.hooks = append(s.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// CloseOnShutdown closes c (e.g. a *sql.DB pool) after the server stopped
+func (s *Runner) CloseOnShutdown(name string, c io.Closer) {
+	s.OnShutdown(name, func(ctx context.Context) error {
+		return c.Close()
+	})
+}
+
+// Ready reports false once shutdown has started
+func (s *Runner) Ready() bool {
+	return !s.draining.Load()
+}
+
+// ReadinessHandler wraps a health/readiness handler, returning 503 once shutdown has started
+func (s *Runner) ReadinessHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.Ready() {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Connection", "close")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"SHUTTING_DOWN"}`))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Run serves handler until ctx is cancelled or SIGTERM/SIGINT is received, then shuts down gracefully
+// Returns an error if the server could not start or did not shut down cleanly
+func (s *Runner) Run(ctx context.Context, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:         s.opts.Addr,
+		Handler:      handler,
+		ReadTimeout:  s.opts.ReadTimeout,  // Time to read request
+		WriteTimeout: s.opts.WriteTimeout, // Time to write response
+		IdleTimeout:  s.opts.IdleTimeout,  // Keep-alive timeout
+	}
+
+	// Bind first so a port conflict is reported before the service counts as started
+	ln, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		logger.LogServiceEvent(logger.EventServiceStartupFailed, s.opts.Name, s.opts.Version, map[string]interface{}{
+			"address": s.opts.Addr,
+			"error":   err.Error(),
+		})
+		s.runHooks(context.Background())
+		return fmt.Errorf("listen on %s: %w", s.opts.Addr, err)
+	}
+
+	parent := ctx
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	logger.LogServiceEvent(logger.EventServiceStarted, s.opts.Name, s.opts.Version, map[string]interface{}{
+		"address": s.opts.Addr,
+	})
+
+	// Wait for a signal or a failing server
+	select {
+	case err := <-serveErr:
+		logger.ErrorWithFields("HTTP server failed", err, map[string]interface{}{
+			"address": s.opts.Addr,
+		})
+		s.runHooks(context.Background())
+		return fmt.Errorf("server on %s failed: %w", s.opts.Addr, err)
+	case <-ctx.Done():
+	}
+
+	// Restore default signal handling: a second signal kills the process
+	stop()
+
+	reason := "signal"
+	if parent.Err() != nil {
+		reason = "context cancelled"
+	}
+	start := time.Now()
+	logger.LogServiceEvent(logger.EventServiceStopping, s.opts.Name, s.opts.Version, map[string]interface{}{
+		"reason":           reason,
+		"drain_period":     s.opts.DrainPeriod.String(),
+		"shutdown_timeout": s.opts.ShutdownTimeout.String(),
+	})
+
+	// 1. + 2. Fail readiness and give load balancers time to notice
+	s.draining.Store(true)
+	srv.SetKeepAlivesEnabled(false)
+	time.Sleep(s.opts.DrainPeriod)
+
+	// 3. Stop accepting connections and wait for in-flight requests
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("http server shutdown: %w", err))
+		srv.Close()
+	}
+
+	// 4. Release resources
+	errs = append(errs, s.runHooks(shutdownCtx)...)
+
+	fields := map[string]interface{}{
+		"duration_ms": time.Since(start).Milliseconds(),
+	}
+	err = errors.Join(errs...)
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	logger.LogServiceEvent(logger.EventServiceStopped, s.opts.Name, s.opts.Version, fields)
+
+	return err
+}
+
+// runHooks runs all shutdown hooks, returning their errors
+func (s *Runner) runHooks(ctx context.Context) []error {
+	var errs []error
+	for _, hook := range s.hooks {
+		if err := hook.fn(ctx); err != nil {
+			logger.ErrorWithFields("Shutdown hook failed", err, map[string]interface{}{
+				"hook": hook.name,
+			})
+			errs = append(errs, fmt.Errorf("%s: %w", hook.name, err))
+		}
+	}
+	return errs
+}
+
+// durationFromEnv parses a Go duration from an environment variable
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d >= 0 {
+		return d
+	}
+	return fallback
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+func init() {
+	logger.Init("server-test", "test")
+}
+
+// freeAddr returns a local address that was free a moment ago
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// waitFor polls cond until it holds or a second has passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunDrainsInFlightRequestsBeforeHooks(t *testing.T) {
+	addr := freeAddr(t)
+	srv := New(Options{Name: "test", Addr: addr, DrainPeriod: 50 * time.Millisecond, ShutdownTimeout: time.Second})
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	srv.OnShutdown("first", func(context.Context) error { record("hook first"); return nil })
+	srv.OnShutdown("second", func(context.Context) error { record("hook second"); return nil })
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		record("request done")
+	})
+	mux.HandleFunc("/readyz", srv.ReadinessHandler(func(w http.ResponseWriter, r *http.Request) {}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- srv.Run(ctx, mux) }()
+
+	waitFor(t, "the server to listen", func() bool {
+		resp, err := http.Get("http://" + addr + "/readyz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	})
+
+	requestErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		requestErr <- err
+	}()
+	<-started
+
+	// Readiness fails as soon as the shutdown starts
+	cancel()
+	waitFor(t, "readiness to fail", func() bool { return !srv.Ready() })
+
+	close(release)
+	if err := <-requestErr; err != nil {
+		t.Fatalf("in-flight request failed: %v", err)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	want := []string{"request done", "hook first", "hook second"}
+	if strings.Join(events, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	if _, err := http.Get("http://" + addr + "/readyz"); err == nil {
+		t.Fatal("server still accepts connections after Run returned")
+	}
+}
+
+func TestReadinessHandlerWhileDraining(t *testing.T) {
+	srv := New(Options{Name: "test"})
+	handler := srv.ReadinessHandler(func(w http.ResponseWriter, r *http.Request) {})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d before shutdown, want 200", rec.Code)
+	}
+
+	srv.draining.Store(true)
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Connection") != "close" {
+		t.Fatalf("status = %d, Connection = %q while draining, want 503 and close", rec.Code, rec.Header().Get("Connection"))
+	}
+}
+
+func TestRunShutdownTimeout(t *testing.T) {
+	addr := freeAddr(t)
+	srv := New(Options{Name: "test", Addr: addr, DrainPeriod: time.Millisecond, ShutdownTimeout: 50 * time.Millisecond})
+
+	var hookCtxErr error
+	srv.OnShutdown("pool", func(ctx context.Context) error {
+		hookCtxErr = ctx.Err()
+		return errors.New("close failed")
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- srv.Run(ctx, handler) }()
+
+	waitFor(t, "the server to listen", func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	})
+	go http.Get("http://" + addr + "/")
+	<-started
+	cancel()
+
+	// The hanging request cannot delay the shutdown beyond the timeout, hooks still run
+	select {
+	case err := <-runErr:
+		if err == nil || !strings.Contains(err.Error(), "http server shutdown") || !strings.Contains(err.Error(), "pool: close failed") {
+			t.Fatalf("Run() = %v, want the shutdown and hook errors", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() did not return after the shutdown timeout")
+	}
+	if !errors.Is(hookCtxErr, context.DeadlineExceeded) {
+		t.Fatalf("hook context error = %v, want the expired shutdown deadline", hookCtxErr)
+	}
+}
+
+func TestRunListenFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	srv := New(Options{Name: "test", Addr: ln.Addr().String()})
+	closed := false
+	srv.OnShutdown("pool", func(context.Context) error { closed = true; return nil })
+
+	if err := srv.Run(context.Background(), http.NotFoundHandler()); err == nil || !strings.Contains(err.Error(), "listen on") {
+		t.Fatalf("Run() = %v, want a listen error", err)
+	}
+	if !closed {
+		t.Fatal("shutdown hooks not run after a failed start")
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	t.Setenv("SHUTDOWN_TIMEOUT", "3s")
+	t.Setenv("SHUTDOWN_DRAIN_PERIOD", "")
+	t.Setenv("ENVIRONMENT", "development")
+
+	opts := New(Options{}).opts
+	if opts.WriteTimeout != DefaultWriteTimeout || opts.ShutdownTimeout != 3*time.Second || opts.DrainPeriod != 0 {
+		t.Fatalf("options = %+v, want default write timeout, 3s shutdown timeout and no drain period in development", opts)
+	}
+
+	t.Setenv("ENVIRONMENT", "production")
+	if drain := New(Options{}).opts.DrainPeriod; drain != DefaultDrainPeriod {
+		t.Fatalf("DrainPeriod = %v in production, want %v", drain, DefaultDrainPeriod)
+	}
+}
@@ -0,0 +1,293 @@
+// shared/go/utils/session/manager.go
+
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	interfaceconfig "github.com/app/shared/go/interfaces/config"
+	"github.com/app/shared/go/utils/ip"
+	"github.com/app/shared/go/utils/logger"
+	"github.com/app/shared/go/utils/security"
+)
+
+// ==========================================
+// SESSION MANAGER
+// ==========================================
+// Issues the session cookie (HttpOnly, SameSite, Secure in production) on login and resolves it on
+// later requests. A session ends at the first of two deadlines: the idle expiry, moved forward on every
+// use (sliding), and the absolute expiry set at login. Logins replace the session of the request
+// (session ID rotation), so an ID planted before the login is never authenticated
+
+// DefaultTouchInterval is the minimum time between two writes of the sliding expiry of a session
+const DefaultTouchInterval = time.Minute
+
+// tokenBytes is the entropy of a session cookie (256 bit)
+const tokenBytes = 32
+
+// maxUserAgentLength truncates the stored user agent
+const maxUserAgentLength = 255
+
+// Errors of Manager
+var (
+	ErrNoSession = errors.New("session: no session cookie")
+	ErrExpired   = errors.New("session: expired")
+)
+
+// Options configures a Manager
+type Options struct {
+	Store         Store
+	CookieName    string
+	Lifetime      time.Duration // Absolute expiry after login
+	IdleTimeout   time.Duration // Sliding expiry after the last use (0 = Lifetime)
+	Secure        bool          // Secure flag of the cookie (HTTPS only)
+	SameSite      http.SameSite // 0 = http.SameSiteLaxMode
+	TouchInterval time.Duration // 0 = DefaultTouchInterval
+}
+
+// OptionsFromConfig reads the cookie name and expiries from authConfig.json
+// The config is expected to be validated with config.ValidateAuthConfig beforehand
+func OptionsFromConfig(cfg interfaceconfig.IAuthConfig, store Store, secure bool) Options {
+	return Options{
+		Store:       store,
+		CookieName:  cfg.Cookie.AuthCookieName,
+		Lifetime:    time.Duration(cfg.Cookie.AuthCookieLifetimeSeconds) * time.Second,
+		IdleTimeout: time.Duration(cfg.Cookie.AuthCookieIdleTimeoutSeconds) * time.Second,
+		Secure:      secure,
+	}
+}
+
+// Manager creates, resolves and revokes sessions
+type Manager struct {
+	opts Options
+}
+
+// NewManager creates a session manager
+func NewManager(opts Options) *Manager {
+	if opts.IdleTimeout <= 0 || opts.IdleTimeout > opts.Lifetime {
+		opts.IdleTimeout = opts.Lifetime
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	if opts.TouchInterval <= 0 {
+		opts.TouchInterval = DefaultTouchInterval
+	}
+	return &Manager{opts: opts}
+}
+
+// CookieName returns the name of the session cookie
+func (m *Manager) CookieName() string {
+	return m.opts.CookieName
+}
+
+// Start creates a session for the user with its roles and sets its cookie
+// A session of the request is removed first (rotation)
+func (m *Manager) Start(w http.ResponseWriter, r *http.Request, userID string, roles []string) (Session, error) {
+	if cookie, err := r.Cookie(m.opts.CookieName); err == nil && cookie.Value != "" {
+		if err := m.opts.Store.Delete(r.Context(), security.HashSHA256(cookie.Value)); err != nil {
+			return Session{}, err
+		}
+	}
+
+	token, err := security.GenerateToken(tokenBytes)
+	if err != nil {
+		return Session{}, err
+	}
+
+	requestID, clientIP := requestInfo(r)
+	now := time.Now().UTC()
+	session := Session{
+		ID:            security.GenerateID(),
+		UserID:        userID,
+		IP:            clientIP,
+		UserAgent:     truncate(r.UserAgent(), maxUserAgentLength),
+		Roles:         roles,
+		CreatedAt:     now,
+		LastSeenAt:    now,
+		IdleExpiresAt: now.Add(m.opts.IdleTimeout),
+		ExpiresAt:     now.Add(m.opts.Lifetime),
+	}
+	if err := m.opts.Store.Create(r.Context(), security.HashSHA256(token), session); err != nil {
+		return Session{}, err
+	}
+
+	m.setCookie(w, token, int(m.opts.Lifetime/time.Second))
+	logger.LogAuthEvent(logger.EventSessionCreated, userID, requestID, clientIP, map[string]interface{}{
+		"session_id": session.ID,
+		"expires_at": session.ExpiresAt,
+	})
+	return session, nil
+}
+
+// Load resolves the session cookie of the request and extends its idle expiry
+// Returns ErrNoSession without cookie, ErrNotFound (logged as invalid) or ErrExpired (logged as expired)
+func (m *Manager) Load(r *http.Request) (Session, error) {
+	cookie, err := r.Cookie(m.opts.CookieName)
+	if err != nil || cookie.Value == "" {
+		return Session{}, ErrNoSession
+	}
+
+	session, err := m.Validate(r.Context(), cookie.Value)
+	if err == nil {
+		return session, nil
+	}
+
+	requestID, clientIP := requestInfo(r)
+	switch {
+	case errors.Is(err, ErrExpired):
+		logger.LogAuthEvent(logger.EventSessionExpired, session.UserID, requestID, clientIP, map[string]interface{}{
+			"session_id": session.ID,
+			"path":       r.URL.Path,
+		})
+	case errors.Is(err, ErrNotFound):
+		logger.LogAuthEvent(logger.EventSessionInvalid, "", requestID, clientIP, map[string]interface{}{
+			"path":   r.URL.Path,
+			"reason": "unknown_session",
+		})
+	}
+	return session, err
+}
+
+// Validate returns the session of a cookie value and extends its idle expiry (at most every TouchInterval)
+// Expired sessions are removed and returned with ErrExpired
+func (m *Manager) Validate(ctx context.Context, token string) (Session, error) {
+	tokenHash := security.HashSHA256(token)
+	session, err := m.opts.Store.Get(ctx, tokenHash)
+	if err != nil {
+		return Session{}, err
+	}
+
+	now := time.Now().UTC()
+	if session.Expired(now) {
+		if err := m.opts.Store.Delete(ctx, tokenHash); err != nil {
+			return Session{}, err
+		}
+		return session, ErrExpired
+	}
+	return m.touch(ctx, session, now)
+}
+
+// Active checks a session by its public ID, e.g. the "sid" claim of a JWT, and extends its idle expiry
+// like Validate, so bearer clients keep their session alive as cookies do
+// Returns ErrNotFound for revoked (or removed) and ErrExpired for expired sessions
+func (m *Manager) Active(ctx context.Context, id string) (Session, error) {
+	session, err := m.opts.Store.GetByID(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	now := time.Now().UTC()
+	if session.Expired(now) {
+		return session, ErrExpired
+	}
+	return m.touch(ctx, session, now)
+}
+
+// touch moves the idle expiry of the session forward, at most every TouchInterval and up to its absolute expiry
+func (m *Manager) touch(ctx context.Context, session Session, now time.Time) (Session, error) {
+	if now.Sub(session.LastSeenAt) < m.opts.TouchInterval {
+		return session, nil
+	}
+	idleExpires := now.Add(m.opts.IdleTimeout)
+	if idleExpires.After(session.ExpiresAt) {
+		idleExpires = session.ExpiresAt
+	}
+	if err := m.opts.Store.Touch(ctx, session.ID, now, idleExpires); err != nil {
+		return Session{}, err
+	}
+	session.LastSeenAt = now
+	session.IdleExpiresAt = idleExpires
+	return session, nil
+}
+
+// End removes the session of the request and its cookie
+func (m *Manager) End(w http.ResponseWriter, r *http.Request) error {
+	m.ClearCookie(w)
+	cookie, err := r.Cookie(m.opts.CookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	return m.opts.Store.Delete(r.Context(), security.HashSHA256(cookie.Value))
+}
+
+// ClearCookie removes the session cookie from the browser
+func (m *Manager) ClearCookie(w http.ResponseWriter) {
+	m.setCookie(w, "", -1)
+}
+
+// List returns the sessions of the user, newest first (expired sessions not yet removed are skipped)
+func (m *Manager) List(ctx context.Context, userID string) ([]Session, error) {
+	sessions, err := m.opts.Store.ListUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	active := sessions[:0]
+	for _, session := range sessions {
+		if !session.Expired(now) {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+// Revoke ends a session of the user by its public ID, ErrNotFound if the user has no such session
+func (m *Manager) Revoke(ctx context.Context, userID, id string) error {
+	return m.opts.Store.DeleteByID(ctx, userID, id)
+}
+
+// RevokeUserSessions ends all sessions of the user (e.g. after a password change)
+func (m *Manager) RevokeUserSessions(ctx context.Context, userID string) error {
+	revoked, err := m.opts.Store.DeleteUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	logger.InfoWithFields("Sessions revoked", map[string]interface{}{
+		"user_id": userID,
+		"count":   revoked,
+	})
+	return nil
+}
+
+// DeleteExpired removes expired sessions from the store, returns the number of removed sessions
+func (m *Manager) DeleteExpired(ctx context.Context) (int64, error) {
+	return m.opts.Store.DeleteExpired(ctx, time.Now().UTC())
+}
+
+// setCookie sets (maxAge > 0) or removes (maxAge < 0) the session cookie
+func (m *Manager) setCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.opts.CookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   m.opts.Secure,
+		SameSite: m.opts.SameSite,
+	})
+}
+
+// requestInfo returns the request ID and client IP set by the middleware (context keys "request_id" and "client_ip")
+func requestInfo(r *http.Request) (string, string) {
+	requestID, _ := r.Context().Value("request_id").(string)
+	clientIP, _ := r.Context().Value("client_ip").(string)
+	if clientIP == "" {
+		clientIP = ip.ClientIP(r)
+	}
+	return requestID, clientIP
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
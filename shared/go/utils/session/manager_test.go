@@ -0,0 +1,171 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/app/shared/go/utils/security"
+)
+
+// newTestManager creates a manager on an in-memory store
+func newTestManager(lifetime, idleTimeout time.Duration) (*Manager, *MemoryStore) {
+	store := NewMemoryStore()
+	return NewManager(Options{
+		Store:       store,
+		CookieName:  "session",
+		Lifetime:    lifetime,
+		IdleTimeout: idleTimeout,
+	}), store
+}
+
+// startSession logs in the user and returns the session with its cookie value
+func startSession(t *testing.T, m *Manager, r *http.Request) (Session, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	session, err := m.Start(w, r, "user-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "session" {
+			return session, cookie.Value
+		}
+	}
+	t.Fatal("no session cookie set")
+	return Session{}, ""
+}
+
+// elapse moves the timestamps of the session back, as if d had passed
+func elapse(t *testing.T, store *MemoryStore, token string, d time.Duration) {
+	t.Helper()
+	tokenHash := security.HashSHA256(token)
+	session, err := store.Get(context.Background(), tokenHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.CreatedAt = session.CreatedAt.Add(-d)
+	session.LastSeenAt = session.LastSeenAt.Add(-d)
+	session.IdleExpiresAt = session.IdleExpiresAt.Add(-d)
+	session.ExpiresAt = session.ExpiresAt.Add(-d)
+	store.Create(context.Background(), tokenHash, session)
+}
+
+func TestManagerSlidingExpiry(t *testing.T) {
+	m, store := newTestManager(time.Hour, 10*time.Minute)
+	ctx := context.Background()
+	_, token := startSession(t, m, httptest.NewRequest(http.MethodPost, "/login", nil))
+
+	// Every use moves the idle expiry forward, the session outlives its idle timeout
+	for i := 0; i < 3; i++ {
+		elapse(t, store, token, 8*time.Minute)
+		session, err := m.Validate(ctx, token)
+		if err != nil {
+			t.Fatalf("use %d: %v, want the session extended", i+1, err)
+		}
+		if idle := time.Until(session.IdleExpiresAt); idle < 9*time.Minute || idle > 10*time.Minute {
+			t.Fatalf("use %d: idle expiry in %v, want 10m", i+1, idle)
+		}
+	}
+
+	// Uses within the touch interval are not written
+	elapse(t, store, token, 30*time.Second)
+	before, _ := store.Get(ctx, security.HashSHA256(token))
+	m.Validate(ctx, token)
+	if after, _ := store.Get(ctx, security.HashSHA256(token)); !after.LastSeenAt.Equal(before.LastSeenAt) {
+		t.Fatal("session touched within the touch interval")
+	}
+
+	// Idle for longer than the timeout
+	elapse(t, store, token, 10*time.Minute)
+	if _, err := m.Validate(ctx, token); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Validate() after 10m idle = %v, want ErrExpired", err)
+	}
+	if _, err := m.Validate(ctx, token); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Validate() of an expired session = %v, want ErrNotFound (removed)", err)
+	}
+}
+
+func TestManagerAbsoluteExpiry(t *testing.T) {
+	m, store := newTestManager(30*time.Minute, 10*time.Minute)
+	ctx := context.Background()
+	started, token := startSession(t, m, httptest.NewRequest(http.MethodPost, "/login", nil))
+
+	for i := 0; i < 3; i++ {
+		elapse(t, store, token, 8*time.Minute)
+		if _, err := m.Validate(ctx, token); err != nil {
+			t.Fatalf("use %d: %v, want the session extended", i+1, err)
+		}
+	}
+
+	// 24m after login the idle expiry is capped at the absolute expiry
+	session, _ := store.Get(ctx, security.HashSHA256(token))
+	if !session.IdleExpiresAt.Equal(session.ExpiresAt) {
+		t.Fatalf("idle expiry %v, want capped at %v", session.IdleExpiresAt, session.ExpiresAt)
+	}
+	if want := started.ExpiresAt.Add(-24 * time.Minute); !session.ExpiresAt.Equal(want) {
+		t.Fatalf("absolute expiry %v, want %v (not extended)", session.ExpiresAt, want)
+	}
+
+	// Used regularly, but past the lifetime
+	elapse(t, store, token, 8*time.Minute)
+	if _, err := m.Validate(ctx, token); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Validate() 32m after login = %v, want ErrExpired", err)
+	}
+	if n := len(store.sessions); n != 0 {
+		t.Fatalf("%d sessions stored, want the expired session removed", n)
+	}
+}
+
+func TestManagerStartRotatesSession(t *testing.T) {
+	m, _ := newTestManager(time.Hour, 0)
+	ctx := context.Background()
+	first, token := startSession(t, m, httptest.NewRequest(http.MethodPost, "/login", nil))
+
+	r := httptest.NewRequest(http.MethodPost, "/login", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: token})
+	second, newToken := startSession(t, m, r)
+
+	if second.ID == first.ID || newToken == token {
+		t.Fatal("login kept the session of the request")
+	}
+	if _, err := m.Validate(ctx, token); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Validate() of the replaced session = %v, want ErrNotFound", err)
+	}
+	if _, err := m.Validate(ctx, newToken); err != nil {
+		t.Fatalf("Validate() of the new session = %v", err)
+	}
+}
+
+func TestManagerActive(t *testing.T) {
+	m, store := newTestManager(time.Hour, 10*time.Minute)
+	ctx := context.Background()
+	started, token := startSession(t, m, httptest.NewRequest(http.MethodPost, "/login", nil))
+
+	// Every check moves the idle expiry forward like a cookie request
+	for i := 0; i < 3; i++ {
+		elapse(t, store, token, 5*time.Minute)
+		if _, err := m.Active(ctx, started.ID); err != nil {
+			t.Fatalf("check %d: Active() = %v", i+1, err)
+		}
+		if session, _ := store.Get(ctx, security.HashSHA256(token)); time.Until(session.IdleExpiresAt) < 9*time.Minute {
+			t.Fatalf("check %d: idle expiry in %v, want 10m", i+1, time.Until(session.IdleExpiresAt))
+		}
+	}
+
+	elapse(t, store, token, 10*time.Minute)
+	if _, err := m.Active(ctx, started.ID); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Active() after 10m idle = %v, want ErrExpired", err)
+	}
+
+	other, _ := startSession(t, m, httptest.NewRequest(http.MethodPost, "/login", nil))
+	if err := m.Revoke(ctx, "user-1", other.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Active(ctx, other.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Active() of a revoked session = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,136 @@
+// shared/go/utils/session/memory.go
+
+package session
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ==========================================
+// IN-MEMORY STORE
+// ==========================================
+
+// MemoryStore keeps sessions in the process (tests, single instance without database)
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session // By token hash
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]Session)}
+}
+
+// Create stores a session
+func (s *MemoryStore) Create(ctx context.Context, tokenHash string, session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[tokenHash] = session
+	return nil
+}
+
+// Get returns the session of a token hash
+func (s *MemoryStore) Get(ctx context.Context, tokenHash string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[tokenHash]
+	if !ok {
+		return Session{}, ErrNotFound
+	}
+	return session, nil
+}
+
+// GetByID returns a session by its public ID
+func (s *MemoryStore) GetByID(ctx context.Context, id string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, session := range s.sessions {
+		if session.ID == id {
+			return session, nil
+		}
+	}
+	return Session{}, ErrNotFound
+}
+
+// Touch records a use of the session
+func (s *MemoryStore) Touch(ctx context.Context, id string, lastSeen, idleExpires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, session := range s.sessions {
+		if session.ID == id {
+			session.LastSeenAt = lastSeen
+			session.IdleExpiresAt = idleExpires
+			s.sessions[hash] = session
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// Delete removes a session, unknown hashes are ignored
+func (s *MemoryStore) Delete(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, tokenHash)
+	return nil
+}
+
+// DeleteByID removes a session of the user by its public ID
+func (s *MemoryStore) DeleteByID(ctx context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, session := range s.sessions {
+		if session.ID == id && session.UserID == userID {
+			delete(s.sessions, hash)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// DeleteUser removes all sessions of the user
+func (s *MemoryStore) DeleteUser(ctx context.Context, userID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed int64
+	for hash, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, hash)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// ListUser returns the sessions of the user, newest first
+func (s *MemoryStore) ListUser(ctx context.Context, userID string) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sessions []Session
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// DeleteExpired removes the sessions expired at now
+func (s *MemoryStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed int64
+	for hash, session := range s.sessions {
+		if session.Expired(now) {
+			delete(s.sessions, hash)
+			removed++
+		}
+	}
+	return removed, nil
+}
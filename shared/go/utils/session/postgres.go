@@ -0,0 +1,151 @@
+// shared/go/utils/session/postgres.go
+
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ==========================================
+// POSTGRES STORE
+// ==========================================
+// Table sessions (app/backend/service-a/db/migrations/003_create_sessions_table.up.sql)
+
+// PostgresStore keeps sessions in Postgres
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a store on the connection pool
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// sessionColumns are the columns scanned by scanSession
+const sessionColumns = `id, user_id, ip, user_agent, roles, created_at, last_seen_at, idle_expires_at, expires_at`
+
+// Create stores a session
+func (s *PostgresStore) Create(ctx context.Context, tokenHash string, session Session) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sessions (token_hash, `+sessionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		tokenHash, session.ID, session.UserID, session.IP, session.UserAgent, strings.Join(session.Roles, ","),
+		session.CreatedAt, session.LastSeenAt, session.IdleExpiresAt, session.ExpiresAt,
+	)
+	return err
+}
+
+// Get returns the session of a token hash
+func (s *PostgresStore) Get(ctx context.Context, tokenHash string) (Session, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE token_hash = $1`, tokenHash)
+	session, err := scanSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, ErrNotFound
+	}
+	return session, err
+}
+
+// GetByID returns a session by its public ID
+func (s *PostgresStore) GetByID(ctx context.Context, id string) (Session, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, id)
+	session, err := scanSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, ErrNotFound
+	}
+	return session, err
+}
+
+// Touch records a use of the session
+func (s *PostgresStore) Touch(ctx context.Context, id string, lastSeen, idleExpires time.Time) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE sessions SET last_seen_at = $2, idle_expires_at = $3 WHERE id = $1`,
+		id, lastSeen, idleExpires,
+	)
+	return affectedOne(result, err)
+}
+
+// Delete removes a session, unknown hashes are ignored
+func (s *PostgresStore) Delete(ctx context.Context, tokenHash string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = $1`, tokenHash)
+	return err
+}
+
+// DeleteByID removes a session of the user by its public ID
+func (s *PostgresStore) DeleteByID(ctx context.Context, userID, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1 AND user_id = $2`, id, userID)
+	return affectedOne(result, err)
+}
+
+// DeleteUser removes all sessions of the user
+func (s *PostgresStore) DeleteUser(ctx context.Context, userID string) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListUser returns the sessions of the user, newest first
+func (s *PostgresStore) ListUser(ctx context.Context, userID string) ([]Session, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+` FROM sessions WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteExpired removes the sessions expired at now
+func (s *PostgresStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM sessions WHERE idle_expires_at <= $1 OR expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// scanSession scans the sessionColumns of a row
+func scanSession(row interface {
+	Scan(dest ...interface{}) error
+}) (Session, error) {
+	var session Session
+	var roles string
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.IP, &session.UserAgent, &roles,
+		&session.CreatedAt, &session.LastSeenAt, &session.IdleExpiresAt, &session.ExpiresAt,
+	)
+	if roles != "" {
+		session.Roles = strings.Split(roles, ",")
+	}
+	return session, err
+}
+
+// affectedOne maps an update of no row to ErrNotFound
+func affectedOne(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
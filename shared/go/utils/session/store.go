@@ -0,0 +1,50 @@
+// shared/go/utils/session/store.go
+
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ==========================================
+// SESSION STORE
+// ==========================================
+// A Store keeps server-side sessions by the SHA-256 of their cookie value, so a leaked store
+// cannot be replayed as cookies. The Postgres store is shared by the gateway and service-a,
+// the in-memory store is meant for tests and single-process setups
+
+// ErrNotFound is returned for unknown or already revoked sessions
+var ErrNotFound = errors.New("session: not found")
+
+// Session is a login of a user on one device
+type Session struct {
+	ID            string    `json:"id"` // Public ID for listing and revocation, not the cookie value
+	UserID        string    `json:"user_id"`
+	IP            string    `json:"ip"`
+	UserAgent     string    `json:"user_agent"`
+	Roles         []string  `json:"roles,omitempty"` // Roles of the user at login, checked like the roles of a JWT
+	CreatedAt     time.Time `json:"created_at"`
+	LastSeenAt    time.Time `json:"last_seen_at"`
+	IdleExpiresAt time.Time `json:"idle_expires_at"` // Sliding expiry, moved forward on use up to ExpiresAt
+	ExpiresAt     time.Time `json:"expires_at"`      // Absolute expiry
+}
+
+// Expired reports whether the session is past its idle or absolute expiry at now
+func (s Session) Expired(now time.Time) bool {
+	return !now.Before(s.IdleExpiresAt) || !now.Before(s.ExpiresAt)
+}
+
+// Store persists sessions, tokenHash is the SHA-256 (hex) of the cookie value
+type Store interface {
+	Create(ctx context.Context, tokenHash string, s Session) error
+	Get(ctx context.Context, tokenHash string) (Session, error)                  // ErrNotFound if unknown
+	GetByID(ctx context.Context, id string) (Session, error)                     // ErrNotFound if unknown
+	Touch(ctx context.Context, id string, lastSeen, idleExpires time.Time) error // By public ID
+	Delete(ctx context.Context, tokenHash string) error
+	DeleteByID(ctx context.Context, userID, id string) error // ErrNotFound if the user has no such session
+	DeleteUser(ctx context.Context, userID string) (int64, error)
+	ListUser(ctx context.Context, userID string) ([]Session, error) // Newest first
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,198 @@
+// shared/go/utils/tracing/propagation.go
+
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+)
+
+// ==========================================
+// W3C TRACE CONTEXT
+// ==========================================
+// traceparent: 00-<trace-id 32 hex>-<parent-id 16 hex>-<flags 2 hex>
+// tracestate:  vendor specific key=value list, passed through unchanged
+// See https://www.w3.org/TR/trace-context/
+
+// Header names
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// maxTracestateLen is the maximum tracestate length that is propagated
+const maxTracestateLen = 512
+
+// flagSampled is the sampled bit of the trace flags
+const flagSampled byte = 0x01
+
+// TraceID identifies a trace (16 bytes)
+type TraceID [16]byte
+
+// SpanID identifies a span (8 bytes)
+type SpanID [8]byte
+
+// String returns the lowercase hex form
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// IsValid reports whether the ID is not all zeros
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// String returns the lowercase hex form
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the ID is not all zeros
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext is the part of a span that is propagated to other services
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+	Remote     bool // Extracted from an incoming request
+}
+
+// IsValid reports whether trace and span ID are set
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// IsSampled reports whether the sampled flag is set
+func (sc SpanContext) IsSampled() bool { return sc.Flags&flagSampled != 0 }
+
+// Traceparent formats the traceparent header value
+func (sc SpanContext) Traceparent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent parses a traceparent header value
+func ParseTraceparent(value string) (SpanContext, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return SpanContext{}, false
+	}
+
+	version, ok := decodeHex(value[0:2])
+	if !ok || version[0] == 0xff {
+		return SpanContext{}, false
+	}
+	// Version 00 has exactly 4 fields, future versions may append more after a "-"
+	if (version[0] == 0 && len(value) != 55) || (len(value) > 55 && value[55] != '-') {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	traceID, ok := decodeHex(value[3:35])
+	if !ok {
+		return SpanContext{}, false
+	}
+	spanID, ok := decodeHex(value[36:52])
+	if !ok {
+		return SpanContext{}, false
+	}
+	flags, ok := decodeHex(value[53:55])
+	if !ok {
+		return SpanContext{}, false
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	sc.Remote = true
+
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// decodeHex decodes lowercase hex only (the spec forbids uppercase)
+func decodeHex(s string) ([]byte, bool) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return nil, false
+		}
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// Extract returns ctx with the remote span context of the incoming request (if present and valid)
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+	if state := header.Get(TracestateHeader); len(state) <= maxTracestateLen {
+		sc.TraceState = state
+	}
+	return context.WithValue(ctx, "trace_remote", sc)
+}
+
+// Inject writes traceparent/tracestate of the current span into the outgoing headers
+// Headers copied from the incoming request are removed if ctx carries no span
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		header.Del(TraceparentHeader)
+		header.Del(TracestateHeader)
+		return
+	}
+	header.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	} else {
+		header.Del(TracestateHeader)
+	}
+}
+
+// ==========================================
+// CONTEXT
+// ==========================================
+// The span is stored under "trace_span", its IDs additionally as strings under
+// "trace_id" / "span_id" so logger.WithContext can add them without importing this package
+
+// ContextWithSpan returns ctx with span as the current span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	ctx = context.WithValue(ctx, "trace_span", span)
+	ctx = context.WithValue(ctx, "trace_id", span.sc.TraceID.String())
+	return context.WithValue(ctx, "span_id", span.sc.SpanID.String())
+}
+
+// SpanFromContext returns the current span (nil if there is none)
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value("trace_span").(*Span)
+	return span
+}
+
+// SpanContextFromContext returns the current span's context, or the extracted remote parent
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.sc
+	}
+	sc, _ := ctx.Value("trace_remote").(SpanContext)
+	return sc
+}
+
+// ==========================================
+// ID GENERATION
+// ==========================================
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
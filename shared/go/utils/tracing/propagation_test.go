@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"valid", testTraceparent, true},
+		{"surrounding spaces", "  " + testTraceparent + " ", true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"future version with extra field", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"version 00 with extra field", testTraceparent + "-extra", false},
+		{"future version without separator", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x", false},
+		{"forbidden version ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"uppercase hex", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"zero span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"wrong separator", "00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"too short", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("ParseTraceparent(%q) ok = %v, want %v", tt.value, ok, tt.ok)
+			}
+			if ok && (!sc.IsValid() || !sc.Remote) {
+				t.Fatalf("ParseTraceparent(%q) = %+v, want a valid remote span context", tt.value, sc)
+			}
+		})
+	}
+}
+
+func TestParseTraceparentFields(t *testing.T) {
+	sc, ok := ParseTraceparent(testTraceparent)
+	if !ok {
+		t.Fatal("valid traceparent rejected")
+	}
+	if got := sc.TraceID.String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("TraceID = %s", got)
+	}
+	if got := sc.SpanID.String(); got != "00f067aa0ba902b7" {
+		t.Errorf("SpanID = %s", got)
+	}
+	if !sc.IsSampled() {
+		t.Error("sampled flag not set")
+	}
+	if got := sc.Traceparent(); got != testTraceparent {
+		t.Errorf("Traceparent() = %s, want %s", got, testTraceparent)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceparentHeader, testTraceparent)
+	header.Set(TracestateHeader, "vendor=value")
+
+	sc := SpanContextFromContext(Extract(context.Background(), header))
+	if sc.Traceparent() != testTraceparent || sc.TraceState != "vendor=value" || !sc.Remote {
+		t.Fatalf("extracted %+v", sc)
+	}
+
+	// Oversized tracestate is dropped, the traceparent is kept
+	header.Set(TracestateHeader, strings.Repeat("a", maxTracestateLen+1))
+	sc = SpanContextFromContext(Extract(context.Background(), header))
+	if !sc.IsValid() || sc.TraceState != "" {
+		t.Fatalf("extracted %+v, want valid context without tracestate", sc)
+	}
+
+	// Invalid traceparent leaves the context unchanged
+	header.Set(TraceparentHeader, "invalid")
+	if sc := SpanContextFromContext(Extract(context.Background(), header)); sc.IsValid() {
+		t.Fatalf("extracted %+v from an invalid header", sc)
+	}
+}
+
+func TestInject(t *testing.T) {
+	tracer := NewTracer("test", nil, Options{})
+
+	incoming := http.Header{}
+	incoming.Set(TraceparentHeader, testTraceparent)
+	incoming.Set(TracestateHeader, "vendor=value")
+	ctx, span := tracer.Start(Extract(context.Background(), incoming), "client", SpanKindClient)
+
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	sc, ok := ParseTraceparent(outgoing.Get(TraceparentHeader))
+	if !ok {
+		t.Fatalf("injected invalid traceparent %q", outgoing.Get(TraceparentHeader))
+	}
+	if sc.TraceID != span.SpanContext().TraceID || sc.SpanID != span.SpanContext().SpanID {
+		t.Fatalf("injected %s, want the span %s", sc.Traceparent(), span.SpanContext().Traceparent())
+	}
+	if got := outgoing.Get(TracestateHeader); got != "vendor=value" {
+		t.Fatalf("tracestate = %q, want it passed through", got)
+	}
+
+	// Without a span, headers copied from the incoming request are removed
+	copied := incoming.Clone()
+	Inject(context.Background(), copied)
+	if copied.Get(TraceparentHeader) != "" || copied.Get(TracestateHeader) != "" {
+		t.Fatalf("headers kept without span: %v", copied)
+	}
+}
@@ -0,0 +1,429 @@
+// shared/go/utils/tracing/tracer.go
+
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/app/shared/go/utils/logger"
+)
+
+// ==========================================
+// TRACER
+// ==========================================
+// Minimal OpenTelemetry-compatible tracer: spans with W3C trace context, parent-based
+// ratio sampling and batched export (OTLP/HTTP or in-memory)
+// Unsampled spans are still created, so IDs are propagated and logged, but never exported
+
+// Defaults
+const (
+	DefaultBatchSize     = 512
+	DefaultQueueSize     = 2048
+	DefaultFlushInterval = 5 * time.Second
+)
+
+// SpanKind describes the relationship of a span to its parent (values match OTLP)
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// StatusCode is the span status (values match OTLP)
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Options configures a Tracer (zero values use the defaults)
+type Options struct {
+	SampleRatio   float64 // Share of new traces that are sampled (0 = 1.0, negative = none)
+	BatchSize     int     // Spans per export
+	QueueSize     int     // Spans buffered before new ones are dropped
+	FlushInterval time.Duration
+}
+
+// Tracer creates spans and hands finished sampled spans to the exporter
+type Tracer struct {
+	service   string
+	exporter  Exporter // nil = spans are not recorded
+	threshold uint64   // Trace IDs below the threshold are sampled
+	opts      Options
+
+	mu      sync.Mutex
+	queue   []SpanData
+	dropped atomic.Uint64
+	flush   chan struct{}
+	done    chan struct{}
+	stopped sync.Once
+}
+
+// NewTracer creates a tracer, exporter may be nil to only propagate trace context
+func NewTracer(service string, exporter Exporter, opts Options) *Tracer {
+	if opts.SampleRatio == 0 {
+		opts.SampleRatio = 1
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+
+	t := &Tracer{
+		service:   service,
+		exporter:  exporter,
+		threshold: sampleThreshold(opts.SampleRatio),
+		opts:      opts,
+		flush:     make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	if exporter != nil {
+		go t.run()
+	}
+	return t
+}
+
+// sampleThreshold converts a ratio into a threshold for the lower 8 bytes of the trace ID
+func sampleThreshold(ratio float64) uint64 {
+	switch {
+	case ratio <= 0:
+		return 0
+	case ratio >= 1:
+		return math.MaxUint64
+	}
+	return uint64(ratio * math.MaxUint64)
+}
+
+// Start creates a span as child of the current (or extracted remote) span in ctx
+// End must be called on the returned span
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		// Parent-based sampling: follow the decision of the caller
+		sc.TraceID = parent.TraceID
+		sc.Flags = parent.Flags
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+		if binary.BigEndian.Uint64(sc.TraceID[8:]) < t.threshold || t.threshold == math.MaxUint64 {
+			sc.Flags = flagSampled
+		}
+	}
+
+	span := &Span{
+		tracer:    t,
+		sc:        sc,
+		name:      name,
+		kind:      kind,
+		start:     time.Now(),
+		recording: t.exporter != nil && sc.IsSampled(),
+	}
+	if parent.IsValid() {
+		span.parent = parent.SpanID
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// enqueue buffers a finished span for export, dropping it when the queue is full
+func (t *Tracer) enqueue(data SpanData) {
+	t.mu.Lock()
+	if len(t.queue) >= t.opts.QueueSize {
+		t.mu.Unlock()
+		t.dropped.Add(1)
+		return
+	}
+	t.queue = append(t.queue, data)
+	full := len(t.queue) >= t.opts.BatchSize
+	t.mu.Unlock()
+
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run exports batches every flush interval or when a batch is full
+func (t *Tracer) run() {
+	ticker := time.NewTicker(t.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		case <-t.flush:
+		}
+		t.ForceFlush(context.Background())
+	}
+}
+
+// ForceFlush exports all buffered spans
+func (t *Tracer) ForceFlush(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+
+	var errs []error
+	for {
+		t.mu.Lock()
+		n := min(len(t.queue), t.opts.BatchSize)
+		batch := t.queue[:n:n]
+		t.queue = t.queue[n:]
+		t.mu.Unlock()
+
+		if n == 0 {
+			break
+		}
+		exportCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := t.exporter.ExportSpans(exportCtx, batch)
+		cancel()
+		if err != nil {
+			logger.WarnWithFields("Trace export failed", map[string]interface{}{
+				"service_name": t.service,
+				"spans":        n,
+				"error":        err.Error(),
+			})
+			errs = append(errs, err)
+		}
+	}
+
+	if dropped := t.dropped.Swap(0); dropped > 0 {
+		logger.WarnWithFields("Trace spans dropped, export queue full", map[string]interface{}{
+			"service_name": t.service,
+			"dropped":      dropped,
+		})
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("export spans: %w", errs[0])
+	}
+	return nil
+}
+
+// Shutdown exports the remaining spans and shuts the exporter down
+// Usable as server.Runner shutdown hook
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+
+	var err error
+	t.stopped.Do(func() {
+		close(t.done)
+		if flushErr := t.ForceFlush(ctx); flushErr != nil {
+			err = flushErr
+		}
+		if shutdownErr := t.exporter.Shutdown(ctx); shutdownErr != nil && err == nil {
+			err = shutdownErr
+		}
+	})
+	return err
+}
+
+// ==========================================
+// SPAN
+// ==========================================
+
+// Span is a timed operation within a trace
+type Span struct {
+	tracer    *Tracer
+	sc        SpanContext
+	parent    SpanID
+	name      string
+	kind      SpanKind
+	start     time.Time
+	recording bool
+
+	mu         sync.Mutex
+	attributes map[string]interface{}
+	status     StatusCode
+	statusMsg  string
+	ended      bool
+}
+
+// SpanContext returns the span's propagated context
+func (s *Span) SpanContext() SpanContext { return s.sc }
+
+// IsRecording reports whether the span will be exported
+func (s *Span) IsRecording() bool { return s.recording }
+
+// SetName renames the span (e.g. once the route is known)
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.name = name
+}
+
+// SetAttribute sets an attribute (string, bool, int, int64 or float64)
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if !s.recording {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{})
+	}
+	s.attributes[key] = value
+}
+
+// SetStatus sets the span status
+func (s *Span) SetStatus(code StatusCode, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status = code
+	s.statusMsg = message
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.SetAttribute("error.type", fmt.Sprintf("%T", err))
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End finishes the span, further calls are ignored
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	if !s.recording {
+		s.mu.Unlock()
+		return
+	}
+	data := SpanData{
+		TraceID:       s.sc.TraceID,
+		SpanID:        s.sc.SpanID,
+		ParentSpanID:  s.parent,
+		TraceState:    s.sc.TraceState,
+		Name:          s.name,
+		Kind:          s.kind,
+		Start:         s.start,
+		End:           time.Now(),
+		Attributes:    s.attributes,
+		Status:        s.status,
+		StatusMessage: s.statusMsg,
+	}
+	s.mu.Unlock()
+
+	s.tracer.enqueue(data)
+}
+
+// SpanData is a finished span as handed to the exporter
+type SpanData struct {
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID // Zero for root spans
+	TraceState    string
+	Name          string
+	Kind          SpanKind
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]interface{}
+	Status        StatusCode
+	StatusMessage string
+}
+
+// ==========================================
+// DEFAULT TRACER
+// ==========================================
+
+// defaultTracer propagates context but records nothing until SetDefault/InitFromEnv
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(NewTracer("", nil, Options{}))
+}
+
+// SetDefault replaces the tracer used by Start
+func SetDefault(t *Tracer) { defaultTracer.Store(t) }
+
+// Default returns the tracer used by Start
+func Default() *Tracer { return defaultTracer.Load() }
+
+// Start creates a span with the default tracer
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return Default().Start(ctx, name, kind)
+}
+
+// InitFromEnv configures the default tracer from the standard OpenTelemetry variables:
+//   - OTEL_EXPORTER_OTLP_TRACES_ENDPOINT (full URL) or OTEL_EXPORTER_OTLP_ENDPOINT (+ "/v1/traces")
+//   - OTEL_EXPORTER_OTLP_HEADERS ("key=value,key2=value2")
+//   - OTEL_TRACES_SAMPLER_ARG (sample ratio, default 1.0)
+//
+// Without endpoint trace context is only propagated and logged
+// Register the returned tracer's Shutdown as shutdown hook
+func InitFromEnv(service string) *Tracer {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint == "" && base != "" {
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+
+	opts := Options{}
+	if ratio, err := strconv.ParseFloat(os.Getenv("OTEL_TRACES_SAMPLER_ARG"), 64); err == nil {
+		opts.SampleRatio = ratio
+		if ratio == 0 {
+			opts.SampleRatio = -1
+		}
+	}
+
+	var exporter Exporter
+	if endpoint != "" {
+		exporter = NewOTLPHTTPExporter(endpoint, service, parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")))
+	}
+
+	t := NewTracer(service, exporter, opts)
+	SetDefault(t)
+
+	logger.InfoWithFields("Tracing initialized", map[string]interface{}{
+		"endpoint":     endpoint,
+		"exporting":    exporter != nil,
+		"sample_ratio": t.opts.SampleRatio,
+	})
+	return t
+}
+
+// parseHeaders parses "key=value,key2=value2"
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(key) != "" {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+		}
+	}
+	return headers
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestStartParentsSpans(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer("test", exporter, Options{})
+	defer tracer.Shutdown(context.Background())
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, testTraceparent)
+	remote, _ := ParseTraceparent(testTraceparent)
+
+	ctx, server := tracer.Start(Extract(context.Background(), header), "server", SpanKindServer)
+	_, client := tracer.Start(ctx, "client", SpanKindClient)
+	client.SetAttribute("attempt", 1)
+	client.End()
+	server.End()
+	client.End() // Ignored
+
+	if err := tracer.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+
+	exported := map[string]SpanData{}
+	for _, span := range spans {
+		exported[span.Name] = span
+		if span.TraceID != remote.TraceID {
+			t.Errorf("span %s has trace %s, want the remote trace %s", span.Name, span.TraceID, remote.TraceID)
+		}
+	}
+	if got := exported["server"].ParentSpanID; got != remote.SpanID {
+		t.Errorf("server parent = %s, want the remote span %s", got, remote.SpanID)
+	}
+	if got := exported["client"].ParentSpanID; got != server.SpanContext().SpanID {
+		t.Errorf("client parent = %s, want the server span %s", got, server.SpanContext().SpanID)
+	}
+	if exported["client"].Attributes["attempt"] != 1 {
+		t.Errorf("client attributes = %v", exported["client"].Attributes)
+	}
+}
+
+func TestStartSampling(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer("test", exporter, Options{SampleRatio: -1})
+	defer tracer.Shutdown(context.Background())
+
+	// New traces are not sampled
+	_, root := tracer.Start(context.Background(), "root", SpanKindServer)
+	if root.IsRecording() || root.SpanContext().IsSampled() {
+		t.Fatal("root span sampled with a negative ratio")
+	}
+	root.End()
+
+	// A sampled caller is followed
+	header := http.Header{}
+	header.Set(TraceparentHeader, testTraceparent)
+	_, child := tracer.Start(Extract(context.Background(), header), "child", SpanKindServer)
+	if !child.IsRecording() {
+		t.Fatal("span of a sampled caller not recorded")
+	}
+	child.End()
+
+	tracer.ForceFlush(context.Background())
+	if spans := exporter.Spans(); len(spans) != 1 || spans[0].Name != "child" {
+		t.Fatalf("exported %v, want only the child span", spans)
+	}
+}